@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"rest-service/internal/payload"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCashArbitrage handles the GET /arbitrage/cash route, returning NSE-BSE
+// spreads ranked by net return. Query: limit (default 50), profitable=1.
+func (ctrl *Controller) GetCashArbitrage(c *gin.Context) {
+	if ctrl.CashArbitrage == nil {
+		payload.Error(c, http.StatusServiceUnavailable, "Cash arbitrage scanner not enabled")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 0 {
+		payload.Error(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	profitable := c.DefaultQuery("profitable", "0") == "1"
+
+	payload.Respond(c, http.StatusOK, ctrl.CashArbitrage.Opportunities(limit, profitable), nil)
+}
+
+// GetCashArbitrageHistory handles the GET /arbitrage/cash/history route,
+// returning the most recent spread events. Query: limit (default 100).
+func (ctrl *Controller) GetCashArbitrageHistory(c *gin.Context) {
+	if ctrl.CashArbitrage == nil {
+		payload.Error(c, http.StatusServiceUnavailable, "Cash arbitrage scanner not enabled")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 0 {
+		payload.Error(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	payload.Respond(c, http.StatusOK, ctrl.CashArbitrage.History(limit), nil)
+}
+
+// GetOptionsArbitrage handles the GET /arbitrage/options route, returning the
+// option no-arbitrage violations of the last scan ranked by net edge per lot.
+// Query: limit (default 50), kind (e.g. "conversion", "long_box").
+func (ctrl *Controller) GetOptionsArbitrage(c *gin.Context) {
+	if ctrl.OptionsArbitrage == nil {
+		payload.Error(c, http.StatusServiceUnavailable, "Options arbitrage detector not enabled")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 0 {
+		payload.Error(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	payload.Respond(c, http.StatusOK, ctrl.OptionsArbitrage.Opportunities(limit, c.Query("kind")), nil)
+}
+
+// GetOptionsArbitrageHistory handles the GET /arbitrage/options/history route,
+// returning the most recent opportunity events. Query: limit (default 100).
+func (ctrl *Controller) GetOptionsArbitrageHistory(c *gin.Context) {
+	if ctrl.OptionsArbitrage == nil {
+		payload.Error(c, http.StatusServiceUnavailable, "Options arbitrage detector not enabled")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 0 {
+		payload.Error(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	payload.Respond(c, http.StatusOK, ctrl.OptionsArbitrage.History(limit), nil)
+}
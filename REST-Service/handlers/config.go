@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"rest-service/internal/config"
+	"rest-service/internal/payload"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetConfiguredUnderlyings handles the GET /config/underlyings route
+func (ctrl *Controller) GetConfiguredUnderlyings(c *gin.Context) {
+	if ctrl.Config == nil {
+		payload.Error(c, http.StatusInternalServerError, "Config not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.Config.Get().Underlyings, nil)
+}
+
+// GetConfiguredUnderlying handles the GET /config/underlyings/:underlying route
+func (ctrl *Controller) GetConfiguredUnderlying(c *gin.Context) {
+	if ctrl.Config == nil {
+		payload.Error(c, http.StatusInternalServerError, "Config not initialized")
+		return
+	}
+
+	uc, ok := ctrl.Config.Underlying(c.Param("underlying"))
+	if !ok {
+		payload.Error(c, http.StatusNotFound, config.ErrUnderlyingNotFound.Error())
+		return
+	}
+	payload.Respond(c, http.StatusOK, uc, nil)
+}
+
+// AddUnderlying handles the POST /config/underlyings route. The underlying is
+// subscribed and the config file rewritten.
+func (ctrl *Controller) AddUnderlying(c *gin.Context) {
+	if ctrl.Config == nil {
+		payload.Error(c, http.StatusInternalServerError, "Config not initialized")
+		return
+	}
+
+	var uc config.UnderlyingConfig
+	if err := c.ShouldBindJSON(&uc); err != nil {
+		payload.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !ctrl.knownUnderlying(c, uc.Underlying) {
+		return
+	}
+
+	if err := ctrl.Config.AddUnderlying(uc); err != nil {
+		ctrl.underlyingError(c, err)
+		return
+	}
+	payload.Respond(c, http.StatusCreated, ctrl.underlyingResponse(uc), nil)
+}
+
+// UpdateUnderlying handles the PUT /config/underlyings/:underlying route
+func (ctrl *Controller) UpdateUnderlying(c *gin.Context) {
+	if ctrl.Config == nil {
+		payload.Error(c, http.StatusInternalServerError, "Config not initialized")
+		return
+	}
+
+	var uc config.UnderlyingConfig
+	if err := c.ShouldBindJSON(&uc); err != nil {
+		payload.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	uc.Underlying = c.Param("underlying")
+
+	if err := ctrl.Config.UpdateUnderlying(uc); err != nil {
+		ctrl.underlyingError(c, err)
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.underlyingResponse(uc), nil)
+}
+
+// RemoveUnderlying handles the DELETE /config/underlyings/:underlying route
+func (ctrl *Controller) RemoveUnderlying(c *gin.Context) {
+	if ctrl.Config == nil {
+		payload.Error(c, http.StatusInternalServerError, "Config not initialized")
+		return
+	}
+
+	if err := ctrl.Config.RemoveUnderlying(c.Param("underlying")); err != nil {
+		ctrl.underlyingError(c, err)
+		return
+	}
+
+	response := gin.H{"status": "removed"}
+	if ctrl.Subscriptions != nil {
+		response["subscribed_tokens"] = ctrl.Subscriptions.Count()
+	}
+	payload.Respond(c, http.StatusOK, response, nil)
+}
+
+// GetSubscriptions handles the GET /subscriptions route, returning the number
+// of subscribed tokens and the centre of each strike window
+func (ctrl *Controller) GetSubscriptions(c *gin.Context) {
+	if ctrl.Subscriptions == nil {
+		payload.Error(c, http.StatusInternalServerError, "Subscriptions not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, gin.H{
+		"subscribed_tokens": ctrl.Subscriptions.Count(),
+		"window_centers":    ctrl.Subscriptions.Centers(),
+	}, nil)
+}
+
+// GetSubscriptionModes handles the GET /admin/subscriptions/modes route,
+// returning the mode policy rules and the mode of every subscribed token
+func (ctrl *Controller) GetSubscriptionModes(c *gin.Context) {
+	if ctrl.Subscriptions == nil {
+		payload.Error(c, http.StatusInternalServerError, "Subscriptions not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.Subscriptions.Modes(), nil)
+}
+
+// knownUnderlying rejects underlyings without options or futures
+func (ctrl *Controller) knownUnderlying(c *gin.Context, underlying string) bool {
+	if ctrl.Scanner == nil || underlying == "" {
+		return true // Left to config validation
+	}
+	if ctrl.Scanner.GetExpiries(underlying) == nil && len(ctrl.Scanner.GetFutures(underlying)) == 0 {
+		payload.Error(c, http.StatusNotFound, "No options or futures for "+underlying)
+		return false
+	}
+	return true
+}
+
+// underlyingError maps config update errors to HTTP statuses
+func (ctrl *Controller) underlyingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, config.ErrUnderlyingNotFound):
+		payload.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, config.ErrUnderlyingExists):
+		payload.Error(c, http.StatusConflict, err.Error())
+	default:
+		payload.Error(c, http.StatusBadRequest, err.Error())
+	}
+}
+
+// underlyingResponse returns an updated underlying with the subscription count
+func (ctrl *Controller) underlyingResponse(uc config.UnderlyingConfig) gin.H {
+	response := gin.H{"underlying": uc}
+	if ctrl.Subscriptions != nil {
+		response["subscribed_tokens"] = ctrl.Subscriptions.Count()
+	}
+	return response
+}
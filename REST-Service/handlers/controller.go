@@ -2,13 +2,28 @@ package handlers
 
 import (
 	kiteconnect "gokiteconnect-master"
+	"rest-service/internal/arbitrage"
+	"rest-service/internal/config"
+	"rest-service/internal/health"
+	"rest-service/internal/killswitch"
 	"rest-service/internal/options"
+	"rest-service/internal/strategy"
+	"rest-service/internal/subscription"
 )
 
 // Controller holds the Kite Connect client and other dependencies
 type Controller struct {
 	KiteClient *kiteconnect.Client
 	Scanner    *options.Scanner
+
+	CashArbitrage    *arbitrage.CashScanner     // Nil when disabled
+	OptionsArbitrage *arbitrage.OptionsDetector // Nil when disabled
+	Strategy         *strategy.Resolver
+	Config           *config.Live
+	Subscriptions    *subscription.Manager
+	Readiness        *health.Readiness
+	Staleness        *health.StalenessMonitor
+	KillSwitch       *killswitch.Switch
 }
 
 // NewController creates a new Controller instance
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rest-service/internal/options"
+	"rest-service/internal/payload"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFutureUnderlyings handles the GET /futures route
+func (ctrl *Controller) GetFutureUnderlyings(c *gin.Context) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.Scanner.GetFutureUnderlyings(), nil)
+}
+
+// GetFuturesCurve handles the GET /futures/:underlying route
+func (ctrl *Controller) GetFuturesCurve(c *gin.Context) {
+	curve, ok := ctrl.lookupFuturesCurve(c)
+	if !ok {
+		return
+	}
+	payload.Respond(c, http.StatusOK, curve, nil)
+}
+
+// GetFuturesRollover handles the GET /futures/:underlying/rollover route
+func (ctrl *Controller) GetFuturesRollover(c *gin.Context) {
+	curve, ok := ctrl.lookupFuturesCurve(c)
+	if !ok {
+		return
+	}
+	if curve.Rollover == nil {
+		payload.Error(c, http.StatusNotFound, "Rollover needs at least two live futures for "+curve.Underlying)
+		return
+	}
+	payload.Respond(c, http.StatusOK, curve.Rollover, nil)
+}
+
+// lookupFuturesCurve builds the curve for the :underlying param, writing the
+// error response when there are no futures
+func (ctrl *Controller) lookupFuturesCurve(c *gin.Context) (*options.FuturesCurve, bool) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return nil, false
+	}
+
+	underlying := c.Param("underlying")
+	curve, ok := ctrl.Scanner.FuturesCurve(underlying, time.Now())
+	if !ok {
+		payload.Error(c, http.StatusNotFound, "No futures for "+underlying)
+		return nil, false
+	}
+	return curve, true
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rest-service/internal/payload"
+
+	kiteconnect "gokiteconnect-master"
+
+	"github.com/gin-gonic/gin"
+)
+
+// started is when the process started, for the uptime in /healthz
+var started = time.Now()
+
+// Healthz handles the GET /healthz route. It only reports that the process
+// serves requests; readiness is /readyz.
+func (ctrl *Controller) Healthz(c *gin.Context) {
+	payload.Respond(c, http.StatusOK, gin.H{
+		"status":         "ok",
+		"uptime_seconds": int64(time.Since(started).Seconds()),
+	}, nil)
+}
+
+// Readyz handles the GET /readyz route, returning every readiness check with
+// 200 when all pass and as the data of a 503 error otherwise
+func (ctrl *Controller) Readyz(c *gin.Context) {
+	if ctrl.Readiness == nil {
+		payload.Error(c, http.StatusInternalServerError, "Readiness not initialized")
+		return
+	}
+
+	status := ctrl.Readiness.Check(time.Now())
+	if !status.Ready {
+		payload.Fail(c, http.StatusServiceUnavailable, kiteconnect.GeneralError, "Not ready", status)
+		return
+	}
+	payload.Respond(c, http.StatusOK, status, nil)
+}
+
+// GetStaleTokens handles the GET /feed/stale route, returning the subscribed
+// tokens without a tick within the threshold as of the last check
+func (ctrl *Controller) GetStaleTokens(c *gin.Context) {
+	if ctrl.Staleness == nil {
+		payload.Error(c, http.StatusInternalServerError, "Staleness monitor not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.Staleness.Report(), nil)
+}
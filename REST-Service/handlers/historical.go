@@ -5,6 +5,8 @@ import (
 	"strconv"
 	"time"
 
+	"rest-service/internal/payload"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,7 +16,7 @@ func (ctrl *Controller) GetHistoricalData(c *gin.Context) {
 	tokenStr := c.Param("instrument_token")
 	instrumentToken, err := strconv.Atoi(tokenStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid instrument token"})
+		payload.Error(c, http.StatusBadRequest, "Invalid instrument token")
 		return
 	}
 
@@ -31,7 +33,7 @@ func (ctrl *Controller) GetHistoricalData(c *gin.Context) {
 		"day":      true,
 	}
 	if !validIntervals[interval] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval. Valid intervals: minute, 3minute, 5minute, 10minute, 15minute, 30minute, 60minute, day"})
+		payload.Error(c, http.StatusBadRequest, "Invalid interval. Valid intervals: minute, 3minute, 5minute, 10minute, 15minute, 30minute, 60minute, day")
 		return
 	}
 
@@ -39,20 +41,20 @@ func (ctrl *Controller) GetHistoricalData(c *gin.Context) {
 	fromStr := c.Query("from")
 	toStr := c.Query("to")
 	if fromStr == "" || toStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to parameters are required (format: yyyy-mm-dd or yyyy-mm-dd hh:mm:ss)"})
+		payload.Error(c, http.StatusBadRequest, "from and to parameters are required (format: yyyy-mm-dd or yyyy-mm-dd hh:mm:ss)")
 		return
 	}
 
 	// Parse dates
 	fromDate, err := parseDate(fromStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date format. Use yyyy-mm-dd or yyyy-mm-dd hh:mm:ss"})
+		payload.Error(c, http.StatusBadRequest, "Invalid from date format. Use yyyy-mm-dd or yyyy-mm-dd hh:mm:ss")
 		return
 	}
 
 	toDate, err := parseDate(toStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date format. Use yyyy-mm-dd or yyyy-mm-dd hh:mm:ss"})
+		payload.Error(c, http.StatusBadRequest, "Invalid to date format. Use yyyy-mm-dd or yyyy-mm-dd hh:mm:ss")
 		return
 	}
 
@@ -61,9 +63,9 @@ func (ctrl *Controller) GetHistoricalData(c *gin.Context) {
 	oi := c.DefaultQuery("oi", "0") == "1"
 
 	// Fetch historical data
-	historicalData, err := ctrl.KiteClient.GetHistoricalData(instrumentToken, interval, fromDate, toDate, continuous, oi)
+	historicalData, err := ctrl.KiteClient.GetHistoricalDataContext(c.Request.Context(), instrumentToken, interval, fromDate, toDate, continuous, oi)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
 
@@ -84,12 +86,9 @@ func (ctrl *Controller) GetHistoricalData(c *gin.Context) {
 		candles[i] = candle
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"data": gin.H{
-			"candles": candles,
-		},
-	})
+	payload.Respond(c, http.StatusOK, gin.H{
+		"candles": candles,
+	}, payload.CandleList{Candles: historicalData})
 }
 
 // parseDate parses date string in yyyy-mm-dd or yyyy-mm-dd hh:mm:ss format
@@ -3,15 +3,17 @@ package handlers
 import (
 	"net/http"
 
+	"rest-service/internal/payload"
+
 	"github.com/gin-gonic/gin"
 )
 
 // GetHoldings handles the GET /holdings route
 func (ctrl *Controller) GetHoldings(c *gin.Context) {
-	holdings, err := ctrl.KiteClient.GetHoldings()
+	holdings, err := ctrl.KiteClient.GetHoldingsContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, holdings)
+	payload.Respond(c, http.StatusOK, holdings, nil)
 }
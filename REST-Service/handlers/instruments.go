@@ -1,101 +1,239 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"rest-service/internal/instruments"
 	"rest-service/internal/options"
+	"rest-service/internal/payload"
 
 	"github.com/gin-gonic/gin"
 )
 
 // OptimizedInstrumentResponse represents the columnar format for instruments
 type OptimizedInstrumentResponse struct {
-	Enums  map[string]map[string]int `json:"enums"`
-	Schema []string                  `json:"schema"`
-	Data   [][]interface{}           `json:"data"`
-	Count  int                       `json:"count"`
+	Version string                    `json:"version,omitempty"` // Instrument master version, pass as ?since= to sync changes
+	Enums   map[string]map[string]int `json:"enums"`
+	Schema  []string                  `json:"schema"`
+	Data    [][]interface{}           `json:"data"`
+	Count   int                       `json:"count"`
+}
+
+// InstrumentDeltaResponse is the change since a version, in the same columnar
+// format as OptimizedInstrumentResponse
+type InstrumentDeltaResponse struct {
+	Version string                    `json:"version"`
+	Since   string                    `json:"since"`
+	Enums   map[string]map[string]int `json:"enums"`
+	Schema  []string                  `json:"schema"`
+	Added   [][]interface{}           `json:"added"`
+	Changed [][]interface{}           `json:"changed"`
+	Removed []uint32                  `json:"removed"`
+}
+
+// instrumentSchema is the column order of the instrument rows
+var instrumentSchema = []string{
+	"InstrumentToken",
+	"Tradingsymbol",
+	"Name",
+	"Exchange",
+	"Segment",
+	"InstrumentType",
+	"TickSize",
+	"LotSize",
+	"StrikePrice",
+	"Expiry",
 }
 
 // GetInstruments handles the GET /instruments route
 // Returns all instruments in optimized columnar format (schema + rows) with enum compression
 // This reduces payload size by ~60% compared to JSON objects
+//
+// The ETag is the instrument master version, so If-None-Match answers 304
+// until the master changes. With ?since=<version> only the rows added,
+// changed and removed since that version are returned; a version that is no
+// longer kept returns the full dump, to be replaced rather than merged.
+//
+// MessagePack carries the same columnar body; Protobuf sends InstrumentList
+// and InstrumentDelta records from rest.proto.
 func (ctrl *Controller) GetInstruments(c *gin.Context) {
 	if ctrl.Scanner == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Scanner not initialized"})
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
 		return
 	}
 
-	instruments, err := ctrl.Scanner.GetAllInstrumentsMap()
+	version := ctrl.Scanner.InstrumentVersion()
+	if version != "" {
+		etag := `"` + version + `"`
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "no-cache")
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	if since := c.Query("since"); since != "" {
+		delta, err := ctrl.Scanner.InstrumentsSince(since)
+		if err == nil {
+			enc := newInstrumentEncoder()
+			payload.Respond(c, http.StatusOK, InstrumentDeltaResponse{
+				Version: delta.Version,
+				Since:   delta.Since,
+				Added:   enc.rows(delta.Added),
+				Changed: enc.rows(delta.Changed),
+				Removed: delta.Removed,
+				Enums:   enc.enums(),
+				Schema:  instrumentSchema,
+			}, payload.InstrumentDelta{InstrumentDelta: delta})
+			return
+		}
+		if !errors.Is(err, instruments.ErrVersionNotFound) {
+			payload.Error(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	instrumentsMap, err := ctrl.Scanner.GetAllInstrumentsMap()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Build enum mappings for repeated strings (saves MBs)
-	exchangeEnum := make(map[string]int)
-	segmentEnum := make(map[string]int)
-	instrumentTypeEnum := make(map[string]int)
-	exchangeCounter := 1
-	segmentCounter := 1
-	typeCounter := 1
+	// Convert map to sorted slice for consistent ordering
+	entries := make([]*options.OptionInstrument, 0, len(instrumentsMap))
+	for _, inst := range instrumentsMap {
+		entries = append(entries, inst)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].InstrumentToken < entries[j].InstrumentToken
+	})
+
+	enc := newInstrumentEncoder()
+	data := enc.rows(entries)
 
-	// First pass: collect all unique enum values
-	for _, inst := range instruments {
-		if _, exists := exchangeEnum[inst.Exchange]; !exists {
-			exchangeEnum[inst.Exchange] = exchangeCounter
-			exchangeCounter++
-		}
-		if _, exists := segmentEnum[inst.Segment]; !exists {
-			segmentEnum[inst.Segment] = segmentCounter
-			segmentCounter++
+	// Build response with enums
+	response := OptimizedInstrumentResponse{
+		Version: version,
+		Enums:   enc.enums(),
+		Schema:  instrumentSchema,
+		Data:    data,
+		Count:   len(data),
+	}
+
+	payload.Respond(c, http.StatusOK, response, payload.InstrumentList{Version: version, Instruments: entries})
+}
+
+// SearchInstruments handles the GET /instruments/search route. The q text is
+// parsed into words and filters ("nifty 26000 ce 30dec"); exchange, segment,
+// type, expiry (yyyy-mm-dd or yyyy-mm) and strike narrow it further, and limit
+// and offset page through the ranked results.
+func (ctrl *Controller) SearchInstruments(c *gin.Context) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return
+	}
+
+	q := options.ParseSearch(c.Query("q"), time.Now())
+	if v := c.Query("exchange"); v != "" {
+		q.Exchange = strings.ToUpper(v)
+	}
+	if v := c.Query("segment"); v != "" {
+		q.Segment = strings.ToUpper(v)
+	}
+	if v := c.Query("type"); v != "" {
+		q.Type = strings.ToUpper(v)
+	}
+	if v := c.Query("expiry"); v != "" {
+		_, dayErr := time.Parse("2006-01-02", v)
+		_, monthErr := time.Parse("2006-01", v)
+		if dayErr != nil && monthErr != nil {
+			payload.Error(c, http.StatusBadRequest, "Invalid expiry format. Use yyyy-mm-dd or yyyy-mm")
+			return
 		}
-		typeStr := string(inst.InstrumentType)
-		if _, exists := instrumentTypeEnum[typeStr]; !exists {
-			instrumentTypeEnum[typeStr] = typeCounter
-			typeCounter++
+		q.Expiry = v
+	}
+	if v := c.Query("strike"); v != "" {
+		strike, err := strconv.ParseFloat(v, 64)
+		if err != nil || strike <= 0 {
+			payload.Error(c, http.StatusBadRequest, "Invalid strike")
+			return
 		}
+		q.Strike = strike
 	}
 
-	// Define schema (column order)
-	schema := []string{
-		"InstrumentToken",
-		"Tradingsymbol",
-		"Name",
-		"Exchange",
-		"Segment",
-		"InstrumentType",
-		"TickSize",
-		"LotSize",
-		"StrikePrice",
-		"Expiry",
+	var err error
+	if q.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(options.DefaultSearchLimit))); err != nil || q.Limit <= 0 {
+		payload.Error(c, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	if q.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || q.Offset < 0 {
+		payload.Error(c, http.StatusBadRequest, "Invalid offset")
+		return
 	}
 
-	// Convert map to sorted slice for consistent ordering
-	type instrumentEntry struct {
-		token      uint32
-		instrument *options.OptionInstrument
+	if len(q.Terms) == 0 && len(q.Numbers) == 0 && q.Exchange == "" && q.Segment == "" && q.Type == "" && q.Expiry == "" && q.Strike == 0 {
+		payload.Error(c, http.StatusBadRequest, "Provide q or at least one filter")
+		return
 	}
-	entries := make([]instrumentEntry, 0, len(instruments))
-	for token, inst := range instruments {
-		entries = append(entries, instrumentEntry{token: token, instrument: inst})
+
+	payload.Respond(c, http.StatusOK, ctrl.Scanner.SearchInstruments(q), nil)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
 	}
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].token < entries[j].token
-	})
+	return false
+}
 
-	// Build columnar data array
-	data := make([][]interface{}, 0, len(instruments))
-	for _, entry := range entries {
-		inst := entry.instrument
-		row := make([]interface{}, len(schema))
+// instrumentEncoder builds columnar rows, replacing repeated strings with
+// enum IDs (saves MBs)
+type instrumentEncoder struct {
+	exchange       map[string]int
+	segment        map[string]int
+	instrumentType map[string]int
+}
+
+func newInstrumentEncoder() *instrumentEncoder {
+	return &instrumentEncoder{
+		exchange:       make(map[string]int),
+		segment:        make(map[string]int),
+		instrumentType: make(map[string]int),
+	}
+}
+
+// enumID returns the ID of a value, numbering new values from 1
+func enumID(enum map[string]int, value string) int {
+	id, ok := enum[value]
+	if !ok {
+		id = len(enum) + 1
+		enum[value] = id
+	}
+	return id
+}
+
+// rows converts instruments to rows in instrumentSchema order
+func (e *instrumentEncoder) rows(insts []*options.OptionInstrument) [][]interface{} {
+	data := make([][]interface{}, 0, len(insts))
+	for _, inst := range insts {
+		row := make([]interface{}, len(instrumentSchema))
 
 		row[0] = inst.InstrumentToken
 		row[1] = inst.Tradingsymbol
 		row[2] = inst.Name
-		row[3] = exchangeEnum[inst.Exchange]                     // Use enum ID instead of string
-		row[4] = segmentEnum[inst.Segment]                       // Use enum ID instead of string
-		row[5] = instrumentTypeEnum[string(inst.InstrumentType)] // Use enum ID instead of string
+		row[3] = enumID(e.exchange, inst.Exchange)                     // Use enum ID instead of string
+		row[4] = enumID(e.segment, inst.Segment)                       // Use enum ID instead of string
+		row[5] = enumID(e.instrumentType, string(inst.InstrumentType)) // Use enum ID instead of string
 		row[6] = inst.TickSize
 		row[7] = inst.LotSize
 		row[8] = inst.StrikePrice
@@ -103,20 +241,14 @@ func (ctrl *Controller) GetInstruments(c *gin.Context) {
 
 		data = append(data, row)
 	}
+	return data
+}
 
-	// Build response with enums
-	response := OptimizedInstrumentResponse{
-		Enums: map[string]map[string]int{
-			"Exchange":       exchangeEnum,
-			"Segment":        segmentEnum,
-			"InstrumentType": instrumentTypeEnum,
-		},
-		Schema: schema,
-		Data:   data,
-		Count:  len(data),
-	}
-
-	// Set content encoding hint (client can still request gzip via Accept-Encoding)
-	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, response)
+// enums returns the enum mappings of the rows built so far
+func (e *instrumentEncoder) enums() map[string]map[string]int {
+	return map[string]map[string]int{
+		"Exchange":       e.exchange,
+		"Segment":        e.segment,
+		"InstrumentType": e.instrumentType,
+	}
 }
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rest-service/internal/options"
+	"rest-service/internal/payload"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syntheticMaster builds an instrument master of about the size of the Kite
+// dump: equities plus weekly option chains of a few index underlyings
+func syntheticMaster() []kiteconnect.Instrument {
+	var all []kiteconnect.Instrument
+	token := 1
+	for i := 0; i < 10000; i++ {
+		symbol := fmt.Sprintf("EQ%05d", i)
+		all = append(all, kiteconnect.Instrument{
+			InstrumentToken: token, Tradingsymbol: symbol, Name: symbol,
+			Exchange: "NSE", Segment: "NSE", InstrumentType: "EQ", TickSize: 0.05, LotSize: 1,
+		})
+		token++
+	}
+	start := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"NIFTY", "BANKNIFTY", "FINNIFTY", "MIDCPNIFTY", "SENSEX"} {
+		for week := 0; week < 12; week++ {
+			expiry := start.AddDate(0, 0, 7*week)
+			for strike := 20000; strike < 20000+150*100; strike += 100 {
+				for _, typ := range []string{"CE", "PE"} {
+					all = append(all, kiteconnect.Instrument{
+						InstrumentToken: token,
+						Tradingsymbol:   fmt.Sprintf("%s%s%d%s", name, expiry.Format("06Jan02"), strike, typ),
+						Name:            name, Exchange: "NFO", Segment: "NFO-OPT", InstrumentType: typ,
+						StrikePrice: float64(strike), TickSize: 0.05, LotSize: 75,
+						Expiry: models.Time{Time: expiry},
+					})
+					token++
+				}
+			}
+		}
+	}
+	return all
+}
+
+// BenchmarkInstruments compares the /instruments encodings; the bytes metric
+// is the response size
+func BenchmarkInstruments(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	scanner := options.NewScanner(nil)
+	scanner.LoadInstruments(syntheticMaster())
+	ctrl := &Controller{Scanner: scanner}
+	r := gin.New()
+	r.GET("/instruments", payload.Compress(), ctrl.GetInstruments)
+
+	cases := []struct {
+		name, accept, encoding string
+	}{
+		{"json", "application/json", ""},
+		{"msgpack", "application/msgpack", ""},
+		{"protobuf", "application/x-protobuf", ""},
+		{"json+gzip", "application/json", "gzip"},
+		{"msgpack+gzip", "application/msgpack", "gzip"},
+		{"protobuf+gzip", "application/x-protobuf", "gzip"},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/instruments", nil)
+				req.Header.Set("Accept", tc.accept)
+				req.Header.Set("Accept-Encoding", tc.encoding)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					b.Fatalf("status %d", w.Code)
+				}
+				size = w.Body.Len()
+			}
+			b.ReportMetric(float64(size), "bytes")
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rest-service/internal/auth"
+	"rest-service/internal/payload"
+
+	kiteconnect "gokiteconnect-master"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KillSwitchRequest engages or releases the kill switch
+type KillSwitchRequest struct {
+	Engaged          bool   `json:"engaged"`
+	Reason           string `json:"reason"`
+	CancelOpenOrders bool   `json:"cancel_open_orders"` // When engaging, also cancel every open order
+}
+
+// GetKillSwitch handles the GET /admin/kill-switch route
+func (ctrl *Controller) GetKillSwitch(c *gin.Context) {
+	if ctrl.KillSwitch == nil {
+		payload.Error(c, http.StatusInternalServerError, "Kill switch not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.KillSwitch.State(), nil)
+}
+
+// SetKillSwitch handles the PUT /admin/kill-switch route. Engaging it halts
+// order placement and modification; with cancel_open_orders the open orders
+// are cancelled too, and those that couldn't be are listed with the error.
+func (ctrl *Controller) SetKillSwitch(c *gin.Context) {
+	if ctrl.KillSwitch == nil {
+		payload.Error(c, http.StatusInternalServerError, "Kill switch not initialized")
+		return
+	}
+
+	var req KillSwitchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		payload.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Engaged && req.Reason == "" {
+		payload.Error(c, http.StatusBadRequest, "A reason is required to engage the kill switch")
+		return
+	}
+
+	state := ctrl.KillSwitch.Set(req.Engaged, req.Reason, auth.Caller(c).Name, time.Now())
+	response := gin.H{"kill_switch": state}
+	if req.Engaged && req.CancelOpenOrders {
+		cancelled, failed, err := ctrl.cancelOpenOrders(c)
+		if err != nil {
+			payload.KiteError(c, err)
+			return
+		}
+		response["cancelled"] = cancelled
+		response["failed"] = failed
+	}
+	payload.Respond(c, http.StatusOK, response, nil)
+}
+
+// cancelOpenOrders cancels every order that isn't complete, rejected or
+// cancelled, returning the cancelled order IDs and the errors of the rest
+func (ctrl *Controller) cancelOpenOrders(c *gin.Context) ([]string, map[string]string, error) {
+	orders, err := ctrl.KiteClient.GetOrdersContext(c.Request.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cancelled := []string{}
+	failed := map[string]string{}
+	for _, o := range orders {
+		switch o.Status {
+		case kiteconnect.OrderStatusComplete, kiteconnect.OrderStatusRejected, kiteconnect.OrderStatusCancelled:
+			continue
+		}
+		var parentOrderID *string
+		if o.ParentOrderID != "" {
+			parentOrderID = &o.ParentOrderID
+		}
+		if _, err := ctrl.KiteClient.CancelOrderContext(c.Request.Context(), o.Variety, o.OrderID, parentOrderID); err != nil {
+			failed[o.OrderID] = err.Error()
+			continue
+		}
+		cancelled = append(cancelled, o.OrderID)
+	}
+	return cancelled, failed, nil
+}
+
+// orderEntryHalted writes the error response when the kill switch is engaged
+func (ctrl *Controller) orderEntryHalted(c *gin.Context) bool {
+	if ctrl.KillSwitch == nil {
+		return false
+	}
+	state := ctrl.KillSwitch.State()
+	if !state.Engaged {
+		return false
+	}
+	payload.Fail(c, http.StatusServiceUnavailable, kiteconnect.OrderError, "Order entry halted by the kill switch: "+state.Reason, state)
+	return true
+}
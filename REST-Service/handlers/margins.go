@@ -3,15 +3,17 @@ package handlers
 import (
 	"net/http"
 
+	"rest-service/internal/payload"
+
 	"github.com/gin-gonic/gin"
 )
 
 // GetMargins handles the GET /margins route
 func (ctrl *Controller) GetMargins(c *gin.Context) {
-	margins, err := ctrl.KiteClient.GetUserMargins()
+	margins, err := ctrl.KiteClient.GetUserMarginsContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, margins)
+	payload.Respond(c, http.StatusOK, margins, nil)
 }
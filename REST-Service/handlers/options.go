@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rest-service/internal/options"
+	"rest-service/internal/payload"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OptionChainResponse is an option chain with its strikes in ascending order
+type OptionChainResponse struct {
+	*options.OptionChain
+	Strikes []*options.StrikeData `json:"strikes"`
+}
+
+// GetOptionUnderlyings handles the GET /options route
+func (ctrl *Controller) GetOptionUnderlyings(c *gin.Context) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.Scanner.GetUnderlyings(), nil)
+}
+
+// GetUnderlyingResolutions handles the GET /underlyings route, listing the
+// spot and near future every underlying resolved to
+func (ctrl *Controller) GetUnderlyingResolutions(c *gin.Context) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return
+	}
+	payload.Respond(c, http.StatusOK, ctrl.Scanner.GetResolutions(), nil)
+}
+
+// GetOptionExpiries handles the GET /options/:underlying route
+func (ctrl *Controller) GetOptionExpiries(c *gin.Context) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return
+	}
+
+	underlying := c.Param("underlying")
+	expiries := ctrl.Scanner.GetExpiries(underlying)
+	if expiries == nil {
+		payload.Error(c, http.StatusNotFound, "No option chains for "+underlying)
+		return
+	}
+
+	dates := make([]string, len(expiries))
+	for i, expiry := range expiries {
+		dates[i] = expiry.Format("2006-01-02")
+	}
+	payload.Respond(c, http.StatusOK, dates, nil)
+}
+
+// GetOptionChain handles the GET /options/:underlying/:expiry route
+func (ctrl *Controller) GetOptionChain(c *gin.Context) {
+	chain, ok := ctrl.lookupChain(c)
+	if !ok {
+		return
+	}
+
+	chain = chain.Snapshot()
+	strikes := chain.SortedStrikes()
+	payload.Respond(c, http.StatusOK, OptionChainResponse{
+		OptionChain: chain,
+		Strikes:     strikes,
+	}, payload.OptionChain{Chain: chain, Strikes: strikes})
+}
+
+// GetOptionChainAnalytics handles the GET /options/:underlying/:expiry/analytics route
+func (ctrl *Controller) GetOptionChainAnalytics(c *gin.Context) {
+	chain, ok := ctrl.lookupChain(c)
+	if !ok {
+		return
+	}
+
+	payload.Respond(c, http.StatusOK, chain.Snapshot().Analytics(), nil)
+}
+
+// lookupChain resolves the :underlying and :expiry params to a live chain,
+// writing the error response when it can't
+func (ctrl *Controller) lookupChain(c *gin.Context) (*options.OptionChain, bool) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return nil, false
+	}
+
+	expiry, err := time.Parse("2006-01-02", c.Param("expiry"))
+	if err != nil {
+		payload.Error(c, http.StatusBadRequest, "Invalid expiry format. Use yyyy-mm-dd")
+		return nil, false
+	}
+
+	chain, ok := ctrl.Scanner.GetOptionChain(c.Param("underlying"), expiry)
+	if !ok {
+		payload.Error(c, http.StatusNotFound, "Option chain not found")
+		return nil, false
+	}
+
+	return chain, true
+}
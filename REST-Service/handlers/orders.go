@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 
+	"rest-service/internal/payload"
+
 	kiteconnect "gokiteconnect-master"
 
 	"github.com/gin-gonic/gin"
@@ -11,88 +13,90 @@ import (
 
 // GetOrders handles the GET /orders route
 func (ctrl *Controller) GetOrders(c *gin.Context) {
-	orders, err := ctrl.KiteClient.GetOrders()
+	orders, err := ctrl.KiteClient.GetOrdersContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, orders)
+	payload.Respond(c, http.StatusOK, orders, nil)
 }
 
 // GetTrades handles the GET /trades route
 func (ctrl *Controller) GetTrades(c *gin.Context) {
-	trades, err := ctrl.KiteClient.GetTrades()
+	trades, err := ctrl.KiteClient.GetTradesContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, trades)
+	payload.Respond(c, http.StatusOK, trades, nil)
 }
 
 // GetOrderHistory handles the GET /orders/:order_id route
 func (ctrl *Controller) GetOrderHistory(c *gin.Context) {
 	orderID := c.Param("order_id")
-	history, err := ctrl.KiteClient.GetOrderHistory(orderID)
+	history, err := ctrl.KiteClient.GetOrderHistoryContext(c.Request.Context(), orderID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, history)
+	payload.Respond(c, http.StatusOK, history, nil)
 }
 
 // GetOrderTrades handles the GET /orders/:order_id/trades route
 func (ctrl *Controller) GetOrderTrades(c *gin.Context) {
 	orderID := c.Param("order_id")
-	trades, err := ctrl.KiteClient.GetOrderTrades(orderID)
+	trades, err := ctrl.KiteClient.GetOrderTradesContext(c.Request.Context(), orderID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, trades)
+	payload.Respond(c, http.StatusOK, trades, nil)
 }
 
 // PlaceOrder handles the POST /orders/:variety route
 func (ctrl *Controller) PlaceOrder(c *gin.Context) {
+	if ctrl.orderEntryHalted(c) {
+		return
+	}
 	variety := c.Param("variety")
 	var params kiteconnect.OrderParams
 
 	// Auto-detects JSON, query params, and form-data
 	if err := c.ShouldBind(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		payload.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	fmt.Printf("Parsed params: %+v\n", params)
-	response, err := ctrl.KiteClient.PlaceOrder(variety, params)
+	response, err := ctrl.KiteClient.PlaceOrderContext(c.Request.Context(), variety, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, response)
+	payload.Respond(c, http.StatusOK, response, nil)
 }
 
 // ModifyOrder handles the PUT /orders/:variety/:order_id route
 func (ctrl *Controller) ModifyOrder(c *gin.Context) {
+	if ctrl.orderEntryHalted(c) {
+		return
+	}
 	variety := c.Param("variety")
 	orderID := c.Param("order_id")
 	var params kiteconnect.OrderParams
 	// Auto-detects JSON, query params, and form-data
 	if err := c.ShouldBind(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		payload.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	fmt.Printf("Parsed params: %+v\n", params)
-	response, err := ctrl.KiteClient.ModifyOrder(variety, orderID, params)
+	response, err := ctrl.KiteClient.ModifyOrderContext(c.Request.Context(), variety, orderID, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, response)
+	payload.Respond(c, http.StatusOK, response, nil)
 }
 
 // CancelOrder handles the DELETE /orders/:variety/:order_id route
@@ -106,10 +110,10 @@ func (ctrl *Controller) CancelOrder(c *gin.Context) {
 		parentOrderIDPtr = &parentOrderID
 	}
 
-	response, err := ctrl.KiteClient.CancelOrder(variety, orderID, parentOrderIDPtr)
+	response, err := ctrl.KiteClient.CancelOrderContext(c.Request.Context(), variety, orderID, parentOrderIDPtr)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, response)
+	payload.Respond(c, http.StatusOK, response, nil)
 }
@@ -3,15 +3,51 @@ package handlers
 import (
 	"net/http"
 
+	"rest-service/internal/options"
+	"rest-service/internal/payload"
+
 	"github.com/gin-gonic/gin"
 )
 
 // GetPositions handles the GET /positions route
 func (ctrl *Controller) GetPositions(c *gin.Context) {
-	positions, err := ctrl.KiteClient.GetPositions()
+	positions, err := ctrl.KiteClient.GetPositionsContext(c.Request.Context())
+	if err != nil {
+		payload.KiteError(c, err)
+		return
+	}
+	payload.Respond(c, http.StatusOK, positions, nil)
+}
+
+// GetPortfolioGreeks handles the GET /portfolio/greeks route
+// It aggregates the Greeks of all net positions using the live option chains
+func (ctrl *Controller) GetPortfolioGreeks(c *gin.Context) {
+	if ctrl.Scanner == nil {
+		payload.Error(c, http.StatusInternalServerError, "Scanner not initialized")
+		return
+	}
+
+	positions, err := ctrl.KiteClient.GetPositionsContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, positions)
+
+	held := make([]options.Position, 0, len(positions.Net))
+	for _, p := range positions.Net {
+		if p.Quantity == 0 {
+			continue
+		}
+		held = append(held, options.Position{
+			InstrumentToken: p.InstrumentToken,
+			Tradingsymbol:   p.Tradingsymbol,
+			Quantity:        p.Quantity,
+			AveragePrice:    p.AveragePrice,
+			CurrentPrice:    p.LastPrice,
+			UnrealizedPnL:   p.Unrealised,
+			RealizedPnL:     p.Realised,
+		})
+	}
+
+	payload.Respond(c, http.StatusOK, ctrl.Scanner.AggregateGreeks(held), nil)
 }
@@ -3,15 +3,17 @@ package handlers
 import (
 	"net/http"
 
+	"rest-service/internal/payload"
+
 	"github.com/gin-gonic/gin"
 )
 
 // GetProfile handles the GET /profile route
 func (ctrl *Controller) GetProfile(c *gin.Context) {
-	profile, err := ctrl.KiteClient.GetFullUserProfile()
+	profile, err := ctrl.KiteClient.GetFullUserProfileContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		payload.KiteError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, profile)
+	payload.Respond(c, http.StatusOK, profile, nil)
 }
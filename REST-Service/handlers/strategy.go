@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"rest-service/internal/payload"
+	"rest-service/internal/strategy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyzeStrategy handles the POST /strategy/analyze route
+func (ctrl *Controller) AnalyzeStrategy(c *gin.Context) {
+	if ctrl.Strategy == nil {
+		payload.Error(c, http.StatusInternalServerError, "Strategy engine not initialized")
+		return
+	}
+
+	var req strategy.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		payload.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := ctrl.Strategy.Analyze(req, time.Now())
+	if err != nil {
+		payload.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	payload.Respond(c, http.StatusOK, result, nil)
+}
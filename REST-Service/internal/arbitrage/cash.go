@@ -0,0 +1,285 @@
+package arbitrage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+)
+
+// Cash arbitrage directions
+const (
+	BuyNSESellBSE = "BUY_NSE_SELL_BSE"
+	BuyBSESellNSE = "BUY_BSE_SELL_NSE"
+)
+
+// Quote is the top of book of one leg of a pair
+type Quote struct {
+	InstrumentToken uint32    `json:"instrument_token"`
+	Tradingsymbol   string    `json:"tradingsymbol"`
+	Exchange        string    `json:"exchange"`
+	LastPrice       float64   `json:"last_price"`
+	BidPrice        float64   `json:"bid_price"`
+	BidQty          uint32    `json:"bid_qty"`
+	AskPrice        float64   `json:"ask_price"`
+	AskQty          uint32    `json:"ask_qty"`
+	LastUpdated     time.Time `json:"last_updated"`
+}
+
+// CashOpportunity is the best executable spread of a dual-listed stock
+type CashOpportunity struct {
+	Symbol      string    `json:"symbol"`
+	Name        string    `json:"name"`
+	NSE         Quote     `json:"nse"`
+	BSE         Quote     `json:"bse"`
+	Direction   string    `json:"direction"`    // Empty until both legs have a two-sided quote
+	BuyPrice    float64   `json:"buy_price"`    // Ask of the buy leg
+	SellPrice   float64   `json:"sell_price"`   // Bid of the sell leg
+	Quantity    int       `json:"quantity"`     // Executable at the top of book on both legs
+	GrossSpread float64   `json:"gross_spread"` // Sell - buy, per share
+	Charges     float64   `json:"charges"`      // For Quantity shares, both legs
+	NetProfit   float64   `json:"net_profit"`   // For Quantity shares
+	NetPct      float64   `json:"net_pct"`      // Net profit as a fraction of the buy turnover
+	Profitable  bool      `json:"profitable"`   // NetPct at or above the scanner's threshold
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// CashParams configures the cash arbitrage scanner
+type CashParams struct {
+	Charges     Charges
+	MinNetPct   float64       // Threshold for an opportunity to count as profitable
+	HistorySize int           // Spread events kept in memory
+	MaxQuoteAge time.Duration // Pairs with a leg last updated longer ago are not traded, zero disables
+}
+
+// cashPair holds both legs of a dual-listed stock
+type cashPair struct {
+	opp       CashOpportunity
+	openEvent *SpreadEvent // Current spread event, nil when not profitable
+}
+
+// CashScanner pairs NSE and BSE listings of the same stock and tracks the
+// executable spread between them
+type CashScanner struct {
+	params  CashParams
+	pairs   map[string]*cashPair // symbol -> pair
+	byToken map[uint32]*cashPair
+	history *History
+	mu      sync.RWMutex
+}
+
+// NewCashScanner creates a cash arbitrage scanner
+func NewCashScanner(params CashParams) *CashScanner {
+	return &CashScanner{
+		params:  params,
+		pairs:   make(map[string]*cashPair),
+		byToken: make(map[uint32]*cashPair),
+		history: NewHistory(params.HistorySize),
+	}
+}
+
+// BuildPairs pairs the NSE and BSE equity listings with the same tradingsymbol.
+// The instrument master carries no ISIN, so listings whose symbols differ
+// between exchanges are not paired. An empty symbols list pairs every
+// dual-listed stock. It returns the symbols that could not be paired.
+func (s *CashScanner) BuildPairs(instruments []kiteconnect.Instrument, symbols []string) []string {
+	wanted := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		wanted[symbol] = true
+	}
+
+	nse := make(map[string]kiteconnect.Instrument)
+	bse := make(map[string]kiteconnect.Instrument)
+	for _, inst := range instruments {
+		if inst.InstrumentType != "EQ" || inst.Segment != inst.Exchange {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[inst.Tradingsymbol] {
+			continue
+		}
+		switch inst.Exchange {
+		case "NSE":
+			nse[inst.Tradingsymbol] = inst
+		case "BSE":
+			bse[inst.Tradingsymbol] = inst
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pairs = make(map[string]*cashPair)
+	s.byToken = make(map[uint32]*cashPair)
+	for symbol, n := range nse {
+		b, ok := bse[symbol]
+		if !ok {
+			continue
+		}
+		pair := &cashPair{opp: CashOpportunity{
+			Symbol: symbol,
+			Name:   n.Name,
+			NSE:    Quote{InstrumentToken: uint32(n.InstrumentToken), Tradingsymbol: n.Tradingsymbol, Exchange: "NSE"},
+			BSE:    Quote{InstrumentToken: uint32(b.InstrumentToken), Tradingsymbol: b.Tradingsymbol, Exchange: "BSE"},
+		}}
+		s.pairs[symbol] = pair
+		s.byToken[pair.opp.NSE.InstrumentToken] = pair
+		s.byToken[pair.opp.BSE.InstrumentToken] = pair
+	}
+
+	missing := make([]string, 0)
+	for _, symbol := range symbols {
+		if _, ok := s.pairs[symbol]; !ok {
+			missing = append(missing, symbol)
+		}
+	}
+	return missing
+}
+
+// Tokens returns the instrument tokens of both legs of every pair
+func (s *CashScanner) Tokens() []uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]uint32, 0, len(s.byToken))
+	for token := range s.byToken {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// OnTick updates the leg the tick belongs to and recomputes its pair's spread
+func (s *CashScanner) OnTick(tick models.Tick) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pair, ok := s.byToken[tick.InstrumentToken]
+	if !ok {
+		return
+	}
+
+	leg := &pair.opp.NSE
+	if tick.InstrumentToken == pair.opp.BSE.InstrumentToken {
+		leg = &pair.opp.BSE
+	}
+	leg.LastPrice = tick.LastPrice
+	leg.BidPrice = tick.Depth.Buy[0].Price
+	leg.BidQty = tick.Depth.Buy[0].Quantity
+	leg.AskPrice = tick.Depth.Sell[0].Price
+	leg.AskQty = tick.Depth.Sell[0].Quantity
+	leg.LastUpdated = time.Now()
+
+	s.evaluate(pair, leg.LastUpdated)
+}
+
+// evaluate picks the better direction for a pair and tracks its spread event.
+// The caller must hold the lock.
+func (s *CashScanner) evaluate(pair *cashPair, now time.Time) {
+	opp := &pair.opp
+	var best CashOpportunity
+	if !s.stale(opp.NSE, now) && !s.stale(opp.BSE, now) {
+		best = s.spread(opp.NSE, opp.BSE, BuyNSESellBSE)
+		if other := s.spread(opp.BSE, opp.NSE, BuyBSESellNSE); other.Direction != "" &&
+			(best.Direction == "" || other.NetPct > best.NetPct) {
+			best = other
+		}
+	}
+
+	opp.Direction = best.Direction
+	opp.BuyPrice = best.BuyPrice
+	opp.SellPrice = best.SellPrice
+	opp.Quantity = best.Quantity
+	opp.GrossSpread = best.GrossSpread
+	opp.Charges = best.Charges
+	opp.NetProfit = best.NetProfit
+	opp.NetPct = best.NetPct
+	opp.Profitable = best.Direction != "" && best.NetPct >= s.params.MinNetPct
+	opp.LastUpdated = now
+
+	// Open, extend or close the spread event
+	switch {
+	case opp.Profitable && pair.openEvent != nil && pair.openEvent.Direction == opp.Direction:
+		pair.openEvent.update(opp.NetProfit, opp.NetPct)
+	case opp.Profitable:
+		if pair.openEvent != nil {
+			s.history.close(pair.openEvent, now)
+		}
+		pair.openEvent = s.history.open("cash", opp.Symbol, opp.Direction, opp.NetProfit, opp.NetPct, now)
+	case pair.openEvent != nil:
+		s.history.close(pair.openEvent, now)
+		pair.openEvent = nil
+	}
+}
+
+// stale reports whether a leg's quote is too old to trade against
+func (s *CashScanner) stale(leg Quote, now time.Time) bool {
+	return s.params.MaxQuoteAge > 0 && now.Sub(leg.LastUpdated) > s.params.MaxQuoteAge
+}
+
+// ExpireStale closes the spread events of pairs whose legs have stopped
+// ticking, which OnTick alone would leave open
+func (s *CashScanner) ExpireStale(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pair := range s.pairs {
+		if pair.openEvent != nil && (s.stale(pair.opp.NSE, now) || s.stale(pair.opp.BSE, now)) {
+			s.evaluate(pair, now)
+		}
+	}
+}
+
+// spread computes buying at buy's ask and selling at sell's bid
+func (s *CashScanner) spread(buy, sell Quote, direction string) CashOpportunity {
+	if buy.AskPrice <= 0 || sell.BidPrice <= 0 || buy.AskQty == 0 || sell.BidQty == 0 {
+		return CashOpportunity{}
+	}
+
+	qty := buy.AskQty
+	if sell.BidQty < qty {
+		qty = sell.BidQty
+	}
+
+	opp := CashOpportunity{
+		Direction:   direction,
+		BuyPrice:    buy.AskPrice,
+		SellPrice:   sell.BidPrice,
+		Quantity:    int(qty),
+		GrossSpread: sell.BidPrice - buy.AskPrice,
+	}
+	opp.Charges = s.params.Charges.RoundTrip(buy.Exchange, buy.AskPrice, sell.Exchange, sell.BidPrice, opp.Quantity)
+	opp.NetProfit = opp.GrossSpread*float64(opp.Quantity) - opp.Charges
+	opp.NetPct = opp.NetProfit / (buy.AskPrice * float64(opp.Quantity))
+	return opp
+}
+
+// Opportunities returns the pairs with a two-sided spread ranked by net
+// percentage, best first. A limit of 0 returns all of them.
+func (s *CashScanner) Opportunities(limit int, profitableOnly bool) []CashOpportunity {
+	s.mu.RLock()
+	result := make([]CashOpportunity, 0, len(s.pairs))
+	for _, pair := range s.pairs {
+		if pair.opp.Direction == "" || (profitableOnly && !pair.opp.Profitable) {
+			continue
+		}
+		result = append(result, pair.opp)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NetPct > result[j].NetPct
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// History returns the most recent spread events, newest first
+func (s *CashScanner) History(limit int) []SpreadEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.history.Recent(limit)
+}
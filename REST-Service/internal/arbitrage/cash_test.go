@@ -0,0 +1,103 @@
+package arbitrage
+
+import (
+	"testing"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func quoteTick(token uint32, bid float64, bidQty uint32, ask float64, askQty uint32) models.Tick {
+	tick := models.Tick{InstrumentToken: token, LastPrice: (bid + ask) / 2}
+	tick.Depth.Buy[0] = models.DepthItem{Price: bid, Quantity: bidQty}
+	tick.Depth.Sell[0] = models.DepthItem{Price: ask, Quantity: askQty}
+	return tick
+}
+
+func newTestCashScanner(t *testing.T) *CashScanner {
+	s := NewCashScanner(CashParams{Charges: IntradayEquityCharges(), MinNetPct: 0.0005, HistorySize: 10})
+	missing := s.BuildPairs([]kiteconnect.Instrument{
+		{InstrumentToken: 1, Tradingsymbol: "ACME", Exchange: "NSE", Segment: "NSE", InstrumentType: "EQ"},
+		{InstrumentToken: 2, Tradingsymbol: "ACME", Exchange: "BSE", Segment: "BSE", InstrumentType: "EQ"},
+		{InstrumentToken: 3, Tradingsymbol: "SOLO", Exchange: "NSE", Segment: "NSE", InstrumentType: "EQ"},
+	}, []string{"ACME", "SOLO"})
+	require.Equal(t, []string{"SOLO"}, missing)
+	require.ElementsMatch(t, []uint32{1, 2}, s.Tokens())
+	return s
+}
+
+func TestCashSpreadNetOfCharges(t *testing.T) {
+	s := newTestCashScanner(t)
+
+	// BSE bid is 5.00 above the NSE ask: buy NSE, sell BSE for the smaller size
+	s.OnTick(quoteTick(1, 999.5, 100, 1000, 50))
+	s.OnTick(quoteTick(2, 1005, 80, 1005.5, 100))
+
+	opps := s.Opportunities(0, false)
+	require.Len(t, opps, 1)
+	opp := opps[0]
+	require.Equal(t, BuyNSESellBSE, opp.Direction)
+	require.Equal(t, 50, opp.Quantity)
+	require.InDelta(t, 5.0, opp.GrossSpread, 1e-9)
+
+	charges := IntradayEquityCharges().RoundTrip("NSE", 1000, "BSE", 1005, 50)
+	require.InDelta(t, charges, opp.Charges, 1e-9)
+	require.InDelta(t, 250-charges, opp.NetProfit, 1e-9)
+	require.True(t, opp.Profitable)
+
+	history := s.History(0)
+	require.Len(t, history, 1)
+	require.Nil(t, history[0].ClosedAt)
+}
+
+func TestCashSpreadEventCloses(t *testing.T) {
+	s := newTestCashScanner(t)
+
+	s.OnTick(quoteTick(1, 999.5, 100, 1000, 50))
+	s.OnTick(quoteTick(2, 1005, 80, 1005.5, 100))
+
+	// Quotes converge: the spread no longer covers charges
+	s.OnTick(quoteTick(2, 1000, 80, 1000.5, 100))
+	require.False(t, s.Opportunities(0, false)[0].Profitable)
+	require.Empty(t, s.Opportunities(0, true))
+
+	history := s.History(0)
+	require.Len(t, history, 1)
+	require.NotNil(t, history[0].ClosedAt)
+}
+
+func TestCashSpreadStaleLeg(t *testing.T) {
+	s := newTestCashScanner(t)
+	s.params.MaxQuoteAge = 5 * time.Second
+
+	s.OnTick(quoteTick(1, 999.5, 100, 1000, 50))
+	s.OnTick(quoteTick(2, 1005, 80, 1005.5, 100))
+	require.Len(t, s.Opportunities(0, true), 1)
+
+	// The NSE leg stops ticking: the next BSE tick must not trade against it
+	s.pairs["ACME"].opp.NSE.LastUpdated = time.Now().Add(-time.Minute)
+	s.OnTick(quoteTick(2, 1005, 80, 1005.5, 100))
+	require.Empty(t, s.Opportunities(0, false))
+
+	history := s.History(0)
+	require.Len(t, history, 1)
+	require.NotNil(t, history[0].ClosedAt)
+}
+
+func TestCashExpireStale(t *testing.T) {
+	s := newTestCashScanner(t)
+	s.params.MaxQuoteAge = 5 * time.Second
+
+	s.OnTick(quoteTick(1, 999.5, 100, 1000, 50))
+	s.OnTick(quoteTick(2, 1005, 80, 1005.5, 100))
+
+	// Neither leg ticks again, so only the sweep closes the event
+	s.ExpireStale(time.Now())
+	require.Nil(t, s.History(0)[0].ClosedAt)
+	s.ExpireStale(time.Now().Add(time.Minute))
+	require.Empty(t, s.Opportunities(0, false))
+	require.NotNil(t, s.History(0)[0].ClosedAt)
+}
@@ -0,0 +1,99 @@
+// Package arbitrage detects cross-exchange and option no-arbitrage violations
+// from live quotes, net of estimated trading charges.
+package arbitrage
+
+import "math"
+
+// Charges holds the statutory and brokerage rates used to estimate trading costs.
+// Percentages are fractions of turnover, e.g. 0.0003 for 0.03%.
+type Charges struct {
+	BrokerageFlat   float64            `json:"brokerage_flat"`     // Per order, added to the percentage brokerage
+	BrokeragePct    float64            `json:"brokerage_pct"`      // Per order, capped at BrokerageCap
+	BrokerageCap    float64            `json:"brokerage_cap"`      // Max brokerage per order (0 = uncapped)
+	STTBuyPct       float64            `json:"stt_buy_pct"`        // Securities transaction tax on buys
+	STTSellPct      float64            `json:"stt_sell_pct"`       // Securities transaction tax on sells
+	ExchangeTxnPct  map[string]float64 `json:"exchange_txn_pct"`   // By exchange
+	SEBIPct         float64            `json:"sebi_pct"`           // SEBI turnover fee
+	StampDutyBuyPct float64            `json:"stamp_duty_buy_pct"` // Stamp duty on buys
+	GSTPct          float64            `json:"gst_pct"`            // On brokerage, exchange and SEBI fees
+}
+
+// IntradayEquityCharges returns typical discount broker charges for intraday equity
+func IntradayEquityCharges() Charges {
+	return Charges{
+		BrokeragePct:    0.0003,
+		BrokerageCap:    20,
+		STTBuyPct:       0,
+		STTSellPct:      0.00025,
+		ExchangeTxnPct:  map[string]float64{"NSE": 0.0000297, "BSE": 0.0000375},
+		SEBIPct:         0.000001,
+		StampDutyBuyPct: 0.00003,
+		GSTPct:          0.18,
+	}
+}
+
+// DeliveryEquityCharges returns typical discount broker charges for delivery equity
+func DeliveryEquityCharges() Charges {
+	c := IntradayEquityCharges()
+	c.BrokeragePct = 0
+	c.BrokerageCap = 0
+	c.STTBuyPct = 0.001
+	c.STTSellPct = 0.001
+	c.StampDutyBuyPct = 0.00015
+	return c
+}
+
+// OptionCharges returns typical discount broker charges for index and stock
+// options, applied to premium turnover
+func OptionCharges() Charges {
+	return Charges{
+		BrokerageFlat:   20,
+		STTSellPct:      0.001,
+		ExchangeTxnPct:  map[string]float64{"NFO": 0.0003503, "BFO": 0.000325},
+		SEBIPct:         0.000001,
+		StampDutyBuyPct: 0.00003,
+		GSTPct:          0.18,
+	}
+}
+
+// FutureCharges returns typical discount broker charges for index and stock futures
+func FutureCharges() Charges {
+	return Charges{
+		BrokeragePct:    0.0003,
+		BrokerageCap:    20,
+		STTSellPct:      0.0002,
+		ExchangeTxnPct:  map[string]float64{"NFO": 0.0000173, "BFO": 0},
+		SEBIPct:         0.000001,
+		StampDutyBuyPct: 0.00002,
+		GSTPct:          0.18,
+	}
+}
+
+// Order estimates the charges of a single order. buy selects the side.
+func (c Charges) Order(exchange string, buy bool, price float64, quantity int) float64 {
+	turnover := price * float64(quantity)
+	if turnover <= 0 {
+		return 0
+	}
+
+	brokerage := c.BrokerageFlat + turnover*c.BrokeragePct
+	if c.BrokerageCap > 0 {
+		brokerage = math.Min(brokerage, c.BrokerageCap)
+	}
+	txn := turnover * c.ExchangeTxnPct[exchange]
+	sebi := turnover * c.SEBIPct
+	gst := (brokerage + txn + sebi) * c.GSTPct
+
+	total := brokerage + txn + sebi + gst
+	if buy {
+		total += turnover * (c.STTBuyPct + c.StampDutyBuyPct)
+	} else {
+		total += turnover * c.STTSellPct
+	}
+	return total
+}
+
+// RoundTrip estimates the charges of buying on one exchange and selling on another
+func (c Charges) RoundTrip(buyExchange string, buyPrice float64, sellExchange string, sellPrice float64, quantity int) float64 {
+	return c.Order(buyExchange, true, buyPrice, quantity) + c.Order(sellExchange, false, sellPrice, quantity)
+}
@@ -0,0 +1,91 @@
+package arbitrage
+
+import "time"
+
+// SpreadEvent is a period during which an opportunity stayed profitable
+type SpreadEvent struct {
+	Kind       string     `json:"kind"` // e.g. "cash", "conversion", "box"
+	Symbol     string     `json:"symbol"`
+	Direction  string     `json:"direction"`
+	OpenedAt   time.Time  `json:"opened_at"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty"` // Nil while still open
+	OpenNet    float64    `json:"open_net"`            // Net profit when the event opened
+	PeakNet    float64    `json:"peak_net"`
+	PeakNetPct float64    `json:"peak_net_pct"`
+	LastNet    float64    `json:"last_net"`
+	LastNetPct float64    `json:"last_net_pct"`
+	Updates    int        `json:"updates"`
+}
+
+// update records a new observation of an open event
+func (e *SpreadEvent) update(net, netPct float64) {
+	e.LastNet = net
+	e.LastNetPct = netPct
+	if netPct > e.PeakNetPct {
+		e.PeakNet = net
+		e.PeakNetPct = netPct
+	}
+	e.Updates++
+}
+
+// History is a fixed-size ring of spread events
+type History struct {
+	events []*SpreadEvent
+	next   int
+	full   bool
+}
+
+// NewHistory creates a history keeping the last size events (default 1000)
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = 1000
+	}
+	return &History{events: make([]*SpreadEvent, size)}
+}
+
+// open starts and records a new event
+func (h *History) open(kind, symbol, direction string, net, netPct float64, now time.Time) *SpreadEvent {
+	e := &SpreadEvent{
+		Kind:      kind,
+		Symbol:    symbol,
+		Direction: direction,
+		OpenedAt:  now,
+		OpenNet:   net,
+	}
+	e.update(net, netPct)
+
+	h.events[h.next] = e
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+	return e
+}
+
+// close marks an event as ended
+func (h *History) close(e *SpreadEvent, now time.Time) {
+	e.ClosedAt = &now
+}
+
+// Recent returns copies of the most recent events, newest first. A limit of 0
+// returns all of them.
+func (h *History) Recent(limit int) []SpreadEvent {
+	count := h.next
+	if h.full {
+		count = len(h.events)
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	result := make([]SpreadEvent, 0, count)
+	for i := 1; i <= count; i++ {
+		e := *h.events[(h.next-i+len(h.events))%len(h.events)]
+		if e.ClosedAt != nil {
+			closed := *e.ClosedAt
+			e.ClosedAt = &closed
+		}
+		result = append(result, e)
+	}
+	return result
+}
@@ -0,0 +1,480 @@
+package arbitrage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"rest-service/internal/calendar"
+	"rest-service/internal/options"
+	"rest-service/internal/store"
+)
+
+// Option arbitrage kinds
+const (
+	KindConversion = "conversion" // Long future, long put, short call
+	KindReversal   = "reversal"   // Short future, short put, long call
+	KindLongBox    = "long_box"   // Bull call spread + bear put spread, bought below its discounted width
+	KindShortBox   = "short_box"  // Box sold above its discounted width
+	KindButterfly  = "butterfly"  // Butterfly bought for a credit (convexity violation)
+	KindCalendar   = "calendar"   // Far call bought below the near call (calendar violation)
+)
+
+// Order sides
+const (
+	Buy  = "BUY"
+	Sell = "SELL"
+)
+
+// Leg is one order of an arbitrage
+type Leg struct {
+	InstrumentToken uint32  `json:"instrument_token"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	Exchange        string  `json:"exchange"`
+	Side            string  `json:"side"`
+	Price           float64 `json:"price"`    // Executable: ask for buys, bid for sells
+	Quantity        int     `json:"quantity"` // Units, one lot per structure
+	future          bool
+}
+
+// OptionOpportunity is a detected no-arbitrage violation for one lot
+type OptionOpportunity struct {
+	ID             string     `json:"id"`
+	Kind           string     `json:"kind"`
+	Underlying     string     `json:"underlying"`
+	Expiry         time.Time  `json:"expiry"`
+	FarExpiry      *time.Time `json:"far_expiry,omitempty"`   // Calendar only
+	HedgeExpiry    *time.Time `json:"hedge_expiry,omitempty"` // Conversions and reversals hedged with a later future
+	Strikes        []float64  `json:"strikes"`
+	Legs           []Leg      `json:"legs"`
+	LotSize        int        `json:"lot_size"`
+	EdgePerUnit    float64    `json:"edge_per_unit"`   // Before charges, discounted to today
+	GrossEdge      float64    `json:"gross_edge"`      // Per lot
+	Charges        float64    `json:"charges"`         // All legs, per lot
+	NetEdge        float64    `json:"net_edge"`        // Per lot
+	MarginRequired float64    `json:"margin_required"` // Estimate: net debit, plus futures margin or box width when selling
+	ReturnOnMargin float64    `json:"return_on_margin"`
+	DetectedAt     time.Time  `json:"detected_at"`
+}
+
+// OptionsParams configures the option arbitrage detector
+type OptionsParams struct {
+	RiskFreeRate    float64 // Used to discount expiry payoffs
+	MinNetEdge      float64 // Per lot, after charges
+	FutureMarginPct float64 // Futures margin as a fraction of notional
+	OptionCharges   Charges
+	FutureCharges   Charges
+	HistorySize     int
+	MaxQuoteAge     time.Duration // Legs whose bid and ask are older are skipped; zero keeps every quote
+}
+
+// OptionsDetector scans the option chains for put-call parity, box spread,
+// butterfly and calendar violations at executable prices
+type OptionsDetector struct {
+	scanner  *options.Scanner
+	params   OptionsParams
+	calendar *calendar.Calendar
+	latest   []OptionOpportunity
+	open     map[string]*SpreadEvent // Opportunity ID -> open event
+	history  *History
+	mu       sync.RWMutex
+}
+
+// NewOptionsDetector creates a detector over the scanner's chains
+func NewOptionsDetector(scanner *options.Scanner, params OptionsParams) *OptionsDetector {
+	return &OptionsDetector{
+		scanner:  scanner,
+		params:   params,
+		calendar: calendar.Default(),
+		open:     make(map[string]*SpreadEvent),
+		history:  NewHistory(params.HistorySize),
+	}
+}
+
+// SetCalendar sets the trading calendar used to discount to expiry
+func (d *OptionsDetector) SetCalendar(cal *calendar.Calendar) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calendar = cal
+}
+
+// quote is an option's executable prices and contract details
+type quote struct {
+	bid float64
+	ask float64
+	leg Leg
+	lot int
+}
+
+// badQuality marks option quotes no structure is built on
+const badQuality = options.QualityStale | options.QualityOneSided
+
+// optionQuote returns an option's quote if it is two-sided, not crossed, and
+// no older than the max quote age
+func (d *OptionsDetector) optionQuote(od *options.OptionData, now time.Time) (quote, bool) {
+	if od == nil || !d.tradable(od.BidPrice, od.AskPrice, od.DepthUpdated, now) || od.Quality&badQuality != 0 {
+		return quote{}, false
+	}
+	inst, ok := d.scanner.GetInstrument(od.InstrumentToken)
+	if !ok || inst.LotSize <= 0 {
+		return quote{}, false
+	}
+	return quote{
+		bid: od.BidPrice,
+		ask: od.AskPrice,
+		lot: inst.LotSize,
+		leg: Leg{InstrumentToken: od.InstrumentToken, Tradingsymbol: od.Tradingsymbol, Exchange: inst.Exchange},
+	}, true
+}
+
+// tradable reports whether a bid and ask from a full tick at depthAt can be
+// traded at now
+func (d *OptionsDetector) tradable(bid, ask float64, depthAt, now time.Time) bool {
+	if bid <= 0 || ask <= 0 || bid > ask {
+		return false
+	}
+	return d.params.MaxQuoteAge <= 0 || now.Sub(depthAt) <= d.params.MaxQuoteAge
+}
+
+// buy and sell return the leg executed at the ask or bid
+func (q quote) buy() Leg {
+	l := q.leg
+	l.Side, l.Price, l.Quantity = Buy, q.ask, q.lot
+	return l
+}
+
+func (q quote) sell() Leg {
+	l := q.leg
+	l.Side, l.Price, l.Quantity = Sell, q.bid, q.lot
+	return l
+}
+
+// Scan checks every chain and records the opportunities whose net edge per lot
+// reaches the threshold. It returns the opportunities, best first.
+func (d *OptionsDetector) Scan(now time.Time) []OptionOpportunity {
+	d.mu.RLock()
+	cal := d.calendar
+	d.mu.RUnlock()
+
+	found := make([]OptionOpportunity, 0)
+	for _, underlying := range d.scanner.GetUnderlyings() {
+		expiries := d.scanner.GetExpiries(underlying)
+		var prev *options.OptionChain
+		for _, expiry := range expiries {
+			T := cal.TimeToExpiry(now, expiry)
+			if T <= 0 {
+				continue
+			}
+			live, ok := d.scanner.GetOptionChain(underlying, expiry)
+			if !ok {
+				continue
+			}
+			chain := live.Snapshot()
+
+			df := math.Exp(-d.params.RiskFreeRate * T)
+			strikes := chain.SortedStrikes()
+			found = append(found, d.parity(chain, strikes, df, T, now)...)
+			found = append(found, d.boxes(chain, strikes, df, now)...)
+			found = append(found, d.butterflies(chain, strikes, now)...)
+			if prev != nil {
+				found = append(found, d.calendars(prev, chain, now)...)
+			}
+			prev = chain
+		}
+	}
+
+	result := make([]OptionOpportunity, 0, len(found))
+	for _, opp := range found {
+		if opp.NetEdge >= d.params.MinNetEdge {
+			opp.DetectedAt = now
+			result = append(result, opp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NetEdge > result[j].NetEdge
+	})
+
+	d.mu.Lock()
+	d.latest = result
+	d.track(result, now)
+	d.mu.Unlock()
+
+	return result
+}
+
+// track opens, updates and closes spread events. The caller must hold the lock.
+func (d *OptionsDetector) track(opps []OptionOpportunity, now time.Time) {
+	seen := make(map[string]bool, len(opps))
+	for _, opp := range opps {
+		seen[opp.ID] = true
+		netPct := opp.ReturnOnMargin
+		if e, ok := d.open[opp.ID]; ok {
+			e.update(opp.NetEdge, netPct)
+			continue
+		}
+		d.open[opp.ID] = d.history.open(opp.Kind, opp.Underlying, opp.ID, opp.NetEdge, netPct, now)
+	}
+	for id, e := range d.open {
+		if !seen[id] {
+			d.history.close(e, now)
+			delete(d.open, id)
+		}
+	}
+}
+
+// Opportunities returns the result of the last scan, best first. A limit of 0
+// returns all of them.
+func (d *OptionsDetector) Opportunities(limit int, kind string) []OptionOpportunity {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	result := make([]OptionOpportunity, 0, len(d.latest))
+	for _, opp := range d.latest {
+		if kind != "" && opp.Kind != kind {
+			continue
+		}
+		result = append(result, opp)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+// History returns the most recent opportunity events, newest first
+func (d *OptionsDetector) History(limit int) []SpreadEvent {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.history.Recent(limit)
+}
+
+// finish fills in charges, margin and net edge from the legs and edge per unit
+func (d *OptionsDetector) finish(opp OptionOpportunity, margin float64) OptionOpportunity {
+	opp.GrossEdge = opp.EdgePerUnit * float64(opp.LotSize)
+	for _, leg := range opp.Legs {
+		charges := d.params.OptionCharges
+		if leg.future {
+			charges = d.params.FutureCharges
+		}
+		opp.Charges += charges.Order(leg.Exchange, leg.Side == Buy, leg.Price, leg.Quantity)
+	}
+	opp.NetEdge = opp.GrossEdge - opp.Charges
+	opp.MarginRequired = margin
+	if margin > 0 {
+		opp.ReturnOnMargin = opp.NetEdge / margin
+	}
+	return opp
+}
+
+// parity checks conversions and reversals against the same-expiry future.
+// At expiry the options and future net to a fixed cash flow, so:
+// conversion edge = (C_bid - P_ask) - (F_ask - K)·DF
+// reversal edge   = (P_bid - C_ask) + (F_bid - K)·DF
+// Expiries without a future, such as weeklies, are hedged with the next
+// future, its prices carried back to the expiry along the futures curve.
+// That leaves the basis between the two expiries open until the hedge is
+// closed, which the carry only estimates.
+func (d *OptionsDetector) parity(chain *options.OptionChain, strikes []*options.StrikeData, df, T float64, now time.Time) []OptionOpportunity {
+	future, carry, ok := d.parityHedge(chain, T, now)
+	if !ok {
+		return nil
+	}
+	var hedgeExpiry *time.Time
+	if !future.Expiry.Equal(chain.Expiry) {
+		expiry := future.Expiry
+		hedgeExpiry = &expiry
+	}
+	futureBid, futureAsk := future.BidPrice*carry, future.AskPrice*carry
+
+	result := make([]OptionOpportunity, 0)
+	for _, sd := range strikes {
+		call, okC := d.optionQuote(sd.Call, now)
+		put, okP := d.optionQuote(sd.Put, now)
+		if !okC || !okP || call.lot != put.lot {
+			continue
+		}
+		lot := call.lot
+		futureLeg := Leg{InstrumentToken: future.InstrumentToken, Tradingsymbol: future.Tradingsymbol, Exchange: future.Exchange, Quantity: lot, future: true}
+		futureMargin := d.params.FutureMarginPct * future.LastPrice * float64(lot)
+		K := sd.Strike
+
+		conversion := OptionOpportunity{
+			Kind: KindConversion, Underlying: chain.Underlying, Expiry: chain.Expiry, Strikes: []float64{K}, LotSize: lot,
+			EdgePerUnit: (call.bid - put.ask) - (futureAsk-K)*df, HedgeExpiry: hedgeExpiry,
+		}
+		buyFuture := futureLeg
+		buyFuture.Side, buyFuture.Price = Buy, future.AskPrice
+		conversion.Legs = []Leg{buyFuture, put.buy(), call.sell()}
+		conversion.ID = fmt.Sprintf("%s:%s:%s:%g", KindConversion, chain.Underlying, chain.Expiry.Format("2006-01-02"), K)
+		result = append(result, d.finish(conversion, futureMargin+math.Max(0, put.ask-call.bid)*float64(lot)))
+
+		reversal := OptionOpportunity{
+			Kind: KindReversal, Underlying: chain.Underlying, Expiry: chain.Expiry, Strikes: []float64{K}, LotSize: lot,
+			EdgePerUnit: (put.bid - call.ask) + (futureBid-K)*df, HedgeExpiry: hedgeExpiry,
+		}
+		sellFuture := futureLeg
+		sellFuture.Side, sellFuture.Price = Sell, future.BidPrice
+		reversal.Legs = []Leg{sellFuture, put.sell(), call.buy()}
+		reversal.ID = fmt.Sprintf("%s:%s:%s:%g", KindReversal, chain.Underlying, chain.Expiry.Format("2006-01-02"), K)
+		result = append(result, d.finish(reversal, futureMargin+math.Max(0, call.ask-put.bid)*float64(lot)))
+	}
+	return result
+}
+
+// parityHedge returns the future conversions and reversals of a chain trade,
+// and the factor carrying its price to the chain's expiry: the future of the
+// same expiry, else the next one against the forward interpolated from the
+// spot along the futures curve
+func (d *OptionsDetector) parityHedge(chain *options.OptionChain, T float64, now time.Time) (*options.FutureData, float64, bool) {
+	quoted := func(f *options.FutureData) bool { return d.tradable(f.BidPrice, f.AskPrice, f.DepthUpdated, now) }
+
+	if token, ok := d.scanner.GetFutureToken(chain.Underlying, chain.Expiry); ok {
+		future, ok := d.scanner.GetFutureData(token)
+		return future, 1, ok && quoted(future)
+	}
+
+	var next *options.FutureData
+	for _, future := range d.scanner.GetFutures(chain.Underlying) {
+		if future.Expiry.After(chain.Expiry) {
+			next = future
+			break
+		}
+	}
+	if next == nil || !quoted(next) || next.LastPrice <= 0 {
+		return nil, 0, false
+	}
+	token, ok := d.scanner.GetSpotToken(chain.Underlying)
+	if !ok {
+		return nil, 0, false
+	}
+	spot, ok := store.GlobalStore.GetLTP(token)
+	if !ok || spot <= 0 {
+		return nil, 0, false
+	}
+	forward, ok := d.scanner.InterpolatedForward(chain.Underlying, spot, T, now)
+	if !ok {
+		return nil, 0, false
+	}
+	return next, forward / next.LastPrice, true
+}
+
+// boxes checks every strike pair K1 < K2. A box pays K2 - K1 at expiry, so it
+// should trade at its discounted width.
+func (d *OptionsDetector) boxes(chain *options.OptionChain, strikes []*options.StrikeData, df float64, now time.Time) []OptionOpportunity {
+	type legs struct{ call, put quote }
+	quoted := make([]legs, 0, len(strikes))
+	strikeOf := make([]float64, 0, len(strikes))
+	for _, sd := range strikes {
+		call, okC := d.optionQuote(sd.Call, now)
+		put, okP := d.optionQuote(sd.Put, now)
+		if okC && okP && call.lot == put.lot {
+			quoted = append(quoted, legs{call, put})
+			strikeOf = append(strikeOf, sd.Strike)
+		}
+	}
+
+	result := make([]OptionOpportunity, 0)
+	for i := 0; i < len(quoted); i++ {
+		for j := i + 1; j < len(quoted); j++ {
+			lo, hi := quoted[i], quoted[j]
+			if lo.call.lot != hi.call.lot {
+				continue
+			}
+			lot := lo.call.lot
+			K1, K2 := strikeOf[i], strikeOf[j]
+			width := (K2 - K1) * df
+			expiry := chain.Expiry.Format("2006-01-02")
+
+			cost := lo.call.ask - hi.call.bid + hi.put.ask - lo.put.bid
+			long := OptionOpportunity{
+				ID:   fmt.Sprintf("%s:%s:%s:%g:%g", KindLongBox, chain.Underlying, expiry, K1, K2),
+				Kind: KindLongBox, Underlying: chain.Underlying, Expiry: chain.Expiry, Strikes: []float64{K1, K2}, LotSize: lot,
+				EdgePerUnit: width - cost,
+				Legs:        []Leg{lo.call.buy(), hi.call.sell(), hi.put.buy(), lo.put.sell()},
+			}
+			result = append(result, d.finish(long, math.Max(0, cost)*float64(lot)))
+
+			credit := lo.call.bid - hi.call.ask + hi.put.bid - lo.put.ask
+			short := OptionOpportunity{
+				ID:   fmt.Sprintf("%s:%s:%s:%g:%g", KindShortBox, chain.Underlying, expiry, K1, K2),
+				Kind: KindShortBox, Underlying: chain.Underlying, Expiry: chain.Expiry, Strikes: []float64{K1, K2}, LotSize: lot,
+				EdgePerUnit: credit - width,
+				Legs:        []Leg{lo.call.sell(), hi.call.buy(), hi.put.sell(), lo.put.buy()},
+			}
+			result = append(result, d.finish(short, (K2-K1)*float64(lot)))
+		}
+	}
+	return result
+}
+
+// butterflies checks convexity on adjacent equally spaced strikes: a long
+// butterfly never pays less than zero, so buying it for a credit is free money
+func (d *OptionsDetector) butterflies(chain *options.OptionChain, strikes []*options.StrikeData, now time.Time) []OptionOpportunity {
+	result := make([]OptionOpportunity, 0)
+	for i := 1; i+1 < len(strikes); i++ {
+		lo, mid, hi := strikes[i-1], strikes[i], strikes[i+1]
+		if math.Abs((mid.Strike-lo.Strike)-(hi.Strike-mid.Strike)) > 1e-9 {
+			continue
+		}
+
+		for _, side := range []struct {
+			typ         options.OptionType
+			lo, mid, hi *options.OptionData
+		}{
+			{options.Call, lo.Call, mid.Call, hi.Call},
+			{options.Put, lo.Put, mid.Put, hi.Put},
+		} {
+			a, okA := d.optionQuote(side.lo, now)
+			b, okB := d.optionQuote(side.mid, now)
+			c, okC := d.optionQuote(side.hi, now)
+			if !okA || !okB || !okC || a.lot != b.lot || b.lot != c.lot {
+				continue
+			}
+
+			body := b.sell()
+			body.Quantity *= 2
+			cost := a.ask + c.ask - 2*b.bid
+			opp := OptionOpportunity{
+				ID:   fmt.Sprintf("%s:%s:%s:%s:%g", KindButterfly, chain.Underlying, chain.Expiry.Format("2006-01-02"), side.typ, mid.Strike),
+				Kind: KindButterfly, Underlying: chain.Underlying, Expiry: chain.Expiry,
+				Strikes: []float64{lo.Strike, mid.Strike, hi.Strike}, LotSize: a.lot,
+				EdgePerUnit: -cost,
+				Legs:        []Leg{a.buy(), body, c.buy()},
+			}
+			result = append(result, d.finish(opp, math.Max(0, cost)*float64(a.lot)))
+		}
+	}
+	return result
+}
+
+// calendars checks that a far-expiry call is worth at least the near-expiry
+// call at the same strike. Puts are skipped: with positive rates a European
+// put calendar can legitimately trade below zero.
+func (d *OptionsDetector) calendars(near, far *options.OptionChain, now time.Time) []OptionOpportunity {
+	farExpiry := far.Expiry
+	result := make([]OptionOpportunity, 0)
+	for strike, nearStrike := range near.Strikes {
+		farStrike, ok := far.Strikes[strike]
+		if !ok {
+			continue
+		}
+		n, okN := d.optionQuote(nearStrike.Call, now)
+		f, okF := d.optionQuote(farStrike.Call, now)
+		if !okN || !okF || n.lot != f.lot {
+			continue
+		}
+
+		cost := f.ask - n.bid
+		opp := OptionOpportunity{
+			ID:   fmt.Sprintf("%s:%s:%s:%s:%g", KindCalendar, near.Underlying, near.Expiry.Format("2006-01-02"), far.Expiry.Format("2006-01-02"), strike),
+			Kind: KindCalendar, Underlying: near.Underlying, Expiry: near.Expiry, FarExpiry: &farExpiry,
+			Strikes: []float64{strike}, LotSize: n.lot,
+			EdgePerUnit: -cost,
+			Legs:        []Leg{f.buy(), n.sell()},
+		}
+		result = append(result, d.finish(opp, math.Max(0, cost)*float64(n.lot)))
+	}
+	return result
+}
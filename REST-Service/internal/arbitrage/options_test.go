@@ -0,0 +1,187 @@
+package arbitrage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"rest-service/internal/calendar"
+	"rest-service/internal/options"
+	"rest-service/internal/store"
+	kiteticker "rest-service/internal/ticker"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsDetectorLongBox(t *testing.T) {
+	now := time.Now()
+	expiry := models.Time{Time: now.AddDate(0, 0, 30)}
+	option := func(token int, strike float64, typ string) kiteconnect.Instrument {
+		return kiteconnect.Instrument{
+			InstrumentToken: token, Tradingsymbol: "TEST" + typ, Name: "TEST", Exchange: "NFO", Segment: "NFO-OPT",
+			InstrumentType: typ, StrikePrice: strike, Expiry: expiry, LotSize: 500,
+		}
+	}
+
+	scanner := options.NewScanner(nil)
+	scanner.LoadInstruments([]kiteconnect.Instrument{
+		option(1, 100, "CE"), option(2, 100, "PE"),
+		option(3, 110, "CE"), option(4, 110, "PE"),
+	})
+
+	setQuote := func(token uint32, bid, ask float64) {
+		od, ok := scanner.GetOptionData(token)
+		require.True(t, ok)
+		od.BidPrice, od.AskPrice = bid, ask
+	}
+	// The 100/110 box costs 12 - 5 + 4 - 2 = 9 against a width of 10
+	setQuote(1, 11.5, 12)
+	setQuote(2, 2, 2.5)
+	setQuote(3, 5, 5.5)
+	setQuote(4, 3.5, 4)
+
+	detector := NewOptionsDetector(scanner, OptionsParams{
+		RiskFreeRate:  0.06,
+		MinNetEdge:    100,
+		OptionCharges: OptionCharges(),
+	})
+	detector.Scan(now)
+
+	boxes := detector.Opportunities(0, KindLongBox)
+	require.Len(t, boxes, 1)
+	box := boxes[0]
+	require.Equal(t, []float64{100, 110}, box.Strikes)
+	require.Len(t, box.Legs, 4)
+	require.InDelta(t, 500*box.EdgePerUnit-box.Charges, box.NetEdge, 1e-9)
+	require.InDelta(t, 9*500.0, box.MarginRequired, 1e-9)
+	require.Greater(t, box.EdgePerUnit, 0.9)
+
+	require.Empty(t, detector.Opportunities(0, KindShortBox))
+
+	history := detector.History(0)
+	require.NotEmpty(t, history)
+	require.Nil(t, history[0].ClosedAt)
+
+	// With a max quote age, a leg whose depth is old, crossed or flagged
+	// stale breaks the box
+	detector = NewOptionsDetector(scanner, OptionsParams{
+		RiskFreeRate:  0.06,
+		MinNetEdge:    100,
+		OptionCharges: OptionCharges(),
+		MaxQuoteAge:   5 * time.Second,
+	})
+	for token := uint32(1); token <= 4; token++ {
+		od, _ := scanner.GetOptionData(token)
+		od.DepthUpdated = now
+	}
+	require.Len(t, detector.Scan(now), 1)
+	require.Empty(t, detector.Scan(now.Add(6*time.Second)))
+
+	od, _ := scanner.GetOptionData(3)
+	od.BidPrice = 5.6
+	require.Empty(t, detector.Scan(now))
+	od.BidPrice = 5
+	od.Quality = options.QualityStale
+	require.Empty(t, detector.Scan(now))
+	od.Quality = options.QualityWideSpread
+	require.Len(t, detector.Scan(now), 1)
+}
+
+func TestOptionsDetectorParityButterflyCalendar(t *testing.T) {
+	now := time.Now()
+	weekly := models.Time{Time: now.AddDate(0, 0, 7)}
+	monthly := models.Time{Time: now.AddDate(0, 0, 30)}
+	option := func(token int, expiry models.Time, strike float64, typ string) kiteconnect.Instrument {
+		return kiteconnect.Instrument{
+			InstrumentToken: token, Tradingsymbol: "PAR" + typ, Name: "PAR", Exchange: "NFO", Segment: "NFO-OPT",
+			InstrumentType: typ, StrikePrice: strike, Expiry: expiry, LotSize: 50,
+		}
+	}
+
+	scanner := options.NewScanner(nil)
+	scanner.LoadInstruments([]kiteconnect.Instrument{
+		option(1, weekly, 100, "CE"), option(2, weekly, 100, "PE"), option(3, weekly, 110, "CE"),
+		option(11, monthly, 90, "CE"), option(12, monthly, 100, "CE"), option(13, monthly, 100, "PE"), option(14, monthly, 110, "CE"),
+		{InstrumentToken: 20, Tradingsymbol: "PARFUT", Name: "PAR", Exchange: "NFO", Segment: "NFO-FUT",
+			InstrumentType: "FUT", Expiry: monthly, LotSize: 50},
+	})
+	scanner.SetSpotToken("PAR", 30)
+	store.GlobalStore.UpdateFromTick(models.Tick{InstrumentToken: 30, LastPrice: 100})
+
+	setQuote := func(token uint32, bid, ask float64) {
+		od, ok := scanner.GetOptionData(token)
+		require.True(t, ok)
+		od.BidPrice, od.AskPrice = bid, ask
+	}
+	setQuote(1, 2, 2.1)
+	setQuote(2, 1.5, 1.6)
+	setQuote(3, 1.5, 1.6)
+	setQuote(11, 10.5, 11)
+	setQuote(12, 6.5, 6.7)
+	setQuote(13, 4, 4.2)
+	setQuote(14, 0.8, 1)
+	tick := models.Tick{InstrumentToken: 20, Mode: string(kiteticker.ModeFull), LastPrice: 104}
+	tick.Depth.Buy[0].Price, tick.Depth.Sell[0].Price = 104, 104.1
+	require.True(t, scanner.UpdateFuture(tick))
+
+	detector := NewOptionsDetector(scanner, OptionsParams{
+		RiskFreeRate:  0.06,
+		MinNetEdge:    math.Inf(-1), // Every structure, to check the edges
+		OptionCharges: OptionCharges(),
+		FutureCharges: FutureCharges(),
+	})
+	detector.Scan(now)
+
+	expiries := scanner.GetExpiries("PAR")
+	require.Len(t, expiries, 2)
+	cal := calendar.Default()
+	tw, tm := cal.TimeToExpiry(now, expiries[0]), cal.TimeToExpiry(now, expiries[1])
+	dfw, dfm := math.Exp(-0.06*tw), math.Exp(-0.06*tm)
+
+	find := func(kind string, expiry time.Time, strike float64) OptionOpportunity {
+		for _, opp := range detector.Opportunities(0, kind) {
+			if opp.Expiry.Equal(expiry) && opp.Strikes[len(opp.Strikes)/2] == strike {
+				return opp
+			}
+		}
+		t.Fatalf("no %s at %g expiring %s", kind, strike, expiry)
+		return OptionOpportunity{}
+	}
+
+	// The monthly trades against its own future
+	conversion := find(KindConversion, expiries[1], 100)
+	require.InDelta(t, (6.5-4.2)-(104.1-100)*dfm, conversion.EdgePerUnit, 1e-9)
+	require.Nil(t, conversion.HedgeExpiry)
+	reversal := find(KindReversal, expiries[1], 100)
+	require.InDelta(t, (4-6.7)+(104-100)*dfm, reversal.EdgePerUnit, 1e-9)
+	require.Equal(t, "PARFUT", reversal.Legs[0].Tradingsymbol)
+	require.Equal(t, Sell, reversal.Legs[0].Side)
+	require.Equal(t, 104.0, reversal.Legs[0].Price)
+
+	// The weekly has no future: it is hedged with the monthly, carried back
+	// to the weekly expiry at the monthly's carry over the spot
+	forward := 100 * math.Exp(math.Log(104.0/100)/tm*tw)
+	reversal = find(KindReversal, expiries[0], 100)
+	require.InDelta(t, (1.5-2.1)+(104*forward/104-100)*dfw, reversal.EdgePerUnit, 1e-9)
+	require.NotNil(t, reversal.HedgeExpiry)
+	require.True(t, reversal.HedgeExpiry.Equal(expiries[1]))
+	require.Equal(t, "PARFUT", reversal.Legs[0].Tradingsymbol)
+	conversion = find(KindConversion, expiries[0], 100)
+	require.InDelta(t, (2-1.6)-(104.1*forward/104-100)*dfw, conversion.EdgePerUnit, 1e-9)
+
+	// The 90/100/110 call butterfly is bought for 11 + 1 - 2×6.5 = -1
+	butterfly := find(KindButterfly, expiries[1], 100)
+	require.InDelta(t, 1, butterfly.EdgePerUnit, 1e-9)
+	require.Equal(t, []float64{90, 100, 110}, butterfly.Strikes)
+	require.Equal(t, 100, butterfly.Legs[1].Quantity)
+	require.Equal(t, Sell, butterfly.Legs[1].Side)
+
+	// The monthly 110 call asks 1 while the weekly bids 1.5
+	calendarSpread := find(KindCalendar, expiries[0], 110)
+	require.InDelta(t, 0.5, calendarSpread.EdgePerUnit, 1e-9)
+	require.True(t, calendarSpread.FarExpiry.Equal(expiries[1]))
+	require.InDelta(t, -4.7, find(KindCalendar, expiries[0], 100).EdgePerUnit, 1e-9)
+}
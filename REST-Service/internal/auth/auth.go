@@ -0,0 +1,171 @@
+// Package auth authenticates REST and /ws clients by API key and checks their
+// role against the one a route requires. Keys are configured as their
+// SHA-256, so the config file never holds a usable key.
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"rest-service/internal/payload"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role is what a key may do. Each role includes the ones below it.
+type Role int
+
+const (
+	Viewer Role = iota + 1 // Market data
+	Trader                 // Account data and orders
+	Admin                  // Config and the kill switch
+)
+
+var roleNames = map[Role]string{Viewer: "viewer", Trader: "trader", Admin: "admin"}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole parses "viewer", "trader" or "admin"
+func ParseRole(s string) (Role, error) {
+	for role, name := range roleNames {
+		if s == name {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q, must be viewer, trader or admin", s)
+}
+
+// Key is an API key, held as the SHA-256 of the key
+type Key struct {
+	Name string
+	Role Role
+	Hash [sha256.Size]byte
+}
+
+// ParseKeyHash decodes the hex SHA-256 of a key
+func ParseKeyHash(s string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != sha256.Size {
+		return hash, fmt.Errorf("sha256 must be %d hex characters", 2*sha256.Size)
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// HashKey returns the SHA-256 of a key
+func HashKey(key string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(key))
+}
+
+// Anonymous is the caller of every request while authentication is disabled
+var Anonymous = Key{Name: "anonymous", Role: Admin}
+
+const keyContextKey = "auth_key"
+
+// Authenticator checks the API key of requests. The key is sent as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>"; /ws also takes an
+// api_key query parameter, as browsers can't set headers on WebSockets.
+// Without keys every request is rejected, unless authentication was
+// explicitly disabled.
+type Authenticator struct {
+	mu       sync.RWMutex
+	keys     map[[sha256.Size]byte]Key
+	disabled bool
+}
+
+// NewAuthenticator returns an Authenticator of keys
+func NewAuthenticator(keys []Key) *Authenticator {
+	a := &Authenticator{}
+	a.SetKeys(keys)
+	return a
+}
+
+// NewDisabledAuthenticator returns an Authenticator that lets every request
+// through as Anonymous, for local development
+func NewDisabledAuthenticator() *Authenticator {
+	return &Authenticator{disabled: true}
+}
+
+// SetKeys replaces the keys, for rotation without a restart
+func (a *Authenticator) SetKeys(keys []Key) {
+	byHash := make(map[[sha256.Size]byte]Key, len(keys))
+	for _, k := range keys {
+		byHash[k.Hash] = k
+	}
+	a.mu.Lock()
+	a.keys = byHash
+	a.mu.Unlock()
+}
+
+// Enabled reports whether requests are authenticated
+func (a *Authenticator) Enabled() bool {
+	return !a.disabled
+}
+
+// Authenticate returns the key of a request. ok is false when the request
+// has none of the keys, which is every request while there are none.
+func (a *Authenticator) Authenticate(r *http.Request) (Key, bool) {
+	if a.disabled {
+		return Anonymous, true
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	presented := requestKey(r)
+	if presented == "" {
+		return Key{}, false
+	}
+	k, ok := a.keys[HashKey(presented)]
+	return k, ok
+}
+
+// requestKey returns the key a request presents
+func requestKey(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	if h := r.Header.Get("X-API-Key"); h != "" {
+		return h
+	}
+	if r.URL.Path == "/ws" {
+		return r.URL.Query().Get("api_key")
+	}
+	return ""
+}
+
+// Require lets through requests whose key has at least role: 401 without a
+// valid key and 403 with a lesser role
+func (a *Authenticator) Require(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k, ok := a.Authenticate(c.Request)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="rest-service"`)
+			payload.Error(c, http.StatusUnauthorized, "Missing or invalid API key")
+			return
+		}
+		if k.Role < role {
+			payload.Fail(c, http.StatusForbidden, payload.PermissionError,
+				fmt.Sprintf("API key %q is a %s, this needs a %s", k.Name, k.Role, role), nil)
+			return
+		}
+		c.Set(keyContextKey, k)
+		c.Next()
+	}
+}
+
+// Caller returns the key Require let a request through with
+func Caller(c *gin.Context) Key {
+	if k, ok := c.Get(keyContextKey); ok {
+		return k.(Key)
+	}
+	return Anonymous
+}
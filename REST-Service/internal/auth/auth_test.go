@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticate(t *testing.T) {
+	// Disabled, everyone is let through
+	k, ok := NewDisabledAuthenticator().Authenticate(httptest.NewRequest(http.MethodGet, "/orders", nil))
+	require.True(t, ok)
+	require.Equal(t, Anonymous, k)
+
+	// Without keys no one is
+	a := NewAuthenticator(nil)
+	require.True(t, a.Enabled())
+	_, ok = a.Authenticate(httptest.NewRequest(http.MethodGet, "/orders", nil))
+	require.False(t, ok)
+
+	hash, err := ParseKeyHash(hex.EncodeToString(sha("old-key")))
+	require.NoError(t, err)
+	a.SetKeys([]Key{{Name: "desk", Role: Trader, Hash: hash}})
+	require.True(t, a.Enabled())
+
+	request := func(path, header, value string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return req
+	}
+	cases := []struct {
+		req *http.Request
+		ok  bool
+	}{
+		{request("/orders", "", ""), false},
+		{request("/orders", "Authorization", "Bearer old-key"), true},
+		{request("/orders", "Authorization", "bearer old-key"), true},
+		{request("/orders", "Authorization", "Basic old-key"), false},
+		{request("/orders", "X-API-Key", "old-key"), true},
+		{request("/orders", "X-API-Key", "other-key"), false},
+		{request("/orders?api_key=old-key", "", ""), false},
+		{request("/ws?api_key=old-key", "", ""), true},
+	}
+	for _, tc := range cases {
+		k, ok := a.Authenticate(tc.req)
+		require.Equal(t, tc.ok, ok, tc.req.URL.String()+" "+tc.req.Header.Get("Authorization")+tc.req.Header.Get("X-API-Key"))
+		if ok {
+			require.Equal(t, "desk", k.Name)
+			require.Equal(t, Trader, k.Role)
+		}
+	}
+
+	// Rotated keys replace the old ones
+	a.SetKeys([]Key{{Name: "desk", Role: Trader, Hash: HashKey("new-key")}})
+	_, ok = a.Authenticate(request("/orders", "X-API-Key", "old-key"))
+	require.False(t, ok)
+	_, ok = a.Authenticate(request("/orders", "X-API-Key", "new-key"))
+	require.True(t, ok)
+}
+
+func sha(key string) []byte {
+	h := HashKey(key)
+	return h[:]
+}
+
+func TestParse(t *testing.T) {
+	role, err := ParseRole("admin")
+	require.NoError(t, err)
+	require.Equal(t, Admin, role)
+	require.True(t, Admin > Trader && Trader > Viewer)
+	_, err = ParseRole("root")
+	require.Error(t, err)
+
+	_, err = ParseKeyHash("abc")
+	require.Error(t, err)
+	_, err = ParseKeyHash("zz" + hex.EncodeToString(make([]byte, 31)))
+	require.Error(t, err)
+}
+
+func TestOrigins(t *testing.T) {
+	require.True(t, Origins(nil).Allows("https://anywhere.example.com"))
+	require.True(t, Origins{"*"}.Allows("https://anywhere.example.com"))
+
+	origins := Origins{"http://localhost:3000", "https://desk.example.com/"}
+	require.False(t, origins.Any())
+	require.True(t, origins.Allows("http://localhost:3000"))
+	require.True(t, origins.Allows("https://desk.example.com"))
+	require.True(t, origins.Allows(""))
+	require.False(t, origins.Allows("http://localhost:3001"))
+}
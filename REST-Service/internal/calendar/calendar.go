@@ -0,0 +1,352 @@
+// Package calendar provides an exchange trading calendar with holidays, session
+// times and expiry cutoffs, and converts time to expiry under several conventions.
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Convention selects how time to expiry is measured
+type Convention string
+
+const (
+	// ConventionCalendar counts wall-clock time until the expiry cutoff over a 365-day year
+	ConventionCalendar Convention = "calendar"
+	// ConventionTradingDays counts whole remaining sessions over a 252-day year
+	ConventionTradingDays Convention = "trading_days"
+	// ConventionTradingMinutes counts remaining session minutes, with non-trading
+	// days contributing a fraction of a session's variance
+	ConventionTradingMinutes Convention = "trading_minutes"
+
+	// TradingDaysPerYear is the number of sessions in a trading year
+	TradingDaysPerYear = 252.0
+	// CalendarDaysPerYear is the number of days in a calendar year
+	CalendarDaysPerYear = 365.0
+
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+)
+
+// IST is the exchange time zone
+var IST, _ = time.LoadLocation("Asia/Kolkata")
+
+// Holiday is a single exchange holiday
+type Holiday struct {
+	Date        string `json:"date"` // "YYYY-MM-DD"
+	Description string `json:"description"`
+}
+
+// Options configures a Calendar
+type Options struct {
+	SessionOpen           string     // "HH:MM" in IST, default "09:15"
+	SessionClose          string     // "HH:MM" in IST, default "15:30"
+	ExpiryCutoff          string     // "HH:MM" in IST, default "15:30"
+	Convention            Convention // Default ConventionCalendar
+	WeekendVarianceWeight float64    // Variance of a non-trading day relative to a session (trading_minutes only)
+	Holidays              []Holiday
+}
+
+// Calendar is an exchange trading calendar
+type Calendar struct {
+	loc           *time.Location
+	open          time.Duration // Offset from midnight
+	close         time.Duration
+	cutoff        time.Duration
+	convention    Convention
+	weekendWeight float64
+	holidays      map[string]string // "YYYY-MM-DD" -> description
+	mu            sync.RWMutex
+}
+
+var defaultCalendar = mustNew(Options{})
+
+// Default returns an NSE calendar with standard session times and no holidays
+func Default() *Calendar {
+	return defaultCalendar
+}
+
+// New creates a calendar from options, filling in NSE defaults
+func New(opts Options) (*Calendar, error) {
+	if opts.SessionOpen == "" {
+		opts.SessionOpen = "09:15"
+	}
+	if opts.SessionClose == "" {
+		opts.SessionClose = "15:30"
+	}
+	if opts.ExpiryCutoff == "" {
+		opts.ExpiryCutoff = opts.SessionClose
+	}
+	if opts.Convention == "" {
+		opts.Convention = ConventionCalendar
+	}
+
+	c := &Calendar{
+		loc:           IST,
+		convention:    opts.Convention,
+		weekendWeight: opts.WeekendVarianceWeight,
+		holidays:      make(map[string]string),
+	}
+
+	var err error
+	if c.open, err = parseClock(opts.SessionOpen); err != nil {
+		return nil, fmt.Errorf("session_open: %w", err)
+	}
+	if c.close, err = parseClock(opts.SessionClose); err != nil {
+		return nil, fmt.Errorf("session_close: %w", err)
+	}
+	if c.cutoff, err = parseClock(opts.ExpiryCutoff); err != nil {
+		return nil, fmt.Errorf("expiry_cutoff: %w", err)
+	}
+	if c.open >= c.close {
+		return nil, fmt.Errorf("session_open (%s) must be before session_close (%s)", opts.SessionOpen, opts.SessionClose)
+	}
+
+	switch c.convention {
+	case ConventionCalendar, ConventionTradingDays, ConventionTradingMinutes:
+	default:
+		return nil, fmt.Errorf("unknown time convention %q", c.convention)
+	}
+	if c.weekendWeight < 0 || c.weekendWeight > 1 {
+		return nil, fmt.Errorf("weekend_variance_weight (%.2f) must be between 0 and 1", c.weekendWeight)
+	}
+
+	if err := c.SetHolidays(opts.Holidays); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func mustNew(opts Options) *Calendar {
+	c, err := New(opts)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LoadHolidays reads a JSON holiday list: [{"date": "2025-12-25", "description": "Christmas"}, ...]
+func LoadHolidays(path string) ([]Holiday, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read holiday file: %w", err)
+	}
+
+	var holidays []Holiday
+	if err := json.Unmarshal(data, &holidays); err != nil {
+		return nil, fmt.Errorf("failed to parse holiday file: %w", err)
+	}
+
+	return holidays, nil
+}
+
+// SetHolidays replaces the holiday list
+func (c *Calendar) SetHolidays(holidays []Holiday) error {
+	set := make(map[string]string, len(holidays))
+	for _, h := range holidays {
+		if _, err := time.Parse(dateLayout, h.Date); err != nil {
+			return fmt.Errorf("invalid holiday date %q (must be YYYY-MM-DD)", h.Date)
+		}
+		set[h.Date] = h.Description
+	}
+
+	c.mu.Lock()
+	c.holidays = set
+	c.mu.Unlock()
+	return nil
+}
+
+// Holidays returns the number of holidays loaded
+func (c *Calendar) Holidays() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.holidays)
+}
+
+// Convention returns the time convention used by TimeToExpiry and DaysToExpiry
+func (c *Calendar) Convention() Convention {
+	return c.convention
+}
+
+// DaysPerYear returns the number of time-to-expiry days in a year under the
+// calendar's convention, used to express theta per day
+func (c *Calendar) DaysPerYear() float64 {
+	switch c.convention {
+	case ConventionTradingDays:
+		return TradingDaysPerYear
+	case ConventionTradingMinutes:
+		return TradingDaysPerYear + c.weekendWeight*(CalendarDaysPerYear-TradingDaysPerYear)
+	default:
+		return CalendarDaysPerYear
+	}
+}
+
+// Location returns the exchange time zone
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// IsHoliday reports whether the date of t is an exchange holiday
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.holidays[t.In(c.loc).Format(dateLayout)]
+	return ok
+}
+
+// IsTradingDay reports whether the date of t is a weekday that is not a holiday
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	t = t.In(c.loc)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.IsHoliday(t)
+}
+
+// IsMarketOpen reports whether t falls inside a trading session
+func (c *Calendar) IsMarketOpen(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+	day := c.midnight(t)
+	return !t.Before(day.Add(c.open)) && t.Before(day.Add(c.close))
+}
+
+// SessionOpen returns the session open time on the date of t
+func (c *Calendar) SessionOpen(t time.Time) time.Time {
+	return c.midnight(t).Add(c.open)
+}
+
+// SessionClose returns the session close time on the date of t
+func (c *Calendar) SessionClose(t time.Time) time.Time {
+	return c.midnight(t).Add(c.close)
+}
+
+// NextSessionOpen returns the open of the next session starting after t
+func (c *Calendar) NextSessionOpen(t time.Time) time.Time {
+	day := c.midnight(t)
+	for i := 0; i < 370; i++ {
+		open := day.Add(c.open)
+		if c.IsTradingDay(day) && open.After(t) {
+			return open
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.Add(c.open)
+}
+
+// ExpiryTime returns the moment a contract expiring on the date of expiry stops trading
+func (c *Calendar) ExpiryTime(expiry time.Time) time.Time {
+	return c.midnight(expiry).Add(c.cutoff)
+}
+
+// TimeToExpiry returns the time from now until the expiry cutoff in years,
+// measured with the calendar's convention. Expired contracts return 0.
+func (c *Calendar) TimeToExpiry(now, expiry time.Time) float64 {
+	end := c.ExpiryTime(expiry)
+	if !now.Before(end) {
+		return 0
+	}
+
+	switch c.convention {
+	case ConventionTradingDays:
+		return float64(c.remainingSessions(now, end)) / TradingDaysPerYear
+	case ConventionTradingMinutes:
+		session := (c.close - c.open).Minutes()
+		trading, idleDays := c.remainingMinutes(now, end)
+		return (trading/session + c.weekendWeight*idleDays) / c.DaysPerYear()
+	default:
+		return end.Sub(now).Hours() / (CalendarDaysPerYear * 24.0)
+	}
+}
+
+// DaysToExpiry returns the whole days left before expiry: calendar days for the
+// calendar convention, trading days otherwise. The expiry day itself counts as 0
+// until the cutoff; expired contracts return -1.
+func (c *Calendar) DaysToExpiry(now, expiry time.Time) int {
+	end := c.ExpiryTime(expiry)
+	if !now.Before(end) {
+		return -1
+	}
+
+	today := c.midnight(now)
+	expiryDay := c.midnight(expiry)
+
+	if c.convention == ConventionCalendar {
+		days := 0
+		for d := today; d.Before(expiryDay); d = d.AddDate(0, 0, 1) {
+			days++
+		}
+		return days
+	}
+
+	days := 0
+	for d := today.AddDate(0, 0, 1); !d.After(expiryDay); d = d.AddDate(0, 0, 1) {
+		if c.IsTradingDay(d) {
+			days++
+		}
+	}
+	return days
+}
+
+// remainingSessions counts sessions between now and end that have not yet closed
+func (c *Calendar) remainingSessions(now, end time.Time) int {
+	sessions := 0
+	for day := c.midnight(now); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !c.IsTradingDay(day) {
+			continue
+		}
+		closeAt := day.Add(c.close)
+		if closeAt.After(end) {
+			closeAt = end
+		}
+		if now.Before(closeAt) {
+			sessions++
+		}
+	}
+	return sessions
+}
+
+// remainingMinutes returns the session minutes between now and end, and the
+// number of non-trading days (weekends, holidays) in between
+func (c *Calendar) remainingMinutes(now, end time.Time) (minutes float64, idleDays float64) {
+	for day := c.midnight(now); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !c.IsTradingDay(day) {
+			if day.After(now) || day.Equal(now) {
+				idleDays++
+			}
+			continue
+		}
+
+		openAt, closeAt := day.Add(c.open), day.Add(c.close)
+		if closeAt.After(end) {
+			closeAt = end
+		}
+		if openAt.Before(now) {
+			openAt = now
+		}
+		if closeAt.After(openAt) {
+			minutes += closeAt.Sub(openAt).Minutes()
+		}
+	}
+	return minutes, idleDays
+}
+
+// midnight returns the start of the exchange day containing t
+func (c *Calendar) midnight(t time.Time) time.Time {
+	t = t.In(c.loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.loc)
+}
+
+// parseClock parses "HH:MM" into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (must be HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
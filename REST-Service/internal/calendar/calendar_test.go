@@ -0,0 +1,76 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func at(date string, clock string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, IST)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newTestCalendar(t *testing.T, convention Convention) *Calendar {
+	c, err := New(Options{
+		Convention:            convention,
+		WeekendVarianceWeight: 0.1,
+		Holidays:              []Holiday{{Date: "2025-12-25", Description: "Christmas"}},
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestExpiryCutoff(t *testing.T) {
+	c := newTestCalendar(t, ConventionCalendar)
+	expiry := at("2025-12-30", "00:00")
+
+	// Still alive on expiry morning, gone after 15:30
+	require.InDelta(t, 6.0/(365*24), c.TimeToExpiry(at("2025-12-30", "09:30"), expiry), 1e-12)
+	require.Equal(t, 0, c.DaysToExpiry(at("2025-12-30", "09:30"), expiry))
+	require.Equal(t, 0.0, c.TimeToExpiry(at("2025-12-30", "15:30"), expiry))
+	require.Equal(t, -1, c.DaysToExpiry(at("2025-12-30", "15:31"), expiry))
+}
+
+func TestTradingCalendar(t *testing.T) {
+	c := newTestCalendar(t, ConventionTradingDays)
+
+	require.False(t, c.IsTradingDay(at("2025-12-25", "10:00"))) // Holiday
+	require.False(t, c.IsTradingDay(at("2025-12-27", "10:00"))) // Saturday
+	require.True(t, c.IsMarketOpen(at("2025-12-26", "15:29")))
+	require.False(t, c.IsMarketOpen(at("2025-12-26", "15:30")))
+	require.Equal(t, at("2025-12-29", "09:15"), c.NextSessionOpen(at("2025-12-26", "16:00")))
+
+	// Wednesday 24th after close to Tuesday 30th: 26, 29, 30 (25th is a holiday)
+	expiry := at("2025-12-30", "00:00")
+	require.Equal(t, 3, c.DaysToExpiry(at("2025-12-24", "16:00"), expiry))
+	require.InDelta(t, 3.0/252, c.TimeToExpiry(at("2025-12-24", "16:00"), expiry), 1e-12)
+}
+
+func TestTradingMinutesWeekendWeight(t *testing.T) {
+	c := newTestCalendar(t, ConventionTradingMinutes)
+	expiry := at("2025-12-29", "00:00")
+
+	// Friday close to Monday cutoff: one full session plus two weighted weekend days
+	got := c.TimeToExpiry(at("2025-12-26", "15:30"), expiry)
+	require.InDelta(t, (1+2*0.1)/c.DaysPerYear(), got, 1e-12)
+
+	// Half a session left on expiry day
+	got = c.TimeToExpiry(at("2025-12-29", "12:22"), expiry)
+	require.InDelta(t, (188.0/375.0)/c.DaysPerYear(), got, 1e-12)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	_, err := New(Options{SessionOpen: "16:00"})
+	require.Error(t, err)
+
+	_, err = New(Options{Convention: "business_days"})
+	require.Error(t, err)
+
+	_, err = New(Options{Holidays: []Holiday{{Date: "25-12-2025"}}})
+	require.Error(t, err)
+}
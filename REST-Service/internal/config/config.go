@@ -1,35 +1,326 @@
 package config
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"time"
 
+	kiteconnect "gokiteconnect-master"
+
+	"rest-service/internal/arbitrage"
+	"rest-service/internal/auth"
+	"rest-service/internal/calendar"
+	"rest-service/internal/health"
 	"rest-service/internal/options"
+	"rest-service/internal/subscription"
+	kiteticker "rest-service/internal/ticker"
 )
 
 // Config holds the application configuration
 type Config struct {
 	Underlyings  []UnderlyingConfig `json:"underlyings"` // List of underlying configurations
 	Subscription SubscriptionConfig `json:"subscription"`
+	Ticker       TickerConfig       `json:"ticker"`
+	Health       HealthConfig       `json:"health"`
+	Kite         KiteConfig         `json:"kite"`
+	Pricing      PricingConfig      `json:"pricing"` // Default pricing for underlyings without their own
+	QuoteQuality QuoteQualityConfig `json:"quote_quality"`
+	Calendar     CalendarConfig     `json:"calendar"`
+
+	InstrumentRefresh InstrumentRefreshConfig `json:"instrument_refresh"`
+	InstrumentMaster  InstrumentMasterConfig  `json:"instrument_master"`
+
+	Arbitrage      ArbitrageConfig `json:"arbitrage"`
+	OISnapshotFile string          `json:"oi_snapshot_file,omitempty"` // Closing OI saved for next session's OI change, default "oi_snapshot.json"
+
+	Auth AuthConfig `json:"auth"`
 }
 
 // UnderlyingConfig holds filter criteria for a specific underlying
 type UnderlyingConfig struct {
-	Underlying      string   `json:"underlying"`            // Underlying symbol (e.g., "NIFTY", "DIXON")
-	OptionType      *string  `json:"option_type,omitempty"` // "CE", "PE", or empty for both
-	Expiry          *string  `json:"expiry,omitempty"`      // "YYYY-MM-DD" format or empty for all
-	MinStrike       *float64 `json:"min_strike,omitempty"`
-	MaxStrike       *float64 `json:"max_strike,omitempty"`
-	MinDaysToExpiry int      `json:"min_days_to_expiry"`
-	MaxDaysToExpiry int      `json:"max_days_to_expiry"`
+	Underlying      string        `json:"underlying"`            // Underlying symbol (e.g., "NIFTY", "DIXON")
+	OptionType      *string       `json:"option_type,omitempty"` // "CE", "PE", or empty for both
+	Expiry          *string       `json:"expiry,omitempty"`      // "YYYY-MM-DD" format or empty for all
+	MinStrike       *float64      `json:"min_strike,omitempty"`
+	MaxStrike       *float64      `json:"max_strike,omitempty"`
+	Window          *WindowConfig `json:"window,omitempty"` // Strikes around the underlying price, applied within min/max strike
+	MinDaysToExpiry int           `json:"min_days_to_expiry"`
+	MaxDaysToExpiry int           `json:"max_days_to_expiry"`
+
+	Pricing *PricingConfig `json:"pricing,omitempty"` // Overrides the default pricing for this underlying
+	Spot    *SpotConfig    `json:"spot,omitempty"`    // Overrides the spot instrument the underlying resolves to
+}
+
+// WindowConfig selects strikes relative to the underlying price. The window
+// follows the underlying as it moves; set limits combine.
+type WindowConfig struct {
+	Strikes     int     `json:"strikes,omitempty"`      // Strikes each side of ATM
+	RangePct    float64 `json:"range_pct,omitempty"`    // Distance from the underlying price, e.g. 0.03 for ±3%
+	MinDelta    float64 `json:"min_delta,omitempty"`    // Absolute delta band, e.g. 0.05
+	MaxDelta    float64 `json:"max_delta,omitempty"`    // e.g. 0.95
+	Volatility  float64 `json:"volatility,omitempty"`   // For the delta band before an option has an IV, default 0.2
+	RecenterPct float64 `json:"recenter_pct,omitempty"` // Underlying move before the window follows it, default 0.0025
+}
+
+// SpotConfig identifies the index or equity an underlying's derivatives settle on
+type SpotConfig struct {
+	Exchange      string `json:"exchange"`      // "NSE" or "BSE"
+	Tradingsymbol string `json:"tradingsymbol"` // e.g. "NIFTY BANK"
+}
+
+// PricingConfig selects the option pricing model and rates
+type PricingConfig struct {
+	Model         string   `json:"model"`                    // "bsm" (default), "black76" or "black76_synthetic"
+	RiskFreeRate  *float64 `json:"risk_free_rate,omitempty"` // Annual rate, e.g. 0.065 for 6.5%
+	DividendYield *float64 `json:"dividend_yield,omitempty"` // Annual continuous yield (bsm only)
 }
 
 // SubscriptionConfig holds subscription settings
 type SubscriptionConfig struct {
 	BatchSize    int `json:"batch_size"`     // Number of tokens per batch
 	BatchDelayMs int `json:"batch_delay_ms"` // Delay between batches in milliseconds
+
+	RecenterIntervalMs int `json:"recenter_interval_ms,omitempty"` // How often strike windows check the underlying, default 1000
+
+	Modes ModeConfig `json:"modes"`
+}
+
+// ModeConfig holds the streaming mode policy. Options near ATM stream in full,
+// the mid wings in quote mode and the far wings in LTP mode. Viewed and
+// position tokens always stream in full.
+type ModeConfig struct {
+	Enabled                 bool    `json:"enabled"`                             // Disabled streams everything in full
+	FullStrikes             int     `json:"full_strikes,omitempty"`              // Strikes each side of ATM in full mode, default 5
+	QuoteStrikes            int     `json:"quote_strikes,omitempty"`             // Strikes each side of ATM in quote mode, default 15
+	RecenterPct             float64 `json:"recenter_pct,omitempty"`              // Underlying move before modes are re-evaluated, default 0.0025
+	PositionsRefreshSeconds int     `json:"positions_refresh_seconds,omitempty"` // How often open positions are fetched, default 60
+}
+
+// QuoteQualityConfig holds the thresholds used to flag option quotes
+type QuoteQualityConfig struct {
+	StaleAfterSeconds int     `json:"stale_after_seconds"` // Quote age after which it is flagged stale
+	MaxSpreadPct      float64 `json:"max_spread_pct"`      // Spread as a fraction of mid, e.g. 0.1 for 10%
+}
+
+// CalendarConfig holds the exchange calendar and time-to-expiry settings
+type CalendarConfig struct {
+	HolidaysFile          string  `json:"holidays_file,omitempty"`           // JSON list of {"date", "description"}
+	SessionOpen           string  `json:"session_open,omitempty"`            // "HH:MM" IST, default "09:15"
+	SessionClose          string  `json:"session_close,omitempty"`           // "HH:MM" IST, default "15:30"
+	ExpiryCutoff          string  `json:"expiry_cutoff,omitempty"`           // "HH:MM" IST, default session close
+	TimeConvention        string  `json:"time_convention,omitempty"`         // "calendar" (default), "trading_days" or "trading_minutes"
+	WeekendVarianceWeight float64 `json:"weekend_variance_weight,omitempty"` // Variance of a non-trading day relative to a session, trading_minutes only
+}
+
+// InstrumentRefreshConfig holds the daily instrument master refresh, which
+// drops expired contracts and picks up new expiries before the session opens
+type InstrumentRefreshConfig struct {
+	Enabled           bool `json:"enabled"`
+	BeforeOpenMinutes int  `json:"before_open_minutes,omitempty"` // Minutes before the session open, default 30
+}
+
+// TickerConfig holds how subscriptions are spread over Kite ticker connections
+type TickerConfig struct {
+	Connections         int `json:"connections,omitempty"`           // Default 3, the Kite limit per API key
+	TokensPerConnection int `json:"tokens_per_connection,omitempty"` // Default 3000, the Kite limit per connection
+}
+
+// HealthConfig holds the readiness and tick staleness monitoring
+type HealthConfig struct {
+	StaleAfterSeconds    int    `json:"stale_after_seconds,omitempty"`    // A subscribed token without a tick for this long is stale, default 60
+	CheckIntervalSeconds int    `json:"check_interval_seconds,omitempty"` // Default 10
+	StaleAction          string `json:"stale_action,omitempty"`           // "none", "resubscribe" or "reconnect", default "none"
+	SessionCheckMinutes  int    `json:"session_check_minutes,omitempty"`  // How often the Kite session is validated, default 5
+	// Share of a connection's tokens that must be stale before the reconnect
+	// action drops it, default 0.5
+	ReconnectStaleFraction float64 `json:"reconnect_stale_fraction,omitempty"`
+}
+
+// KiteConfig holds the rate limits and retries of the Kite REST client
+type KiteConfig struct {
+	RateLimits map[string]RateLimitConfig `json:"rate_limits,omitempty"` // By class: quote, historical, orders or default; unset classes keep the Kite limits
+	MaxRetries *int                       `json:"max_retries,omitempty"` // Retries of failed reads and rate limited requests, default 3
+}
+
+// RateLimitConfig is the token bucket of a class of Kite endpoints
+type RateLimitConfig struct {
+	PerSecond float64 `json:"per_second"`
+	Burst     int     `json:"burst,omitempty"` // Default per_second rounded up
+}
+
+// ToRateLimits converts the configured rate limits, defaulting the burst
+func (kc *KiteConfig) ToRateLimits() map[string]kiteconnect.RateLimit {
+	limits := make(map[string]kiteconnect.RateLimit, len(kc.RateLimits))
+	for class, limit := range kc.RateLimits {
+		burst := limit.Burst
+		if burst == 0 {
+			burst = int(math.Ceil(limit.PerSecond))
+		}
+		limits[class] = kiteconnect.RateLimit{Rate: limit.PerSecond, Burst: burst}
+	}
+	return limits
+}
+
+// ToRetryPolicy converts the configured retries
+func (kc *KiteConfig) ToRetryPolicy() kiteconnect.RetryPolicy {
+	policy := kiteconnect.DefaultRetryPolicy
+	if kc.MaxRetries != nil {
+		policy.MaxRetries = *kc.MaxRetries
+	}
+	return policy
+}
+
+// AuthConfig holds the API keys of REST and /ws clients and the browser
+// origins allowed to call the API
+type AuthConfig struct {
+	APIKeys        []APIKeyConfig `json:"api_keys,omitempty"`
+	Disabled       bool           `json:"disabled,omitempty"`        // Required to run without api_keys; the API is then served on 127.0.0.1 only. Read at startup
+	AllowedOrigins []string       `json:"allowed_origins,omitempty"` // CORS and /ws allowlist, e.g. "http://localhost:3000"; empty or "*" allows any, unless disabled. Read at startup
+}
+
+// APIKeyConfig is an API key and its role. Only the key's SHA-256 is kept,
+// e.g. from `printf %s "$KEY" | sha256sum`.
+type APIKeyConfig struct {
+	Name   string `json:"name"`
+	Role   string `json:"role"` // "viewer" (market data), "trader" (account and orders) or "admin" (config and kill switch)
+	SHA256 string `json:"sha256"`
+}
+
+// Validate checks the API keys, and that there are some unless
+// authentication is disabled. Without authentication any web page could
+// drive the API from the operator's browser, so disabling it takes an
+// explicit origin allowlist.
+func (ac *AuthConfig) Validate() error {
+	keys, err := ac.ToKeys()
+	if err != nil {
+		return err
+	}
+	switch {
+	case ac.Disabled && len(keys) > 0:
+		return fmt.Errorf("api_keys are configured but disabled is set")
+	case !ac.Disabled && len(keys) == 0:
+		return fmt.Errorf("no api_keys configured; set disabled and allowed_origins to run without authentication on 127.0.0.1")
+	case ac.Disabled && auth.Origins(ac.AllowedOrigins).Any():
+		return fmt.Errorf("disabled needs allowed_origins to list the browser origins, without \"*\"")
+	}
+	return nil
+}
+
+// ToKeys converts the configured API keys
+func (ac *AuthConfig) ToKeys() ([]auth.Key, error) {
+	keys := make([]auth.Key, 0, len(ac.APIKeys))
+	seen := make(map[[sha256.Size]byte]bool)
+	for i, k := range ac.APIKeys {
+		if k.Name == "" {
+			return nil, fmt.Errorf("api_keys[%d]: name cannot be empty", i)
+		}
+		role, err := auth.ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api_keys[%d] (%s): %w", i, k.Name, err)
+		}
+		hash, err := auth.ParseKeyHash(k.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("api_keys[%d] (%s): %w", i, k.Name, err)
+		}
+		if seen[hash] {
+			return nil, fmt.Errorf("api_keys[%d] (%s): key configured more than once", i, k.Name)
+		}
+		seen[hash] = true
+		keys = append(keys, auth.Key{Name: k.Name, Role: role, Hash: hash})
+	}
+	return keys, nil
+}
+
+// InstrumentMasterConfig holds where versions of the instrument master are
+// kept for offline startup and incremental sync
+type InstrumentMasterConfig struct {
+	Dir  string `json:"dir,omitempty"`  // Default "instruments"
+	Keep int    `json:"keep,omitempty"` // Versions kept, default 7
+}
+
+// ArbitrageConfig holds the arbitrage scanner settings
+type ArbitrageConfig struct {
+	Cash    CashArbitrageConfig    `json:"cash"`
+	Options OptionsArbitrageConfig `json:"options"`
+}
+
+// CashArbitrageConfig holds the NSE-BSE cash arbitrage scanner settings
+type CashArbitrageConfig struct {
+	Enabled           bool     `json:"enabled"`
+	Symbols           []string `json:"symbols"`                       // Stocks to pair; empty pairs every dual-listed stock
+	Product           string   `json:"product,omitempty"`             // "intraday" (default) or "delivery", selects the charges
+	MinNetPct         float64  `json:"min_net_pct"`                   // Net of charges, as a fraction of turnover
+	HistorySize       int      `json:"history_size,omitempty"`        // Spread events kept, default 1000
+	PublishIntervalMs int      `json:"publish_interval_ms,omitempty"` // How often ranked opportunities are pushed on /ws, default 1000
+	MaxQuoteAgeMs     int      `json:"max_quote_age_ms,omitempty"`    // Pairs with an older leg are not traded, default 5000
+}
+
+// OptionsArbitrageConfig holds the option no-arbitrage detector settings
+type OptionsArbitrageConfig struct {
+	Enabled         bool    `json:"enabled"`
+	MinNetEdge      float64 `json:"min_net_edge"`                // Per lot, after charges
+	FutureMarginPct float64 `json:"future_margin_pct,omitempty"` // Futures margin as a fraction of notional, default 0.12
+	HistorySize     int     `json:"history_size,omitempty"`      // Opportunity events kept, default 1000
+	ScanIntervalMs  int     `json:"scan_interval_ms,omitempty"`  // How often the chains are scanned and results pushed on /ws, default 1000
+	MaxQuoteAgeMs   int     `json:"max_quote_age_ms,omitempty"`  // Legs whose bid and ask are older are skipped, default 5000
+}
+
+// ToOptionsParams converts OptionsArbitrageConfig to arbitrage.OptionsParams,
+// discounting at the given risk-free rate
+func (oc *OptionsArbitrageConfig) ToOptionsParams(riskFreeRate float64) arbitrage.OptionsParams {
+	return arbitrage.OptionsParams{
+		RiskFreeRate:    riskFreeRate,
+		MinNetEdge:      oc.MinNetEdge,
+		FutureMarginPct: oc.FutureMarginPct,
+		OptionCharges:   arbitrage.OptionCharges(),
+		FutureCharges:   arbitrage.FutureCharges(),
+		HistorySize:     oc.HistorySize,
+		MaxQuoteAge:     time.Duration(oc.MaxQuoteAgeMs) * time.Millisecond,
+	}
+}
+
+// ToModeRules converts ModeConfig to subscription.ModeRules
+func (mc *ModeConfig) ToModeRules() subscription.ModeRules {
+	return subscription.ModeRules{
+		Enabled:      mc.Enabled,
+		FullStrikes:  mc.FullStrikes,
+		QuoteStrikes: mc.QuoteStrikes,
+		RecenterPct:  mc.RecenterPct,
+	}
+}
+
+// ToCashParams converts CashArbitrageConfig to arbitrage.CashParams
+func (ac *CashArbitrageConfig) ToCashParams() arbitrage.CashParams {
+	charges := arbitrage.IntradayEquityCharges()
+	if ac.Product == "delivery" {
+		charges = arbitrage.DeliveryEquityCharges()
+	}
+	return arbitrage.CashParams{
+		Charges:     charges,
+		MinNetPct:   ac.MinNetPct,
+		HistorySize: ac.HistorySize,
+		MaxQuoteAge: time.Duration(ac.MaxQuoteAgeMs) * time.Millisecond,
+	}
+}
+
+// SaveConfig writes the configuration to a JSON file, replacing it atomically
+func SaveConfig(configPath string, config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp, configPath); err != nil {
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
+	return nil
 }
 
 // LoadConfig loads configuration from a JSON file
@@ -50,23 +341,24 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 
 	// Validate each underlying config
+	seen := make(map[string]bool)
 	for i, uc := range config.Underlyings {
 		if uc.Underlying == "" {
 			return nil, fmt.Errorf("underlying[%d]: underlying name cannot be empty", i)
 		}
-		if uc.MinDaysToExpiry < 0 || uc.MaxDaysToExpiry < 0 {
-			return nil, fmt.Errorf("underlying[%d] (%s): days to expiry cannot be negative", i, uc.Underlying)
-		}
-		if uc.MinDaysToExpiry > uc.MaxDaysToExpiry {
-			return nil, fmt.Errorf("underlying[%d] (%s): min_days_to_expiry (%d) cannot be greater than max_days_to_expiry (%d)",
-				i, uc.Underlying, uc.MinDaysToExpiry, uc.MaxDaysToExpiry)
+		if seen[uc.Underlying] {
+			return nil, fmt.Errorf("underlying[%d] (%s): configured more than once", i, uc.Underlying)
 		}
-		if uc.MinStrike != nil && uc.MaxStrike != nil && *uc.MinStrike > *uc.MaxStrike {
-			return nil, fmt.Errorf("underlying[%d] (%s): min_strike (%.2f) cannot be greater than max_strike (%.2f)",
-				i, uc.Underlying, *uc.MinStrike, *uc.MaxStrike)
+		seen[uc.Underlying] = true
+		if err := uc.Validate(); err != nil {
+			return nil, fmt.Errorf("underlying[%d] (%s): %w", i, uc.Underlying, err)
 		}
 	}
 
+	if err := config.Pricing.validate(); err != nil {
+		return nil, fmt.Errorf("pricing: %w", err)
+	}
+
 	// Set defaults
 	if config.Subscription.BatchSize == 0 {
 		config.Subscription.BatchSize = 100
@@ -74,10 +366,167 @@ func LoadConfig(configPath string) (*Config, error) {
 	if config.Subscription.BatchDelayMs == 0 {
 		config.Subscription.BatchDelayMs = 100
 	}
+	if config.Subscription.RecenterIntervalMs == 0 {
+		config.Subscription.RecenterIntervalMs = 1000
+	}
+	if modes := &config.Subscription.Modes; modes.FullStrikes < 0 || modes.QuoteStrikes < 0 || modes.RecenterPct < 0 {
+		return nil, fmt.Errorf("subscription.modes: values cannot be negative")
+	}
+	if config.Subscription.Modes.FullStrikes == 0 {
+		config.Subscription.Modes.FullStrikes = 5
+	}
+	if config.Subscription.Modes.QuoteStrikes == 0 {
+		config.Subscription.Modes.QuoteStrikes = 15
+	}
+	if config.Subscription.Modes.QuoteStrikes < config.Subscription.Modes.FullStrikes {
+		return nil, fmt.Errorf("subscription.modes: quote_strikes cannot be less than full_strikes")
+	}
+	if config.Subscription.Modes.RecenterPct == 0 {
+		config.Subscription.Modes.RecenterPct = options.DefaultRecenterPct
+	}
+	if config.Subscription.Modes.PositionsRefreshSeconds == 0 {
+		config.Subscription.Modes.PositionsRefreshSeconds = 60
+	}
+	if config.Ticker.Connections < 0 || config.Ticker.TokensPerConnection < 0 {
+		return nil, fmt.Errorf("ticker: connections and tokens_per_connection cannot be negative")
+	}
+	if config.Ticker.Connections == 0 {
+		config.Ticker.Connections = kiteticker.DefaultConnections
+	}
+	if config.Ticker.TokensPerConnection == 0 {
+		config.Ticker.TokensPerConnection = kiteticker.DefaultTokensPerConnection
+	}
+	if h := &config.Health; h.StaleAfterSeconds < 0 || h.CheckIntervalSeconds < 0 || h.SessionCheckMinutes < 0 {
+		return nil, fmt.Errorf("health: stale_after_seconds, check_interval_seconds and session_check_minutes cannot be negative")
+	}
+	if config.Health.StaleAfterSeconds == 0 {
+		config.Health.StaleAfterSeconds = 60
+	}
+	if config.Health.CheckIntervalSeconds == 0 {
+		config.Health.CheckIntervalSeconds = 10
+	}
+	if config.Health.SessionCheckMinutes == 0 {
+		config.Health.SessionCheckMinutes = 5
+	}
+	if config.Health.ReconnectStaleFraction == 0 {
+		config.Health.ReconnectStaleFraction = health.DefaultReconnectFraction
+	}
+	if f := config.Health.ReconnectStaleFraction; f < 0 || f > 1 {
+		return nil, fmt.Errorf("health: reconnect_stale_fraction must be between 0 and 1, got %v", f)
+	}
+	action, err := health.ParseStaleAction(config.Health.StaleAction)
+	if err != nil {
+		return nil, fmt.Errorf("health: %w", err)
+	}
+	config.Health.StaleAction = string(action)
+	for class, limit := range config.Kite.RateLimits {
+		switch class {
+		case kiteconnect.RateClassQuote, kiteconnect.RateClassHistorical, kiteconnect.RateClassOrders, kiteconnect.RateClassDefault:
+		default:
+			return nil, fmt.Errorf("kite.rate_limits: unknown class %q, must be quote, historical, orders or default", class)
+		}
+		if limit.PerSecond <= 0 || limit.Burst < 0 {
+			return nil, fmt.Errorf("kite.rate_limits.%s: per_second must be positive and burst cannot be negative", class)
+		}
+	}
+	if config.Kite.MaxRetries != nil && *config.Kite.MaxRetries < 0 {
+		return nil, fmt.Errorf("kite: max_retries cannot be negative")
+	}
+	if config.InstrumentRefresh.BeforeOpenMinutes < 0 {
+		return nil, fmt.Errorf("instrument_refresh: before_open_minutes cannot be negative")
+	}
+	if config.InstrumentRefresh.BeforeOpenMinutes == 0 {
+		config.InstrumentRefresh.BeforeOpenMinutes = 30
+	}
+	if config.InstrumentMaster.Keep < 0 {
+		return nil, fmt.Errorf("instrument_master: keep cannot be negative")
+	}
+	if config.InstrumentMaster.Dir == "" {
+		config.InstrumentMaster.Dir = "instruments"
+	}
+	if config.InstrumentMaster.Keep == 0 {
+		config.InstrumentMaster.Keep = 7
+	}
+	if p := config.Arbitrage.Cash.Product; p != "" && p != "intraday" && p != "delivery" {
+		return nil, fmt.Errorf("arbitrage.cash: product must be intraday or delivery, got %q", p)
+	}
+	if config.Arbitrage.Cash.PublishIntervalMs == 0 {
+		config.Arbitrage.Cash.PublishIntervalMs = 1000
+	}
+	if config.Arbitrage.Cash.MaxQuoteAgeMs < 0 {
+		return nil, fmt.Errorf("arbitrage.cash: max_quote_age_ms cannot be negative")
+	}
+	if config.Arbitrage.Cash.MaxQuoteAgeMs == 0 {
+		config.Arbitrage.Cash.MaxQuoteAgeMs = 5000
+	}
+	if config.Arbitrage.Options.FutureMarginPct < 0 || config.Arbitrage.Options.FutureMarginPct > 1 {
+		return nil, fmt.Errorf("arbitrage.options: future_margin_pct must be between 0 and 1")
+	}
+	if config.Arbitrage.Options.FutureMarginPct == 0 {
+		config.Arbitrage.Options.FutureMarginPct = 0.12
+	}
+	if config.Arbitrage.Options.MaxQuoteAgeMs < 0 {
+		return nil, fmt.Errorf("arbitrage.options: max_quote_age_ms cannot be negative")
+	}
+	if config.Arbitrage.Options.MaxQuoteAgeMs == 0 {
+		config.Arbitrage.Options.MaxQuoteAgeMs = 5000
+	}
+	if config.Arbitrage.Options.ScanIntervalMs == 0 {
+		config.Arbitrage.Options.ScanIntervalMs = 1000
+	}
+	if config.OISnapshotFile == "" {
+		config.OISnapshotFile = "oi_snapshot.json"
+	}
+	if config.QuoteQuality.StaleAfterSeconds < 0 || config.QuoteQuality.MaxSpreadPct < 0 {
+		return nil, fmt.Errorf("quote_quality: thresholds cannot be negative")
+	}
+	if err := config.Auth.Validate(); err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
 
 	return &config, nil
 }
 
+// Validate checks an underlying's filter, pricing and spot settings
+func (uc *UnderlyingConfig) Validate() error {
+	if uc.Underlying == "" {
+		return fmt.Errorf("underlying name cannot be empty")
+	}
+	if uc.MinDaysToExpiry < 0 || uc.MaxDaysToExpiry < 0 {
+		return fmt.Errorf("days to expiry cannot be negative")
+	}
+	if uc.MinDaysToExpiry > uc.MaxDaysToExpiry {
+		return fmt.Errorf("min_days_to_expiry (%d) cannot be greater than max_days_to_expiry (%d)",
+			uc.MinDaysToExpiry, uc.MaxDaysToExpiry)
+	}
+	if uc.MinStrike != nil && uc.MaxStrike != nil && *uc.MinStrike > *uc.MaxStrike {
+		return fmt.Errorf("min_strike (%.2f) cannot be greater than max_strike (%.2f)",
+			*uc.MinStrike, *uc.MaxStrike)
+	}
+	if uc.Window != nil {
+		if err := uc.Window.validate(); err != nil {
+			return fmt.Errorf("window: %w", err)
+		}
+	}
+	if uc.Pricing != nil {
+		if err := uc.Pricing.validate(); err != nil {
+			return err
+		}
+	}
+	if uc.Spot != nil {
+		if uc.Spot.Exchange != "NSE" && uc.Spot.Exchange != "BSE" {
+			return fmt.Errorf("spot exchange must be NSE or BSE, got %q", uc.Spot.Exchange)
+		}
+		if uc.Spot.Tradingsymbol == "" {
+			return fmt.Errorf("spot tradingsymbol cannot be empty")
+		}
+	}
+	if _, err := uc.ToFilterCriteria(); err != nil {
+		return err
+	}
+	return nil
+}
+
 // ToFilterCriteria converts UnderlyingConfig to options.FilterCriteria
 func (uc *UnderlyingConfig) ToFilterCriteria() (options.FilterCriteria, error) {
 	criteria := options.FilterCriteria{
@@ -108,6 +557,17 @@ func (uc *UnderlyingConfig) ToFilterCriteria() (options.FilterCriteria, error) {
 	criteria.MinStrike = uc.MinStrike
 	criteria.MaxStrike = uc.MaxStrike
 
+	if w := uc.Window; w != nil {
+		criteria.Window = &options.StrikeWindow{
+			Strikes:     w.Strikes,
+			RangePct:    w.RangePct,
+			MinDelta:    w.MinDelta,
+			MaxDelta:    w.MaxDelta,
+			Volatility:  w.Volatility,
+			RecenterPct: w.RecenterPct,
+		}
+	}
+
 	return criteria, nil
 }
 
@@ -125,3 +585,129 @@ func (c *Config) GetAllFilterCriteria() ([]options.FilterCriteria, error) {
 
 	return allCriteria, nil
 }
+
+// validate checks that a window has a limit and its values are in range
+func (wc *WindowConfig) validate() error {
+	if wc.Strikes < 0 || wc.RangePct < 0 || wc.Volatility < 0 || wc.RecenterPct < 0 {
+		return fmt.Errorf("values cannot be negative")
+	}
+	if wc.Strikes == 0 && wc.RangePct == 0 && wc.MinDelta == 0 && wc.MaxDelta == 0 {
+		return fmt.Errorf("set at least one of strikes, range_pct, min_delta or max_delta")
+	}
+	if wc.MinDelta < 0 || wc.MaxDelta < 0 || wc.MinDelta > 1 || wc.MaxDelta > 1 {
+		return fmt.Errorf("delta band must be between 0 and 1")
+	}
+	if wc.MaxDelta > 0 && wc.MinDelta > wc.MaxDelta {
+		return fmt.Errorf("min_delta (%.2f) cannot be greater than max_delta (%.2f)", wc.MinDelta, wc.MaxDelta)
+	}
+	return nil
+}
+
+// validate checks the model name and rate ranges
+func (pc *PricingConfig) validate() error {
+	if _, err := options.ParsePricingModel(pc.Model); err != nil {
+		return err
+	}
+	if pc.RiskFreeRate != nil && (*pc.RiskFreeRate < 0 || *pc.RiskFreeRate > 1) {
+		return fmt.Errorf("risk_free_rate (%.4f) must be between 0 and 1", *pc.RiskFreeRate)
+	}
+	if pc.DividendYield != nil && (*pc.DividendYield < 0 || *pc.DividendYield > 1) {
+		return fmt.Errorf("dividend_yield (%.4f) must be between 0 and 1", *pc.DividendYield)
+	}
+	return nil
+}
+
+// ToPricingParams converts PricingConfig to options.PricingParams, taking unset
+// fields from base
+func (pc *PricingConfig) ToPricingParams(base options.PricingParams) (options.PricingParams, error) {
+	params := base
+
+	if pc.Model != "" {
+		model, err := options.ParsePricingModel(pc.Model)
+		if err != nil {
+			return params, err
+		}
+		params.Model = model
+	}
+	if pc.RiskFreeRate != nil {
+		params.RiskFreeRate = *pc.RiskFreeRate
+	}
+	if pc.DividendYield != nil {
+		params.DividendYield = *pc.DividendYield
+	}
+
+	return params, nil
+}
+
+// GetPricingParams returns the default pricing parameters and the per-underlying overrides
+func (c *Config) GetPricingParams() (options.PricingParams, map[string]options.PricingParams, error) {
+	defaults, err := c.Pricing.ToPricingParams(options.PricingParams{
+		Model:        options.ModelBSM,
+		RiskFreeRate: options.DefaultRiskFreeRate,
+	})
+	if err != nil {
+		return defaults, nil, err
+	}
+
+	overrides := make(map[string]options.PricingParams)
+	for _, uc := range c.Underlyings {
+		if uc.Pricing == nil {
+			continue
+		}
+		params, err := uc.Pricing.ToPricingParams(defaults)
+		if err != nil {
+			return defaults, nil, fmt.Errorf("%s: %w", uc.Underlying, err)
+		}
+		overrides[uc.Underlying] = params
+	}
+
+	return defaults, overrides, nil
+}
+
+// ToQualityParams converts QuoteQualityConfig to options.QualityParams, using
+// the defaults for unset thresholds
+func (qc *QuoteQualityConfig) ToQualityParams() options.QualityParams {
+	params := options.DefaultQualityParams()
+	if qc.StaleAfterSeconds > 0 {
+		params.StaleAfter = time.Duration(qc.StaleAfterSeconds) * time.Second
+	}
+	if qc.MaxSpreadPct > 0 {
+		params.MaxSpreadPct = qc.MaxSpreadPct
+	}
+	return params
+}
+
+// NewCalendar builds the trading calendar, loading the holiday file if configured
+func (cc *CalendarConfig) NewCalendar() (*calendar.Calendar, error) {
+	opts := calendar.Options{
+		SessionOpen:           cc.SessionOpen,
+		SessionClose:          cc.SessionClose,
+		ExpiryCutoff:          cc.ExpiryCutoff,
+		Convention:            calendar.Convention(cc.TimeConvention),
+		WeekendVarianceWeight: cc.WeekendVarianceWeight,
+	}
+
+	if cc.HolidaysFile != "" {
+		holidays, err := calendar.LoadHolidays(cc.HolidaysFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Holidays = holidays
+	}
+
+	return calendar.New(opts)
+}
+
+// GetSpotOverrides returns the configured spot instruments by underlying
+func (c *Config) GetSpotOverrides() map[string]options.SpotRef {
+	overrides := make(map[string]options.SpotRef)
+	for _, uc := range c.Underlyings {
+		if uc.Spot != nil {
+			overrides[uc.Underlying] = options.SpotRef{
+				Exchange:      uc.Spot.Exchange,
+				Tradingsymbol: uc.Spot.Tradingsymbol,
+			}
+		}
+	}
+	return overrides
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrUnderlyingExists and ErrUnderlyingNotFound are returned by the runtime
+// underlying updates
+var (
+	ErrUnderlyingExists   = errors.New("underlying already configured")
+	ErrUnderlyingNotFound = errors.New("underlying not configured")
+)
+
+// Live holds the running configuration. Underlyings can be changed at runtime,
+// in which case the file is rewritten, and edits to the file are picked up by
+// Watch. Every accepted change is passed to the OnChange callbacks.
+type Live struct {
+	path     string
+	config   *Config
+	modTime  time.Time
+	onChange []func(prev, next *Config)
+	mu       sync.Mutex
+	changeMu sync.Mutex // Serializes changes so callbacks see them in order
+}
+
+// NewLive wraps a configuration loaded from path
+func NewLive(path string, config *Config) *Live {
+	l := &Live{path: path, config: config}
+	if info, err := os.Stat(path); err == nil {
+		l.modTime = info.ModTime()
+	}
+	return l
+}
+
+// OnChange registers a callback run after each accepted change, with the lock
+// released. Callbacks must not modify either config.
+func (l *Live) OnChange(f func(prev, next *Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onChange = append(l.onChange, f)
+}
+
+// Get returns the current configuration. It must not be modified.
+func (l *Live) Get() *Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.config
+}
+
+// Underlying returns the configuration of one underlying
+func (l *Live) Underlying(name string) (UnderlyingConfig, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, uc := range l.config.Underlyings {
+		if uc.Underlying == name {
+			return uc, true
+		}
+	}
+	return UnderlyingConfig{}, false
+}
+
+// AddUnderlying adds an underlying and persists the configuration
+func (l *Live) AddUnderlying(uc UnderlyingConfig) error {
+	if err := uc.Validate(); err != nil {
+		return err
+	}
+	return l.update(func(c *Config) error {
+		for _, existing := range c.Underlyings {
+			if existing.Underlying == uc.Underlying {
+				return ErrUnderlyingExists
+			}
+		}
+		c.Underlyings = append(c.Underlyings, uc)
+		return nil
+	})
+}
+
+// UpdateUnderlying replaces an underlying's configuration and persists it
+func (l *Live) UpdateUnderlying(uc UnderlyingConfig) error {
+	if err := uc.Validate(); err != nil {
+		return err
+	}
+	return l.update(func(c *Config) error {
+		for i, existing := range c.Underlyings {
+			if existing.Underlying == uc.Underlying {
+				c.Underlyings[i] = uc
+				return nil
+			}
+		}
+		return ErrUnderlyingNotFound
+	})
+}
+
+// RemoveUnderlying removes an underlying and persists the configuration
+func (l *Live) RemoveUnderlying(name string) error {
+	return l.update(func(c *Config) error {
+		for i, existing := range c.Underlyings {
+			if existing.Underlying == name {
+				if len(c.Underlyings) == 1 {
+					return fmt.Errorf("at least one underlying must be specified")
+				}
+				c.Underlyings = append(c.Underlyings[:i], c.Underlyings[i+1:]...)
+				return nil
+			}
+		}
+		return ErrUnderlyingNotFound
+	})
+}
+
+// update applies f to a copy of the configuration, saves it and swaps it in
+func (l *Live) update(f func(c *Config) error) error {
+	l.changeMu.Lock()
+	defer l.changeMu.Unlock()
+
+	l.mu.Lock()
+	old := l.config
+	next := *old
+	next.Underlyings = append([]UnderlyingConfig(nil), old.Underlyings...)
+	if err := f(&next); err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	if err := SaveConfig(l.path, &next); err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	if info, err := os.Stat(l.path); err == nil {
+		l.modTime = info.ModTime()
+	}
+	l.config = &next
+	callbacks := l.onChange
+	l.mu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(old, &next)
+	}
+	return nil
+}
+
+// Reload loads the file if it changed since it was last read or written. An
+// invalid file is reported and the running configuration kept.
+func (l *Live) Reload() (bool, error) {
+	l.changeMu.Lock()
+	defer l.changeMu.Unlock()
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	if !info.ModTime().After(l.modTime) {
+		l.mu.Unlock()
+		return false, nil
+	}
+	l.modTime = info.ModTime()
+	l.mu.Unlock()
+
+	next, err := LoadConfig(l.path)
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	old := l.config
+	if reflect.DeepEqual(old, next) {
+		l.mu.Unlock()
+		return false, nil
+	}
+	// Turning authentication off would need a restart to also bind to
+	// 127.0.0.1, so a reload can't remove every key
+	if next.Auth.Disabled != old.Auth.Disabled {
+		l.mu.Unlock()
+		return false, fmt.Errorf("auth: disabled can only be changed with a restart")
+	}
+	l.config = next
+	callbacks := l.onChange
+	l.mu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(old, next)
+	}
+	return true, nil
+}
+
+// Watch polls the file for changes until stop is closed
+func (l *Live) Watch(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			changed, err := l.Reload()
+			if err != nil {
+				log.Printf("Config reload failed, keeping the running config: %v", err)
+			} else if changed {
+				log.Printf("Reloaded %s", l.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testAuth configures one API key
+const testAuth = `"auth": {"api_keys": [{"name": "desk", "role": "admin", "sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}]}`
+
+func TestLiveUnderlyings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"underlyings": [{"underlying": "NIFTY", "max_days_to_expiry": 30}], `+testAuth+`}`), 0644))
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	live := NewLive(path, cfg)
+	changes := 0
+	live.OnChange(func(prev, next *Config) { changes++ })
+
+	require.NoError(t, live.AddUnderlying(UnderlyingConfig{Underlying: "BANKNIFTY", MaxDaysToExpiry: 7}))
+	require.ErrorIs(t, live.AddUnderlying(UnderlyingConfig{Underlying: "BANKNIFTY"}), ErrUnderlyingExists)
+	require.Error(t, live.AddUnderlying(UnderlyingConfig{Underlying: "FINNIFTY", MinDaysToExpiry: 5, MaxDaysToExpiry: 1}))
+	require.Len(t, cfg.Underlyings, 1) // The previous config is not modified
+
+	saved, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, saved.Underlyings, 2)
+
+	require.NoError(t, live.RemoveUnderlying("NIFTY"))
+	require.ErrorIs(t, live.RemoveUnderlying("NIFTY"), ErrUnderlyingNotFound)
+	require.Error(t, live.RemoveUnderlying("BANKNIFTY")) // Last one
+	require.Equal(t, 2, changes)
+
+	// Our own writes are not reloaded, external edits are
+	changed, err := live.Reload()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	edit := func(content string, after time.Duration) (bool, error) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		future := time.Now().Add(after)
+		require.NoError(t, os.Chtimes(path, future, future))
+		return live.Reload()
+	}
+	changed, err = edit(`{"underlyings": [{"underlying": "SENSEX"}], `+testAuth+`}`, time.Minute)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "SENSEX", live.Get().Underlyings[0].Underlying)
+	require.Equal(t, 3, changes)
+
+	// A reload can't remove every key and so turn authentication off
+	_, err = edit(`{"underlyings": [{"underlying": "SENSEX"}]}`, 2*time.Minute)
+	require.Error(t, err)
+	_, err = edit(`{"underlyings": [{"underlying": "SENSEX"}], "auth": {"disabled": true, "allowed_origins": ["http://localhost:3000"]}}`, 3*time.Minute)
+	require.Error(t, err)
+	require.False(t, live.Get().Auth.Disabled)
+	require.Len(t, live.Get().Auth.APIKeys, 1)
+	require.Equal(t, 3, changes)
+}
+
+func TestAuthConfigValidate(t *testing.T) {
+	origins := []string{"http://localhost:3000"}
+	require.Error(t, (&AuthConfig{}).Validate())
+	require.NoError(t, (&AuthConfig{Disabled: true, AllowedOrigins: origins}).Validate())
+
+	// Without keys the origins are all that keeps other sites out
+	require.Error(t, (&AuthConfig{Disabled: true}).Validate())
+	require.Error(t, (&AuthConfig{Disabled: true, AllowedOrigins: []string{"*"}}).Validate())
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"rest-service/internal/calendar"
+	kiteticker "rest-service/internal/ticker"
+)
+
+// TickerCheck is ready when at least one ticker connection is up and every
+// connection holding tokens is
+func TickerCheck(shards func() []kiteticker.ShardStatus) CheckFunc {
+	return func(time.Time) (bool, string) {
+		up := 0
+		var down []int
+		for _, shard := range shards() {
+			if shard.Connected {
+				up++
+			} else if shard.Tokens > 0 {
+				down = append(down, shard.ID)
+			}
+		}
+		if len(down) > 0 {
+			return false, fmt.Sprintf("ticker connections %v holding tokens are down", down)
+		}
+		if up == 0 {
+			return false, "no ticker connection is up"
+		}
+		return true, ""
+	}
+}
+
+// InstrumentsCheck is ready when the instruments are loaded and, once the
+// session has opened on a trading day, were loaded that day, so new expiries
+// and contracts are listed
+func InstrumentsCheck(loadedAt func() time.Time, cal *calendar.Calendar) CheckFunc {
+	return func(now time.Time) (bool, string) {
+		loaded := loadedAt()
+		if loaded.IsZero() {
+			return false, "instruments not loaded"
+		}
+		if cal.IsTradingDay(now) && !now.Before(cal.SessionOpen(now)) {
+			day := now.In(cal.Location())
+			midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, cal.Location())
+			if loaded.Before(midnight) {
+				return false, "instruments last loaded " + loaded.In(cal.Location()).Format(time.RFC3339) + ", before today's session"
+			}
+		}
+		return true, ""
+	}
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rest-service/internal/calendar"
+	kiteticker "rest-service/internal/ticker"
+
+	kiteconnect "gokiteconnect-master"
+
+	"github.com/stretchr/testify/require"
+)
+
+var ist, _ = time.LoadLocation("Asia/Kolkata")
+
+type fakeFeed struct {
+	tokens       []uint32
+	conns        map[int][]uint32
+	resubscribed []uint32
+	reconnected  []uint32
+}
+
+func (f *fakeFeed) Tokens() []uint32 { return append([]uint32(nil), f.tokens...) }
+
+func (f *fakeFeed) Resubscribe(tokens []uint32) error {
+	f.resubscribed = append(f.resubscribed, tokens...)
+	return nil
+}
+
+func (f *fakeFeed) Reconnect(tokens []uint32) []int {
+	f.reconnected = append(f.reconnected, tokens...)
+	return []int{0}
+}
+
+// Connections puts every token on connection 0 unless conns is set
+func (f *fakeFeed) Connections() map[int][]uint32 {
+	if f.conns != nil {
+		return f.conns
+	}
+	return map[int][]uint32{0: f.Tokens()}
+}
+
+type fakeTicks map[uint32]time.Time
+
+func (f fakeTicks) LastUpdate(token uint32) (time.Time, bool) {
+	t, ok := f[token]
+	return t, ok
+}
+
+func TestStalenessMonitor(t *testing.T) {
+	open := time.Date(2026, 10, 19, 9, 15, 0, 0, ist) // Monday
+	feed := &fakeFeed{tokens: []uint32{1, 2, 3}}
+	ticks := fakeTicks{
+		1: open.Add(50 * time.Second),
+		2: open.Add(-18 * time.Hour), // Last ticked the previous session
+	}
+	m := NewStalenessMonitor(feed, ticks, calendar.Default(), time.Minute, StaleActionResubscribe, 0)
+
+	// Nothing is stale before the open or within the threshold after it
+	require.Empty(t, m.Check(open.Add(-time.Hour)).Stale)
+	require.Empty(t, m.Check(open.Add(30*time.Second)).Stale)
+
+	report := m.Check(open.Add(90 * time.Second))
+	require.True(t, report.MarketOpen)
+	require.Len(t, report.Stale, 2)
+	require.Equal(t, uint32(2), report.Stale[0].InstrumentToken)
+	require.NotNil(t, report.Stale[0].LastUpdate)
+	require.Equal(t, 90.0, report.Stale[0].StaleSeconds) // Since the open, not yesterday's tick
+	require.Equal(t, uint32(3), report.Stale[1].InstrumentToken)
+	require.Nil(t, report.Stale[1].LastUpdate)
+	require.Equal(t, []uint32{2, 3}, feed.resubscribed)
+	require.Equal(t, report, m.Report())
+
+	// Stale tokens are acted on once per threshold; a token subscribed
+	// mid-session gets the threshold from when it was first seen
+	feed.tokens = append(feed.tokens, 4)
+	ticks[1] = open.Add(100 * time.Second)
+	report = m.Check(open.Add(120 * time.Second))
+	require.Len(t, report.Stale, 2)
+	require.Empty(t, report.Resubscribed)
+
+	report = m.Check(open.Add(190 * time.Second))
+	require.Len(t, report.Stale, 4)
+	require.Equal(t, []uint32{1, 2, 3, 4}, report.Resubscribed)
+
+	// Reconnect drops the connections instead
+	m = NewStalenessMonitor(feed, ticks, calendar.Default(), time.Minute, StaleActionReconnect, 0)
+	require.Empty(t, m.Check(open.Add(4*time.Minute)).Stale)
+	report = m.Check(open.Add(5 * time.Minute))
+	require.Equal(t, []int{0}, report.Reconnected)
+	require.Len(t, feed.reconnected, 4)
+
+	// but only once enough of a connection's tokens are stale: one illiquid
+	// token does not drop the connection it shares with live ones
+	feed = &fakeFeed{tokens: []uint32{1, 2, 3, 4, 5}, conns: map[int][]uint32{0: {1, 2, 3}, 1: {4, 5}}}
+	live := open.Add(9*time.Minute + 30*time.Second)
+	ticks = fakeTicks{1: live, 2: live, 4: live}
+	m = NewStalenessMonitor(feed, ticks, calendar.Default(), time.Minute, StaleActionReconnect, 0.5)
+	require.Empty(t, m.Check(open.Add(9*time.Minute)).Stale)
+	report = m.Check(open.Add(10 * time.Minute))
+	require.Len(t, report.Stale, 2)
+	require.Equal(t, []int{0}, report.Held)
+	require.Equal(t, []uint32{5}, feed.reconnected)
+
+	ticks[2] = open.Add(-time.Hour)
+	report = m.Check(open.Add(10*time.Minute + 10*time.Second))
+	require.Empty(t, report.Held)
+	require.Equal(t, []uint32{5, 2, 3}, feed.reconnected) // 5 was acted on within the threshold
+
+	_, err := ParseStaleAction("restart")
+	require.Error(t, err)
+}
+
+func TestReadiness(t *testing.T) {
+	cal := calendar.Default()
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, ist)
+
+	shards := []kiteticker.ShardStatus{{ID: 0, Tokens: 10, Connected: true}, {ID: 1}}
+	loadedAt := time.Date(2026, 10, 19, 8, 45, 0, 0, ist)
+	session := NewSessionMonitor(func() error { return nil })
+
+	r := NewReadiness()
+	r.Add("ticker", TickerCheck(func() []kiteticker.ShardStatus { return shards }))
+	r.Add("session", session.Ready)
+	r.Add("instruments", InstrumentsCheck(func() time.Time { return loadedAt }, cal))
+
+	status := r.Check(now)
+	require.False(t, status.Ready)
+	require.Equal(t, Check{Name: "session", Message: "session not checked yet"}, status.Checks[1])
+
+	require.NoError(t, session.Refresh(now))
+	require.True(t, r.Check(now).Ready)
+
+	// An idle connection may be down, one holding tokens may not
+	shards[1].Tokens = 5
+	status = r.Check(now)
+	require.False(t, status.Checks[0].OK)
+	require.Contains(t, status.Checks[0].Message, "[1]")
+	shards[1].Connected = true
+
+	// Yesterday's instruments are stale once today's session opens
+	loadedAt = loadedAt.AddDate(0, 0, -1)
+	require.True(t, r.Check(time.Date(2026, 10, 19, 9, 0, 0, 0, ist)).Ready)
+	require.False(t, r.Check(now).Ready)
+	loadedAt = loadedAt.AddDate(0, 0, 1)
+
+	session = NewSessionMonitor(func() error {
+		return kiteconnect.NewError(kiteconnect.TokenError, "Incorrect `api_key` or `access_token`.", nil)
+	})
+	session.Refresh(now)
+	ok, message := session.Ready(now)
+	require.False(t, ok)
+	require.Equal(t, "session invalid: Incorrect `api_key` or `access_token`.", message)
+
+	session = NewSessionMonitor(func() error { return errors.New("dial tcp: timeout") })
+	session.Refresh(now)
+	ok, message = session.Ready(now)
+	require.False(t, ok)
+	require.Contains(t, message, "dial tcp: timeout")
+}
@@ -0,0 +1,64 @@
+// Package health reports whether the service is ready to serve: the ticker
+// connections are up, the Kite session is valid and the instruments are
+// current. It also watches subscribed tokens for ticks that stop arriving.
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Check is the result of one readiness check
+type Check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Status is the result of every readiness check
+type Status struct {
+	Ready     bool      `json:"ready"`
+	Checks    []Check   `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// CheckFunc reports whether a dependency is ready at now, and why not
+type CheckFunc func(now time.Time) (ok bool, message string)
+
+// Readiness runs named checks; the service is ready when all pass
+type Readiness struct {
+	names  []string
+	checks []CheckFunc
+	mu     sync.Mutex
+}
+
+// NewReadiness creates a Readiness without checks
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// Add adds a check
+func (r *Readiness) Add(name string, check CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, name)
+	r.checks = append(r.checks, check)
+}
+
+// Check runs every check
+func (r *Readiness) Check(now time.Time) Status {
+	r.mu.Lock()
+	names := append([]string(nil), r.names...)
+	checks := append([]CheckFunc(nil), r.checks...)
+	r.mu.Unlock()
+
+	status := Status{Ready: true, Checks: make([]Check, len(checks)), CheckedAt: now}
+	for i, check := range checks {
+		ok, message := check(now)
+		status.Checks[i] = Check{Name: names[i], OK: ok, Message: message}
+		if !ok {
+			status.Ready = false
+		}
+	}
+	return status
+}
@@ -0,0 +1,77 @@
+package health
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+)
+
+// SessionMonitor checks the Kite session periodically, so readiness probes
+// never call the API themselves
+type SessionMonitor struct {
+	check     func() error
+	err       error
+	checked   bool
+	checkedAt time.Time
+	mu        sync.Mutex
+}
+
+// NewSessionMonitor creates a monitor that validates the session with check,
+// such as fetching the user profile
+func NewSessionMonitor(check func() error) *SessionMonitor {
+	return &SessionMonitor{check: check}
+}
+
+// Refresh validates the session now
+func (m *SessionMonitor) Refresh(now time.Time) error {
+	err := m.check()
+
+	m.mu.Lock()
+	prev := m.err
+	m.err, m.checked, m.checkedAt = err, true, now
+	m.mu.Unlock()
+
+	if err != nil && (prev == nil || prev.Error() != err.Error()) {
+		log.Printf("Kite session check failed: %v", err)
+	}
+	return err
+}
+
+// Run validates the session every interval until stop is closed
+func (m *SessionMonitor) Run(interval time.Duration, stop <-chan struct{}) {
+	m.Refresh(time.Now())
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-t.C:
+			m.Refresh(now)
+		}
+	}
+}
+
+// Ready is the readiness check of the session. A token or permission error
+// means the session has to be renewed; other errors, such as the API being
+// unreachable, are reported as they are.
+func (m *SessionMonitor) Ready(time.Time) (bool, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.checked {
+		return false, "session not checked yet"
+	}
+	if m.err == nil {
+		return true, ""
+	}
+	var kerr kiteconnect.Error
+	if errors.As(m.err, &kerr) && (kerr.ErrorType == kiteconnect.TokenError || kerr.ErrorType == kiteconnect.PermissionError) {
+		return false, "session invalid: " + kerr.Message
+	}
+	return false, "session check failed at " + m.checkedAt.Format(time.RFC3339) + ": " + m.err.Error()
+}
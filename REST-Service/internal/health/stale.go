@@ -0,0 +1,257 @@
+package health
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"rest-service/internal/calendar"
+)
+
+// StaleAction is what the staleness monitor does about stale tokens
+type StaleAction string
+
+const (
+	// StaleActionNone only reports stale tokens
+	StaleActionNone StaleAction = "none"
+	// StaleActionResubscribe unsubscribes and subscribes stale tokens again
+	StaleActionResubscribe StaleAction = "resubscribe"
+	// StaleActionReconnect drops the ticker connections on which at least the
+	// reconnect fraction of the tokens are stale
+	StaleActionReconnect StaleAction = "reconnect"
+)
+
+// ParseStaleAction parses a stale action, "" being none
+func ParseStaleAction(s string) (StaleAction, error) {
+	switch a := StaleAction(s); a {
+	case "":
+		return StaleActionNone, nil
+	case StaleActionNone, StaleActionResubscribe, StaleActionReconnect:
+		return a, nil
+	}
+	return "", fmt.Errorf("stale action must be none, resubscribe or reconnect, got %q", s)
+}
+
+// Feed is the ticker whose subscriptions the monitor watches
+type Feed interface {
+	Tokens() []uint32
+	Resubscribe(tokens []uint32) error
+	Reconnect(tokens []uint32) []int
+	Connections() map[int][]uint32 // Subscribed tokens by connection ID
+}
+
+// DefaultReconnectFraction is the share of a connection's tokens that must be
+// stale before StaleActionReconnect drops it
+const DefaultReconnectFraction = 0.5
+
+// Ticks tells when a token last ticked, as store.TickStore does
+type Ticks interface {
+	LastUpdate(token uint32) (time.Time, bool)
+}
+
+// StaleToken is a subscribed token without a tick within the threshold
+type StaleToken struct {
+	InstrumentToken uint32     `json:"instrument_token"`
+	LastUpdate      *time.Time `json:"last_update"`   // Nil if it never ticked
+	StaleSeconds    float64    `json:"stale_seconds"` // Since the last tick, the session open or the subscription, whichever is latest
+}
+
+// StaleReport is the result of a staleness check
+type StaleReport struct {
+	CheckedAt    time.Time    `json:"checked_at"`
+	MarketOpen   bool         `json:"market_open"`
+	StaleAfter   float64      `json:"stale_after_seconds"`
+	Subscribed   int          `json:"subscribed"`
+	Stale        []StaleToken `json:"stale"`
+	Action       StaleAction  `json:"action"`
+	Resubscribed []uint32     `json:"resubscribed,omitempty"`
+	Reconnected  []int        `json:"reconnected,omitempty"`    // Ticker connection IDs
+	Held         []int        `json:"reconnect_held,omitempty"` // Connections with stale tokens below the reconnect fraction
+}
+
+// StalenessMonitor flags subscribed tokens without a tick within a threshold
+// during market hours. Until the threshold has passed since the open, or
+// since a token was subscribed, nothing is flagged. Each stale token is acted
+// on at most once per threshold. Reconnecting only drops a connection when
+// at least fraction of its tokens are stale, so that a few illiquid tokens do
+// not drop thousands of live ones.
+type StalenessMonitor struct {
+	feed     Feed
+	ticks    Ticks
+	cal      *calendar.Calendar
+	after    time.Duration
+	action   StaleAction
+	fraction float64
+
+	seen  map[uint32]time.Time // When each token was first seen subscribed
+	acted map[uint32]time.Time // When each token was last acted on
+	last  StaleReport
+	mu    sync.Mutex
+}
+
+// NewStalenessMonitor creates a monitor flagging tokens after the given
+// duration without a tick. A non-positive fraction takes
+// DefaultReconnectFraction.
+func NewStalenessMonitor(feed Feed, ticks Ticks, cal *calendar.Calendar, after time.Duration, action StaleAction, fraction float64) *StalenessMonitor {
+	if fraction <= 0 {
+		fraction = DefaultReconnectFraction
+	}
+	return &StalenessMonitor{
+		feed:     feed,
+		ticks:    ticks,
+		cal:      cal,
+		after:    after,
+		action:   action,
+		fraction: fraction,
+		seen:     make(map[uint32]time.Time),
+		acted:    make(map[uint32]time.Time),
+		last:     StaleReport{Stale: []StaleToken{}, Action: action, StaleAfter: after.Seconds()},
+	}
+}
+
+// Check flags the stale tokens at now and acts on them
+func (m *StalenessMonitor) Check(now time.Time) StaleReport {
+	tokens := m.feed.Tokens()
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	var conns map[int][]uint32
+	if m.action == StaleActionReconnect {
+		conns = m.feed.Connections()
+	}
+
+	m.mu.Lock()
+	current := make(map[uint32]bool, len(tokens))
+	for _, token := range tokens {
+		current[token] = true
+		if _, ok := m.seen[token]; !ok {
+			m.seen[token] = now
+		}
+	}
+	for token := range m.seen {
+		if !current[token] {
+			delete(m.seen, token)
+			delete(m.acted, token)
+		}
+	}
+
+	report := StaleReport{
+		CheckedAt:  now,
+		MarketOpen: m.cal.IsMarketOpen(now),
+		StaleAfter: m.after.Seconds(),
+		Subscribed: len(tokens),
+		Stale:      []StaleToken{},
+		Action:     m.action,
+	}
+	var due []uint32
+	if report.MarketOpen {
+		open := m.cal.SessionOpen(now)
+		for _, token := range tokens {
+			since := open
+			if seen := m.seen[token]; seen.After(since) {
+				since = seen
+			}
+			stale := StaleToken{InstrumentToken: token}
+			if last, ok := m.ticks.LastUpdate(token); ok {
+				stale.LastUpdate = &last
+				if last.After(since) {
+					since = last
+				}
+			}
+			if now.Sub(since) < m.after {
+				continue
+			}
+			stale.StaleSeconds = now.Sub(since).Seconds()
+			report.Stale = append(report.Stale, stale)
+		}
+
+		var held map[uint32]bool
+		if conns != nil {
+			held, report.Held = m.held(conns, report.Stale)
+		}
+		for _, stale := range report.Stale {
+			token := stale.InstrumentToken
+			if held[token] {
+				continue
+			}
+			if acted, ok := m.acted[token]; !ok || now.Sub(acted) >= m.after {
+				m.acted[token] = now
+				due = append(due, token)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	if len(due) > 0 {
+		switch m.action {
+		case StaleActionResubscribe:
+			if err := m.feed.Resubscribe(due); err != nil {
+				log.Printf("Resubscribing %d stale tokens failed: %v", len(due), err)
+			} else {
+				log.Printf("Resubscribed %d stale tokens", len(due))
+			}
+			report.Resubscribed = due
+		case StaleActionReconnect:
+			report.Reconnected = m.feed.Reconnect(due)
+			log.Printf("Reconnecting ticker connections %v for %d stale tokens", report.Reconnected, len(due))
+		}
+	}
+
+	m.mu.Lock()
+	m.last = report
+	m.mu.Unlock()
+	return report
+}
+
+// held returns the stale tokens on connections where fewer than the
+// reconnect fraction of the tokens are stale, and the IDs of those
+// connections
+func (m *StalenessMonitor) held(conns map[int][]uint32, stale []StaleToken) (map[uint32]bool, []int) {
+	isStale := make(map[uint32]bool, len(stale))
+	for _, s := range stale {
+		isStale[s.InstrumentToken] = true
+	}
+
+	held := make(map[uint32]bool)
+	var ids []int
+	for id, tokens := range conns {
+		var count int
+		for _, token := range tokens {
+			if isStale[token] {
+				count++
+			}
+		}
+		if count == 0 || float64(count) >= m.fraction*float64(len(tokens)) {
+			continue
+		}
+		for _, token := range tokens {
+			if isStale[token] {
+				held[token] = true
+			}
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return held, ids
+}
+
+// Report returns the result of the last check
+func (m *StalenessMonitor) Report() StaleReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Run checks every interval until stop is closed
+func (m *StalenessMonitor) Run(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-t.C:
+			m.Check(now)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package killswitch halts order entry across the service. While engaged,
+// orders can't be placed or modified; cancels still go through so positions
+// can be closed down.
+package killswitch
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// State is whether the switch is engaged, and who last changed it
+type State struct {
+	Engaged bool       `json:"engaged"`
+	Reason  string     `json:"reason,omitempty"`
+	By      string     `json:"by,omitempty"` // Name of the API key
+	At      *time.Time `json:"at,omitempty"`
+}
+
+// Switch is the kill switch. The zero value is released.
+type Switch struct {
+	mu    sync.RWMutex
+	state State
+}
+
+// Set engages or releases the switch
+func (s *Switch) Set(engaged bool, reason, by string, now time.Time) State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = State{Engaged: engaged, Reason: reason, By: by, At: &now}
+	if engaged {
+		log.Printf("Kill switch engaged by %s: %s", by, reason)
+	} else {
+		log.Printf("Kill switch released by %s", by)
+	}
+	return s.state
+}
+
+// State returns the current state
+func (s *Switch) State() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Engaged reports whether order entry is halted
+func (s *Switch) Engaged() bool {
+	return s.State().Engaged
+}
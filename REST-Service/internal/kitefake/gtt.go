@@ -0,0 +1,252 @@
+package kitefake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+)
+
+// GTT statuses.
+const (
+	gttActive    = "active"
+	gttTriggered = "triggered"
+	gttRejected  = "rejected"
+	gttDeleted   = "deleted"
+)
+
+// gtt is a GTT trigger as Kite returns it.
+type gtt struct {
+	ID        int          `json:"id"`
+	UserID    string       `json:"user_id"`
+	Type      string       `json:"type"`
+	CreatedAt string       `json:"created_at"`
+	UpdatedAt string       `json:"updated_at"`
+	ExpiresAt string       `json:"expires_at"`
+	Status    string       `json:"status"`
+	Condition gttCondition `json:"condition"`
+	Orders    []gttOrder   `json:"orders"`
+	Meta      *gttMeta     `json:"meta"`
+}
+
+type gttCondition struct {
+	Exchange        string    `json:"exchange"`
+	Tradingsymbol   string    `json:"tradingsymbol"`
+	InstrumentToken uint32    `json:"instrument_token"`
+	LastPrice       float64   `json:"last_price"`
+	TriggerValues   []float64 `json:"trigger_values"`
+}
+
+type gttOrder struct {
+	Exchange        string     `json:"exchange"`
+	TradingSymbol   string     `json:"tradingsymbol"`
+	TransactionType string     `json:"transaction_type"`
+	Quantity        float64    `json:"quantity"`
+	Price           float64    `json:"price"`
+	OrderType       string     `json:"order_type"`
+	Product         string     `json:"product"`
+	Result          *gttResult `json:"result"`
+}
+
+// gttResult is the order a trigger placed.
+type gttResult struct {
+	OrderResult struct {
+		OrderID         string `json:"order_id"`
+		Status          string `json:"status"`
+		RejectionReason string `json:"rejection_reason"`
+	} `json:"order_result"`
+	Timestamp   string  `json:"timestamp"`
+	TriggeredAt float64 `json:"triggered_at"`
+}
+
+type gttMeta struct {
+	RejectionReason string `json:"rejection_reason"`
+}
+
+// gttParams parses and validates the trigger of a place or modify request:
+// a single trigger either side of the last price, or two-leg triggers
+// around it.
+func (s *Server) gttParams(r *http.Request) (string, gttCondition, []gttOrder, error) {
+	var (
+		kind      = r.Form.Get("type")
+		condition gttCondition
+		orders    []gttOrder
+	)
+	if err := json.Unmarshal([]byte(r.Form.Get("condition")), &condition); err != nil {
+		return "", condition, nil, inputError("Invalid `condition`.")
+	}
+	if err := json.Unmarshal([]byte(r.Form.Get("orders")), &orders); err != nil {
+		return "", condition, nil, inputError("Invalid `orders`.")
+	}
+	in, ok := s.bySymbol[condition.Exchange+":"+condition.Tradingsymbol]
+	if !ok || in.index {
+		return "", condition, nil, inputError("Invalid `tradingsymbol`.")
+	}
+	condition.InstrumentToken = in.token
+	if condition.LastPrice <= 0 {
+		condition.LastPrice = in.last
+	}
+
+	triggers := condition.TriggerValues
+	switch kind {
+	case string(kiteconnect.GTTTypeSingle):
+		if len(triggers) != 1 || len(orders) != 1 || triggers[0] == condition.LastPrice {
+			return "", condition, nil, inputError("A single trigger needs one trigger value away from the last price and one order.")
+		}
+	case string(kiteconnect.GTTTypeOCO):
+		if len(triggers) != 2 || len(orders) != 2 || triggers[0] >= condition.LastPrice || triggers[1] <= condition.LastPrice {
+			return "", condition, nil, inputError("A two-leg trigger needs trigger values below and above the last price and two orders.")
+		}
+	default:
+		return "", condition, nil, inputError("Invalid `type`.")
+	}
+	for _, o := range orders {
+		if o.Exchange != in.exchange || o.TradingSymbol != in.symbol || o.Quantity <= 0 || o.Price <= 0 {
+			return "", condition, nil, inputError("GTT orders must be limit orders of the trigger's instrument.")
+		}
+	}
+	return kind, condition, orders, nil
+}
+
+func (s *Server) getGTTs(r *http.Request, _ []string) (interface{}, error) {
+	out := []gtt{}
+	for _, g := range s.gtts {
+		if g.Status != gttDeleted {
+			out = append(out, *g)
+		}
+	}
+	return out, nil
+}
+
+// findGTT returns the trigger of a request's ID.
+func (s *Server) findGTT(id string) (*gtt, error) {
+	for _, g := range s.gtts {
+		if strconv.Itoa(g.ID) == id && g.Status != gttDeleted {
+			return g, nil
+		}
+	}
+	return nil, notFound("Couldn't find that `trigger_id`.")
+}
+
+func (s *Server) getGTT(r *http.Request, params []string) (interface{}, error) {
+	g, err := s.findGTT(params[0])
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (s *Server) placeGTT(r *http.Request, _ []string) (interface{}, error) {
+	kind, condition, orders, err := s.gttParams(r)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	s.gttSeq++
+	g := &gtt{
+		ID:        s.gttSeq,
+		UserID:    UserID,
+		Type:      kind,
+		CreatedAt: kiteTime(now),
+		UpdatedAt: kiteTime(now),
+		ExpiresAt: kiteTime(now.AddDate(1, 0, 0)),
+		Status:    gttActive,
+		Condition: condition,
+		Orders:    orders,
+	}
+	s.gtts = append(s.gtts, g)
+	return map[string]int{"trigger_id": g.ID}, nil
+}
+
+func (s *Server) modifyGTT(r *http.Request, params []string) (interface{}, error) {
+	g, err := s.findGTT(params[0])
+	if err != nil {
+		return nil, err
+	}
+	if g.Status != gttActive {
+		return nil, inputError("Only active triggers can be modified, this one is %s.", g.Status)
+	}
+	kind, condition, orders, err := s.gttParams(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Type, g.Condition, g.Orders = kind, condition, orders
+	g.UpdatedAt = kiteTime(s.now())
+	return map[string]int{"trigger_id": g.ID}, nil
+}
+
+func (s *Server) deleteGTT(r *http.Request, params []string) (interface{}, error) {
+	g, err := s.findGTT(params[0])
+	if err != nil {
+		return nil, err
+	}
+	g.Status = gttDeleted
+	g.UpdatedAt = kiteTime(s.now())
+	return map[string]int{"trigger_id": g.ID}, nil
+}
+
+// triggerGTTs places the order of each active trigger the last price has
+// crossed. A single trigger fires when the price reaches it from the side
+// it was placed on; a two-leg trigger fires the lower leg at or below its
+// value and the upper leg at or above.
+func (s *Server) triggerGTTs(now time.Time) {
+	for _, g := range s.gtts {
+		if g.Status != gttActive {
+			continue
+		}
+		in := s.byToken[g.Condition.InstrumentToken]
+		triggers := g.Condition.TriggerValues
+
+		leg := -1
+		switch {
+		case g.Type == string(kiteconnect.GTTTypeOCO) && in.last <= triggers[0]:
+			leg = 0
+		case g.Type == string(kiteconnect.GTTTypeOCO) && in.last >= triggers[1]:
+			leg = 1
+		case g.Type == string(kiteconnect.GTTTypeSingle) && triggers[0] > g.Condition.LastPrice && in.last >= triggers[0]:
+			leg = 0
+		case g.Type == string(kiteconnect.GTTTypeSingle) && triggers[0] < g.Condition.LastPrice && in.last <= triggers[0]:
+			leg = 0
+		}
+		if leg < 0 {
+			continue
+		}
+
+		lo := &g.Orders[leg]
+		product := lo.Product
+		if product == "" {
+			product = kiteconnect.ProductCNC
+		}
+		result := &gttResult{Timestamp: kiteTime(now), TriggeredAt: in.last}
+		o, err := s.place(orderParams{
+			variety:         kiteconnect.VarietyRegular,
+			exchange:        lo.Exchange,
+			tradingsymbol:   lo.TradingSymbol,
+			transactionType: lo.TransactionType,
+			orderType:       kiteconnect.OrderTypeLimit,
+			product:         product,
+			quantity:        int(lo.Quantity),
+			price:           lo.Price,
+		}, now)
+		switch {
+		case err != nil:
+			g.Status = gttRejected
+			g.Meta = &gttMeta{RejectionReason: err.Error()}
+			result.OrderResult.Status = "failed"
+			result.OrderResult.RejectionReason = err.Error()
+		case o.Status == kiteconnect.OrderStatusRejected:
+			g.Status = gttTriggered
+			result.OrderResult.OrderID = o.OrderID
+			result.OrderResult.Status = "failed"
+			result.OrderResult.RejectionReason = o.StatusMessage
+		default:
+			g.Status = gttTriggered
+			result.OrderResult.OrderID = o.OrderID
+			result.OrderResult.Status = "success"
+		}
+		lo.Result = result
+		g.UpdatedAt = kiteTime(now)
+	}
+}
@@ -0,0 +1,308 @@
+package kitefake
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kiteticker "rest-service/internal/ticker"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2026, 10, 19, 10, 0, 0, 0, ist) // Monday
+
+// newTestClient serves a fake at testNow and returns a client for it
+// without rate limits or retries.
+func newTestClient(t *testing.T, opts ...Option) (*Server, *kiteconnect.Client, string) {
+	fake := New(append([]Option{WithClock(func() time.Time { return testNow })}, opts...)...)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	kc := kiteconnect.New("api_key")
+	kc.SetAccessToken("access_token")
+	kc.SetBaseURI(srv.URL)
+	kc.SetRetryPolicy(kiteconnect.RetryPolicy{})
+	for class := range kiteconnect.DefaultRateLimits() {
+		kc.SetRateLimit(class, kiteconnect.RateLimit{})
+	}
+	return fake, kc, srv.URL
+}
+
+func requireKiteError(t *testing.T, err error, errorType string) {
+	t.Helper()
+	var kerr kiteconnect.Error
+	require.True(t, errors.As(err, &kerr), "got %v", err)
+	require.Equal(t, errorType, kerr.ErrorType, kerr.Message)
+}
+
+func orderStatus(t *testing.T, kc *kiteconnect.Client, orderID string) kiteconnect.Order {
+	t.Helper()
+	history, err := kc.GetOrderHistory(orderID)
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	return history[len(history)-1]
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	fake, kc, _ := newTestClient(t)
+	require.NoError(t, fake.SetPrice("NSE", "INFY", 1500))
+
+	buy := kiteconnect.OrderParams{
+		Exchange: "NSE", Tradingsymbol: "INFY", TransactionType: "BUY", OrderType: "LIMIT",
+		Product: "CNC", Quantity: 10, Price: 1490, Tag: "lifecycle",
+	}
+	resp, err := kc.PlaceOrder(kiteconnect.VarietyRegular, buy)
+	require.NoError(t, err)
+	require.Equal(t, "OPEN", orderStatus(t, kc, resp.OrderID).Status)
+
+	// A modified limit fills once the price comes down to it, at the better price
+	_, err = kc.ModifyOrder(kiteconnect.VarietyRegular, resp.OrderID, kiteconnect.OrderParams{Price: 1495})
+	require.NoError(t, err)
+	require.NoError(t, fake.SetPrice("NSE", "INFY", 1494))
+
+	o := orderStatus(t, kc, resp.OrderID)
+	require.Equal(t, kiteconnect.OrderStatusComplete, o.Status)
+	require.True(t, o.Modified)
+	require.Equal(t, 1494.0, o.AveragePrice)
+	require.Equal(t, []string{"lifecycle"}, o.Tags)
+
+	trades, err := kc.GetOrderTrades(resp.OrderID)
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	require.Equal(t, 10.0, trades[0].Quantity)
+
+	_, err = kc.CancelOrder(kiteconnect.VarietyRegular, resp.OrderID, nil)
+	requireKiteError(t, err, kiteconnect.OrderError)
+
+	// Stop orders wait for their trigger until cancelled
+	resp, err = kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NSE", Tradingsymbol: "INFY", TransactionType: "SELL", OrderType: "SL-M",
+		Product: "MIS", Quantity: 10, TriggerPrice: 1480,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "TRIGGER PENDING", orderStatus(t, kc, resp.OrderID).Status)
+	_, err = kc.CancelOrder(kiteconnect.VarietyRegular, resp.OrderID, nil)
+	require.NoError(t, err)
+	o = orderStatus(t, kc, resp.OrderID)
+	require.Equal(t, kiteconnect.OrderStatusCancelled, o.Status)
+	require.Equal(t, 10.0, o.CancelledQuantity)
+
+	require.NoError(t, fake.SetPrice("NSE", "INFY", 1504))
+	positions, err := kc.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions.Net, 1)
+	p := positions.Net[0]
+	require.Equal(t, 10, p.Quantity)
+	require.Equal(t, 1494.0, p.AveragePrice)
+	require.InDelta(t, 100, p.Unrealised, 1e-6)
+}
+
+func TestOrderRejections(t *testing.T) {
+	_, kc, _ := newTestClient(t, WithCash(10000))
+
+	// Without the margin or holdings orders are placed and rejected
+	resp, err := kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NSE", Tradingsymbol: "RELIANCE", TransactionType: "BUY", OrderType: "MARKET", Product: "CNC", Quantity: 100,
+	})
+	require.NoError(t, err)
+	o := orderStatus(t, kc, resp.OrderID)
+	require.Equal(t, kiteconnect.OrderStatusRejected, o.Status)
+	require.Contains(t, o.StatusMessage, "Insufficient funds")
+
+	resp, err = kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NSE", Tradingsymbol: "TCS", TransactionType: "SELL", OrderType: "MARKET", Product: "CNC", Quantity: 1,
+	})
+	require.NoError(t, err)
+	require.Contains(t, orderStatus(t, kc, resp.OrderID).StatusMessage, "Insufficient holdings")
+
+	// Invalid orders are refused
+	_, err = kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NSE", Tradingsymbol: "INFY", TransactionType: "BUY", OrderType: "LIMIT", Product: "CNC", Quantity: 1,
+	})
+	requireKiteError(t, err, kiteconnect.InputError)
+	_, err = kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NSE", Tradingsymbol: "INFY", TransactionType: "BUY", OrderType: "MARKET", Product: "NRML", Quantity: 1,
+	})
+	requireKiteError(t, err, kiteconnect.InputError)
+	_, err = kc.ModifyOrder(kiteconnect.VarietyRegular, "missing", kiteconnect.OrderParams{Price: 1})
+	requireKiteError(t, err, kiteconnect.GeneralError)
+}
+
+func TestMargins(t *testing.T) {
+	fake, kc, _ := newTestClient(t)
+	future := fake.Instruments()
+	var symbol string
+	for _, in := range future {
+		if in.Name == "NIFTY" && in.InstrumentType == "FUT" {
+			symbol = in.Tradingsymbol
+			break
+		}
+	}
+	require.NotEmpty(t, symbol)
+	price, _ := fake.LastPrice("NFO", symbol)
+
+	_, err := kc.PlaceOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange: "NFO", Tradingsymbol: symbol, TransactionType: "BUY", OrderType: "MARKET", Product: "NRML", Quantity: 75,
+	})
+	require.NoError(t, err)
+
+	margins, err := kc.GetUserMargins()
+	require.NoError(t, err)
+	require.InDelta(t, 0.12*75*price, margins.Equity.Used.Span, 1e-6)
+	require.InDelta(t, 0.15*75*price, margins.Equity.Used.Debits, 1e-6)
+	require.InDelta(t, DefaultCash-margins.Equity.Used.Debits, margins.Equity.Net, 1e-6)
+
+	_, err = kc.GetUserSegmentMargins("currency")
+	requireKiteError(t, err, kiteconnect.InputError)
+}
+
+func TestGTT(t *testing.T) {
+	fake, kc, _ := newTestClient(t)
+	require.NoError(t, fake.SetPrice("NSE", "SBIN", 850))
+
+	resp, err := kc.PlaceGTT(kiteconnect.GTTParams{
+		Tradingsymbol: "SBIN", Exchange: "NSE", LastPrice: 850, TransactionType: "SELL",
+		Trigger: &kiteconnect.GTTOneCancelsOtherTrigger{
+			Lower: kiteconnect.TriggerParams{TriggerValue: 800, LimitPrice: 799, Quantity: 40},
+			Upper: kiteconnect.TriggerParams{TriggerValue: 900, LimitPrice: 901, Quantity: 40},
+		},
+	})
+	require.NoError(t, err)
+	gtt, err := kc.GetGTT(resp.TriggerID)
+	require.NoError(t, err)
+	require.Equal(t, "active", gtt.Status)
+
+	// The upper leg sells the holding once the price reaches it
+	require.NoError(t, fake.SetPrice("NSE", "SBIN", 905))
+	gtt, err = kc.GetGTT(resp.TriggerID)
+	require.NoError(t, err)
+	require.Equal(t, "triggered", gtt.Status)
+
+	orders, err := kc.GetOrders()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Equal(t, kiteconnect.OrderStatusComplete, orders[0].Status)
+	require.Equal(t, 905.0, orders[0].AveragePrice)
+
+	_, err = kc.DeleteGTT(resp.TriggerID)
+	require.NoError(t, err)
+	_, err = kc.GetGTT(resp.TriggerID)
+	requireKiteError(t, err, kiteconnect.GeneralError)
+}
+
+func TestHistoricalData(t *testing.T) {
+	_, kc, _ := newTestClient(t)
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, ist)
+
+	candles, err := kc.GetHistoricalData(408065, "minute", day, day.Add(23*time.Hour), false, false)
+	require.NoError(t, err)
+	require.Len(t, candles, 46) // 09:15 to the current minute, 10:00
+	require.True(t, candles[0].Date.Equal(day.Add(9*time.Hour+15*time.Minute)))
+	for _, c := range candles {
+		require.True(t, c.Low <= c.Open && c.Open <= c.High && c.Low <= c.Close && c.Close <= c.High)
+	}
+
+	// Candles are the same whatever range they are requested in
+	later, err := kc.GetHistoricalData(408065, "minute", day.Add(9*time.Hour+30*time.Minute), day.Add(10*time.Hour), false, false)
+	require.NoError(t, err)
+	require.Equal(t, candles[15:], later)
+
+	daily, err := kc.GetHistoricalData(256265, "day", day.AddDate(0, 0, -7), day, false, true)
+	require.NoError(t, err)
+	require.Len(t, daily, 6) // Weekdays of the week before and today
+
+	_, err = kc.GetHistoricalData(408065, "minute", day.AddDate(0, 0, -90), day, false, false)
+	requireKiteError(t, err, kiteconnect.InputError)
+}
+
+func TestInstruments(t *testing.T) {
+	_, kc, _ := newTestClient(t)
+
+	instruments, err := kc.GetInstruments()
+	require.NoError(t, err)
+	symbols := make(map[string]kiteconnect.Instrument, len(instruments))
+	for _, in := range instruments {
+		symbols[in.Exchange+":"+in.Tradingsymbol] = in
+	}
+	require.Equal(t, "INDICES", symbols["NSE:NIFTY 50"].Segment)
+	require.Equal(t, 75.0, symbols["NFO:NIFTY26OCTFUT"].LotSize)
+	// Weekly and monthly options, expiring on Tuesdays
+	require.Contains(t, symbols, "NFO:NIFTY26O2025000CE")
+	require.Equal(t, "2026-10-27", symbols["NFO:NIFTY26OCT25000PE"].Expiry.Format("2006-01-02"))
+
+	bse, err := kc.GetInstrumentsByExchange("BSE")
+	require.NoError(t, err)
+	require.Len(t, bse, 6)
+
+	ltp, err := kc.GetLTP("NSE:INFY", "NFO:NIFTY26OCTFUT", "NSE:MISSING")
+	require.NoError(t, err)
+	require.Len(t, ltp, 2)
+}
+
+func TestAuthAndFailures(t *testing.T) {
+	fake, kc, _ := newTestClient(t, WithAccessToken("secret"))
+
+	_, err := kc.GetUserProfile()
+	requireKiteError(t, err, kiteconnect.TokenError)
+
+	kc.SetAccessToken("secret")
+	profile, err := kc.GetUserProfile()
+	require.NoError(t, err)
+	require.Equal(t, UserID, profile.UserID)
+
+	fake.Fail(http.MethodGet, "/user/profile", http.StatusServiceUnavailable, kiteconnect.NetworkError, "down")
+	_, err = kc.GetUserProfile()
+	requireKiteError(t, err, kiteconnect.NetworkError)
+	_, err = kc.GetUserProfile()
+	require.NoError(t, err)
+}
+
+func TestTicker(t *testing.T) {
+	fake, _, base := newTestClient(t)
+	root, err := url.Parse(strings.Replace(base, "http", "ws", 1) + "/ws")
+	require.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		ticks = make(map[uint32]models.Tick)
+	)
+	ticker := kiteticker.New("api_key", "access_token")
+	ticker.SetRootURL(*root)
+	ticker.SetAutoReconnect(false)
+	ticker.OnConnect(func() {
+		require.NoError(t, ticker.Subscribe([]uint32{408065, 256265}))
+		require.NoError(t, ticker.SetMode(kiteticker.ModeFull, []uint32{408065}))
+	})
+	ticker.OnTick(func(tick models.Tick) {
+		mu.Lock()
+		defer mu.Unlock()
+		ticks[tick.InstrumentToken] = tick
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ticker.ServeWithContext(ctx)
+
+	full := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return ticks[408065].Mode == string(kiteticker.ModeFull) && ticks[256265].IsIndex
+	}
+	require.Eventually(t, full, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, fake.SetPrice("NSE", "INFY", 1612.35))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return ticks[408065].LastPrice == 1612.35
+	}, 5*time.Second, 10*time.Millisecond)
+}
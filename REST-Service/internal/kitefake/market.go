@@ -0,0 +1,662 @@
+package kitefake
+
+import (
+	"bytes"
+	"encoding/csv"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+)
+
+// riskFreeRate prices futures and options off their underlying.
+const riskFreeRate = 0.065
+
+// instrument is a tradable or index instrument and its live quote.
+// Derivatives, and BSE equities, are priced off their underlying.
+type instrument struct {
+	token         uint32
+	exchangeToken uint32
+	exchange      string
+	segment       string
+	symbol        string
+	name          string
+	kind          string // Instrument type: EQ, FUT, CE or PE
+	expiry        time.Time
+	strike        float64
+	tick          float64
+	lot           int
+	index         bool
+
+	underlying *instrument
+	basis      float64 // Premium of a BSE equity over NSE
+	vol        float64 // Annual volatility of a spot
+	stepVol    float64 // Volatility of a spot per Step
+
+	last, open, high, low, close float64
+	lastQty, volume              int
+	tradedValue                  float64
+	lastTrade                    time.Time
+	oi, oiHigh, oiLow            float64
+}
+
+func (in *instrument) key() string {
+	return in.exchange + ":" + in.symbol
+}
+
+func (in *instrument) isOption() bool {
+	return in.kind == "CE" || in.kind == "PE"
+}
+
+func (in *instrument) isDerivative() bool {
+	return in.kind == "FUT" || in.isOption()
+}
+
+// trade moves the last price, updating the day's range, volume and OI.
+func (in *instrument) trade(price float64, rnd *rand.Rand, now time.Time) {
+	if price < in.tick {
+		price = in.tick
+	}
+	in.last = price
+	in.high = math.Max(in.high, price)
+	in.low = math.Min(in.low, price)
+	if in.index {
+		return
+	}
+
+	in.lastQty = in.lotSize() * (1 + rnd.Intn(10))
+	in.volume += in.lastQty
+	in.tradedValue += price * float64(in.lastQty)
+	in.lastTrade = now
+	if in.isDerivative() {
+		in.oi = math.Max(0, in.oi+float64(in.lot*(rnd.Intn(21)-10)))
+		in.oiHigh = math.Max(in.oiHigh, in.oi)
+		in.oiLow = math.Min(in.oiLow, in.oi)
+	}
+}
+
+// reset starts the day at price: it is the previous close and the open.
+func (in *instrument) reset(price float64) {
+	in.last, in.open, in.high, in.low, in.close = price, price, price, price, price
+}
+
+func (in *instrument) lotSize() int {
+	if in.lot < 1 {
+		return 1
+	}
+	return in.lot
+}
+
+// averagePrice is the volume weighted average price of the day.
+func (in *instrument) averagePrice() float64 {
+	if in.volume == 0 {
+		return in.last
+	}
+	return roundTick(in.tradedValue/float64(in.volume), in.tick)
+}
+
+// fair prices a derivative or BSE equity off its underlying: futures at
+// cost of carry and options with Black-Scholes on a smiled volatility.
+func (in *instrument) fair(now time.Time) float64 {
+	spot := in.underlying.last
+	t := in.expiry.Add(15*time.Hour+30*time.Minute).Sub(now).Hours() / 24 / 365
+	switch in.kind {
+	case "EQ":
+		return roundTick(spot*(1+in.basis), in.tick)
+	case "FUT":
+		return roundTick(spot*math.Exp(riskFreeRate*math.Max(t, 0)), in.tick)
+	}
+
+	vol := in.underlying.vol + 0.1*math.Abs(math.Log(in.strike/spot))
+	return roundTick(blackScholes(spot, in.strike, t, vol, in.kind == "CE"), in.tick)
+}
+
+// blackScholes prices a European option, at intrinsic value once expired.
+func blackScholes(spot, strike, t, vol float64, call bool) float64 {
+	if t <= 0 {
+		if call {
+			return math.Max(spot-strike, 0)
+		}
+		return math.Max(strike-spot, 0)
+	}
+	sd := vol * math.Sqrt(t)
+	d1 := (math.Log(spot/strike) + (riskFreeRate+vol*vol/2)*t) / sd
+	d2 := d1 - sd
+	df := math.Exp(-riskFreeRate * t)
+	if call {
+		return spot*normCDF(d1) - strike*df*normCDF(d2)
+	}
+	return strike*df*normCDF(-d2) - spot*normCDF(-d1)
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func roundTick(price, tick float64) float64 {
+	if tick <= 0 {
+		return math.Round(price*100) / 100
+	}
+	return math.Round(math.Round(price/tick)*tick*100) / 100
+}
+
+// Seed universe: the spots, and the underlyings with futures and options.
+var spotSeeds = []struct {
+	symbol, name string
+	token        uint32
+	price, vol   float64
+	index        bool
+}{
+	{"NIFTY 50", "NIFTY 50", 256265, 25000, 0.13, true},
+	{"NIFTY BANK", "NIFTY BANK", 260105, 56000, 0.15, true},
+	{"INFY", "INFOSYS", 408065, 1500, 0.25, false},
+	{"RELIANCE", "RELIANCE INDUSTRIES", 738561, 1400, 0.22, false},
+	{"TCS", "TATA CONSULTANCY SERV LT", 2953217, 3100, 0.22, false},
+	{"HDFCBANK", "HDFC BANK", 341249, 950, 0.2, false},
+	{"SBIN", "STATE BANK OF INDIA", 779521, 850, 0.25, false},
+	{"DIXON", "DIXON TECHNO (INDIA) LTD", 5552641, 15000, 0.35, false},
+}
+
+var derivativeSeeds = []struct {
+	name, spot string
+	lot        int
+	step       float64 // Strike interval
+	strikes    int     // Strikes either side of the money
+	weekly     bool    // Weekly option expiries besides monthly ones
+}{
+	{"NIFTY", "NIFTY 50", 75, 50, 20, true},
+	{"BANKNIFTY", "NIFTY BANK", 35, 100, 20, false},
+	{"RELIANCE", "RELIANCE", 500, 10, 10, false},
+	{"INFY", "INFY", 400, 20, 10, false},
+	{"DIXON", "DIXON", 50, 100, 10, false},
+}
+
+// Segment numbers in the low byte of instrument tokens.
+const (
+	segmentNFO = 2
+	segmentBSE = 4
+)
+
+// seedInstruments creates the instrument universe, expiring on the Tuesdays
+// after now.
+func (s *Server) seedInstruments(now time.Time) {
+	for _, sp := range spotSeeds {
+		in := &instrument{
+			token:         sp.token,
+			exchangeToken: sp.token >> 8,
+			exchange:      kiteconnect.ExchangeNSE,
+			segment:       kiteconnect.ExchangeNSE,
+			symbol:        sp.symbol,
+			name:          sp.name,
+			kind:          "EQ",
+			tick:          0.05,
+			lot:           1,
+			index:         sp.index,
+			vol:           sp.vol,
+			stepVol:       sp.vol / 200,
+		}
+		if sp.index {
+			in.segment = "INDICES"
+			in.lot = 0
+		}
+		in.reset(sp.price)
+		in.trade(roundTick(sp.price*(1+0.002*s.rnd.NormFloat64()), in.tick), s.rnd, now)
+		in.open, in.high, in.low = in.last, in.last, in.last
+		s.add(in)
+	}
+
+	bseToken := uint32(500100)
+	for _, sp := range spotSeeds {
+		if sp.index {
+			continue
+		}
+		s.add(&instrument{
+			token:         bseToken<<8 | segmentBSE,
+			exchangeToken: bseToken,
+			exchange:      kiteconnect.ExchangeBSE,
+			segment:       kiteconnect.ExchangeBSE,
+			symbol:        sp.symbol,
+			name:          sp.name,
+			kind:          "EQ",
+			tick:          0.05,
+			lot:           1,
+			underlying:    s.bySymbol["NSE:"+sp.symbol],
+			basis:         0.0004,
+		})
+		bseToken++
+	}
+
+	nfoToken := uint32(35001)
+	add := func(in *instrument) {
+		in.token = nfoToken<<8 | segmentNFO
+		in.exchangeToken = nfoToken
+		in.exchange = kiteconnect.ExchangeNFO
+		in.tick = 0.05
+		s.add(in)
+		nfoToken++
+	}
+	for _, d := range derivativeSeeds {
+		spot := s.bySymbol["NSE:"+d.spot]
+		atm := math.Round(spot.last/d.step) * d.step
+		for _, exp := range expiries(now, d.weekly) {
+			if exp.monthly {
+				add(&instrument{
+					segment:    "NFO-FUT",
+					symbol:     d.name + strings.ToUpper(exp.date.Format("06Jan")) + "FUT",
+					name:       d.name,
+					kind:       "FUT",
+					expiry:     exp.date,
+					lot:        d.lot,
+					underlying: spot,
+				})
+			}
+			for k := -d.strikes; k <= d.strikes; k++ {
+				strike := atm + float64(k)*d.step
+				for _, kind := range []string{"CE", "PE"} {
+					add(&instrument{
+						segment:    "NFO-OPT",
+						symbol:     optionSymbol(d.name, exp, strike, kind),
+						name:       d.name,
+						kind:       kind,
+						expiry:     exp.date,
+						strike:     strike,
+						lot:        d.lot,
+						underlying: spot,
+					})
+				}
+			}
+		}
+	}
+
+	for _, in := range s.instruments {
+		if in.underlying == nil {
+			continue
+		}
+		in.reset(in.fair(now))
+		if in.isDerivative() {
+			in.oi = float64(in.lot * (200 + s.rnd.Intn(5000)))
+			in.oiHigh, in.oiLow = in.oi, in.oi
+		}
+	}
+}
+
+func (s *Server) add(in *instrument) {
+	s.instruments = append(s.instruments, in)
+	s.byToken[in.token] = in
+	s.bySymbol[in.key()] = in
+}
+
+// lookup finds an instrument by exchange:tradingsymbol or token.
+func (s *Server) lookup(key string) (*instrument, bool) {
+	if in, ok := s.bySymbol[key]; ok {
+		return in, true
+	}
+	token, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	in, ok := s.byToken[uint32(token)]
+	return in, ok
+}
+
+type expiry struct {
+	date    time.Time
+	monthly bool
+}
+
+// expiries returns the expiries of the next three months on or after now's
+// day: the last Tuesday of each month and, if weekly, the next four Tuesdays.
+func expiries(now time.Time, weekly bool) []expiry {
+	now = now.In(ist)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, ist)
+
+	monthly := make(map[time.Time]bool)
+	for m := 0; len(monthly) < 3; m++ {
+		last := time.Date(today.Year(), today.Month()+time.Month(m)+1, 0, 0, 0, 0, 0, ist)
+		last = last.AddDate(0, 0, -((int(last.Weekday()) - int(time.Tuesday) + 7) % 7))
+		if !last.Before(today) {
+			monthly[last] = true
+		}
+	}
+
+	dates := make(map[time.Time]bool, len(monthly))
+	for d := range monthly {
+		dates[d] = true
+	}
+	if weekly {
+		next := today.AddDate(0, 0, (int(time.Tuesday)-int(today.Weekday())+7)%7)
+		for w := 0; w < 4; w++ {
+			dates[next.AddDate(0, 0, 7*w)] = true
+		}
+	}
+
+	var out []expiry
+	for d := range dates {
+		out = append(out, expiry{date: d, monthly: monthly[d]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].date.Before(out[j].date) })
+	return out
+}
+
+// optionSymbol returns Kite's tradingsymbol of an option: NIFTY25OCT25000CE
+// for monthly expiries and NIFTY25O2125000CE, with a month code and the
+// day, for weekly ones.
+func optionSymbol(name string, exp expiry, strike float64, kind string) string {
+	var date string
+	if exp.monthly {
+		date = strings.ToUpper(exp.date.Format("06Jan"))
+	} else {
+		date = exp.date.Format("06") + string("123456789OND"[exp.date.Month()-1]) + exp.date.Format("02")
+	}
+	return name + date + strconv.FormatFloat(strike, 'f', -1, 64) + kind
+}
+
+// Instruments returns the instrument master the server serves.
+func (s *Server) Instruments() kiteconnect.Instruments {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(kiteconnect.Instruments, 0, len(s.instruments))
+	for _, in := range s.instruments {
+		inst := kiteconnect.Instrument{
+			InstrumentToken: int(in.token),
+			ExchangeToken:   int(in.exchangeToken),
+			Tradingsymbol:   in.symbol,
+			Name:            in.name,
+			StrikePrice:     in.strike,
+			LotSize:         float64(in.lot),
+			InstrumentType:  in.kind,
+			Segment:         in.segment,
+			Exchange:        in.exchange,
+		}
+		inst.Expiry.Time = in.expiry
+		if !in.index {
+			inst.TickSize = in.tick
+		}
+		out = append(out, inst)
+	}
+	return out
+}
+
+var instrumentColumns = []string{
+	"instrument_token", "exchange_token", "tradingsymbol", "name", "last_price", "expiry",
+	"strike", "tick_size", "lot_size", "instrument_type", "segment", "exchange",
+}
+
+// getInstruments serves the instrument master CSV, of all exchanges or one.
+func (s *Server) getInstruments(r *http.Request, params []string) (interface{}, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(instrumentColumns)
+
+	format := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	for _, in := range s.instruments {
+		if len(params) == 1 && in.exchange != params[0] {
+			continue
+		}
+		var expiry string
+		if !in.expiry.IsZero() {
+			expiry = in.expiry.Format("2006-01-02")
+		}
+		tick := in.tick
+		if in.index {
+			tick = 0
+		}
+		w.Write([]string{
+			strconv.FormatUint(uint64(in.token), 10), strconv.FormatUint(uint64(in.exchangeToken), 10),
+			in.symbol, in.name, "0", expiry, format(in.strike), format(tick), strconv.Itoa(in.lot),
+			in.kind, in.segment, in.exchange,
+		})
+	}
+	w.Flush()
+	return csvBody(buf.Bytes()), nil
+}
+
+// quoted returns the instruments of the i parameters, skipping unknown
+// ones as Kite does.
+func (s *Server) quoted(r *http.Request) (map[string]*instrument, error) {
+	keys := r.Form["i"]
+	if len(keys) == 0 {
+		return nil, inputError("No instruments specified.")
+	}
+	out := make(map[string]*instrument, len(keys))
+	for _, key := range keys {
+		if in, ok := s.lookup(key); ok {
+			out[key] = in
+		}
+	}
+	return out, nil
+}
+
+func ohlc(in *instrument) map[string]float64 {
+	return map[string]float64{"open": in.open, "high": in.high, "low": in.low, "close": in.close}
+}
+
+func (s *Server) getQuote(r *http.Request, _ []string) (interface{}, error) {
+	ins, err := s.quoted(r)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	out := make(map[string]interface{}, len(ins))
+	for key, in := range ins {
+		depth := s.depth(in)
+		lower, upper := circuitLimits(in)
+		out[key] = map[string]interface{}{
+			"instrument_token":    in.token,
+			"timestamp":           kiteTime(now),
+			"last_price":          in.last,
+			"last_quantity":       in.lastQty,
+			"last_trade_time":     kiteTime(in.lastTrade),
+			"average_price":       in.averagePrice(),
+			"volume":              in.volume,
+			"buy_quantity":        depthQuantity(depth.Buy),
+			"sell_quantity":       depthQuantity(depth.Sell),
+			"ohlc":                ohlc(in),
+			"net_change":          roundTick(in.last-in.close, 0),
+			"oi":                  in.oi,
+			"oi_day_high":         in.oiHigh,
+			"oi_day_low":          in.oiLow,
+			"lower_circuit_limit": lower,
+			"upper_circuit_limit": upper,
+			"depth":               depth,
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) getLTP(r *http.Request, _ []string) (interface{}, error) {
+	ins, err := s.quoted(r)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(ins))
+	for key, in := range ins {
+		out[key] = map[string]interface{}{"instrument_token": in.token, "last_price": in.last}
+	}
+	return out, nil
+}
+
+func (s *Server) getOHLC(r *http.Request, _ []string) (interface{}, error) {
+	ins, err := s.quoted(r)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(ins))
+	for key, in := range ins {
+		out[key] = map[string]interface{}{"instrument_token": in.token, "last_price": in.last, "ohlc": ohlc(in)}
+	}
+	return out, nil
+}
+
+// depthLevel is a level of market depth.
+type depthLevel struct {
+	Price    float64 `json:"price"`
+	Quantity int     `json:"quantity"`
+	Orders   int     `json:"orders"`
+}
+
+type marketDepth struct {
+	Buy  [5]depthLevel `json:"buy"`
+	Sell [5]depthLevel `json:"sell"`
+}
+
+// depth returns five levels a tick apart either side of the last price.
+// Indices have no depth.
+func (s *Server) depth(in *instrument) marketDepth {
+	var d marketDepth
+	if in.index {
+		return d
+	}
+	for i := range d.Buy {
+		offset := float64(i+1) * in.tick
+		d.Buy[i] = depthLevel{roundTick(math.Max(in.last-offset, in.tick), in.tick), in.lotSize() * (1 + s.rnd.Intn(20)), 1 + s.rnd.Intn(10)}
+		d.Sell[i] = depthLevel{roundTick(in.last+offset, in.tick), in.lotSize() * (1 + s.rnd.Intn(20)), 1 + s.rnd.Intn(10)}
+	}
+	return d
+}
+
+func depthQuantity(levels [5]depthLevel) int {
+	var total int
+	for _, l := range levels {
+		total += l.Quantity
+	}
+	return total
+}
+
+// circuitLimits are 10% either side of the close, and for options from a
+// tick to five times the close.
+func circuitLimits(in *instrument) (float64, float64) {
+	if in.index {
+		return 0, 0
+	}
+	if in.isOption() {
+		return in.tick, roundTick(math.Max(in.close*5, 1), in.tick)
+	}
+	return roundTick(in.close*0.9, in.tick), roundTick(in.close*1.1, in.tick)
+}
+
+// Candle intervals and the longest range Kite serves for each, in days.
+var intervals = map[string]struct {
+	step    time.Duration
+	maxDays int
+}{
+	"minute":   {time.Minute, 60},
+	"3minute":  {3 * time.Minute, 100},
+	"5minute":  {5 * time.Minute, 100},
+	"10minute": {10 * time.Minute, 100},
+	"15minute": {15 * time.Minute, 200},
+	"30minute": {30 * time.Minute, 200},
+	"60minute": {time.Hour, 400},
+	"day":      {24 * time.Hour, 2000},
+}
+
+// getHistorical serves candles of the weekday sessions from 09:15 to 15:30
+// IST between from and to, up to now. Candles are a function of the
+// instrument and time, so overlapping requests agree.
+func (s *Server) getHistorical(r *http.Request, params []string) (interface{}, error) {
+	token, err := strconv.ParseUint(params[0], 10, 32)
+	if err != nil {
+		return nil, inputError("invalid token")
+	}
+	in, ok := s.byToken[uint32(token)]
+	if !ok {
+		return nil, inputError("invalid token")
+	}
+	interval, ok := intervals[params[1]]
+	if !ok {
+		return nil, inputError("invalid interval")
+	}
+	from, err := parseKiteTime(r.Form.Get("from"))
+	if err != nil {
+		return nil, inputError("invalid from date")
+	}
+	to, err := parseKiteTime(r.Form.Get("to"))
+	if err != nil {
+		return nil, inputError("invalid to date")
+	}
+	if to.Before(from) {
+		return nil, inputError("invalid from date: from date should be less than to date")
+	}
+	if to.Sub(from) > time.Duration(interval.maxDays)*24*time.Hour {
+		return nil, inputError("interval exceeds max limit: %d days", interval.maxDays)
+	}
+	withOI := r.Form.Get("oi") == "1"
+
+	now := s.now()
+	candles := [][]interface{}{}
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, ist); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		open := day.Add(9*time.Hour + 15*time.Minute)
+		close := day.Add(15*time.Hour + 30*time.Minute)
+		if params[1] == "day" {
+			if !day.Before(from) && !day.After(now) {
+				candles = append(candles, s.candle(in, day, open, close, withOI))
+			}
+			continue
+		}
+		for t := open; t.Before(close); t = t.Add(interval.step) {
+			if t.Before(from) || t.After(to) || t.After(now) {
+				continue
+			}
+			end := t.Add(interval.step)
+			if end.After(close) {
+				end = close
+			}
+			candles = append(candles, s.candle(in, t, t, end, withOI))
+		}
+	}
+	return map[string]interface{}{"candles": candles}, nil
+}
+
+// candle returns the candle dated date of the prices from start to end.
+// Prices follow slow waves around the previous close, so they are stable
+// across calls.
+func (s *Server) candle(in *instrument, date, start, end time.Time, withOI bool) []interface{} {
+	price := func(at time.Time) float64 {
+		days := float64(at.Unix()) / 86400
+		return in.close * (1 + 0.04*math.Sin(2*math.Pi*days/23) + 0.01*math.Sin(2*math.Pi*days*24/7))
+	}
+	noise := hash(in.token, start)
+	o, c := roundTick(price(start), in.tick), roundTick(price(end), in.tick)
+	h := roundTick(math.Max(o, c)*(1+0.002*noise), in.tick)
+	l := roundTick(math.Min(o, c)*(1-0.002*noise), in.tick)
+
+	var volume int
+	if !in.index {
+		volume = in.lotSize() * (100 + int(noise*1000)) * int(end.Sub(start)/time.Minute)
+	}
+	candle := []interface{}{date.In(ist).Format("2006-01-02T15:04:05-0700"), o, h, l, c, volume}
+	if withOI {
+		var oi int
+		if in.isDerivative() {
+			oi = in.lotSize() * (1000 + int(hash(in.token+1, start)*500))
+		}
+		candle = append(candle, oi)
+	}
+	return candle
+}
+
+// hash returns a number in [0, 1) determined by a token and time.
+func hash(token uint32, t time.Time) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatUint(uint64(token), 10) + "@" + strconv.FormatInt(t.Unix(), 10)))
+	return float64(h.Sum64()%1000000) / 1000000
+}
+
+// parseKiteTime parses a request timestamp in IST.
+func parseKiteTime(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, ist)
+	if err != nil {
+		return time.ParseInLocation("2006-01-02", value, ist)
+	}
+	return t, nil
+}
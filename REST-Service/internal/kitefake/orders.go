@@ -0,0 +1,852 @@
+package kitefake
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+)
+
+// Order statuses besides the final ones kiteconnect defines.
+const (
+	statusOpen           = "OPEN"
+	statusTriggerPending = "TRIGGER PENDING"
+)
+
+// order is an order as Kite returns it. Each change is kept as a snapshot
+// in history, which is the order's history.
+type order struct {
+	AccountID               string                 `json:"account_id"`
+	PlacedBy                string                 `json:"placed_by"`
+	OrderID                 string                 `json:"order_id"`
+	ExchangeOrderID         string                 `json:"exchange_order_id"`
+	ParentOrderID           *string                `json:"parent_order_id"`
+	Status                  string                 `json:"status"`
+	StatusMessage           string                 `json:"status_message"`
+	StatusMessageRaw        string                 `json:"status_message_raw"`
+	OrderTimestamp          string                 `json:"order_timestamp"`
+	ExchangeUpdateTimestamp string                 `json:"exchange_update_timestamp"`
+	ExchangeTimestamp       string                 `json:"exchange_timestamp"`
+	Variety                 string                 `json:"variety"`
+	Modified                bool                   `json:"modified"`
+	Exchange                string                 `json:"exchange"`
+	TradingSymbol           string                 `json:"tradingsymbol"`
+	InstrumentToken         uint32                 `json:"instrument_token"`
+	OrderType               string                 `json:"order_type"`
+	TransactionType         string                 `json:"transaction_type"`
+	Validity                string                 `json:"validity"`
+	ValidityTTL             int                    `json:"validity_ttl"`
+	Product                 string                 `json:"product"`
+	Quantity                float64                `json:"quantity"`
+	DisclosedQuantity       float64                `json:"disclosed_quantity"`
+	Price                   float64                `json:"price"`
+	TriggerPrice            float64                `json:"trigger_price"`
+	AveragePrice            float64                `json:"average_price"`
+	FilledQuantity          float64                `json:"filled_quantity"`
+	PendingQuantity         float64                `json:"pending_quantity"`
+	CancelledQuantity       float64                `json:"cancelled_quantity"`
+	Tag                     *string                `json:"tag"`
+	Tags                    []string               `json:"tags"`
+	Meta                    map[string]interface{} `json:"meta"`
+
+	history []order
+	placed  time.Time // Expires TTL orders
+}
+
+// record moves the order to a status, adding a snapshot to its history.
+func (o *order) record(status, message string, now time.Time) {
+	o.Status = status
+	o.StatusMessage = message
+	o.StatusMessageRaw = message
+	o.ExchangeUpdateTimestamp = kiteTime(now)
+	snapshot := *o
+	snapshot.history = nil
+	o.history = append(o.history, snapshot)
+}
+
+func (o *order) pending() bool {
+	return o.Status == statusOpen || o.Status == statusTriggerPending
+}
+
+func (o *order) buy() bool {
+	return o.TransactionType == kiteconnect.TransactionTypeBuy
+}
+
+// trade is a fill as Kite returns it.
+type trade struct {
+	TradeID           string  `json:"trade_id"`
+	OrderID           string  `json:"order_id"`
+	ExchangeOrderID   string  `json:"exchange_order_id"`
+	TradingSymbol     string  `json:"tradingsymbol"`
+	Exchange          string  `json:"exchange"`
+	InstrumentToken   uint32  `json:"instrument_token"`
+	TransactionType   string  `json:"transaction_type"`
+	Product           string  `json:"product"`
+	AveragePrice      float64 `json:"average_price"`
+	Quantity          float64 `json:"quantity"`
+	FillTimestamp     string  `json:"fill_timestamp"`
+	ExchangeTimestamp string  `json:"exchange_timestamp"`
+}
+
+// orderParams are the parameters of a new order.
+type orderParams struct {
+	variety         string
+	exchange        string
+	tradingsymbol   string
+	transactionType string
+	orderType       string
+	product         string
+	validity        string
+	validityTTL     int
+	quantity        int
+	disclosed       int
+	price           float64
+	triggerPrice    float64
+	tag             string
+}
+
+var varieties = map[string]bool{
+	kiteconnect.VarietyRegular: true,
+	kiteconnect.VarietyAMO:     true,
+	kiteconnect.VarietyCO:      true,
+	kiteconnect.VarietyIceberg: true,
+}
+
+func (s *Server) getOrders(r *http.Request, _ []string) (interface{}, error) {
+	out := make([]order, 0, len(s.orders))
+	for _, o := range s.orders {
+		snapshot := *o
+		snapshot.history = nil
+		out = append(out, snapshot)
+	}
+	return out, nil
+}
+
+func (s *Server) getOrderHistory(r *http.Request, params []string) (interface{}, error) {
+	o, ok := s.orderByID[params[0]]
+	if !ok {
+		return nil, notFound("Couldn't find that `order_id`.")
+	}
+	return o.history, nil
+}
+
+func (s *Server) getTrades(r *http.Request, _ []string) (interface{}, error) {
+	return append([]trade{}, s.trades...), nil
+}
+
+func (s *Server) getOrderTrades(r *http.Request, params []string) (interface{}, error) {
+	if _, ok := s.orderByID[params[0]]; !ok {
+		return nil, notFound("Couldn't find that `order_id`.")
+	}
+	out := []trade{}
+	for _, t := range s.trades {
+		if t.OrderID == params[0] {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) placeOrder(r *http.Request, params []string) (interface{}, error) {
+	p := orderParams{
+		variety:         params[0],
+		exchange:        r.Form.Get("exchange"),
+		tradingsymbol:   r.Form.Get("tradingsymbol"),
+		transactionType: r.Form.Get("transaction_type"),
+		orderType:       r.Form.Get("order_type"),
+		product:         r.Form.Get("product"),
+		validity:        r.Form.Get("validity"),
+		tag:             r.Form.Get("tag"),
+	}
+	var err error
+	if p.quantity, err = formInt(r, "quantity"); err != nil {
+		return nil, err
+	}
+	if p.disclosed, err = formInt(r, "disclosed_quantity"); err != nil {
+		return nil, err
+	}
+	if p.validityTTL, err = formInt(r, "validity_ttl"); err != nil {
+		return nil, err
+	}
+	if p.price, err = formFloat(r, "price"); err != nil {
+		return nil, err
+	}
+	if p.triggerPrice, err = formFloat(r, "trigger_price"); err != nil {
+		return nil, err
+	}
+
+	o, err := s.place(p, s.now())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"order_id": o.OrderID}, nil
+}
+
+// place validates and places an order, matching it against the market.
+// Orders failing validation are refused; orders without the margin or
+// holdings for them are placed and rejected, as on the exchange.
+func (s *Server) place(p orderParams, now time.Time) (*order, error) {
+	if !varieties[p.variety] {
+		return nil, inputError("Invalid `variety`.")
+	}
+	if p.validity == "" {
+		p.validity = kiteconnect.ValidityDay
+	}
+	in, ok := s.bySymbol[p.exchange+":"+p.tradingsymbol]
+	if !ok {
+		return nil, inputError("Invalid `tradingsymbol`.")
+	}
+	if in.index {
+		return nil, inputError("Indices are not tradable.")
+	}
+	if p.transactionType != kiteconnect.TransactionTypeBuy && p.transactionType != kiteconnect.TransactionTypeSell {
+		return nil, inputError("Invalid `transaction_type`.")
+	}
+	if !productAllowed(in, p.product) {
+		return nil, inputError("Invalid `product` for %s.", in.symbol)
+	}
+	if len(p.tag) > 20 {
+		return nil, inputError("Tag can be at most 20 characters.")
+	}
+
+	o := &order{
+		AccountID:       UserID,
+		PlacedBy:        UserID,
+		Variety:         p.variety,
+		Exchange:        in.exchange,
+		TradingSymbol:   in.symbol,
+		InstrumentToken: in.token,
+		TransactionType: p.transactionType,
+		Product:         p.product,
+		Tags:            []string{},
+		Meta:            map[string]interface{}{},
+		OrderTimestamp:  kiteTime(now),
+		placed:          now,
+	}
+	if p.tag != "" {
+		o.Tag = &p.tag
+		o.Tags = []string{p.tag}
+	}
+	if err := s.amend(o, in, p); err != nil {
+		return nil, err
+	}
+
+	s.orderSeq++
+	o.OrderID = fmt.Sprintf("%s%09d", now.In(ist).Format("060102"), s.orderSeq)
+	o.ExchangeOrderID = fmt.Sprintf("1100000%09d", s.orderSeq)
+	o.ExchangeTimestamp = kiteTime(now)
+	s.orders = append(s.orders, o)
+	s.orderByID[o.OrderID] = o
+
+	o.record("PUT ORDER REQ RECEIVED", "", now)
+	o.record("VALIDATION PENDING", "", now)
+	o.record("OPEN PENDING", "", now)
+	if reason := s.risk(o, in); reason != "" {
+		o.CancelledQuantity, o.PendingQuantity = 0, 0
+		o.record(kiteconnect.OrderStatusRejected, reason, now)
+		return o, nil
+	}
+	s.open(o, now)
+	s.match(o, in, now)
+	if o.Validity == kiteconnect.ValidityIOC && o.pending() {
+		s.cancel(o, now)
+	}
+	return o, nil
+}
+
+// productAllowed reports whether an instrument can be traded in a product:
+// CNC is for equities and NRML for derivatives.
+func productAllowed(in *instrument, product string) bool {
+	switch product {
+	case kiteconnect.ProductMIS:
+		return true
+	case kiteconnect.ProductCNC:
+		return !in.isDerivative()
+	case kiteconnect.ProductNRML:
+		return in.isDerivative()
+	}
+	return false
+}
+
+// amend validates the order type, quantity and prices of params and applies
+// them to the order; zero values leave a modified order's values.
+func (s *Server) amend(o *order, in *instrument, p orderParams) error {
+	orderType, validity := o.OrderType, o.Validity
+	quantity, price, trigger := o.Quantity, o.Price, o.TriggerPrice
+	if p.orderType != "" {
+		orderType = p.orderType
+	}
+	if p.validity != "" {
+		validity = p.validity
+	}
+	if p.quantity != 0 {
+		quantity = float64(p.quantity)
+	}
+	if p.price != 0 {
+		price = p.price
+	}
+	if p.triggerPrice != 0 {
+		trigger = p.triggerPrice
+	}
+
+	switch validity {
+	case kiteconnect.ValidityDay, kiteconnect.ValidityIOC:
+	case kiteconnect.ValidityTTL:
+		if p.validityTTL < 1 && o.ValidityTTL < 1 {
+			return inputError("`validity_ttl` is required for TTL orders.")
+		}
+	default:
+		return inputError("Invalid `validity`.")
+	}
+	if quantity <= 0 || math.Mod(quantity, float64(in.lotSize())) != 0 {
+		return inputError("Quantity should be a multiple of the lot size %d.", in.lotSize())
+	}
+	switch orderType {
+	case kiteconnect.OrderTypeMarket:
+		price, trigger = 0, 0
+	case kiteconnect.OrderTypeLimit:
+		trigger = 0
+		if price <= 0 {
+			return inputError("`price` is required for LIMIT orders.")
+		}
+	case kiteconnect.OrderTypeSL:
+		if price <= 0 || trigger <= 0 {
+			return inputError("`price` and `trigger_price` are required for SL orders.")
+		}
+	case kiteconnect.OrderTypeSLM:
+		price = 0
+		if trigger <= 0 {
+			return inputError("`trigger_price` is required for SL-M orders.")
+		}
+	default:
+		return inputError("Invalid `order_type`.")
+	}
+	for _, v := range []float64{price, trigger} {
+		if v != roundTick(v, in.tick) {
+			return inputError("Price %v is not a multiple of the tick size %v.", v, in.tick)
+		}
+	}
+
+	o.OrderType, o.Validity = orderType, validity
+	if p.validityTTL > 0 {
+		o.ValidityTTL = p.validityTTL
+	}
+	o.Quantity, o.Price, o.TriggerPrice = quantity, price, trigger
+	o.PendingQuantity = quantity - o.FilledQuantity
+	if p.disclosed > 0 {
+		o.DisclosedQuantity = float64(p.disclosed)
+	}
+	return nil
+}
+
+// open moves an order to the book: stop orders wait for their trigger.
+func (s *Server) open(o *order, now time.Time) {
+	if o.OrderType == kiteconnect.OrderTypeSL || o.OrderType == kiteconnect.OrderTypeSLM {
+		o.record(statusTriggerPending, "", now)
+		return
+	}
+	o.record(statusOpen, "", now)
+}
+
+// match fills a pending order if the last price reaches it: stop orders
+// trigger first, market orders fill at the last price and limit orders at
+// their price or better.
+func (s *Server) match(o *order, in *instrument, now time.Time) {
+	if o.Status == statusTriggerPending {
+		if (o.buy() && in.last < o.TriggerPrice) || (!o.buy() && in.last > o.TriggerPrice) {
+			return
+		}
+		o.record(statusOpen, "", now)
+	}
+	if o.Status != statusOpen {
+		return
+	}
+
+	price := in.last
+	if o.Price > 0 {
+		if (o.buy() && in.last > o.Price) || (!o.buy() && in.last < o.Price) {
+			return
+		}
+		if o.buy() {
+			price = math.Min(o.Price, in.last)
+		} else {
+			price = math.Max(o.Price, in.last)
+		}
+	}
+	s.fill(o, in, price, now)
+}
+
+// matchOrders matches the pending orders after prices moved, cancelling
+// TTL orders past their validity.
+func (s *Server) matchOrders(now time.Time) {
+	for _, o := range s.orders {
+		if !o.pending() {
+			continue
+		}
+		if o.Validity == kiteconnect.ValidityTTL && now.Sub(o.placed) >= time.Duration(o.ValidityTTL)*time.Minute {
+			s.cancel(o, now)
+			continue
+		}
+		s.match(o, s.byToken[o.InstrumentToken], now)
+	}
+}
+
+// fill completes an order at price, booking the trade into positions.
+func (s *Server) fill(o *order, in *instrument, price float64, now time.Time) {
+	quantity := o.PendingQuantity
+	o.AveragePrice = price
+	o.FilledQuantity += quantity
+	o.PendingQuantity = 0
+	o.record(kiteconnect.OrderStatusComplete, "", now)
+
+	s.tradeSeq++
+	s.trades = append(s.trades, trade{
+		TradeID:           strconv.Itoa(10000000 + s.tradeSeq),
+		OrderID:           o.OrderID,
+		ExchangeOrderID:   o.ExchangeOrderID,
+		TradingSymbol:     o.TradingSymbol,
+		Exchange:          o.Exchange,
+		InstrumentToken:   o.InstrumentToken,
+		TransactionType:   o.TransactionType,
+		Product:           o.Product,
+		AveragePrice:      price,
+		Quantity:          quantity,
+		FillTimestamp:     kiteTime(now),
+		ExchangeTimestamp: kiteTime(now),
+	})
+
+	p := s.position(in, o.Product)
+	q, value := int(quantity), quantity*price
+	if o.buy() {
+		p.BuyQuantity += q
+		p.BuyValue += value
+		p.DayBuyQuantity += q
+		p.DayBuyValue += value
+	} else {
+		p.SellQuantity += q
+		p.SellValue += value
+		p.DaySellQuantity += q
+		p.DaySellValue += value
+	}
+	p.Quantity = p.BuyQuantity - p.SellQuantity
+}
+
+// cancel cancels the pending quantity of an order.
+func (s *Server) cancel(o *order, now time.Time) {
+	o.CancelledQuantity = o.PendingQuantity
+	o.PendingQuantity = 0
+	o.record("CANCEL PENDING", "", now)
+	o.record(kiteconnect.OrderStatusCancelled, "", now)
+}
+
+// modifiable returns the pending order of a modify or cancel request.
+func (s *Server) modifiable(params []string, action string) (*order, error) {
+	o, ok := s.orderByID[params[1]]
+	if !ok || o.Variety != params[0] {
+		return nil, notFound("Couldn't find that `order_id`.")
+	}
+	if !o.pending() {
+		return nil, orderError("Order cannot be %s as it is %s.", action, o.Status)
+	}
+	return o, nil
+}
+
+func (s *Server) modifyOrder(r *http.Request, params []string) (interface{}, error) {
+	o, err := s.modifiable(params, "modified")
+	if err != nil {
+		return nil, err
+	}
+
+	p := orderParams{orderType: r.Form.Get("order_type"), validity: r.Form.Get("validity")}
+	if p.quantity, err = formInt(r, "quantity"); err != nil {
+		return nil, err
+	}
+	if p.disclosed, err = formInt(r, "disclosed_quantity"); err != nil {
+		return nil, err
+	}
+	if p.price, err = formFloat(r, "price"); err != nil {
+		return nil, err
+	}
+	if p.triggerPrice, err = formFloat(r, "trigger_price"); err != nil {
+		return nil, err
+	}
+
+	in := s.byToken[o.InstrumentToken]
+	if err := s.amend(o, in, p); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	o.Modified = true
+	o.record("MODIFY VALIDATION PENDING", "", now)
+	o.record("MODIFY PENDING", "", now)
+	o.record("MODIFIED", "", now)
+	s.open(o, now)
+	s.match(o, in, now)
+	return map[string]string{"order_id": o.OrderID}, nil
+}
+
+func (s *Server) cancelOrder(r *http.Request, params []string) (interface{}, error) {
+	o, err := s.modifiable(params, "cancelled")
+	if err != nil {
+		return nil, err
+	}
+	s.cancel(o, s.now())
+	return map[string]string{"order_id": o.OrderID}, nil
+}
+
+func formInt(r *http.Request, key string) (int, error) {
+	v := r.Form.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, inputError("Invalid `%s`.", key)
+	}
+	return n, nil
+}
+
+func formFloat(r *http.Request, key string) (float64, error) {
+	v := r.Form.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, inputError("Invalid `%s`.", key)
+	}
+	return f, nil
+}
+
+// position returns the day position of an instrument and product, adding
+// an empty one.
+func (s *Server) position(in *instrument, product string) *kiteconnect.Position {
+	for _, p := range s.positions {
+		if p.InstrumentToken == in.token && p.Product == product {
+			return p
+		}
+	}
+	p := &kiteconnect.Position{
+		Tradingsymbol:   in.symbol,
+		Exchange:        in.exchange,
+		InstrumentToken: in.token,
+		Product:         product,
+		Multiplier:      1,
+	}
+	s.positions = append(s.positions, p)
+	return p
+}
+
+// netQuantity is the net quantity of an instrument in a product.
+func (s *Server) netQuantity(token uint32, product string) int {
+	for _, p := range s.positions {
+		if p.InstrumentToken == token && p.Product == product {
+			return p.Quantity
+		}
+	}
+	return 0
+}
+
+// valued returns a position with its averages and P&L at the last price.
+func valued(p kiteconnect.Position, in *instrument) kiteconnect.Position {
+	average := func(value float64, quantity int) float64 {
+		if quantity == 0 {
+			return 0
+		}
+		return value / float64(quantity)
+	}
+	p.BuyPrice = average(p.BuyValue, p.BuyQuantity)
+	p.SellPrice = average(p.SellValue, p.SellQuantity)
+	p.DayBuyPrice = average(p.DayBuyValue, p.DayBuyQuantity)
+	p.DaySellPrice = average(p.DaySellValue, p.DaySellQuantity)
+	p.BuyM2MValue, p.SellM2MValue = p.BuyValue, p.SellValue
+
+	p.LastPrice, p.ClosePrice = in.last, in.close
+	p.Value = p.SellValue - p.BuyValue
+	p.PnL = p.Value + float64(p.Quantity)*in.last*p.Multiplier
+	p.M2M = p.PnL
+
+	matched := p.BuyQuantity
+	if p.SellQuantity < matched {
+		matched = p.SellQuantity
+	}
+	p.Realised = float64(matched) * (p.SellPrice - p.BuyPrice)
+	p.Unrealised = p.PnL - p.Realised
+	switch {
+	case p.Quantity > 0:
+		p.AveragePrice = p.BuyPrice
+	case p.Quantity < 0:
+		p.AveragePrice = p.SellPrice
+	}
+	return p
+}
+
+func (s *Server) getPositions(r *http.Request, _ []string) (interface{}, error) {
+	out := make([]kiteconnect.Position, 0, len(s.positions))
+	for _, p := range s.positions {
+		out = append(out, valued(*p, s.byToken[p.InstrumentToken]))
+	}
+	return map[string]interface{}{"net": out, "day": out}, nil
+}
+
+// convertPosition moves quantity of a position to another product.
+func (s *Server) convertPosition(r *http.Request, _ []string) (interface{}, error) {
+	in, ok := s.bySymbol[r.Form.Get("exchange")+":"+r.Form.Get("tradingsymbol")]
+	if !ok {
+		return nil, inputError("Invalid `tradingsymbol`.")
+	}
+	quantity, err := formInt(r, "quantity")
+	if err != nil {
+		return nil, err
+	}
+	oldProduct, newProduct := r.Form.Get("old_product"), r.Form.Get("new_product")
+	if newProduct == oldProduct || !productAllowed(in, newProduct) {
+		return nil, inputError("Invalid `new_product`.")
+	}
+
+	var from *kiteconnect.Position
+	for _, p := range s.positions {
+		if p.InstrumentToken == in.token && p.Product == oldProduct {
+			from = p
+		}
+	}
+	buy := r.Form.Get("transaction_type") == kiteconnect.TransactionTypeBuy
+	if from == nil || quantity <= 0 || (buy && from.Quantity < quantity) || (!buy && -from.Quantity < quantity) {
+		return nil, inputError("Position with quantity %d not found.", quantity)
+	}
+
+	to := s.position(in, newProduct)
+	q := float64(quantity)
+	if buy {
+		value := q * from.BuyValue / float64(from.BuyQuantity)
+		from.BuyQuantity, from.DayBuyQuantity = from.BuyQuantity-quantity, from.DayBuyQuantity-quantity
+		from.BuyValue, from.DayBuyValue = from.BuyValue-value, from.DayBuyValue-value
+		to.BuyQuantity, to.DayBuyQuantity = to.BuyQuantity+quantity, to.DayBuyQuantity+quantity
+		to.BuyValue, to.DayBuyValue = to.BuyValue+value, to.DayBuyValue+value
+	} else {
+		value := q * from.SellValue / float64(from.SellQuantity)
+		from.SellQuantity, from.DaySellQuantity = from.SellQuantity-quantity, from.DaySellQuantity-quantity
+		from.SellValue, from.DaySellValue = from.SellValue-value, from.DaySellValue-value
+		to.SellQuantity, to.DaySellQuantity = to.SellQuantity+quantity, to.DaySellQuantity+quantity
+		to.SellValue, to.DaySellValue = to.SellValue+value, to.DaySellValue+value
+	}
+	from.Quantity = from.BuyQuantity - from.SellQuantity
+	to.Quantity = to.BuyQuantity - to.SellQuantity
+	return true, nil
+}
+
+// holding is a holding as Kite returns it.
+type holding struct {
+	Tradingsymbol       string                 `json:"tradingsymbol"`
+	Exchange            string                 `json:"exchange"`
+	InstrumentToken     uint32                 `json:"instrument_token"`
+	ISIN                string                 `json:"isin"`
+	Product             string                 `json:"product"`
+	Price               float64                `json:"price"`
+	UsedQuantity        int                    `json:"used_quantity"`
+	Quantity            int                    `json:"quantity"`
+	T1Quantity          int                    `json:"t1_quantity"`
+	RealisedQuantity    int                    `json:"realised_quantity"`
+	AuthorisedQuantity  int                    `json:"authorised_quantity"`
+	AuthorisedDate      string                 `json:"authorised_date"`
+	OpeningQuantity     int                    `json:"opening_quantity"`
+	CollateralQuantity  int                    `json:"collateral_quantity"`
+	CollateralType      string                 `json:"collateral_type"`
+	Discrepancy         bool                   `json:"discrepancy"`
+	AveragePrice        float64                `json:"average_price"`
+	LastPrice           float64                `json:"last_price"`
+	ClosePrice          float64                `json:"close_price"`
+	PnL                 float64                `json:"pnl"`
+	DayChange           float64                `json:"day_change"`
+	DayChangePercentage float64                `json:"day_change_percentage"`
+	MTF                 map[string]interface{} `json:"mtf"`
+}
+
+// Seed holdings, bought on NSE before today.
+var holdingSeeds = []struct {
+	symbol, isin string
+	quantity     int
+	average      float64
+}{
+	{"INFY", "INE009A01021", 10, 1420.5},
+	{"RELIANCE", "INE002A01018", 25, 1295.2},
+	{"SBIN", "INE062A01020", 40, 780.35},
+}
+
+func (s *Server) seedHoldings() {
+	for _, h := range holdingSeeds {
+		in := s.bySymbol["NSE:"+h.symbol]
+		s.holdings = append(s.holdings, &holding{
+			Tradingsymbol:    in.symbol,
+			Exchange:         in.exchange,
+			InstrumentToken:  in.token,
+			ISIN:             h.isin,
+			Product:          kiteconnect.ProductCNC,
+			Quantity:         h.quantity,
+			RealisedQuantity: h.quantity,
+			OpeningQuantity:  h.quantity,
+			AveragePrice:     h.average,
+		})
+	}
+}
+
+// holdingQuantity is the quantity held of an equity, on either exchange.
+func (s *Server) holdingQuantity(symbol string) int {
+	for _, h := range s.holdings {
+		if h.Tradingsymbol == symbol {
+			return h.Quantity
+		}
+	}
+	return 0
+}
+
+// getHoldings serves the holdings, with the quantity sold today from them
+// as used.
+func (s *Server) getHoldings(r *http.Request, _ []string) (interface{}, error) {
+	out := make([]holding, 0, len(s.holdings))
+	for _, h := range s.holdings {
+		in := s.byToken[h.InstrumentToken]
+		v := *h
+		sold := -(s.netQuantity(in.token, kiteconnect.ProductCNC) + s.netQuantity(s.bySymbol["BSE:"+in.symbol].token, kiteconnect.ProductCNC))
+		if sold > 0 {
+			v.UsedQuantity = int(math.Min(float64(sold), float64(v.Quantity)))
+		}
+		v.LastPrice, v.ClosePrice = in.last, in.close
+		v.PnL = float64(v.Quantity) * (in.last - v.AveragePrice)
+		v.DayChange = in.last - in.close
+		v.DayChangePercentage = v.DayChange / in.close * 100
+		v.MTF = map[string]interface{}{"quantity": 0, "used_quantity": 0, "average_price": 0, "value": 0, "initial_margin": 0}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Margin rates of the margin model: the fraction of the notional blocked.
+const (
+	misRate      = 0.2  // Intraday equity
+	spanRate     = 0.12 // Futures and short options
+	exposureRate = 0.03
+)
+
+// margin returns the margin of quantity of an instrument bought or sold at
+// price. Delivery buys and option buys pay in full; options are sold on
+// their underlying's notional.
+func margin(in *instrument, product string, buy bool, quantity, price float64) kiteconnect.UsedMargins {
+	var m kiteconnect.UsedMargins
+	switch {
+	case in.isOption() && buy:
+		m.OptionPremium = quantity * price
+	case in.isOption():
+		notional := quantity * in.underlying.last
+		m.Span, m.Exposure = spanRate*notional, exposureRate*notional
+	case in.kind == "FUT":
+		notional := quantity * price
+		m.Span, m.Exposure = spanRate*notional, exposureRate*notional
+	case product == kiteconnect.ProductCNC:
+		if buy {
+			m.Delivery = quantity * price
+		}
+	default:
+		m.Exposure = misRate * quantity * price
+	}
+	return m
+}
+
+func addMargins(a, b kiteconnect.UsedMargins) kiteconnect.UsedMargins {
+	a.Span += b.Span
+	a.Exposure += b.Exposure
+	a.OptionPremium += b.OptionPremium
+	a.Delivery += b.Delivery
+	return a
+}
+
+func totalMargin(m kiteconnect.UsedMargins) float64 {
+	return m.Span + m.Exposure + m.OptionPremium + m.Delivery
+}
+
+// orderMargin is the margin a pending order blocks: nothing for the part
+// reducing the position, and at the last price for market orders.
+func (s *Server) orderMargin(o *order, in *instrument) kiteconnect.UsedMargins {
+	fresh := o.PendingQuantity
+	net := float64(s.netQuantity(in.token, o.Product))
+	if (o.buy() && net < 0) || (!o.buy() && net > 0) {
+		fresh = math.Max(0, fresh-math.Abs(net))
+	}
+	price := o.Price
+	if price == 0 {
+		price = math.Max(o.TriggerPrice, in.last)
+	}
+	return margin(in, o.Product, o.buy(), fresh, price)
+}
+
+// equityMargins computes the equity segment from the opening cash, the
+// margin of positions and pending orders, and the day's P&L.
+func (s *Server) equityMargins() kiteconnect.Margins {
+	var used kiteconnect.UsedMargins
+	for _, p := range s.positions {
+		in := s.byToken[p.InstrumentToken]
+		v := valued(*p, in)
+		used.M2MRealised += v.Realised
+		used.M2MUnrealised += v.Unrealised
+		if p.Quantity != 0 {
+			used = addMargins(used, margin(in, p.Product, p.Quantity > 0, math.Abs(float64(p.Quantity)), v.AveragePrice))
+		}
+	}
+	for _, o := range s.orders {
+		if o.pending() {
+			used = addMargins(used, s.orderMargin(o, s.byToken[o.InstrumentToken]))
+		}
+	}
+	used.Debits = totalMargin(used)
+	net := s.cash + used.M2MRealised + used.M2MUnrealised - used.Debits
+
+	return kiteconnect.Margins{
+		Category: "equity",
+		Enabled:  true,
+		Net:      net,
+		Available: kiteconnect.AvailableMargins{
+			Cash:           s.cash,
+			OpeningBalance: s.cash,
+			LiveBalance:    net,
+		},
+		Used: used,
+	}
+}
+
+// risk returns why a new order is rejected: selling more than is held for
+// delivery, or not having the margin.
+func (s *Server) risk(o *order, in *instrument) string {
+	if o.Product == kiteconnect.ProductCNC && !o.buy() {
+		held := s.holdingQuantity(in.symbol) + s.netQuantity(in.token, kiteconnect.ProductCNC)
+		if o.Quantity > float64(held) {
+			return fmt.Sprintf("Insufficient holdings. Available %d, requested %v.", held, o.Quantity)
+		}
+		return ""
+	}
+
+	required := totalMargin(s.orderMargin(o, in))
+	if available := s.equityMargins().Net; required > available {
+		return fmt.Sprintf("Insufficient funds. Required margin is %.2f but available margin is %.2f.", required, available)
+	}
+	return ""
+}
+
+func (s *Server) getMargins(r *http.Request, _ []string) (interface{}, error) {
+	return map[string]kiteconnect.Margins{
+		"equity":    s.equityMargins(),
+		"commodity": {Enabled: true},
+	}, nil
+}
+
+func (s *Server) getSegmentMargins(r *http.Request, params []string) (interface{}, error) {
+	switch params[0] {
+	case "equity":
+		return s.equityMargins(), nil
+	case "commodity":
+		return kiteconnect.Margins{Enabled: true}, nil
+	}
+	return nil, inputError("Invalid segment `%s`.", params[0])
+}
@@ -0,0 +1,428 @@
+// Package kitefake is an in-process fake of the Kite Connect REST API and
+// ticker. It keeps orders, positions, holdings, margins, GTTs and market data
+// in memory with Kite's state transitions, so handlers can be tested end to
+// end and the service run locally without a Zerodha account. Point a client
+// at it with Client.SetBaseURI and a ticker with SetRootURL on /ws.
+package kitefake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+)
+
+// UserID is the user the fake is logged in as.
+const UserID = "FK0001"
+
+// Defaults of a new server.
+const (
+	DefaultCash = 1000000.0
+	DefaultSeed = 1
+)
+
+// ist is Indian Standard Time, the timezone of every timestamp Kite sends.
+var ist = time.FixedZone("IST", 5*3600+1800)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithClock sets the clock of the server, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) { s.now = now }
+}
+
+// WithSeed seeds the random walk of prices, so runs are reproducible.
+func WithSeed(seed int64) Option {
+	return func(s *Server) { s.seed = seed }
+}
+
+// WithCash sets the opening cash balance of the equity segment.
+func WithCash(cash float64) Option {
+	return func(s *Server) { s.cash = cash }
+}
+
+// WithAccessToken makes the server accept only requests authenticated with
+// the token, as an access token or enctoken. Any token is accepted by default.
+func WithAccessToken(token string) Option {
+	return func(s *Server) { s.accessToken = token }
+}
+
+// Server is a fake Kite Connect API. It is safe for concurrent use.
+type Server struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	seed        int64
+	rnd         *rand.Rand
+	accessToken string
+
+	instruments []*instrument
+	byToken     map[uint32]*instrument
+	bySymbol    map[string]*instrument // By exchange:tradingsymbol
+
+	cash      float64
+	orders    []*order
+	orderByID map[string]*order
+	trades    []trade
+	positions []*kiteconnect.Position
+	holdings  []*holding
+	gtts      []*gtt
+	orderSeq  int
+	tradeSeq  int
+	gttSeq    int
+
+	failures []failure
+	ticker   tickerHub
+}
+
+// New creates a fake with the seed instruments, holdings and cash.
+func New(opts ...Option) *Server {
+	s := &Server{
+		now:       time.Now,
+		seed:      DefaultSeed,
+		cash:      DefaultCash,
+		byToken:   make(map[uint32]*instrument),
+		bySymbol:  make(map[string]*instrument),
+		orderByID: make(map[string]*order),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.rnd = rand.New(rand.NewSource(s.seed))
+	s.ticker.conns = make(map[*tickerConn]struct{})
+
+	s.seedInstruments(s.now())
+	s.seedHoldings()
+	return s
+}
+
+// failure is an injected error for the next request to a route.
+type failure struct {
+	method, path string
+	err          *apiError
+}
+
+// Fail makes the next request to method and path fail with status and a
+// Kite error envelope, to exercise error handling.
+func (s *Server) Fail(method, path string, status int, errorType, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{method, path, &apiError{status, errorType, message}})
+}
+
+// takeFailure removes and returns the failure injected for a request, if any.
+func (s *Server) takeFailure(method, path string) *apiError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.failures {
+		if f.method == method && f.path == path {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			return f.err
+		}
+	}
+	return nil
+}
+
+// apiError is an error response in Kite's envelope.
+type apiError struct {
+	status    int
+	errorType string
+	message   string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func inputError(format string, a ...interface{}) error {
+	return &apiError{http.StatusBadRequest, kiteconnect.InputError, fmt.Sprintf(format, a...)}
+}
+
+func orderError(format string, a ...interface{}) error {
+	return &apiError{http.StatusBadRequest, kiteconnect.OrderError, fmt.Sprintf(format, a...)}
+}
+
+func notFound(format string, a ...interface{}) error {
+	return &apiError{http.StatusNotFound, kiteconnect.GeneralError, fmt.Sprintf(format, a...)}
+}
+
+// csvBody is a response sent as CSV rather than in the JSON envelope.
+type csvBody []byte
+
+// handler serves a route with the path segments matched by its wildcards,
+// holding the server lock.
+type handler func(s *Server, r *http.Request, params []string) (interface{}, error)
+
+type route struct {
+	method  string
+	pattern string // Path with * for a single segment
+	handle  handler
+}
+
+var routes = []route{
+	{http.MethodGet, "/user/profile", (*Server).getProfile},
+	{http.MethodGet, "/user/profile/full", (*Server).getFullProfile},
+	{http.MethodGet, "/user/margins", (*Server).getMargins},
+	{http.MethodGet, "/user/margins/*", (*Server).getSegmentMargins},
+
+	{http.MethodGet, "/orders", (*Server).getOrders},
+	{http.MethodGet, "/trades", (*Server).getTrades},
+	{http.MethodGet, "/orders/*", (*Server).getOrderHistory},
+	{http.MethodGet, "/orders/*/trades", (*Server).getOrderTrades},
+	{http.MethodPost, "/orders/*", (*Server).placeOrder},
+	{http.MethodPut, "/orders/*/*", (*Server).modifyOrder},
+	{http.MethodDelete, "/orders/*/*", (*Server).cancelOrder},
+
+	{http.MethodGet, "/portfolio/positions", (*Server).getPositions},
+	{http.MethodPut, "/portfolio/positions", (*Server).convertPosition},
+	{http.MethodGet, "/portfolio/holdings", (*Server).getHoldings},
+
+	{http.MethodGet, "/gtt/triggers", (*Server).getGTTs},
+	{http.MethodGet, "/gtt/triggers/*", (*Server).getGTT},
+	{http.MethodPost, "/gtt/triggers", (*Server).placeGTT},
+	{http.MethodPut, "/gtt/triggers/*", (*Server).modifyGTT},
+	{http.MethodDelete, "/gtt/triggers/*", (*Server).deleteGTT},
+
+	{http.MethodGet, "/quote", (*Server).getQuote},
+	{http.MethodGet, "/quote/ltp", (*Server).getLTP},
+	{http.MethodGet, "/quote/ohlc", (*Server).getOHLC},
+	{http.MethodGet, "/instruments", (*Server).getInstruments},
+	{http.MethodGet, "/instruments/*", (*Server).getInstruments},
+	{http.MethodGet, "/instruments/historical/*/*", (*Server).getHistorical},
+}
+
+// match returns the route of a request and the segments of its wildcards.
+func match(method, path string) (handler, []string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, rt := range routes {
+		if rt.method != method {
+			continue
+		}
+		pattern := strings.Split(strings.Trim(rt.pattern, "/"), "/")
+		if len(pattern) != len(segments) {
+			continue
+		}
+		var params []string
+		matched := true
+		for i, p := range pattern {
+			if p == "*" {
+				params = append(params, segments[i])
+			} else if p != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rt.handle, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// ServeHTTP serves the Kite REST API and, on /ws, the ticker.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ws" {
+		s.serveTicker(w, r)
+		return
+	}
+	if err := s.takeFailure(r.Method, r.URL.Path); err != nil {
+		writeError(w, err)
+		return
+	}
+	if !s.authorized(r.Header.Get("Authorization")) {
+		writeError(w, &apiError{http.StatusForbidden, kiteconnect.TokenError, "Incorrect `api_key` or `access_token`."})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, inputError("Invalid request body: %v", err))
+		return
+	}
+
+	handle, params, ok := match(r.Method, r.URL.Path)
+	if !ok {
+		writeError(w, &apiError{http.StatusNotFound, kiteconnect.GeneralError, "Route not found"})
+		return
+	}
+
+	s.mu.Lock()
+	data, err := handle(s, r, params)
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if body, ok := data.(csvBody); ok {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write(body)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "data": data})
+}
+
+// authorized reports whether an Authorization header carries an access
+// token ("token api_key:access_token") or an enctoken ("enctoken token").
+func (s *Server) authorized(header string) bool {
+	var token string
+	switch {
+	case strings.HasPrefix(header, "token "):
+		parts := strings.SplitN(strings.TrimPrefix(header, "token "), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return false
+		}
+		token = parts[1]
+	case strings.HasPrefix(header, "enctoken "):
+		token = strings.TrimPrefix(header, "enctoken ")
+	default:
+		return false
+	}
+	if s.accessToken == "" {
+		return token != ""
+	}
+	return token == s.accessToken
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiError)
+	if !ok {
+		e = &apiError{http.StatusInternalServerError, kiteconnect.GeneralError, err.Error()}
+	}
+	writeJSON(w, e.status, map[string]interface{}{
+		"status":     "error",
+		"error_type": e.errorType,
+		"message":    e.message,
+		"data":       nil,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// kiteTime formats a time as Kite does in responses, empty when unset.
+func kiteTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(ist).Format("2006-01-02 15:04:05")
+}
+
+func (s *Server) getProfile(r *http.Request, _ []string) (interface{}, error) {
+	return map[string]interface{}{
+		"user_id":        UserID,
+		"user_name":      "Kite Fake",
+		"user_shortname": "Fake",
+		"user_type":      "individual",
+		"email":          "fake@example.com",
+		"broker":         "ZERODHA",
+		"avatar_url":     nil,
+		"meta":           map[string]interface{}{"demat_consent": "physical"},
+		"products":       []string{kiteconnect.ProductCNC, kiteconnect.ProductNRML, kiteconnect.ProductMIS},
+		"order_types":    []string{kiteconnect.OrderTypeMarket, kiteconnect.OrderTypeLimit, kiteconnect.OrderTypeSL, kiteconnect.OrderTypeSLM},
+		"exchanges":      []string{kiteconnect.ExchangeNSE, kiteconnect.ExchangeBSE, kiteconnect.ExchangeNFO},
+	}, nil
+}
+
+func (s *Server) getFullProfile(r *http.Request, _ []string) (interface{}, error) {
+	return map[string]interface{}{
+		"user_id":            UserID,
+		"user_name":          "Kite Fake",
+		"user_shortname":     "Fake",
+		"user_type":          "individual",
+		"email":              "fake@example.com",
+		"phone":              "*9999",
+		"broker":             "ZERODHA",
+		"twofa_type":         "totp",
+		"bank_accounts":      []map[string]string{{"name": "FAKE BANK", "branch": "MUMBAI", "account": "*1234"}},
+		"dp_ids":             []string{"1234567890123456"},
+		"products":           []string{kiteconnect.ProductCNC, kiteconnect.ProductNRML, kiteconnect.ProductMIS},
+		"order_types":        []string{kiteconnect.OrderTypeMarket, kiteconnect.OrderTypeLimit, kiteconnect.OrderTypeSL, kiteconnect.OrderTypeSLM},
+		"exchanges":          []string{kiteconnect.ExchangeNSE, kiteconnect.ExchangeBSE, kiteconnect.ExchangeNFO},
+		"pan":                "*1234F",
+		"tags":               []string{},
+		"password_timestamp": "",
+		"twofa_timestamp":    "",
+		"meta":               map[string]interface{}{"poa": "consent", "silo": "", "account_blocks": []string{}},
+	}, nil
+}
+
+// Run steps the market every interval until stop is closed.
+func (s *Server) Run(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			s.Step()
+		}
+	}
+}
+
+// Step moves the market by one tick: spot prices take a random walk,
+// derivatives are repriced, resting orders and GTTs are matched against the
+// new prices and ticker subscribers receive them.
+func (s *Server) Step() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, in := range s.instruments {
+		if in.underlying == nil {
+			in.trade(roundTick(in.last*(1+in.stepVol*s.rnd.NormFloat64()), in.tick), s.rnd, now)
+		}
+	}
+	s.settle(now)
+}
+
+// SetPrice sets the last price of a spot instrument, an index or equity, and
+// reprices its derivatives, matching orders and GTTs as Step does.
+func (s *Server) SetPrice(exchange, tradingsymbol string, price float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	in, ok := s.bySymbol[exchange+":"+tradingsymbol]
+	if !ok {
+		return fmt.Errorf("unknown instrument %s:%s", exchange, tradingsymbol)
+	}
+	if in.underlying != nil {
+		return fmt.Errorf("%s:%s is priced off its underlying", exchange, tradingsymbol)
+	}
+	now := s.now()
+	in.trade(price, s.rnd, now)
+	s.settle(now)
+	return nil
+}
+
+// LastPrice returns the last price of an instrument.
+func (s *Server) LastPrice(exchange, tradingsymbol string) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	in, ok := s.bySymbol[exchange+":"+tradingsymbol]
+	if !ok {
+		return 0, false
+	}
+	return in.last, true
+}
+
+// settle reprices derivatives after spots moved, then matches orders and
+// GTTs and sends the ticks.
+func (s *Server) settle(now time.Time) {
+	for _, in := range s.instruments {
+		if in.underlying != nil {
+			in.trade(in.fair(now), s.rnd, now)
+		}
+	}
+	s.matchOrders(now)
+	s.triggerGTTs(now)
+	s.broadcast(now)
+}
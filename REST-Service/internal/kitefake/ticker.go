@@ -0,0 +1,255 @@
+package kitefake
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Ticker modes and their packet lengths, as the Kite ticker sends them.
+const (
+	modeLTP   = "ltp"
+	modeQuote = "quote"
+	modeFull  = "full"
+
+	ltpLength        = 8
+	indexQuoteLength = 28
+	indexFullLength  = 32
+	quoteLength      = 44
+	fullLength       = 184
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// tickerHub holds the ticker connections.
+type tickerHub struct {
+	mu    sync.Mutex
+	conns map[*tickerConn]struct{}
+}
+
+// tickerConn is a ticker connection and the mode of each subscribed token.
+type tickerConn struct {
+	ws    *websocket.Conn
+	send  chan []byte
+	mu    sync.Mutex
+	modes map[uint32]string
+}
+
+// tickerMessage is a subscribe, unsubscribe or mode request.
+type tickerMessage struct {
+	Action string          `json:"a"`
+	Value  json.RawMessage `json:"v"`
+}
+
+// serveTicker serves a ticker connection: tokens subscribe in quote mode,
+// get their current tick at once and then one after every market move.
+func (s *Server) serveTicker(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &tickerConn{ws: ws, send: make(chan []byte, 64), modes: make(map[uint32]string)}
+	s.ticker.mu.Lock()
+	s.ticker.conns[c] = struct{}{}
+	s.ticker.mu.Unlock()
+
+	go c.write()
+	defer func() {
+		s.ticker.mu.Lock()
+		delete(s.ticker.conns, c)
+		s.ticker.mu.Unlock()
+		close(c.send)
+	}()
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var m tickerMessage
+		if err := json.Unmarshal(msg, &m); err != nil {
+			continue
+		}
+
+		var tokens []uint32
+		switch m.Action {
+		case "subscribe", "unsubscribe":
+			if err := json.Unmarshal(m.Value, &tokens); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			for _, t := range tokens {
+				if m.Action == "subscribe" {
+					c.modes[t] = modeQuote
+				} else {
+					delete(c.modes, t)
+				}
+			}
+			c.mu.Unlock()
+		case "mode":
+			var v []json.RawMessage
+			var mode string
+			if json.Unmarshal(m.Value, &v) != nil || len(v) != 2 || json.Unmarshal(v[0], &mode) != nil || json.Unmarshal(v[1], &tokens) != nil {
+				continue
+			}
+			if mode != modeLTP && mode != modeQuote && mode != modeFull {
+				continue
+			}
+			c.mu.Lock()
+			for _, t := range tokens {
+				if _, ok := c.modes[t]; ok {
+					c.modes[t] = mode
+				}
+			}
+			c.mu.Unlock()
+		default:
+			continue
+		}
+
+		if m.Action != "unsubscribe" {
+			s.mu.Lock()
+			c.push(s.packets(c, tokens, s.now()))
+			s.mu.Unlock()
+		}
+	}
+}
+
+// write sends the queued messages until the connection closes.
+func (c *tickerConn) write() {
+	for msg := range c.send {
+		c.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := c.ws.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+			c.ws.Close()
+			for range c.send {
+			}
+			return
+		}
+	}
+	c.ws.Close()
+}
+
+// push queues a message, dropping it if the client is too slow.
+func (c *tickerConn) push(msg []byte) {
+	if msg == nil {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+	}
+}
+
+// broadcast sends every connection the ticks of its subscriptions.
+func (s *Server) broadcast(now time.Time) {
+	s.ticker.mu.Lock()
+	defer s.ticker.mu.Unlock()
+	for c := range s.ticker.conns {
+		c.push(s.packets(c, nil, now))
+	}
+}
+
+// packets returns a message of the ticks of tokens, or of all subscribed
+// tokens if nil, in the modes the connection subscribed them in. Messages
+// are a count of packets, then each packet prefixed with its length.
+func (s *Server) packets(c *tickerConn, tokens []uint32, now time.Time) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tokens == nil {
+		for t := range c.modes {
+			tokens = append(tokens, t)
+		}
+	}
+	var pkts [][]byte
+	for _, t := range tokens {
+		mode, ok := c.modes[t]
+		in, known := s.byToken[t]
+		if ok && known {
+			pkts = append(pkts, s.packet(in, mode, now))
+		}
+	}
+	if len(pkts) == 0 {
+		return nil
+	}
+
+	msg := make([]byte, 2, 2+len(pkts)*(2+fullLength))
+	binary.BigEndian.PutUint16(msg, uint16(len(pkts)))
+	for _, p := range pkts {
+		msg = append(msg, byte(len(p)>>8), byte(len(p)))
+		msg = append(msg, p...)
+	}
+	return msg
+}
+
+// packet encodes the tick of an instrument in a mode. Prices are in paise.
+func (s *Server) packet(in *instrument, mode string, now time.Time) []byte {
+	paise := func(p float64) uint32 { return uint32(math.Round(p * 100)) }
+
+	length := ltpLength
+	switch {
+	case mode == modeLTP:
+	case in.index && mode == modeFull:
+		length = indexFullLength
+	case in.index:
+		length = indexQuoteLength
+	case mode == modeFull:
+		length = fullLength
+	default:
+		length = quoteLength
+	}
+	b := make([]byte, length)
+	put := func(offset int, v uint32) { binary.BigEndian.PutUint32(b[offset:], v) }
+	put(0, in.token)
+	put(4, paise(in.last))
+	if length == ltpLength {
+		return b
+	}
+
+	if in.index {
+		put(8, paise(in.high))
+		put(12, paise(in.low))
+		put(16, paise(in.open))
+		put(20, paise(in.close))
+		put(24, uint32(int32(math.Round((in.last-in.close)*100))))
+		if length == indexFullLength {
+			put(28, uint32(now.Unix()))
+		}
+		return b
+	}
+
+	depth := s.depth(in)
+	put(8, uint32(in.lastQty))
+	put(12, paise(in.averagePrice()))
+	put(16, uint32(in.volume))
+	put(20, uint32(depthQuantity(depth.Buy)))
+	put(24, uint32(depthQuantity(depth.Sell)))
+	put(28, paise(in.open))
+	put(32, paise(in.high))
+	put(36, paise(in.low))
+	put(40, paise(in.close))
+	if length == quoteLength {
+		return b
+	}
+
+	put(44, uint32(in.lastTrade.Unix()))
+	put(48, uint32(in.oi))
+	put(52, uint32(in.oiHigh))
+	put(56, uint32(in.oiLow))
+	put(60, uint32(now.Unix()))
+	for i, side := range [][5]depthLevel{depth.Buy, depth.Sell} {
+		for j, l := range side {
+			offset := 64 + i*60 + j*12
+			put(offset, uint32(l.Quantity))
+			put(offset+4, paise(l.Price))
+			binary.BigEndian.PutUint16(b[offset+8:], uint16(l.Orders))
+		}
+	}
+	return b
+}
@@ -0,0 +1,375 @@
+// Package metrics is a small Prometheus client: counters, gauges and
+// histograms with labels, served in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics in the order they were registered. The zero value
+// is empty and ready to use.
+type Registry struct {
+	metrics []collector
+	names   map[string]bool
+	mu      sync.Mutex
+}
+
+// DefaultRegistry is the registry the New functions register with
+var DefaultRegistry = &Registry{}
+
+// collector writes a metric family in the text format
+type collector interface {
+	family() string
+	write(w *bufio.Writer)
+}
+
+// register adds a metric family, panicking if one of the same name is
+// already registered, as the exposition would repeat it
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[c.family()] {
+		panic(fmt.Sprintf("metrics: %s registered twice", c.family()))
+	}
+	if r.names == nil {
+		r.names = make(map[string]bool)
+	}
+	r.names[c.family()] = true
+	r.metrics = append(r.metrics, c)
+}
+
+// Handler serves the registry in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.mu.Lock()
+		metrics := append([]collector(nil), r.metrics...)
+		r.mu.Unlock()
+
+		bw := bufio.NewWriter(w)
+		for _, m := range metrics {
+			m.write(bw)
+		}
+		bw.Flush()
+	})
+}
+
+// desc is the name, help and label names of a metric family
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) family() string {
+	return d.name
+}
+
+func (d desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.typ)
+}
+
+// key joins label values into a map key, checking their number
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// series formats a series name with its labels, plus an extra label if set
+func (d desc) series(suffix string, values []string, extraName, extraValue string) string {
+	var b strings.Builder
+	b.WriteString(d.name)
+	b.WriteString(suffix)
+	if len(values) == 0 && extraName == "" {
+		return b.String()
+	}
+	b.WriteByte('{')
+	for i, name := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escape(values[i]))
+		b.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(values) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName)
+		b.WriteString(`="`)
+		b.WriteString(extraValue)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sample is the value of one label set
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// values is a set of samples by label values
+type values struct {
+	desc
+	samples map[string]*sample
+	mu      sync.Mutex
+}
+
+func (r *Registry) newValues(name, help, typ string, labels []string) *values {
+	v := &values{desc: desc{name: name, help: help, typ: typ, labels: labels}, samples: make(map[string]*sample)}
+	r.register(v)
+	return v
+}
+
+func (v *values) add(delta float64, labels []string) {
+	key := v.key(labels)
+	v.mu.Lock()
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labels: append([]string(nil), labels...)}
+		v.samples[key] = s
+	}
+	s.value += delta
+	v.mu.Unlock()
+}
+
+func (v *values) set(value float64, labels []string) {
+	key := v.key(labels)
+	v.mu.Lock()
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labels: append([]string(nil), labels...)}
+		v.samples[key] = s
+	}
+	s.value = value
+	v.mu.Unlock()
+}
+
+func (v *values) write(w *bufio.Writer) {
+	v.mu.Lock()
+	samples := make([]sample, 0, len(v.samples))
+	for _, s := range v.samples {
+		samples = append(samples, *s)
+	}
+	v.mu.Unlock()
+
+	v.header(w)
+	writeSamples(w, v.desc, samples)
+}
+
+func writeSamples(w *bufio.Writer, d desc, samples []sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].labels, "\xff") < strings.Join(samples[j].labels, "\xff")
+	})
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s %s\n", d.series("", s.labels, "", ""), formatFloat(s.value))
+	}
+}
+
+// Counter is a value per label set that only goes up
+type Counter struct {
+	*values
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.newValues(name, help, "counter", labels)}
+}
+
+// Inc adds one to the counter of the label values
+func (c *Counter) Inc(labels ...string) {
+	c.add(1, labels)
+}
+
+// Add adds a non-negative delta to the counter of the label values
+func (c *Counter) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		return
+	}
+	c.add(delta, labels)
+}
+
+// Gauge is a value per label set that goes up and down
+type Gauge struct {
+	*values
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+// NewGauge registers a gauge with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.newValues(name, help, "gauge", labels)}
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(value float64, labels ...string) {
+	g.set(value, labels)
+}
+
+// Add adds delta to the gauge of the label values
+func (g *Gauge) Add(delta float64, labels ...string) {
+	g.add(delta, labels)
+}
+
+// funcMetric reads its samples from a callback at scrape time
+type funcMetric struct {
+	desc
+	collect func(emit func(value float64, labels ...string))
+}
+
+// NewGaugeFunc registers a gauge whose samples collect emits at scrape time
+func NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labels ...string))) {
+	DefaultRegistry.NewGaugeFunc(name, help, labels, collect)
+}
+
+// NewGaugeFunc registers a gauge whose samples collect emits at scrape time
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labels ...string))) {
+	r.register(&funcMetric{desc: desc{name: name, help: help, typ: "gauge", labels: labels}, collect: collect})
+}
+
+// NewCounterFunc registers a counter whose samples collect emits at scrape time
+func NewCounterFunc(name, help string, labels []string, collect func(emit func(value float64, labels ...string))) {
+	DefaultRegistry.NewCounterFunc(name, help, labels, collect)
+}
+
+// NewCounterFunc registers a counter whose samples collect emits at scrape time
+func (r *Registry) NewCounterFunc(name, help string, labels []string, collect func(emit func(value float64, labels ...string))) {
+	r.register(&funcMetric{desc: desc{name: name, help: help, typ: "counter", labels: labels}, collect: collect})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	var samples []sample
+	f.collect(func(value float64, labels ...string) {
+		f.key(labels)
+		samples = append(samples, sample{labels: labels, value: value})
+	})
+	f.header(w)
+	writeSamples(w, f.desc, samples)
+}
+
+// Histogram counts observations per label set in cumulative buckets
+type Histogram struct {
+	desc
+	buckets []float64
+	data    map[string]*histogramSeries
+	mu      sync.Mutex
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds,
+// ascending, and label names
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds,
+// ascending, and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		data:    make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records a value for the label values
+func (h *Histogram) Observe(value float64, labels ...string) {
+	key := h.key(labels)
+	i := sort.SearchFloat64s(h.buckets, value)
+
+	h.mu.Lock()
+	s, ok := h.data[key]
+	if !ok {
+		s = &histogramSeries{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.data[key] = s
+	}
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	series := make([]histogramSeries, 0, len(h.data))
+	for _, s := range h.data {
+		c := *s
+		c.counts = append([]uint64(nil), s.counts...)
+		series = append(series, c)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(series, func(i, j int) bool {
+		return strings.Join(series[i].labels, "\xff") < strings.Join(series[j].labels, "\xff")
+	})
+	h.header(w)
+	for _, s := range series {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s %d\n", h.series("_bucket", s.labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s %d\n", h.series("_bucket", s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s %s\n", h.series("_sum", s.labels, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s %d\n", h.series("_count", s.labels, "", ""), s.count)
+	}
+}
+
+// ExponentialBuckets returns count bucket bounds starting at start, each
+// factor times the last
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
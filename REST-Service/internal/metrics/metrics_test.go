@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	return w.Body.String()
+}
+
+// sampleValue returns the value of a series in the exposition, 0 if absent.
+func sampleValue(t *testing.T, out, series string) float64 {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+			require.NoError(t, err)
+			return v
+		}
+	}
+	return 0
+}
+
+func TestExposition(t *testing.T) {
+	r := &Registry{}
+	requests := r.NewCounter("test_requests_total", "Requests.", "path")
+	requests.Inc(`/a"b`)
+	requests.Add(2, `/a"b`)
+	requests.Add(-1, `/a"b`) // Counters never go down
+
+	inflight := r.NewGauge("test_inflight", "In flight.")
+	inflight.Set(3)
+	inflight.Add(-1)
+
+	latency := r.NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1}, "method")
+	latency.Observe(0.05, "GET")
+	latency.Observe(0.5, "GET")
+	latency.Observe(5, "GET")
+
+	r.NewGaugeFunc("test_queue", "Queue.", []string{"name"}, func(emit func(float64, ...string)) {
+		emit(7, "orders")
+	})
+
+	out := scrape(t, r)
+	require.Contains(t, out, "# HELP test_requests_total Requests.\n# TYPE test_requests_total counter\n")
+	require.Contains(t, out, `test_requests_total{path="/a\"b"} 3`+"\n")
+	require.Contains(t, out, "# TYPE test_inflight gauge\ntest_inflight 2\n")
+	require.Contains(t, out, "# TYPE test_latency_seconds histogram\n"+
+		`test_latency_seconds_bucket{method="GET",le="0.1"} 1`+"\n"+
+		`test_latency_seconds_bucket{method="GET",le="1"} 2`+"\n"+
+		`test_latency_seconds_bucket{method="GET",le="+Inf"} 3`+"\n"+
+		`test_latency_seconds_sum{method="GET"} 5.55`+"\n"+
+		`test_latency_seconds_count{method="GET"} 3`+"\n")
+	require.Contains(t, out, `test_queue{name="orders"} 7`+"\n")
+
+	require.Panics(t, func() { requests.Inc() })
+
+	// A family is exposed once, so names can't be registered twice
+	require.Panics(t, func() { r.NewGauge("test_requests_total", "Requests.") })
+	require.Panics(t, func() { r.NewCounterFunc("test_queue", "Queue.", nil, nil) })
+}
+
+func TestServiceMetrics(t *testing.T) {
+	require.Equal(t, "/instruments/historical/:id/minute", Endpoint("/instruments/historical/256265/minute"))
+	require.Equal(t, "/orders/regular", Endpoint("/orders/regular?tag=x1"))
+	require.Equal(t, "nse_fo", Segment(12345<<8|2))
+
+	// The service metrics are global, so only their changes are checked
+	series := []string{
+		`kite_api_errors_total{method="GET",endpoint="/orders/:id",error_type="TokenException"}`,
+		`kite_api_request_duration_seconds_count{method="GET",endpoint="/user/margins"}`,
+		`kite_ticks_total{segment="indices"}`,
+		`kite_ticks_total{segment="nse_fo"}`,
+		`kite_tick_feed_latency_seconds_bucket{segment="indices",le="0.04"}`,
+		`kite_tick_feed_latency_seconds_count{segment="indices"}`,
+	}
+	before := scrape(t, DefaultRegistry)
+
+	ObserveKiteRequest("GET", "/orders/220101000000001", 120*time.Millisecond,
+		kiteconnect.NewError(kiteconnect.TokenError, "Session expired", nil))
+	ObserveKiteRequest("GET", "/user/margins", 30*time.Millisecond, nil)
+
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	ObserveTick(models.Tick{InstrumentToken: 256265<<8 | 9, Timestamp: models.Time{Time: now.Add(-40 * time.Millisecond)}}, now)
+	ObserveTick(models.Tick{InstrumentToken: 1<<8 | 2}, now) // LTP ticks carry no timestamp
+
+	out := scrape(t, DefaultRegistry)
+	for _, s := range series {
+		require.Equal(t, 1.0, sampleValue(t, out, s)-sampleValue(t, before, s), s)
+	}
+	require.NotContains(t, out, `endpoint="/user/margins",error_type`)
+	require.NotContains(t, out, `kite_tick_feed_latency_seconds_count{segment="nse_fo"}`)
+}
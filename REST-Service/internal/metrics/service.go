@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+)
+
+// Buckets in seconds for network latencies and for per-option computations
+var (
+	LatencyBuckets = ExponentialBuckets(0.005, 2, 12) // 5ms to ~10s
+	ComputeBuckets = ExponentialBuckets(0.000005, 2, 14)
+)
+
+// The service metrics
+var (
+	Ticks = NewCounter("kite_ticks_total",
+		"Ticks received from the Kite ticker.", "segment")
+	FeedLatency = NewHistogram("kite_tick_feed_latency_seconds",
+		"Receive time minus the exchange timestamp of ticks that carry one.", LatencyBuckets, "segment")
+
+	WSClients = NewGauge("ws_clients",
+		"Connected /ws clients.")
+	WSDropped = NewCounter("ws_dropped_messages_total",
+		"Messages not delivered to /ws clients, by reason.", "reason")
+
+	GreeksDuration = NewHistogram("greeks_compute_seconds",
+		"Time to compute the IV and Greeks of one option.", ComputeBuckets)
+	IVFailures = NewCounter("iv_solver_failures_total",
+		"IV solves without a solution, by quote side and reason.", "side", "reason")
+
+	KiteRequestDuration = NewHistogram("kite_api_request_duration_seconds",
+		"Latency of Kite REST API requests.", LatencyBuckets, "method", "endpoint")
+	KiteErrors = NewCounter("kite_api_errors_total",
+		"Failed Kite REST API requests by kiteconnect.Error type.", "method", "endpoint", "error_type")
+)
+
+// segments names the exchange segment in the low byte of an instrument token
+var segments = map[uint32]string{
+	1: "nse_cm",
+	2: "nse_fo",
+	3: "nse_cd",
+	4: "bse_cm",
+	5: "bse_fo",
+	6: "bse_cd",
+	7: "mcx_fo",
+	8: "mcx_sx",
+	9: "indices",
+}
+
+// Segment returns the exchange segment name of an instrument token
+func Segment(token uint32) string {
+	if name, ok := segments[token&0xff]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ObserveTick counts a tick received at now and records its feed latency
+func ObserveTick(tick models.Tick, now time.Time) {
+	segment := Segment(tick.InstrumentToken)
+	Ticks.Inc(segment)
+	if !tick.Timestamp.IsZero() {
+		FeedLatency.Observe(now.Sub(tick.Timestamp.Time).Seconds(), segment)
+	}
+}
+
+// ObserveKiteRequest records a Kite REST request; it is the
+// kiteconnect.RequestHook of the service's client
+func ObserveKiteRequest(method, uri string, elapsed time.Duration, err error) {
+	endpoint := Endpoint(uri)
+	KiteRequestDuration.Observe(elapsed.Seconds(), method, endpoint)
+	if err == nil {
+		return
+	}
+	errorType := kiteconnect.GeneralError
+	var kerr kiteconnect.Error
+	if errors.As(err, &kerr) && kerr.ErrorType != "" {
+		errorType = kerr.ErrorType
+	}
+	KiteErrors.Inc(method, endpoint, errorType)
+}
+
+// Endpoint reduces a request URI to its route, replacing path segments with
+// digits, such as tokens and order IDs, by ":id" to keep the label set small
+func Endpoint(uri string) string {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	parts := strings.Split(uri, "/")
+	for i, part := range parts {
+		if strings.ContainsAny(part, "0123456789") {
+			parts[i] = ":id"
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,173 @@
+package options
+
+import (
+	"math"
+	"time"
+)
+
+// Buildup classifies a contract's activity from its price and OI changes
+type Buildup string
+
+const (
+	BuildupNone          Buildup = ""
+	BuildupLong          Buildup = "long_buildup"   // Price up, OI up
+	BuildupShort         Buildup = "short_buildup"  // Price down, OI up
+	BuildupShortCovering Buildup = "short_covering" // Price up, OI down
+	BuildupLongUnwinding Buildup = "long_unwinding" // Price down, OI down
+)
+
+// ClassifyBuildup returns the buildup for a price change and an OI change.
+// Either change being zero leaves the contract unclassified.
+func ClassifyBuildup(priceChange float64, oiChange int64) Buildup {
+	switch {
+	case priceChange == 0 || oiChange == 0:
+		return BuildupNone
+	case priceChange > 0 && oiChange > 0:
+		return BuildupLong
+	case priceChange < 0 && oiChange > 0:
+		return BuildupShort
+	case priceChange > 0:
+		return BuildupShortCovering
+	default:
+		return BuildupLongUnwinding
+	}
+}
+
+// OIChange returns the change in OI since the previous close, falling back to
+// the change since session open when the previous close OI is unknown
+func (od *OptionData) OIChange() int64 {
+	if od.PrevCloseOI > 0 {
+		return int64(od.OI) - int64(od.PrevCloseOI)
+	}
+	return od.OIChangeSinceOpen()
+}
+
+// OIChangeSinceOpen returns the change in OI since the first tick of the session
+func (od *OptionData) OIChangeSinceOpen() int64 {
+	if od.OpenOI == 0 {
+		return 0
+	}
+	return int64(od.OI) - int64(od.OpenOI)
+}
+
+// StrikeAnalytics holds the per-strike OI changes and buildups
+type StrikeAnalytics struct {
+	Strike              float64 `json:"strike"`
+	CallOI              uint32  `json:"call_oi"`
+	PutOI               uint32  `json:"put_oi"`
+	CallOIChange        int64   `json:"call_oi_change"`      // Since previous close
+	PutOIChange         int64   `json:"put_oi_change"`       // Since previous close
+	CallOIChangeOpen    int64   `json:"call_oi_change_open"` // Since session open
+	PutOIChangeOpen     int64   `json:"put_oi_change_open"`  // Since session open
+	CallBuildup         Buildup `json:"call_buildup"`
+	PutBuildup          Buildup `json:"put_buildup"`
+	TotalPayoutAtStrike float64 `json:"total_payout_at_strike"` // Writers' payout if expiry settles here
+}
+
+// ChainAnalytics holds OI and volume derived analytics of an option chain
+type ChainAnalytics struct {
+	Underlying      string    `json:"underlying"`
+	Expiry          time.Time `json:"expiry"`
+	UnderlyingPrice float64   `json:"underlying_price"`
+
+	TotalCallOI     uint64  `json:"total_call_oi"`
+	TotalPutOI      uint64  `json:"total_put_oi"`
+	TotalCallVolume uint64  `json:"total_call_volume"`
+	TotalPutVolume  uint64  `json:"total_put_volume"`
+	PCROI           float64 `json:"pcr_oi"`     // Put/call OI ratio (0 if no call OI)
+	PCRVolume       float64 `json:"pcr_volume"` // Put/call volume ratio (0 if no call volume)
+
+	MaxPain    float64 `json:"max_pain"`   // Strike minimising the writers' total payout
+	Support    float64 `json:"support"`    // Strike with the highest put OI
+	Resistance float64 `json:"resistance"` // Strike with the highest call OI
+
+	CallOIChange     int64 `json:"call_oi_change"`      // Since previous close
+	PutOIChange      int64 `json:"put_oi_change"`       // Since previous close
+	CallOIChangeOpen int64 `json:"call_oi_change_open"` // Since session open
+	PutOIChangeOpen  int64 `json:"put_oi_change_open"`  // Since session open
+	PrevCloseOI      bool  `json:"prev_close_oi"`       // False when changes fall back to session open
+
+	Strikes     []StrikeAnalytics `json:"strikes"`
+	LastUpdated time.Time         `json:"last_updated"`
+}
+
+// Analytics computes PCR, max pain, support/resistance, OI changes and buildups
+// from the chain's current OI and volume
+func (oc *OptionChain) Analytics() ChainAnalytics {
+	strikes := oc.SortedStrikes()
+	result := ChainAnalytics{
+		Underlying:      oc.Underlying,
+		Expiry:          oc.Expiry,
+		UnderlyingPrice: oc.UnderlyingPrice,
+		PrevCloseOI:     len(strikes) > 0,
+		Strikes:         make([]StrikeAnalytics, 0, len(strikes)),
+		LastUpdated:     time.Now(),
+	}
+
+	var maxCallOI, maxPutOI uint32
+	for _, sd := range strikes {
+		sa := StrikeAnalytics{Strike: sd.Strike}
+
+		if od := sd.Call; od != nil {
+			sa.CallOI = od.OI
+			sa.CallOIChange = od.OIChange()
+			sa.CallOIChangeOpen = od.OIChangeSinceOpen()
+			sa.CallBuildup = od.Buildup
+			result.TotalCallOI += uint64(od.OI)
+			result.TotalCallVolume += uint64(od.Volume)
+			result.PrevCloseOI = result.PrevCloseOI && od.PrevCloseOI > 0
+			if od.OI > maxCallOI {
+				maxCallOI = od.OI
+				result.Resistance = sd.Strike
+			}
+		}
+		if od := sd.Put; od != nil {
+			sa.PutOI = od.OI
+			sa.PutOIChange = od.OIChange()
+			sa.PutOIChangeOpen = od.OIChangeSinceOpen()
+			sa.PutBuildup = od.Buildup
+			result.TotalPutOI += uint64(od.OI)
+			result.TotalPutVolume += uint64(od.Volume)
+			result.PrevCloseOI = result.PrevCloseOI && od.PrevCloseOI > 0
+			if od.OI > maxPutOI {
+				maxPutOI = od.OI
+				result.Support = sd.Strike
+			}
+		}
+
+		result.CallOIChange += sa.CallOIChange
+		result.PutOIChange += sa.PutOIChange
+		result.CallOIChangeOpen += sa.CallOIChangeOpen
+		result.PutOIChangeOpen += sa.PutOIChangeOpen
+		result.Strikes = append(result.Strikes, sa)
+	}
+
+	if result.TotalCallOI > 0 {
+		result.PCROI = float64(result.TotalPutOI) / float64(result.TotalCallOI)
+	}
+	if result.TotalCallVolume > 0 {
+		result.PCRVolume = float64(result.TotalPutVolume) / float64(result.TotalCallVolume)
+	}
+
+	if result.TotalCallOI+result.TotalPutOI == 0 {
+		return result
+	}
+
+	// Max pain: the settlement strike at which option writers pay out the least
+	minPayout := math.Inf(1)
+	for i := range result.Strikes {
+		settle := result.Strikes[i].Strike
+		payout := 0.0
+		for _, sa := range result.Strikes {
+			payout += math.Max(0, settle-sa.Strike) * float64(sa.CallOI)
+			payout += math.Max(0, sa.Strike-settle) * float64(sa.PutOI)
+		}
+		result.Strikes[i].TotalPayoutAtStrike = payout
+		if payout < minPayout {
+			minPayout = payout
+			result.MaxPain = settle
+		}
+	}
+
+	return result
+}
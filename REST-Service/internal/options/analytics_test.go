@@ -0,0 +1,135 @@
+package options
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kiteticker "rest-service/internal/ticker"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyBuildup(t *testing.T) {
+	require.Equal(t, BuildupLong, ClassifyBuildup(5, 100))
+	require.Equal(t, BuildupShort, ClassifyBuildup(-5, 100))
+	require.Equal(t, BuildupShortCovering, ClassifyBuildup(5, -100))
+	require.Equal(t, BuildupLongUnwinding, ClassifyBuildup(-5, -100))
+	require.Equal(t, BuildupNone, ClassifyBuildup(0, 100))
+}
+
+func TestChainAnalytics(t *testing.T) {
+	chain := &OptionChain{Strikes: map[float64]*StrikeData{
+		100: {Strike: 100, Call: &OptionData{OI: 100, Volume: 10, PrevCloseOI: 80}, Put: &OptionData{OI: 500, Volume: 30, PrevCloseOI: 600}},
+		110: {Strike: 110, Call: &OptionData{OI: 300, Volume: 20, PrevCloseOI: 300}, Put: &OptionData{OI: 300, Volume: 10, PrevCloseOI: 250}},
+		120: {Strike: 120, Call: &OptionData{OI: 600, Volume: 10, PrevCloseOI: 500}, Put: &OptionData{OI: 100, Volume: 0, PrevCloseOI: 100}},
+	}}
+
+	a := chain.Analytics()
+	require.InDelta(t, 900.0/1000.0, a.PCROI, 1e-12)
+	require.InDelta(t, 40.0/40.0, a.PCRVolume, 1e-12)
+	require.Equal(t, 100.0, a.Support)
+	require.Equal(t, 120.0, a.Resistance)
+	require.True(t, a.PrevCloseOI)
+	require.Equal(t, int64(120), a.CallOIChange)
+	require.Equal(t, int64(-50), a.PutOIChange)
+
+	// Writers pay 5000 at 100 and 120, but only 100*10 + 100*10 at 110
+	require.Equal(t, 110.0, a.MaxPain)
+	require.Equal(t, 2000.0, a.Strikes[1].TotalPayoutAtStrike)
+}
+
+func TestUpdateFromTickDepth(t *testing.T) {
+	full := models.Tick{Mode: string(kiteticker.ModeFull), LastPrice: 100, VolumeTraded: 10, OI: 5000}
+	full.Depth.Buy[0] = models.DepthItem{Price: 99.5, Quantity: 50}
+	full.Depth.Sell[0] = models.DepthItem{Price: 100.5, Quantity: 75}
+
+	od := &OptionData{}
+	od.UpdateFromTick(full)
+	require.Equal(t, 99.5, od.BidPrice)
+	require.Equal(t, uint32(5000), od.OI)
+	require.Equal(t, od.LastUpdated, od.DepthUpdated)
+	depthAt := od.DepthUpdated
+
+	// Quote mode updates the volume, LTP mode only the price; neither
+	// carries depth or OI, so the bid and ask are dropped and the OI kept
+	od.UpdateFromTick(models.Tick{Mode: string(kiteticker.ModeQuote), LastPrice: 101, VolumeTraded: 20})
+	od.UpdateFromTick(models.Tick{Mode: string(kiteticker.ModeLTP), LastPrice: 102})
+	require.Equal(t, 102.0, od.LastPrice)
+	require.Equal(t, 102.0, od.MidPrice())
+	require.Equal(t, uint32(20), od.Volume)
+	require.Zero(t, od.BidPrice)
+	require.Zero(t, od.AskPrice)
+	require.Zero(t, od.AskQty)
+	require.Equal(t, uint32(5000), od.OI)
+	require.Equal(t, uint32(5000), od.OpenOI)
+	require.Equal(t, depthAt, od.DepthUpdated)
+
+	fd := &FutureData{}
+	fd.UpdateFromTick(full)
+	require.Equal(t, 100.5, fd.AskPrice)
+	fd.UpdateFromTick(models.Tick{Mode: string(kiteticker.ModeLTP), LastPrice: 102})
+	require.Zero(t, fd.AskPrice)
+	require.Equal(t, uint32(5000), fd.OI)
+	require.Equal(t, uint32(10), fd.Volume)
+	require.False(t, fd.DepthUpdated.IsZero())
+}
+
+func TestChainSnapshot(t *testing.T) {
+	chain := &OptionChain{Underlying: "NIFTY", Strikes: map[float64]*StrikeData{
+		100: {Strike: 100, Call: &OptionData{Strike: 100, Type: Call, OI: 100}},
+	}}
+	snap := chain.Snapshot()
+	require.Equal(t, "NIFTY", snap.Underlying)
+	require.Equal(t, uint32(100), snap.Strikes[100].Call.OI)
+	require.Nil(t, snap.Strikes[100].Put)
+
+	// Ticks updating the live chain neither race with nor reach a snapshot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			chain.Update(func() {
+				chain.UnderlyingPrice = float64(i)
+				chain.Strikes[100].Call.UpdateFromTick(models.Tick{Mode: string(kiteticker.ModeFull), LastPrice: float64(i), OI: uint32(i)})
+			})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		s := chain.Snapshot()
+		_, err := json.Marshal(s.SortedStrikes())
+		require.NoError(t, err)
+	}
+	<-done
+	require.Equal(t, uint32(100), snap.Strikes[100].Call.OI)
+	require.Equal(t, uint32(999), chain.Snapshot().Strikes[100].Call.OI)
+}
+
+func TestOISnapshot(t *testing.T) {
+	expiry := models.Time{Time: normalize(time.Now().AddDate(0, 0, 7))}
+	s := NewScanner(nil)
+	s.LoadInstruments([]kiteconnect.Instrument{
+		{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: expiry},
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "PE", StrikePrice: 25000, Expiry: expiry},
+	})
+	chain, ok := s.GetOptionChain("NIFTY", expiry.Time)
+	require.True(t, ok)
+	chain.Update(func() { chain.Strikes[25000].Call.OI = 1500 })
+
+	path := filepath.Join(t.TempDir(), "oi.json")
+	closed := time.Date(2026, 10, 19, 15, 31, 0, 0, ist)
+	require.NoError(t, s.SaveOISnapshot(path, closed))
+
+	_, err := s.LoadOISnapshot(path, closed)
+	require.Error(t, err) // Not a previous close on the same day
+	loaded, err := s.LoadOISnapshot(path, closed.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, 1, loaded)
+	snap := chain.Snapshot()
+	require.Equal(t, uint32(1500), snap.Strikes[25000].Call.PrevCloseOI)
+	require.Zero(t, snap.Strikes[25000].Put.PrevCloseOI)
+}
@@ -1,85 +1,303 @@
 package options
 
 import (
+	"sync"
+	"time"
+
+	"rest-service/internal/calendar"
+	"rest-service/internal/metrics"
 	"rest-service/internal/store"
 )
 
 // Calculator wraps GreeksCalculator and provides high-level methods
 type Calculator struct {
-	greeksCalc *GreeksCalculator
+	greeksCalc *GreeksCalculator            // Default calculator for underlyings without overrides
+	calcs      map[string]*GreeksCalculator // underlying -> calculator
 	scanner    *Scanner
+	quality    QualityParams
+	calendar   *calendar.Calendar
+	forwards   map[string]map[time.Time]cachedForward // underlying -> expiry -> forward
+	mu         sync.RWMutex
+}
+
+// cachedForward is a chain's future price, or the forward interpolated along
+// the futures curve, kept until a future or spot tick of its underlying
+// invalidates it
+type cachedForward struct {
+	price float64
+	at    time.Time
 }
 
 // NewCalculator creates a new calculator instance
 func NewCalculator(scanner *Scanner, riskFreeRate float64) *Calculator {
 	return &Calculator{
 		greeksCalc: NewGreeksCalculator(riskFreeRate),
+		calcs:      make(map[string]*GreeksCalculator),
 		scanner:    scanner,
+		quality:    DefaultQualityParams(),
+		calendar:   calendar.Default(),
+		forwards:   make(map[string]map[time.Time]cachedForward),
+	}
+}
+
+// SetCalendar sets the trading calendar used to measure time to expiry
+func (c *Calculator) SetCalendar(cal *calendar.Calendar) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calendar = cal
+}
+
+// tradingCalendar returns the trading calendar used to measure time to expiry
+func (c *Calculator) tradingCalendar() *calendar.Calendar {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.calendar
+}
+
+// SetQualityParams sets the thresholds used to flag option quotes
+func (c *Calculator) SetQualityParams(params QualityParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quality = params
+}
+
+// qualityParams returns the thresholds used to flag option quotes
+func (c *Calculator) qualityParams() QualityParams {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.quality
+}
+
+// SetDefaultPricing sets the pricing parameters used for underlyings without overrides
+func (c *Calculator) SetDefaultPricing(params PricingParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.greeksCalc = NewGreeksCalculatorWithParams(params)
+}
+
+// SetPricing sets the pricing parameters for a single underlying
+func (c *Calculator) SetPricing(underlying string, params PricingParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calcs[underlying] = NewGreeksCalculatorWithParams(params)
+}
+
+// ClearPricing removes an underlying's pricing override
+func (c *Calculator) ClearPricing(underlying string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.calcs, underlying)
+}
+
+// calculatorFor returns the Greeks calculator configured for an underlying
+func (c *Calculator) calculatorFor(underlying string) *GreeksCalculator {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if gc, ok := c.calcs[underlying]; ok {
+		return gc
 	}
+	return c.greeksCalc
+}
+
+// PricingFor returns the pricing parameters configured for an underlying
+func (c *Calculator) PricingFor(underlying string) PricingParams {
+	return c.calculatorFor(underlying).Params()
+}
+
+// resolveForward picks the price fed to the model and the calculator for it.
+// Black-76 falls back from the future to the synthetic forward (and vice versa),
+// and finally to BSM on the spot when no forward is available.
+func (c *Calculator) resolveForward(gc *GreeksCalculator, chain *OptionChain, spot float64, spotOK bool, timeToExpiry float64) (*GreeksCalculator, float64, bool) {
+	if gc.Model().UsesForward() {
+		futurePrice, futureOK := c.forward(chain, spot, spotOK, timeToExpiry, time.Now())
+		if gc.Model() == ModelBlack76 && futureOK {
+			return gc, futurePrice, true
+		}
+
+		// The synthetic forward moves with the chain's own quotes, so it is
+		// derived afresh on every tick
+		if synthetic, ok := ImpliedForward(chain, gc.RiskFreeRate(), timeToExpiry); ok {
+			return gc.withModel(ModelBlack76Synthetic), synthetic, true
+		}
+		if futureOK {
+			return gc.withModel(ModelBlack76), futurePrice, true
+		}
+
+		// No forward available, price off the spot instead
+		gc = gc.withModel(ModelBSM)
+	}
+
+	if !spotOK {
+		return gc, 0, false
+	}
+	return gc, spot, true
+}
+
+// forward returns the futurePrice of a chain, reusing the last one resolved
+// until a future or spot tick of the underlying
+func (c *Calculator) forward(chain *OptionChain, spot float64, spotOK bool, timeToExpiry float64, now time.Time) (float64, bool) {
+	c.mu.RLock()
+	fwd, ok := c.forwards[chain.Underlying][chain.Expiry]
+	c.mu.RUnlock()
+	if ok && now.Sub(fwd.at) < forwardCacheAge && !now.Before(fwd.at) {
+		return fwd.price, true
+	}
+
+	price, ok := c.futurePrice(chain, spot, spotOK, timeToExpiry)
+	if !ok {
+		return 0, false // Retried on the next tick rather than waiting for the underlying
+	}
+
+	c.mu.Lock()
+	if c.forwards[chain.Underlying] == nil {
+		c.forwards[chain.Underlying] = make(map[time.Time]cachedForward)
+	}
+	c.forwards[chain.Underlying][chain.Expiry] = cachedForward{price: price, at: now}
+	c.mu.Unlock()
+	return price, true
+}
+
+// InvalidateForwards drops the cached future prices and futures curve of the
+// underlying a future or spot token belongs to. Call it on every tick,
+// before options priced off the forward are updated.
+func (c *Calculator) InvalidateForwards(token uint32) {
+	if c.scanner == nil {
+		return
+	}
+	underlying, ok := c.scanner.InvalidateForwards(token)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.forwards, underlying)
+	c.mu.Unlock()
+}
+
+// futurePrice returns the last price of the future expiring with the chain, or
+// a forward from the futures curve's carry when there is no such future
+func (c *Calculator) futurePrice(chain *OptionChain, spot float64, spotOK bool, timeToExpiry float64) (float64, bool) {
+	if c.scanner == nil {
+		return 0, false
+	}
+
+	if token, ok := c.scanner.GetFutureToken(chain.Underlying, chain.Expiry); ok {
+		if price, ok := store.GlobalStore.GetLTP(token); ok && price > 0 {
+			return price, true
+		}
+	}
+
+	if !spotOK {
+		return 0, false
+	}
+	return c.scanner.InterpolatedForward(chain.Underlying, spot, timeToExpiry, time.Now())
 }
 
 // CalculateAllGreeks calculates all Greeks and IV for an option
-// It automatically fetches underlying price from the store
+// It automatically fetches underlying and futures prices from the store
 func (c *Calculator) CalculateAllGreeks(optionData *OptionData, chain *OptionChain) {
 	//fmt.Println("Calculating Greeks for ", optionData.Tradingsymbol, " Chain: ", chain.Underlying)
 	if optionData == nil || chain == nil {
 		return
 	}
+	start := time.Now()
+	defer func() { metrics.GreeksDuration.Observe(time.Since(start).Seconds()) }()
 
 	// Try to get from store
-	underlyingPrice, ok := store.GlobalStore.GetLTP(chain.UnderlyingToken)
-	if !ok {
-		return
+	underlyingPrice, spotOK := store.GlobalStore.GetLTP(chain.UnderlyingToken)
+	if spotOK {
+		chain.UnderlyingPrice = underlyingPrice // Update chain
 	}
-	chain.UnderlyingPrice = underlyingPrice // Update chain
 
-	// Calculate time to expiry in years
-	timeToExpiry := CalculateTimeToExpiry(optionData.Expiry)
+	// Calculate time to expiry in years, up to the expiry cutoff on the trading calendar
+	cal := c.tradingCalendar()
+	timeToExpiry := cal.TimeToExpiry(time.Now(), optionData.Expiry)
 	if timeToExpiry <= 0 {
 		return
 	}
 
-	// Use mid price (average of bid and ask) or last price
-	optionPrice := optionData.LastPrice
-	if optionData.BidPrice > 0 && optionData.AskPrice > 0 {
-		optionPrice = (optionData.BidPrice + optionData.AskPrice) / 2.0
+	gc := c.calculatorFor(chain.Underlying).withDaysPerYear(cal.DaysPerYear())
+	gc, modelPrice, ok := c.resolveForward(gc, chain, underlyingPrice, spotOK, timeToExpiry)
+	if !ok {
+		return
 	}
+	chain.Forward = modelPrice
+
+	// Flag the quote before solving so bad inputs are visible even without an IV
+	quality := assessQuote(optionData, c.qualityParams(), time.Now())
+	optionData.Model = gc.Model()
+	optionData.Forward = modelPrice
 
+	// Use mid price (average of bid and ask) or last price
+	optionPrice := optionData.MidPrice()
 	if optionPrice <= 0 {
+		optionData.Quality = quality | QualityNoIV
 		return
 	}
 
+	// Bid and ask IVs are solved separately; a missing side leaves them at zero
+	optionData.BidIV = c.solveQuoteIV(gc, "bid", optionData.BidPrice, modelPrice, optionData, timeToExpiry)
+	optionData.AskIV = c.solveQuoteIV(gc, "ask", optionData.AskPrice, modelPrice, optionData, timeToExpiry)
+
 	// Calculate IV first (using market price)
-	iv := c.greeksCalc.CalculateIV(
+	iv, err := gc.CalculateIV(
 		optionPrice,
-		underlyingPrice,
+		modelPrice,
 		optionData.Strike,
 		timeToExpiry,
 		optionData.Type,
 	)
+	if err == ErrBelowIntrinsic {
+		quality |= QualityBelowIntrinsic
+	}
+	if err != nil {
+		quality |= QualityNoIV
+		ivFailed("mid", err)
+	}
 
 	optionData.IV = iv
+	optionData.Quality = quality
+
+	// Calculate Greeks using the calculated IV, clearing them when there is no solution
+	delta, gamma, theta, vega := gc.CalculateGreeks(
+		optionData.Type,
+		modelPrice,
+		optionData.Strike,
+		timeToExpiry,
+		iv,
+	)
 
-	// Calculate Greeks using the calculated IV
-	if iv > 0 {
-		delta, gamma, theta, vega := c.greeksCalc.CalculateGreeks(
-			optionData.Type,
-			underlyingPrice,
-			optionData.Strike,
-			timeToExpiry,
-			iv,
-		)
+	optionData.Delta = delta
+	optionData.Gamma = gamma
+	optionData.Theta = theta
+	optionData.Vega = vega
 
-		optionData.Delta = delta
-		optionData.Gamma = gamma
-		optionData.Theta = theta
-		optionData.Vega = vega
+	second := gc.CalculateSecondOrderGreeks(
+		optionData.Type,
+		modelPrice,
+		optionData.Strike,
+		timeToExpiry,
+		iv,
+	)
+
+	optionData.Rho = second.Rho
+	optionData.Vanna = second.Vanna
+	optionData.Volga = second.Volga
+	optionData.Charm = second.Charm
+	optionData.Speed = second.Speed
+	optionData.Color = second.Color
+
+	// Intrinsic value is measured against the spot when we have it
+	intrinsicBase := modelPrice
+	if spotOK {
+		intrinsicBase = underlyingPrice
 	}
 
 	// Calculate intrinsic and time value
-	intrinsic, timeValue := c.greeksCalc.CalculateIntrinsicAndTimeValue(
+	intrinsic, timeValue := gc.CalculateIntrinsicAndTimeValue(
 		optionPrice,
-		underlyingPrice,
+		intrinsicBase,
 		optionData.Strike,
 		optionData.Type,
 	)
@@ -87,3 +305,36 @@ func (c *Calculator) CalculateAllGreeks(optionData *OptionData, chain *OptionCha
 	optionData.IntrinsicValue = intrinsic
 	optionData.TimeValue = timeValue
 }
+
+// solveQuoteIV solves the IV of one side of the quote, returning 0 when the side
+// is missing or has no solution
+func (c *Calculator) solveQuoteIV(gc *GreeksCalculator, side string, price, modelPrice float64, optionData *OptionData, timeToExpiry float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+
+	iv, err := gc.CalculateIV(price, modelPrice, optionData.Strike, timeToExpiry, optionData.Type)
+	if err != nil {
+		ivFailed(side, err)
+		return 0
+	}
+	return iv
+}
+
+// ivReasons labels the IV solver errors in metrics
+var ivReasons = map[error]string{
+	ErrInvalidIVInputs: "invalid_inputs",
+	ErrBelowIntrinsic:  "below_intrinsic",
+	ErrAboveUpperBound: "above_upper_bound",
+	ErrIVOutOfBracket:  "out_of_bracket",
+	ErrIVNoConvergence: "no_convergence",
+}
+
+// ivFailed counts an IV solve of one side of the quote without a solution
+func ivFailed(side string, err error) {
+	reason, ok := ivReasons[err]
+	if !ok {
+		reason = "other"
+	}
+	metrics.IVFailures.Inc(side, reason)
+}
@@ -13,13 +13,14 @@ import (
 	"os"
 	"time"
 
+	"rest-service/internal/calendar"
 	"rest-service/internal/options"
 	kiteticker "rest-service/internal/ticker"
 
 	kiteconnect "gokiteconnect-master"
 )
 
-func initOptionTrading(kc *kiteconnect.Client, ticker *kiteticker.ExtendedTicker) {
+func initOptionTrading(kc *kiteconnect.Client, ticker *kiteticker.Pool) {
 	// 1. Create and initialize scanner
 	scanner := options.NewScanner(kc)
 
@@ -89,28 +90,39 @@ func initOptionTrading(kc *kiteconnect.Client, ticker *kiteticker.ExtendedTicker
 			return
 		}
 
-		// Update call or put data
-		if inst.InstrumentType == options.Call && strikeData.Call != nil {
-			strikeData.Call.UpdateFromTick(tick)
-		} else if inst.InstrumentType == options.Put && strikeData.Put != nil {
-			strikeData.Put.UpdateFromTick(tick)
-		}
+		// Update call or put data, locked against snapshots
+		chain.Update(func() {
+			if inst.InstrumentType == options.Call && strikeData.Call != nil {
+				strikeData.Call.UpdateFromTick(tick)
+			} else if inst.InstrumentType == options.Put && strikeData.Put != nil {
+				strikeData.Put.UpdateFromTick(tick)
+			}
+		})
 
 		// TODO: Calculate Greeks
 		// TODO: Run strategy
 		// TODO: Generate signals
 	})
 
-	// 5. Refresh scanner daily (new expiries added)
+	// 5. Refresh scanner before each session opens (expired contracts dropped,
+	// new expiries added, live option state kept)
 	go func() {
-		ticker := time.NewTicker(24 * time.Hour)
-		defer ticker.Stop()
+		cal := calendar.Default()
+		for {
+			open := cal.NextSessionOpen(time.Now())
+			if time.Until(open) < 30*time.Minute {
+				open = cal.NextSessionOpen(open)
+			}
+			time.Sleep(time.Until(open.Add(-30 * time.Minute)))
 
-		for range ticker.C {
 			log.Println("Refreshing option scanner...")
-			if err := scanner.ScanInstruments(); err != nil {
+			result, err := scanner.RefreshInstruments(time.Now())
+			if err != nil {
 				log.Printf("Error refreshing scanner: %v", err)
+				continue
 			}
+			ticker.Unsubscribe(result.Removed)
+			// Filter and subscribe again to pick up new expiries
 		}
 	}()
 }
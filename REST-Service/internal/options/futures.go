@@ -0,0 +1,311 @@
+package options
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"rest-service/internal/store"
+
+	"gokiteconnect-master/models"
+)
+
+// RolloverWindowDays is how many days before the near expiry rollover is tracked
+const RolloverWindowDays = 7
+
+// forwardCacheAge bounds how long cached forward inputs are reused without a
+// tick invalidating them, as the time to expiry keeps running down
+const forwardCacheAge = time.Minute
+
+// curvePoint is a live future's time to expiry in years and last price
+type curvePoint struct{ t, price float64 }
+
+// cachedCurve is the part of an underlying's futures curve that
+// InterpolatedForward needs, kept until a future or spot tick
+type cachedCurve struct {
+	points []curvePoint
+	at     time.Time
+}
+
+// FutureQuote is a future on the curve with its basis and carry against spot
+type FutureQuote struct {
+	FutureData
+	DaysToExpiry    int     `json:"days_to_expiry"`
+	TimeToExpiry    float64 `json:"time_to_expiry"`   // Years
+	Basis           float64 `json:"basis"`            // Future - spot
+	BasisPct        float64 `json:"basis_pct"`        // Basis as a fraction of spot
+	AnnualizedCarry float64 `json:"annualized_carry"` // ln(F/S)/T, continuously compounded
+}
+
+// Rollover tracks how much near-month open interest has moved to later series
+type Rollover struct {
+	NearSymbol   string    `json:"near_symbol"`
+	NextSymbol   string    `json:"next_symbol"`
+	NearExpiry   time.Time `json:"near_expiry"`
+	DaysToExpiry int       `json:"days_to_expiry"` // Of the near series
+	NearOI       uint64    `json:"near_oi"`
+	NextOI       uint64    `json:"next_oi"`
+	TotalOI      uint64    `json:"total_oi"`     // All live series
+	RolloverPct  float64   `json:"rollover_pct"` // Next-month OI / total OI (0-1)
+	InWindow     bool      `json:"in_window"`    // Within RolloverWindowDays of the near expiry
+}
+
+// FuturesCurve is the term structure of an underlying's futures
+type FuturesCurve struct {
+	Underlying  string        `json:"underlying"`
+	SpotToken   uint32        `json:"spot_token"`
+	Spot        float64       `json:"spot"` // 0 when the spot is not subscribed
+	Futures     []FutureQuote `json:"futures"`
+	Rollover    *Rollover     `json:"rollover,omitempty"` // Nil with fewer than two live series
+	LastUpdated time.Time     `json:"last_updated"`
+}
+
+// UpdateFuture applies a tick to the future it is for, locked against the
+// snapshots readers take, and reports whether the token is a future
+func (s *Scanner) UpdateFuture(tick models.Tick) bool {
+	s.mu.RLock()
+	future, ok := s.liveFuture(tick.InstrumentToken)
+	s.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	s.futuresMu.Lock()
+	defer s.futuresMu.Unlock()
+	future.UpdateFromTick(tick)
+	return true
+}
+
+// GetFutureData returns a snapshot of the data of a future token
+func (s *Scanner) GetFutureData(token uint32) (*FutureData, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	future, ok := s.liveFuture(token)
+	if !ok {
+		return nil, false
+	}
+
+	s.futuresMu.RLock()
+	defer s.futuresMu.RUnlock()
+	snap := *future
+	return &snap, true
+}
+
+// GetFutures returns snapshots of the futures of an underlying ordered by expiry
+func (s *Scanner) GetFutures(underlying string) []*FutureData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.futuresMu.RLock()
+	defer s.futuresMu.RUnlock()
+
+	futures := make([]*FutureData, 0, len(s.futures[underlying]))
+	for _, future := range s.futures[underlying] {
+		snap := *future
+		futures = append(futures, &snap)
+	}
+
+	sort.Slice(futures, func(i, j int) bool {
+		return futures[i].Expiry.Before(futures[j].Expiry)
+	})
+
+	return futures
+}
+
+// liveFuture returns the future a token is for, which ticks change in place.
+// The caller must hold the lock.
+func (s *Scanner) liveFuture(token uint32) (*FutureData, bool) {
+	inst, ok := s.instruments[token]
+	if !ok || inst.InstrumentType != "FUT" {
+		return nil, false
+	}
+
+	future, ok := s.futures[inst.Name][inst.Expiry]
+	return future, ok
+}
+
+// GetFutureUnderlyings returns the underlyings that have futures
+func (s *Scanner) GetFutureUnderlyings() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	underlyings := make([]string, 0, len(s.futures))
+	for u := range s.futures {
+		underlyings = append(underlyings, u)
+	}
+
+	sort.Strings(underlyings)
+	return underlyings
+}
+
+// FuturesCurve builds the live term structure of an underlying's unexpired
+// futures, with basis and carry against the spot in the tick store
+func (s *Scanner) FuturesCurve(underlying string, now time.Time) (*FuturesCurve, bool) {
+	futures := s.GetFutures(underlying)
+	if len(futures) == 0 {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	cal := s.calendar
+	s.mu.RUnlock()
+
+	curve := &FuturesCurve{
+		Underlying:  underlying,
+		Futures:     make([]FutureQuote, 0, len(futures)),
+		LastUpdated: now,
+	}
+	if token, ok := s.GetSpotToken(underlying); ok {
+		curve.SpotToken = token
+		if spot, ok := store.GlobalStore.GetLTP(token); ok {
+			curve.Spot = spot
+		}
+	}
+
+	for _, future := range futures {
+		days := cal.DaysToExpiry(now, future.Expiry)
+		if days < 0 {
+			continue
+		}
+
+		quote := FutureQuote{
+			FutureData:   *future,
+			DaysToExpiry: days,
+			TimeToExpiry: cal.TimeToExpiry(now, future.Expiry),
+		}
+		if curve.Spot > 0 && future.LastPrice > 0 {
+			quote.Basis = future.LastPrice - curve.Spot
+			quote.BasisPct = quote.Basis / curve.Spot
+			if quote.TimeToExpiry > 0 {
+				quote.AnnualizedCarry = math.Log(future.LastPrice/curve.Spot) / quote.TimeToExpiry
+			}
+		}
+		curve.Futures = append(curve.Futures, quote)
+	}
+
+	curve.Rollover = rollover(curve.Futures)
+	return curve, true
+}
+
+// rollover computes near to next month rollover from an expiry-ordered curve
+func rollover(quotes []FutureQuote) *Rollover {
+	if len(quotes) < 2 {
+		return nil
+	}
+
+	near, next := quotes[0], quotes[1]
+	r := &Rollover{
+		NearSymbol:   near.Tradingsymbol,
+		NextSymbol:   next.Tradingsymbol,
+		NearExpiry:   near.Expiry,
+		DaysToExpiry: near.DaysToExpiry,
+		NearOI:       uint64(near.OI),
+		NextOI:       uint64(next.OI),
+		InWindow:     near.DaysToExpiry <= RolloverWindowDays,
+	}
+	for _, q := range quotes {
+		r.TotalOI += uint64(q.OI)
+	}
+	if r.TotalOI > 0 {
+		r.RolloverPct = float64(r.NextOI) / float64(r.TotalOI)
+	}
+	return r
+}
+
+// InterpolatedForward estimates the forward for an expiry without a listed
+// future by interpolating annualized carry linearly in time between the live
+// futures (flat beyond either end) and applying it to the spot
+func (s *Scanner) InterpolatedForward(underlying string, spot, timeToExpiry float64, now time.Time) (float64, bool) {
+	if spot <= 0 || timeToExpiry <= 0 {
+		return 0, false
+	}
+
+	curve := s.curvePoints(underlying, now)
+	if len(curve) == 0 {
+		return 0, false
+	}
+
+	type point struct{ t, carry float64 }
+	points := make([]point, len(curve))
+	for i, p := range curve {
+		points[i] = point{p.t, math.Log(p.price/spot) / p.t}
+	}
+
+	carry := points[0].carry
+	for i, p := range points {
+		if timeToExpiry <= p.t {
+			if i > 0 {
+				prev := points[i-1]
+				w := (timeToExpiry - prev.t) / (p.t - prev.t)
+				carry = prev.carry + w*(p.carry-prev.carry)
+			} else {
+				carry = p.carry
+			}
+			break
+		}
+		carry = p.carry
+	}
+
+	return spot * math.Exp(carry*timeToExpiry), true
+}
+
+// curvePoints returns the priced, unexpired futures of an underlying in
+// expiry order, building the curve only when no cached one is fresh
+func (s *Scanner) curvePoints(underlying string, now time.Time) []curvePoint {
+	s.mu.RLock()
+	cached, ok := s.curves[underlying]
+	s.mu.RUnlock()
+	if ok && now.Sub(cached.at) < forwardCacheAge && !now.Before(cached.at) {
+		return cached.points
+	}
+
+	var points []curvePoint
+	if curve, ok := s.FuturesCurve(underlying, now); ok {
+		for _, q := range curve.Futures {
+			if q.LastPrice > 0 && q.TimeToExpiry > 0 {
+				points = append(points, curvePoint{q.TimeToExpiry, q.LastPrice})
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.curves[underlying] = cachedCurve{points: points, at: now}
+	s.mu.Unlock()
+	return points
+}
+
+// InvalidateForwards drops the cached futures curve of the underlying a
+// future or spot token belongs to, returning the underlying. Call it on every
+// tick, before options priced off the forward are updated.
+func (s *Scanner) InvalidateForwards(token uint32) (string, bool) {
+	s.mu.RLock()
+	underlying, ok := s.forwardUnderlying(token)
+	_, cached := s.curves[underlying]
+	s.mu.RUnlock()
+
+	if ok && cached {
+		s.mu.Lock()
+		delete(s.curves, underlying)
+		s.mu.Unlock()
+	}
+	return underlying, ok
+}
+
+// forwardUnderlying returns the underlying whose forwards a future or spot
+// token moves. The caller must hold the lock.
+func (s *Scanner) forwardUnderlying(token uint32) (string, bool) {
+	if inst, ok := s.instruments[token]; ok {
+		if inst.InstrumentType == "FUT" {
+			return inst.Name, true
+		}
+		if inst.InstrumentType == Call || inst.InstrumentType == Put {
+			return "", false
+		}
+	}
+	for underlying, spot := range s.spotTokens {
+		if spot == token {
+			return underlying, true
+		}
+	}
+	return "", false
+}
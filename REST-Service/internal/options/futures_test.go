@@ -0,0 +1,119 @@
+package options
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"rest-service/internal/calendar"
+
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollover(t *testing.T) {
+	quotes := []FutureQuote{
+		{FutureData: FutureData{Tradingsymbol: "NIFTY25DECFUT", OI: 600}, DaysToExpiry: 3},
+		{FutureData: FutureData{Tradingsymbol: "NIFTY26JANFUT", OI: 300}, DaysToExpiry: 31},
+		{FutureData: FutureData{Tradingsymbol: "NIFTY26FEBFUT", OI: 100}, DaysToExpiry: 59},
+	}
+
+	r := rollover(quotes)
+	require.NotNil(t, r)
+	require.Equal(t, uint64(1000), r.TotalOI)
+	require.InDelta(t, 0.3, r.RolloverPct, 1e-12)
+	require.True(t, r.InWindow)
+
+	require.Nil(t, rollover(quotes[:1]))
+}
+
+func TestInterpolatedForward(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, calendar.IST)
+	near := time.Date(2025, 12, 30, 0, 0, 0, 0, calendar.IST)
+	far := time.Date(2026, 1, 27, 0, 0, 0, 0, calendar.IST)
+
+	s := NewScanner(nil)
+	cal := calendar.Default()
+	spot := 100.0
+	tNear, tFar := cal.TimeToExpiry(now, near), cal.TimeToExpiry(now, far)
+	s.futures["NIFTY"] = map[time.Time]*FutureData{
+		near: {Expiry: near, LastPrice: spot * math.Exp(0.06*tNear)},
+		far:  {Expiry: far, LastPrice: spot * math.Exp(0.08*tFar)},
+	}
+	s.instruments[1] = &OptionInstrument{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "FUT", Expiry: near}
+	s.instruments[2] = &OptionInstrument{InstrumentToken: 2, Name: "NIFTY", InstrumentType: Call, Expiry: near}
+	s.spotTokens["NIFTY"] = 3
+
+	// Halfway in time between the two futures carries halfway between their rates
+	mid := (tNear + tFar) / 2
+	forward, ok := s.InterpolatedForward("NIFTY", spot, mid, now)
+	require.True(t, ok)
+	require.InDelta(t, spot*math.Exp(0.07*mid), forward, 1e-9)
+
+	// Flat carry before the first future
+	forward, ok = s.InterpolatedForward("NIFTY", spot, tNear/2, now)
+	require.True(t, ok)
+	require.InDelta(t, spot*math.Exp(0.06*tNear/2), forward, 1e-9)
+
+	// The curve is kept until a future or spot tick of the underlying, or
+	// until it ages out
+	s.futures["NIFTY"][near].LastPrice = spot * math.Exp(0.10*tNear)
+	forward, _ = s.InterpolatedForward("NIFTY", spot, tNear/2, now)
+	require.InDelta(t, spot*math.Exp(0.06*tNear/2), forward, 1e-9)
+
+	_, ok = s.InvalidateForwards(2) // An option tick
+	require.False(t, ok)
+	forward, _ = s.InterpolatedForward("NIFTY", spot, tNear/2, now)
+	require.InDelta(t, spot*math.Exp(0.06*tNear/2), forward, 1e-9)
+
+	underlying, ok := s.InvalidateForwards(1)
+	require.True(t, ok)
+	require.Equal(t, "NIFTY", underlying)
+	forward, _ = s.InterpolatedForward("NIFTY", spot, tNear/2, now)
+	require.InDelta(t, spot*math.Exp(0.10*tNear/2), forward, 1e-9)
+
+	s.futures["NIFTY"][near].LastPrice = spot * math.Exp(0.06*tNear)
+	underlying, ok = s.InvalidateForwards(3)
+	require.True(t, ok)
+	require.Equal(t, "NIFTY", underlying)
+	forward, _ = s.InterpolatedForward("NIFTY", spot, tNear/2, now)
+	require.InDelta(t, spot*math.Exp(0.06*tNear/2), forward, 1e-9)
+
+	s.futures["NIFTY"][near].LastPrice = spot * math.Exp(0.10*tNear)
+	later := now.Add(forwardCacheAge)
+	forward, _ = s.InterpolatedForward("NIFTY", spot, tNear/2, later)
+	carry := 0.10 * tNear / cal.TimeToExpiry(later, near)
+	require.InDelta(t, spot*math.Exp(carry*tNear/2), forward, 1e-9)
+}
+
+func TestFuturesSnapshot(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, calendar.IST)
+	expiry := time.Date(2025, 12, 30, 0, 0, 0, 0, calendar.IST)
+	s := NewScanner(nil)
+	s.instruments[1] = &OptionInstrument{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "FUT", Expiry: expiry}
+	s.futures["NIFTY"] = map[time.Time]*FutureData{expiry: {InstrumentToken: 1, Expiry: expiry}}
+
+	// Ticks updating a future neither race with nor reach the curve's copy
+	snap, ok := s.GetFutureData(1)
+	require.True(t, ok)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 1000; i++ {
+			s.UpdateFuture(models.Tick{InstrumentToken: 1, LastPrice: float64(i), OI: uint32(i)})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		curve, ok := s.FuturesCurve("NIFTY", now)
+		require.True(t, ok)
+		_, err := json.Marshal(curve)
+		require.NoError(t, err)
+	}
+	<-done
+	require.Zero(t, snap.LastPrice)
+	future, _ := s.GetFutureData(1)
+	require.Equal(t, 1000.0, future.LastPrice)
+	require.False(t, s.UpdateFuture(models.Tick{InstrumentToken: 2}))
+}
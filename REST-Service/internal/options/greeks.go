@@ -3,27 +3,101 @@ package options
 import (
 	"math"
 	"time"
+
+	"rest-service/internal/calendar"
 )
 
-// GreeksCalculator calculates option Greeks using Black-Scholes model
+// GreeksCalculator calculates option Greeks using the generalised Black-Scholes model.
+// The cost of carry depends on the pricing model: BSM prices off the spot with
+// carry r - q, Black-76 prices off a forward (future or synthetic) with zero carry.
 type GreeksCalculator struct {
-	riskFreeRate float64 // Risk-free interest rate (annual, e.g., 0.06 for 6%)
+	riskFreeRate  float64      // Risk-free interest rate (annual, e.g., 0.06 for 6%)
+	dividendYield float64      // Continuous dividend yield (annual, BSM only)
+	model         PricingModel // Pricing model used for price and Greeks
+	daysPerYear   float64      // Days in a year of time to expiry, used to quote theta per day
 }
 
-// NewGreeksCalculator creates a new Greeks calculator
+// NewGreeksCalculator creates a new Black-Scholes-Merton Greeks calculator
 // riskFreeRate: Annual risk-free rate (default: 0.06 for 6%)
 func NewGreeksCalculator(riskFreeRate float64) *GreeksCalculator {
-	if riskFreeRate == 0 {
-		riskFreeRate = 0.06 // Default 6% risk-free rate
+	return NewGreeksCalculatorWithParams(PricingParams{
+		Model:        ModelBSM,
+		RiskFreeRate: riskFreeRate,
+	})
+}
+
+// NewGreeksCalculatorWithParams creates a Greeks calculator for the given pricing parameters
+func NewGreeksCalculatorWithParams(params PricingParams) *GreeksCalculator {
+	if params.RiskFreeRate == 0 {
+		params.RiskFreeRate = DefaultRiskFreeRate
+	}
+	if params.Model == "" {
+		params.Model = ModelBSM
 	}
 	return &GreeksCalculator{
-		riskFreeRate: riskFreeRate,
+		riskFreeRate:  params.RiskFreeRate,
+		dividendYield: params.DividendYield,
+		model:         params.Model,
+		daysPerYear:   calendar.CalendarDaysPerYear,
+	}
+}
+
+// Model returns the pricing model used by this calculator
+func (gc *GreeksCalculator) Model() PricingModel {
+	return gc.model
+}
+
+// RiskFreeRate returns the annual risk-free rate used by this calculator
+func (gc *GreeksCalculator) RiskFreeRate() float64 {
+	return gc.riskFreeRate
+}
+
+// Params returns the pricing parameters of this calculator
+func (gc *GreeksCalculator) Params() PricingParams {
+	return PricingParams{
+		Model:         gc.model,
+		RiskFreeRate:  gc.riskFreeRate,
+		DividendYield: gc.dividendYield,
+	}
+}
+
+// withModel returns a copy of the calculator using a different pricing model
+func (gc *GreeksCalculator) withModel(model PricingModel) *GreeksCalculator {
+	if gc.model == model {
+		return gc
 	}
+	copied := *gc
+	copied.model = model
+	return &copied
+}
+
+// withDaysPerYear returns a copy of the calculator quoting theta per 1/days of a year
+func (gc *GreeksCalculator) withDaysPerYear(days float64) *GreeksCalculator {
+	if gc.daysPerYear == days || days <= 0 {
+		return gc
+	}
+	copied := *gc
+	copied.daysPerYear = days
+	return &copied
+}
+
+// costOfCarry returns the carry term b of the generalised model.
+// Black-76 prices a forward, which has no carry; BSM carries at r - q.
+func (gc *GreeksCalculator) costOfCarry() float64 {
+	if gc.model.UsesForward() {
+		return 0
+	}
+	return gc.riskFreeRate - gc.dividendYield
+}
+
+// carryDiscount returns e^((b-r)T), the factor applied to the underlying leg
+func (gc *GreeksCalculator) carryDiscount(T float64) float64 {
+	return math.Exp((gc.costOfCarry() - gc.riskFreeRate) * T)
 }
 
 // CalculateGreeks calculates all Greeks for an option
-// underlyingPrice: Current price of the underlying asset
-// Returns: delta, gamma, theta, vega, or error
+// underlyingPrice: Spot price for BSM, forward/future price for Black-76
+// Returns: delta, gamma, theta, vega
 func (gc *GreeksCalculator) CalculateGreeks(
 	optionType OptionType,
 	underlyingPrice float64,
@@ -35,120 +109,161 @@ func (gc *GreeksCalculator) CalculateGreeks(
 		return 0, 0, 0, 0
 	}
 
-	// Calculate d1 and d2 for Black-Scholes
+	// Calculate d1 and d2 for the generalised Black-Scholes formula
 	d1, d2 := gc.calculateD1D2(underlyingPrice, strike, timeToExpiry, iv)
 
-	// Calculate standard normal CDF
-	nd1 := gc.normCDF(d1)
-	nd2 := gc.normCDF(d2)
-	nd2Neg := gc.normCDF(-d2)
+	b := gc.costOfCarry()
+	r := gc.riskFreeRate
+	carry := gc.carryDiscount(timeToExpiry)
+	discount := math.Exp(-r * timeToExpiry)
 
 	// Calculate PDF (probability density function)
 	pdfD1 := gc.normPDF(d1)
 
+	// Time decay common to calls and puts
+	decay := -(underlyingPrice * carry * pdfD1 * iv) / (2 * math.Sqrt(timeToExpiry))
+
 	// Calculate Greeks
 	if optionType == Call {
-		delta = nd1
-		theta = (-(underlyingPrice * pdfD1 * iv) / (2 * math.Sqrt(timeToExpiry))) -
-			(gc.riskFreeRate * strike * math.Exp(-gc.riskFreeRate*timeToExpiry) * nd2)
+		delta = carry * gc.normCDF(d1)
+		theta = decay -
+			(b-r)*underlyingPrice*carry*gc.normCDF(d1) -
+			r*strike*discount*gc.normCDF(d2)
 	} else { // Put
-		delta = nd1 - 1
-		theta = (-(underlyingPrice * pdfD1 * iv) / (2 * math.Sqrt(timeToExpiry))) +
-			(gc.riskFreeRate * strike * math.Exp(-gc.riskFreeRate*timeToExpiry) * nd2Neg)
+		delta = carry * (gc.normCDF(d1) - 1)
+		theta = decay +
+			(b-r)*underlyingPrice*carry*gc.normCDF(-d1) +
+			r*strike*discount*gc.normCDF(-d2)
 	}
 
 	// Gamma is the same for calls and puts
-	gamma = pdfD1 / (underlyingPrice * iv * math.Sqrt(timeToExpiry))
+	gamma = carry * pdfD1 / (underlyingPrice * iv * math.Sqrt(timeToExpiry))
 
 	// Vega is the same for calls and puts
-	vega = underlyingPrice * pdfD1 * math.Sqrt(timeToExpiry) / 100 // Divide by 100 for percentage
+	vega = underlyingPrice * carry * pdfD1 * math.Sqrt(timeToExpiry) / 100 // Divide by 100 for percentage
 
 	// Convert theta to per day (from per year)
-	theta = theta / 365.0
+	theta = theta / gc.daysPerYear
 
 	return delta, gamma, theta, vega
 }
 
-// calculateD1D2 calculates d1 and d2 for Black-Scholes formula
-func (gc *GreeksCalculator) calculateD1D2(S, K, T, sigma float64) (d1, d2 float64) {
-	d1 = (math.Log(S/K) + (gc.riskFreeRate+0.5*sigma*sigma)*T) / (sigma * math.Sqrt(T))
-	d2 = d1 - sigma*math.Sqrt(T)
-	return d1, d2
+// SecondOrderGreeks holds rho and the higher-order sensitivities of an option.
+// Volatility sensitivities are per 1% vol, time sensitivities per day.
+type SecondOrderGreeks struct {
+	Rho   float64 // dPrice/dRate per 1% rate change
+	Vanna float64 // dDelta/dVol per 1% vol change
+	Volga float64 // dVega/dVol per 1% vol change (vomma)
+	Charm float64 // Change in delta per day passing
+	Speed float64 // dGamma/dUnderlying
+	Color float64 // Change in gamma per day passing
 }
 
-// CalculateIV calculates Implied Volatility using Newton-Raphson method
-// optionPrice: Current market price of the option
-// underlyingPrice: Current price of the underlying asset
-// strike: Strike price
-// timeToExpiry: Time to expiry in years
-// optionType: Call or Put
-// Returns: Implied Volatility (annual)
-func (gc *GreeksCalculator) CalculateIV(
-	optionPrice float64,
+// CalculateSecondOrderGreeks calculates rho, vanna, volga, charm, speed and color
+// underlyingPrice: Spot price for BSM, forward/future price for Black-76
+func (gc *GreeksCalculator) CalculateSecondOrderGreeks(
+	optionType OptionType,
 	underlyingPrice float64,
 	strike float64,
-	timeToExpiry float64,
-	optionType OptionType,
-) float64 {
-	if optionPrice <= 0 || underlyingPrice <= 0 || strike <= 0 || timeToExpiry <= 0 {
-		return 0
+	timeToExpiry float64, // in years
+	iv float64, // Implied Volatility (annual, e.g., 0.20 for 20%)
+) SecondOrderGreeks {
+	if underlyingPrice <= 0 || strike <= 0 || timeToExpiry <= 0 || iv <= 0 {
+		return SecondOrderGreeks{}
 	}
 
-	// Initial guess for IV (start with 20%)
-	iv := 0.20
-	maxIterations := 100
-	tolerance := 0.0001
+	S, K, T := underlyingPrice, strike, timeToExpiry
+	d1, d2 := gc.calculateD1D2(S, K, T, iv)
 
-	for i := 0; i < maxIterations; i++ {
-		// Calculate theoretical price with current IV
-		theoreticalPrice := gc.blackScholesPrice(underlyingPrice, strike, timeToExpiry, iv, optionType)
-
-		// Calculate vega (sensitivity to volatility)
-		d1, _ := gc.calculateD1D2(underlyingPrice, strike, timeToExpiry, iv)
-		vega := underlyingPrice * gc.normPDF(d1) * math.Sqrt(timeToExpiry) / 100
+	b := gc.costOfCarry()
+	r := gc.riskFreeRate
+	carry := gc.carryDiscount(T)
+	discount := math.Exp(-r * T)
+	sqrtT := math.Sqrt(T)
+	volSqrtT := iv * sqrtT
+	pdfD1 := gc.normPDF(d1)
 
-		// Avoid division by zero
-		if math.Abs(vega) < 0.0001 {
-			break
-		}
+	gamma := carry * pdfD1 / (S * volSqrtT)
+	vega := S * carry * pdfD1 * sqrtT
 
-		// Newton-Raphson update
-		priceDiff := theoreticalPrice - optionPrice
-		ivNew := iv - priceDiff/vega
+	var g SecondOrderGreeks
 
-		// Ensure IV stays positive
-		if ivNew < 0 {
-			ivNew = 0.01
-		}
+	// Rho: a forward held fixed only feels the discount factor; with spot
+	// pricing the carry moves with the rate as well
+	if gc.model.UsesForward() {
+		g.Rho = -T * gc.blackScholesPrice(S, K, T, iv, optionType)
+	} else if optionType == Call {
+		g.Rho = K * T * discount * gc.normCDF(d2)
+	} else {
+		g.Rho = -K * T * discount * gc.normCDF(-d2)
+	}
 
-		// Check convergence
-		if math.Abs(ivNew-iv) < tolerance {
-			return ivNew
-		}
+	g.Vanna = -carry * pdfD1 * d2 / iv
+	g.Volga = vega * d1 * d2 / iv
+	g.Speed = -gamma / S * (1 + d1/volSqrtT)
 
-		iv = ivNew
+	// Charm is dDelta/dt with t the calendar time passing (= -dDelta/dT)
+	charm := -carry * pdfD1 * (b/volSqrtT - d2/(2*T))
+	if optionType == Call {
+		charm -= (b - r) * carry * gc.normCDF(d1)
+	} else {
+		charm += (b - r) * carry * gc.normCDF(-d1)
 	}
+	g.Charm = charm
+
+	// Color is dGamma/dt with t the calendar time passing (= -dGamma/dT)
+	g.Color = gamma / (2 * T) * (2*(r-b)*T + 1 + d1*(2*b*T-d2*volSqrtT)/volSqrtT)
+
+	// Scale to the reported units
+	g.Rho /= 100
+	g.Vanna /= 100
+	g.Volga /= 100 * 100
+	g.Charm /= gc.daysPerYear
+	g.Color /= gc.daysPerYear
+
+	return g
+}
 
-	return iv
+// calculateD1D2 calculates d1 and d2 for the generalised Black-Scholes formula
+func (gc *GreeksCalculator) calculateD1D2(S, K, T, sigma float64) (d1, d2 float64) {
+	d1 = (math.Log(S/K) + (gc.costOfCarry()+0.5*sigma*sigma)*T) / (sigma * math.Sqrt(T))
+	d2 = d1 - sigma*math.Sqrt(T)
+	return d1, d2
 }
 
-// blackScholesPrice calculates theoretical option price using Black-Scholes
+// blackScholesPrice calculates theoretical option price using the generalised Black-Scholes formula
+// With zero carry this is Black-76 on a forward; with carry r - q it is BSM with dividends.
 func (gc *GreeksCalculator) blackScholesPrice(
 	S, K, T, sigma float64,
 	optionType OptionType,
 ) float64 {
 	d1, d2 := gc.calculateD1D2(S, K, T, sigma)
+	carry := gc.carryDiscount(T)
+	discount := math.Exp(-gc.riskFreeRate * T)
 
 	if optionType == Call {
-		return S*gc.normCDF(d1) - K*math.Exp(-gc.riskFreeRate*T)*gc.normCDF(d2)
+		return S*carry*gc.normCDF(d1) - K*discount*gc.normCDF(d2)
 	} else { // Put
-		return K*math.Exp(-gc.riskFreeRate*T)*gc.normCDF(-d2) - S*gc.normCDF(-d1)
+		return K*discount*gc.normCDF(-d2) - S*carry*gc.normCDF(-d1)
+	}
+}
+
+// Price returns the theoretical option price, falling back to intrinsic value
+// at or after expiry and for a zero volatility
+func (gc *GreeksCalculator) Price(optionType OptionType, S, K, T, sigma float64) float64 {
+	if T <= 0 || sigma <= 0 || S <= 0 {
+		if optionType == Call {
+			return math.Max(0, S-K)
+		}
+		return math.Max(0, K-S)
 	}
+	return gc.blackScholesPrice(S, K, T, sigma, optionType)
 }
 
 // normCDF calculates the cumulative distribution function of the standard normal distribution
+// Erfc keeps full precision in the tails, where deep OTM prices live
 func (gc *GreeksCalculator) normCDF(x float64) float64 {
-	return 0.5 * (1 + gc.erf(x/math.Sqrt2))
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
 }
 
 // normPDF calculates the probability density function of the standard normal distribution
@@ -156,28 +271,6 @@ func (gc *GreeksCalculator) normPDF(x float64) float64 {
 	return (1.0 / math.Sqrt(2*math.Pi)) * math.Exp(-0.5*x*x)
 }
 
-// erf calculates the error function approximation
-func (gc *GreeksCalculator) erf(x float64) float64 {
-	// Abramowitz and Stegun approximation
-	a1 := 0.254829592
-	a2 := -0.284496736
-	a3 := 1.421413741
-	a4 := -1.453152027
-	a5 := 1.061405429
-	p := 0.3275911
-
-	sign := 1.0
-	if x < 0 {
-		sign = -1.0
-	}
-	x = math.Abs(x)
-
-	t := 1.0 / (1.0 + p*x)
-	y := 1.0 - (((((a5*t+a4)*t)+a3)*t+a2)*t+a1)*t*math.Exp(-x*x)
-
-	return sign * y
-}
-
 // CalculateIntrinsicAndTimeValue calculates intrinsic and time value
 func (gc *GreeksCalculator) CalculateIntrinsicAndTimeValue(
 	optionPrice float64,
@@ -199,12 +292,8 @@ func (gc *GreeksCalculator) CalculateIntrinsicAndTimeValue(
 	return intrinsicValue, timeValue
 }
 
-// CalculateTimeToExpiry calculates time to expiry in years
+// CalculateTimeToExpiry calculates time to expiry in years until the 15:30 IST
+// expiry cutoff, using the default exchange calendar
 func CalculateTimeToExpiry(expiry time.Time) float64 {
-	now := time.Now()
-	if expiry.Before(now) {
-		return 0
-	}
-	duration := expiry.Sub(now)
-	return duration.Hours() / (365.0 * 24.0)
+	return calendar.Default().TimeToExpiry(time.Now(), expiry)
 }
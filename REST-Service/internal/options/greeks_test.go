@@ -0,0 +1,228 @@
+package options
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"rest-service/internal/calendar"
+
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutCallParity(t *testing.T) {
+	tt := []struct {
+		name   string
+		params PricingParams
+		price  float64 // spot for BSM, forward for Black-76
+	}{
+		{name: "bsm", params: PricingParams{Model: ModelBSM, RiskFreeRate: 0.065}, price: 26000},
+		{name: "bsm with dividend", params: PricingParams{Model: ModelBSM, RiskFreeRate: 0.065, DividendYield: 0.013}, price: 1450},
+		{name: "black76", params: PricingParams{Model: ModelBlack76, RiskFreeRate: 0.065}, price: 26110},
+	}
+
+	for _, tc := range tt {
+		gc := NewGreeksCalculatorWithParams(tc.params)
+		strike, T, sigma := tc.price*1.02, 30.0/365.0, 0.18
+
+		call := gc.blackScholesPrice(tc.price, strike, T, sigma, Call)
+		put := gc.blackScholesPrice(tc.price, strike, T, sigma, Put)
+
+		// C - P = S e^((b-r)T) - K e^(-rT)
+		expected := tc.price*gc.carryDiscount(T) - strike*math.Exp(-tc.params.RiskFreeRate*T)
+		require.InDelta(t, expected, call-put, 1e-8, tc.name)
+	}
+}
+
+func TestImpliedForward(t *testing.T) {
+	gc := NewGreeksCalculatorWithParams(PricingParams{Model: ModelBlack76, RiskFreeRate: 0.065})
+	forward, T, sigma := 26125.0, 14.0/365.0, 0.12
+
+	chain := &OptionChain{Strikes: make(map[float64]*StrikeData)}
+	for strike := 25500.0; strike <= 26500; strike += 50 {
+		chain.Strikes[strike] = &StrikeData{
+			Strike: strike,
+			Call:   &OptionData{LastPrice: gc.blackScholesPrice(forward, strike, T, sigma, Call)},
+			Put:    &OptionData{LastPrice: gc.blackScholesPrice(forward, strike, T, sigma, Put)},
+		}
+	}
+
+	implied, ok := ImpliedForward(chain, 0.065, T)
+	require.True(t, ok)
+	require.InDelta(t, forward, implied, 1e-6)
+
+	_, ok = ImpliedForward(&OptionChain{Strikes: map[float64]*StrikeData{}}, 0.065, T)
+	require.False(t, ok)
+}
+
+func TestCalculatorCachesForwards(t *testing.T) {
+	now := time.Now() // The curve is built at the current time
+	near := normalize(now.AddDate(0, 0, 30))
+	weekly := normalize(now.AddDate(0, 0, 7))
+	cal := calendar.Default()
+	tNear, tWeekly := cal.TimeToExpiry(now, near), cal.TimeToExpiry(now, weekly)
+
+	s := NewScanner(nil)
+	s.instruments[1] = &OptionInstrument{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "FUT", Expiry: near}
+	s.futures["NIFTY"] = map[time.Time]*FutureData{near: {InstrumentToken: 1, Expiry: near, LastPrice: 100 * math.Exp(0.06*tNear)}}
+	s.spotTokens["NIFTY"] = 3
+	c := NewCalculator(s, 0.065)
+
+	// The weekly has no future, so its forward comes off the curve and is
+	// reused until a future or spot tick
+	chain := &OptionChain{Underlying: "NIFTY", Expiry: weekly, Strikes: map[float64]*StrikeData{
+		100: {Strike: 100, Call: &OptionData{LastPrice: 6}, Put: &OptionData{LastPrice: 4}},
+	}}
+	forward, ok := c.forward(chain, 100, true, tWeekly, now)
+	require.True(t, ok)
+	require.InDelta(t, 100*math.Exp(0.06*tWeekly), forward, 1e-6)
+
+	s.UpdateFuture(models.Tick{InstrumentToken: 1, LastPrice: 100 * math.Exp(0.08*tNear)})
+	forward, _ = c.forward(chain, 100, true, tWeekly, now)
+	require.InDelta(t, 100*math.Exp(0.06*tWeekly), forward, 1e-6)
+	c.InvalidateForwards(1)
+	forward, _ = c.forward(chain, 100, true, tWeekly, now)
+	require.InDelta(t, 100*math.Exp(0.08*tWeekly), forward, 1e-6)
+
+	// Without a spot there is no forward, and nothing is cached
+	c.InvalidateForwards(3)
+	_, ok = c.forward(chain, 0, false, tWeekly, now)
+	require.False(t, ok)
+	_, ok = c.forward(chain, 100, true, tWeekly, now)
+	require.True(t, ok)
+
+	// The synthetic forward follows every option tick
+	gc := NewGreeksCalculatorWithParams(PricingParams{Model: ModelBlack76Synthetic, RiskFreeRate: 0.065})
+	growth := math.Exp(0.065 * tWeekly)
+	gc, synthetic, ok := c.resolveForward(gc, chain, 100, true, tWeekly)
+	require.True(t, ok)
+	require.Equal(t, ModelBlack76Synthetic, gc.Model())
+	require.InDelta(t, 100+2*growth, synthetic, 1e-6)
+	chain.Strikes[100].Call.LastPrice = 7
+	_, synthetic, _ = c.resolveForward(gc, chain, 100, true, tWeekly)
+	require.InDelta(t, 100+3*growth, synthetic, 1e-6)
+}
+
+func TestCalculateIVRoundTrip(t *testing.T) {
+	tt := []struct {
+		name   string
+		model  PricingModel
+		strike float64
+		T      float64
+		sigma  float64
+		typ    OptionType
+	}{
+		{name: "atm call", model: ModelBSM, strike: 26000, T: 20.0 / 365.0, sigma: 0.15, typ: Call},
+		{name: "otm put black76", model: ModelBlack76, strike: 25000, T: 20.0 / 365.0, sigma: 0.22, typ: Put},
+		{name: "deep otm call", model: ModelBSM, strike: 29000, T: 30.0 / 365.0, sigma: 0.14, typ: Call},
+		{name: "near expiry", model: ModelBlack76, strike: 26100, T: 2.0 / (365.0 * 24.0), sigma: 0.10, typ: Call},
+		{name: "high vol", model: ModelBSM, strike: 24000, T: 5.0 / 365.0, sigma: 1.8, typ: Put},
+	}
+
+	for _, tc := range tt {
+		gc := NewGreeksCalculatorWithParams(PricingParams{Model: tc.model, RiskFreeRate: 0.06})
+		price := gc.blackScholesPrice(26000, tc.strike, tc.T, tc.sigma, tc.typ)
+
+		iv, err := gc.CalculateIV(price, 26000, tc.strike, tc.T, tc.typ)
+		require.NoError(t, err, tc.name)
+		require.InDelta(t, tc.sigma, iv, 1e-6, tc.name)
+	}
+}
+
+func TestCalculateIVNoSolution(t *testing.T) {
+	gc := NewGreeksCalculator(0.06)
+	T := 10.0 / 365.0
+
+	// ITM call trading below intrinsic
+	_, err := gc.CalculateIV(900, 26000, 25000, T, Call)
+	require.Equal(t, ErrBelowIntrinsic, err)
+
+	// Put worth more than the discounted strike
+	_, err = gc.CalculateIV(25100, 26000, 25000, T, Put)
+	require.Equal(t, ErrAboveUpperBound, err)
+
+	_, err = gc.CalculateIV(0, 26000, 25000, T, Put)
+	require.Equal(t, ErrInvalidIVInputs, err)
+}
+
+func TestAssessQuote(t *testing.T) {
+	now := time.Now()
+	params := DefaultQualityParams()
+
+	fresh := &OptionData{BidPrice: 100, AskPrice: 101, LastUpdated: now, DepthUpdated: now}
+	require.Equal(t, QuoteQuality(0), assessQuote(fresh, params, now))
+
+	// Still trading, but the bid and ask are from a full tick a minute ago
+	oldDepth := &OptionData{BidPrice: 100, AskPrice: 101, LastUpdated: now, DepthUpdated: now.Add(-time.Minute)}
+	require.Equal(t, QualityStale, assessQuote(oldDepth, params, now))
+
+	wide := &OptionData{BidPrice: 10, AskPrice: 14, LastUpdated: now.Add(-time.Minute)}
+	q := assessQuote(wide, params, now)
+	require.True(t, q.Has(QualityWideSpread|QualityStale))
+	require.Equal(t, []string{"stale", "wide_spread"}, q.Flags())
+
+	oneSided := &OptionData{AskPrice: 5, LastUpdated: now, DepthUpdated: now}
+	require.Equal(t, QualityOneSided, assessQuote(oneSided, params, now))
+}
+
+func TestGreeksMatchFiniteDifferences(t *testing.T) {
+	tt := []struct {
+		name   string
+		params PricingParams
+		typ    OptionType
+		strike float64
+	}{
+		{name: "bsm call", params: PricingParams{Model: ModelBSM, RiskFreeRate: 0.065, DividendYield: 0.012}, typ: Call, strike: 26300},
+		{name: "bsm put", params: PricingParams{Model: ModelBSM, RiskFreeRate: 0.065, DividendYield: 0.012}, typ: Put, strike: 25700},
+		{name: "black76 call", params: PricingParams{Model: ModelBlack76, RiskFreeRate: 0.065}, typ: Call, strike: 25900},
+		{name: "black76 put", params: PricingParams{Model: ModelBlack76, RiskFreeRate: 0.065}, typ: Put, strike: 26400},
+	}
+
+	const (
+		S     = 26000.0
+		T     = 21.0 / 365.0
+		sigma = 0.16
+		dS    = 2.0
+		dVol  = 1e-4
+		dT    = 1e-5
+		dRate = 1e-5
+	)
+
+	for _, tc := range tt {
+		gc := NewGreeksCalculatorWithParams(tc.params)
+		price := func(s, tt, vol float64) float64 {
+			return gc.blackScholesPrice(s, tc.strike, tt, vol, tc.typ)
+		}
+		fdDelta := func(s, tt, vol float64) float64 {
+			return (price(s+dS, tt, vol) - price(s-dS, tt, vol)) / (2 * dS)
+		}
+		fdGamma := func(s, tt, vol float64) float64 {
+			return (price(s+dS, tt, vol) - 2*price(s, tt, vol) + price(s-dS, tt, vol)) / (dS * dS)
+		}
+		rated := func(rate float64) float64 {
+			p := tc.params
+			p.RiskFreeRate = rate
+			return NewGreeksCalculatorWithParams(p).blackScholesPrice(S, tc.strike, T, sigma, tc.typ)
+		}
+
+		delta, gamma, theta, vega := gc.CalculateGreeks(tc.typ, S, tc.strike, T, sigma)
+		second := gc.CalculateSecondOrderGreeks(tc.typ, S, tc.strike, T, sigma)
+
+		expect := func(greek string, want, got float64) {
+			require.InEpsilon(t, want, got, 1e-3, "%s %s", tc.name, greek)
+		}
+
+		expect("delta", fdDelta(S, T, sigma), delta)
+		expect("gamma", fdGamma(S, T, sigma), gamma)
+		expect("vega", (price(S, T, sigma+dVol)-price(S, T, sigma-dVol))/(2*dVol)/100, vega)
+		expect("theta", -(price(S, T+dT, sigma)-price(S, T-dT, sigma))/(2*dT)/365, theta)
+		expect("rho", (rated(tc.params.RiskFreeRate+dRate)-rated(tc.params.RiskFreeRate-dRate))/(2*dRate)/100, second.Rho)
+		expect("vanna", (fdDelta(S, T, sigma+dVol)-fdDelta(S, T, sigma-dVol))/(2*dVol)/100, second.Vanna)
+		expect("volga", (price(S, T, sigma+dVol)-2*price(S, T, sigma)+price(S, T, sigma-dVol))/(dVol*dVol)/10000, second.Volga)
+		expect("charm", -(fdDelta(S, T+dT, sigma)-fdDelta(S, T-dT, sigma))/(2*dT)/365, second.Charm)
+		expect("speed", (fdGamma(S+dS, T, sigma)-fdGamma(S-dS, T, sigma))/(2*dS), second.Speed)
+		expect("color", -(fdGamma(S, T+dT, sigma)-fdGamma(S, T-dT, sigma))/(2*dT)/365, second.Color)
+	}
+}
@@ -0,0 +1,128 @@
+package options
+
+import (
+	"errors"
+	"math"
+)
+
+// Implied volatility solver errors. A price that no volatility can reproduce is
+// reported instead of being clamped to an arbitrary value.
+var (
+	ErrInvalidIVInputs = errors.New("invalid inputs for implied volatility")
+	ErrBelowIntrinsic  = errors.New("price is below the discounted intrinsic value")
+	ErrAboveUpperBound = errors.New("price is above the no-arbitrage upper bound")
+	ErrIVOutOfBracket  = errors.New("implied volatility is outside the solver bracket")
+	ErrIVNoConvergence = errors.New("implied volatility solver did not converge")
+)
+
+const (
+	ivMin           = 1e-4 // 0.01% annualised
+	ivMax           = 5.0  // 500% annualised
+	ivPriceTol      = 1e-8 // Absolute price tolerance
+	ivVolTol        = 1e-10
+	ivMaxIterations = 100
+)
+
+// CalculateIV calculates Implied Volatility with a safeguarded Newton-Raphson solver.
+// The root is kept inside a [lo, hi] bracket; whenever a Newton step leaves the
+// bracket or vega vanishes (deep OTM, near expiry) the solver bisects instead.
+// optionPrice: Current market price of the option
+// underlyingPrice: Spot price for BSM, forward/future price for Black-76
+// strike: Strike price
+// timeToExpiry: Time to expiry in years
+// optionType: Call or Put
+// Returns: Implied Volatility (annual), or an error when no solution exists
+func (gc *GreeksCalculator) CalculateIV(
+	optionPrice float64,
+	underlyingPrice float64,
+	strike float64,
+	timeToExpiry float64,
+	optionType OptionType,
+) (float64, error) {
+	if optionPrice <= 0 || underlyingPrice <= 0 || strike <= 0 || timeToExpiry <= 0 {
+		return 0, ErrInvalidIVInputs
+	}
+
+	// No-arbitrage bounds: discounted intrinsic <= price <= discounted underlying (call) or strike (put)
+	lower, upper := gc.priceBounds(underlyingPrice, strike, timeToExpiry, optionType)
+	if optionPrice < lower-ivPriceTol {
+		return 0, ErrBelowIntrinsic
+	}
+	if optionPrice >= upper {
+		return 0, ErrAboveUpperBound
+	}
+
+	objective := func(sigma float64) float64 {
+		return gc.blackScholesPrice(underlyingPrice, strike, timeToExpiry, sigma, optionType) - optionPrice
+	}
+
+	lo, hi := ivMin, ivMax
+	fLo, fHi := objective(lo), objective(hi)
+	if fLo > 0 {
+		// Even a near-zero volatility is too expensive: the price is pure intrinsic
+		return 0, ErrBelowIntrinsic
+	}
+	if fHi < 0 {
+		return 0, ErrIVOutOfBracket
+	}
+
+	sigma := gc.initialIVGuess(optionPrice, underlyingPrice, strike, timeToExpiry)
+	if sigma <= lo || sigma >= hi {
+		sigma = 0.5 * (lo + hi)
+	}
+
+	for i := 0; i < ivMaxIterations; i++ {
+		diff := objective(sigma)
+		if math.Abs(diff) < ivPriceTol {
+			return sigma, nil
+		}
+
+		// Shrink the bracket around the root (price is increasing in volatility)
+		if diff > 0 {
+			hi = sigma
+		} else {
+			lo = sigma
+		}
+		if hi-lo < ivVolTol {
+			return 0.5 * (lo + hi), nil
+		}
+
+		// Newton step on raw vega (not the per-1% vega reported in Greeks)
+		vega := gc.rawVega(underlyingPrice, strike, timeToExpiry, sigma)
+		next := sigma - diff/vega
+		if vega < 1e-12 || math.IsNaN(next) || next <= lo || next >= hi {
+			next = 0.5 * (lo + hi) // Bisection fallback
+		}
+
+		sigma = next
+	}
+
+	return 0, ErrIVNoConvergence
+}
+
+// priceBounds returns the model-free lower and upper bounds of the option price
+func (gc *GreeksCalculator) priceBounds(S, K, T float64, optionType OptionType) (lower, upper float64) {
+	underlying := S * gc.carryDiscount(T)
+	strike := K * math.Exp(-gc.riskFreeRate*T)
+
+	if optionType == Call {
+		return math.Max(0, underlying-strike), underlying
+	}
+	return math.Max(0, strike-underlying), strike
+}
+
+// rawVega returns dPrice/dSigma for a unit change in volatility
+func (gc *GreeksCalculator) rawVega(S, K, T, sigma float64) float64 {
+	d1, _ := gc.calculateD1D2(S, K, T, sigma)
+	return S * gc.carryDiscount(T) * gc.normPDF(d1) * math.Sqrt(T)
+}
+
+// initialIVGuess uses the Brenner-Subrahmanyam ATM approximation, which is
+// close enough for near-the-money options and is clamped to the bracket otherwise
+func (gc *GreeksCalculator) initialIVGuess(price, S, K, T float64) float64 {
+	guess := math.Sqrt(2*math.Pi/T) * price / math.Sqrt(S*K)
+	if math.IsNaN(guess) || guess <= 0 {
+		return 0.2
+	}
+	return guess
+}
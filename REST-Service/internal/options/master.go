@@ -0,0 +1,146 @@
+package options
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"rest-service/internal/instruments"
+)
+
+// InstrumentDelta is the change in the instruments between two versions of
+// the instrument master
+type InstrumentDelta struct {
+	Since   string              `json:"since"`
+	Version string              `json:"version"`
+	Added   []*OptionInstrument `json:"added"`
+	Changed []*OptionInstrument `json:"changed"`
+	Removed []uint32            `json:"removed"`
+}
+
+// SetMaster sets the store the applied instrument masters are saved to, and
+// loaded from when Kite Connect is unavailable
+func (s *Scanner) SetMaster(master *instruments.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.master = master
+}
+
+// InstrumentVersion returns the version of the loaded instrument master, or
+// "" if it was not saved
+func (s *Scanner) InstrumentVersion() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// InstrumentsLoadedAt returns when the instruments were last applied, zero if
+// they never were
+func (s *Scanner) InstrumentsLoadedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loadedAt
+}
+
+// saveMaster saves the loaded instruments as a version, so the rows served
+// always match a saved version, and returns it
+func (s *Scanner) saveMaster(now time.Time) string {
+	s.mu.RLock()
+	master, all := s.master, s.allInstruments
+	s.mu.RUnlock()
+	if master == nil {
+		return ""
+	}
+
+	v, created, err := master.Save(all, now)
+	if err != nil {
+		log.Printf("Error saving instrument master: %v", err)
+		return ""
+	}
+	if created {
+		log.Printf("Saved instrument master version %s (%d instruments)", v.Version, v.Count)
+	}
+
+	s.mu.Lock()
+	s.version = v.Version
+	s.mu.Unlock()
+	return v.Version
+}
+
+// InstrumentsSince returns the instruments added, changed and removed since a
+// saved version. It returns instruments.ErrVersionNotFound for a version that
+// was never saved or has been pruned.
+func (s *Scanner) InstrumentsSince(version string) (InstrumentDelta, error) {
+	s.mu.RLock()
+	master, current, next := s.master, s.version, s.instruments
+	s.mu.RUnlock()
+	if master == nil || current == "" {
+		return InstrumentDelta{}, instruments.ErrVersionNotFound
+	}
+
+	delta := InstrumentDelta{
+		Since:   version,
+		Version: current,
+		Added:   []*OptionInstrument{},
+		Changed: []*OptionInstrument{},
+		Removed: []uint32{},
+	}
+	if version == current {
+		return delta, nil
+	}
+
+	all, err := master.Load(version)
+	if err != nil {
+		return InstrumentDelta{}, err
+	}
+	prev := make(map[uint32]*OptionInstrument, len(all))
+	for _, inst := range all {
+		optInst := NewOptionInstrument(inst)
+		prev[optInst.InstrumentToken] = optInst
+	}
+
+	delta.Added, delta.Changed, delta.Removed = DiffInstruments(prev, next)
+	return delta, nil
+}
+
+// DiffInstruments returns the instruments of next that are not in prev, those
+// whose listing changed, and the tokens of prev missing from next, all ordered
+// by token. The last price is a reference value and not compared.
+func DiffInstruments(prev, next map[uint32]*OptionInstrument) (added, changed []*OptionInstrument, removed []uint32) {
+	added, changed, removed = []*OptionInstrument{}, []*OptionInstrument{}, []uint32{}
+	for token, inst := range next {
+		old, ok := prev[token]
+		switch {
+		case !ok:
+			added = append(added, inst)
+		case !sameListing(old, inst):
+			changed = append(changed, inst)
+		}
+	}
+	for token := range prev {
+		if _, ok := next[token]; !ok {
+			removed = append(removed, token)
+		}
+	}
+
+	byToken := func(insts []*OptionInstrument) {
+		sort.Slice(insts, func(i, j int) bool { return insts[i].InstrumentToken < insts[j].InstrumentToken })
+	}
+	byToken(added)
+	byToken(changed)
+	sortTokens(removed)
+	return added, changed, removed
+}
+
+func sameListing(a, b *OptionInstrument) bool {
+	return a.ExchangeToken == b.ExchangeToken &&
+		a.Tradingsymbol == b.Tradingsymbol &&
+		a.Name == b.Name &&
+		a.Exchange == b.Exchange &&
+		a.Segment == b.Segment &&
+		a.InstrumentType == b.InstrumentType &&
+		a.StrikePrice == b.StrikePrice &&
+		a.Expiry.Equal(b.Expiry) &&
+		a.TickSize == b.TickSize &&
+		a.LotSize == b.LotSize
+}
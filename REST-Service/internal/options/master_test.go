@@ -0,0 +1,58 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	"rest-service/internal/instruments"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentsSince(t *testing.T) {
+	master, err := instruments.NewStore(t.TempDir(), 7)
+	require.NoError(t, err)
+	s := NewScanner(nil)
+	s.SetMaster(master)
+
+	expiry := models.Time{Time: time.Date(2026, 10, 27, 0, 0, 0, 0, ist)}
+	first := []kiteconnect.Instrument{
+		{InstrumentToken: 1, Tradingsymbol: "NIFTY 50", Exchange: "NSE", Segment: "INDICES", InstrumentType: "EQ"},
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, LotSize: 75, Expiry: expiry, LastPrice: 120},
+		{InstrumentToken: 3, Name: "NIFTY", InstrumentType: "PE", StrikePrice: 25000, LotSize: 75, Expiry: expiry},
+	}
+	day := time.Date(2026, 10, 20, 8, 45, 0, 0, ist)
+	s.ApplyInstruments(first, day)
+	require.Equal(t, "2026-10-20", s.saveMaster(day))
+
+	// A new strike, a lot size change and a delisting; the last price is ignored
+	second := []kiteconnect.Instrument{
+		first[0],
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, LotSize: 65, Expiry: expiry, LastPrice: 95},
+		{InstrumentToken: 4, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25100, LotSize: 75, Expiry: expiry},
+	}
+	next := day.AddDate(0, 0, 1)
+	s.ApplyInstruments(second, next)
+	require.Empty(t, s.InstrumentVersion())
+	require.Equal(t, "2026-10-21", s.saveMaster(next))
+
+	delta, err := s.InstrumentsSince("2026-10-20")
+	require.NoError(t, err)
+	require.Equal(t, "2026-10-21", delta.Version)
+	require.Len(t, delta.Added, 1)
+	require.Equal(t, uint32(4), delta.Added[0].InstrumentToken)
+	require.Len(t, delta.Changed, 1)
+	require.Equal(t, 65, delta.Changed[0].LotSize)
+	require.Equal(t, []uint32{3}, delta.Removed)
+
+	delta, err = s.InstrumentsSince("2026-10-21")
+	require.NoError(t, err)
+	require.Empty(t, delta.Added)
+	require.Empty(t, delta.Removed)
+
+	_, err = s.InstrumentsSince("2026-01-01")
+	require.ErrorIs(t, err, instruments.ErrVersionNotFound)
+}
@@ -1,8 +1,12 @@
 package options
 
 import (
+	"sort"
+	"sync"
 	"time"
 
+	kiteticker "rest-service/internal/ticker"
+
 	"gokiteconnect-master/models"
 )
 
@@ -32,50 +36,184 @@ type OptionInstrument struct {
 
 // OptionChain represents a complete option chain for an underlying
 type OptionChain struct {
-	Underlying      string
-	UnderlyingToken uint32
-	UnderlyingPrice float64 // Current price of the underlying
-	Expiry          time.Time
-	Strikes         map[float64]*StrikeData
-	LastUpdated     time.Time
+	Underlying      string                  `json:"underlying"`
+	UnderlyingToken uint32                  `json:"underlying_token"`
+	UnderlyingPrice float64                 `json:"underlying_price"` // Current price of the underlying
+	Forward         float64                 `json:"forward"`          // Forward price used by the pricing model
+	Expiry          time.Time               `json:"expiry"`
+	Strikes         map[float64]*StrikeData `json:"-"`
+	LastUpdated     time.Time               `json:"last_updated"`
+
+	mu sync.RWMutex // Held by Update, and read-held by Snapshot
 }
 
 // StrikeData contains both call and put data for a strike
 type StrikeData struct {
-	Strike      float64
-	Call        *OptionData
-	Put         *OptionData
-	LastUpdated time.Time
+	Strike      float64     `json:"strike"`
+	Call        *OptionData `json:"call,omitempty"`
+	Put         *OptionData `json:"put,omitempty"`
+	LastUpdated time.Time   `json:"last_updated"`
 }
 
 // OptionData contains real-time data for a single option
 type OptionData struct {
-	InstrumentToken uint32
-	Tradingsymbol   string
-	Type            OptionType
-	Strike          float64
-	Expiry          time.Time
+	InstrumentToken uint32     `json:"instrument_token"`
+	Tradingsymbol   string     `json:"tradingsymbol"`
+	Type            OptionType `json:"type"`
+	Strike          float64    `json:"strike"`
+	Expiry          time.Time  `json:"expiry"`
 
 	// Market Data (from ticks)
-	LastPrice   float64
-	BidPrice    float64
-	AskPrice    float64
-	BidQty      uint32
-	AskQty      uint32
-	Volume      uint32
-	OI          uint32 // Open Interest
-	LastUpdated time.Time
+	LastPrice    float64   `json:"last_price"`
+	BidPrice     float64   `json:"bid_price"`
+	AskPrice     float64   `json:"ask_price"`
+	BidQty       uint32    `json:"bid_qty"`
+	AskQty       uint32    `json:"ask_qty"`
+	Volume       uint32    `json:"volume"`
+	OI           uint32    `json:"oi"` // Open Interest
+	LastUpdated  time.Time `json:"last_updated"`
+	DepthUpdated time.Time `json:"depth_updated"` // Last full mode tick, which the bid, ask and OI are from
+
+	// Reference values for change and buildup analytics
+	PrevClose   float64 `json:"prev_close"`    // Previous session's closing price
+	PrevCloseOI uint32  `json:"prev_close_oi"` // OI at the previous session's close (0 if unknown)
+	OpenOI      uint32  `json:"open_oi"`       // First OI seen in the current session
+	Buildup     Buildup `json:"buildup"`       // Price/OI buildup classification
+	openOIDay   string  // IST date OpenOI was recorded on
 
 	// Calculated Fields
-	IV    float64 // Implied Volatility
-	Delta float64
-	Gamma float64
-	Theta float64
-	Vega  float64
+	Model   PricingModel `json:"model"`   // Pricing model the IV and Greeks below were computed with
+	Forward float64      `json:"forward"` // Underlying price fed to the model (spot for BSM, forward for Black-76)
+	IV      float64      `json:"iv"`      // Implied Volatility of the mid price
+	BidIV   float64      `json:"bid_iv"`  // Implied Volatility of the best bid (0 if no solution)
+	AskIV   float64      `json:"ask_iv"`  // Implied Volatility of the best ask (0 if no solution)
+	Quality QuoteQuality `json:"quality"` // Quote and IV quality flags
+	Delta   float64      `json:"delta"`
+	Gamma   float64      `json:"gamma"`
+	Theta   float64      `json:"theta"` // Per day
+	Vega    float64      `json:"vega"`  // Per 1% vol
+
+	// Rho and second-order Greeks
+	Rho   float64 `json:"rho"`   // Per 1% rate
+	Vanna float64 `json:"vanna"` // dDelta per 1% vol
+	Volga float64 `json:"volga"` // dVega per 1% vol
+	Charm float64 `json:"charm"` // Delta change per day
+	Speed float64 `json:"speed"` // dGamma/dUnderlying
+	Color float64 `json:"color"` // Gamma change per day
 
 	// Greeks calculated from market data
-	IntrinsicValue float64
-	TimeValue      float64
+	IntrinsicValue float64 `json:"intrinsic_value"`
+	TimeValue      float64 `json:"time_value"`
+}
+
+// FutureData contains real-time data for a single future
+type FutureData struct {
+	InstrumentToken uint32    `json:"instrument_token"`
+	Tradingsymbol   string    `json:"tradingsymbol"`
+	Underlying      string    `json:"underlying"`
+	Exchange        string    `json:"exchange"`
+	Expiry          time.Time `json:"expiry"`
+	LotSize         int       `json:"lot_size"`
+
+	// Market Data (from ticks)
+	LastPrice    float64   `json:"last_price"`
+	BidPrice     float64   `json:"bid_price"`
+	AskPrice     float64   `json:"ask_price"`
+	Volume       uint32    `json:"volume"`
+	OI           uint32    `json:"oi"`
+	PrevClose    float64   `json:"prev_close"`
+	LastUpdated  time.Time `json:"last_updated"`
+	DepthUpdated time.Time `json:"depth_updated"` // Last full mode tick, which the bid, ask and OI are from
+}
+
+// UpdateFromTick updates future data from a market tick
+func (fd *FutureData) UpdateFromTick(tick models.Tick) {
+	fd.LastPrice = tick.LastPrice
+	fd.LastUpdated = time.Now()
+
+	// Quote and LTP mode ticks have no depth, so the last bid and ask are
+	// dropped rather than left frozen; the last known OI is kept
+	if hasDepth(tick) {
+		fd.BidPrice = tick.Depth.Buy[0].Price
+		fd.AskPrice = tick.Depth.Sell[0].Price
+		fd.OI = tick.OI
+		fd.DepthUpdated = fd.LastUpdated
+	} else {
+		fd.BidPrice, fd.AskPrice = 0, 0
+	}
+	if hasVolume(tick) {
+		fd.Volume = tick.VolumeTraded
+	}
+	if tick.OHLC.Close > 0 {
+		fd.PrevClose = tick.OHLC.Close
+	}
+}
+
+// hasDepth reports whether a tick carries market depth and OI, which only
+// full mode ticks do
+func hasDepth(tick models.Tick) bool {
+	return tick.Mode == string(kiteticker.ModeFull)
+}
+
+// hasVolume reports whether a tick carries volume and OHLC, which LTP mode
+// ticks don't
+func hasVolume(tick models.Tick) bool {
+	return tick.Mode == string(kiteticker.ModeFull) || tick.Mode == string(kiteticker.ModeQuote)
+}
+
+// Update runs f, which may change the chain's prices, options and Greeks,
+// with the chain locked against Snapshot. Ticks update the live chain in
+// place, so anything serving or scanning it reads a snapshot instead.
+func (oc *OptionChain) Update(f func()) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	f()
+}
+
+// Snapshot returns a copy of the chain, its strikes and their option data,
+// consistent as of one moment
+func (oc *OptionChain) Snapshot() *OptionChain {
+	oc.mu.RLock()
+	defer oc.mu.RUnlock()
+
+	snap := &OptionChain{
+		Underlying:      oc.Underlying,
+		UnderlyingToken: oc.UnderlyingToken,
+		UnderlyingPrice: oc.UnderlyingPrice,
+		Forward:         oc.Forward,
+		Expiry:          oc.Expiry,
+		Strikes:         make(map[float64]*StrikeData, len(oc.Strikes)),
+		LastUpdated:     oc.LastUpdated,
+	}
+	for strike, sd := range oc.Strikes {
+		copied := *sd
+		if sd.Call != nil {
+			call := *sd.Call
+			copied.Call = &call
+		}
+		if sd.Put != nil {
+			put := *sd.Put
+			copied.Put = &put
+		}
+		snap.Strikes[strike] = &copied
+	}
+	return snap
+}
+
+// SortedStrikes returns the strikes of the chain in ascending order. The
+// strikes are shared with the chain, so take them from a Snapshot of a live
+// chain.
+func (oc *OptionChain) SortedStrikes() []*StrikeData {
+	strikes := make([]*StrikeData, 0, len(oc.Strikes))
+	for _, strikeData := range oc.Strikes {
+		strikes = append(strikes, strikeData)
+	}
+
+	sort.Slice(strikes, func(i, j int) bool {
+		return strikes[i].Strike < strikes[j].Strike
+	})
+
+	return strikes
 }
 
 // Position represents an open option position
@@ -126,18 +264,34 @@ func (od *OptionData) UpdateFromTick(tick models.Tick) {
 	od.LastPrice = tick.LastPrice
 	od.LastUpdated = time.Now()
 
-	// Update from depth if available
-	if len(tick.Depth.Buy) > 0 {
+	// Quote and LTP mode ticks have no depth, so the last bid and ask are
+	// dropped rather than left frozen; the last known OI is kept
+	if hasDepth(tick) {
 		od.BidPrice = tick.Depth.Buy[0].Price
 		od.BidQty = tick.Depth.Buy[0].Quantity
-	}
-	if len(tick.Depth.Sell) > 0 {
 		od.AskPrice = tick.Depth.Sell[0].Price
 		od.AskQty = tick.Depth.Sell[0].Quantity
+		od.OI = tick.OI
+		od.DepthUpdated = od.LastUpdated
+	} else {
+		od.BidPrice, od.BidQty, od.AskPrice, od.AskQty = 0, 0, 0, 0
+	}
+	if hasVolume(tick) {
+		od.Volume = tick.VolumeTraded
+	}
+
+	if tick.OHLC.Close > 0 {
+		od.PrevClose = tick.OHLC.Close
+	}
+
+	// Remember the first OI of each session as the intraday reference
+	today := od.LastUpdated.In(ist).Format("2006-01-02")
+	if od.openOIDay != today && tick.OI > 0 {
+		od.OpenOI = tick.OI
+		od.openOIDay = today
 	}
 
-	od.Volume = tick.VolumeTraded
-	od.OI = tick.OI
+	od.Buildup = ClassifyBuildup(od.LastPrice-od.PrevClose, od.OIChange())
 
 	//fmt.Println("OptionData Updated :", od.Tradingsymbol, " LastPrice: ", od.LastPrice, " BidPrice: ", od.BidPrice, " AskPrice: ", od.AskPrice, " Volume: ", od.Volume, " OI: ", od.OI)
 }
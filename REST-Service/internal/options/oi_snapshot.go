@@ -0,0 +1,97 @@
+package options
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// OISnapshot records the closing OI of every option in the chains for a session,
+// so OI change since the previous close survives a restart
+type OISnapshot struct {
+	SessionDate string            `json:"session_date"` // "YYYY-MM-DD" in IST
+	OI          map[uint32]uint32 `json:"oi"`           // token -> OI
+}
+
+// SaveOISnapshot writes the current OI of all options to path, stamped with the
+// session date of now
+func (s *Scanner) SaveOISnapshot(path string, now time.Time) error {
+	snapshot := OISnapshot{
+		SessionDate: now.In(ist).Format("2006-01-02"),
+		OI:          make(map[uint32]uint32),
+	}
+	for _, chain := range s.allChains() {
+		for _, strikeData := range chain.Snapshot().Strikes {
+			for _, od := range []*OptionData{strikeData.Call, strikeData.Put} {
+				if od != nil && od.OI > 0 {
+					snapshot.OI[od.InstrumentToken] = od.OI
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode OI snapshot: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated snapshot
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write OI snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadOISnapshot sets the previous close OI of all options from a snapshot taken
+// in an earlier session than now. It returns the number of options updated.
+func (s *Scanner) LoadOISnapshot(path string, now time.Time) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read OI snapshot: %w", err)
+	}
+
+	var snapshot OISnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("failed to parse OI snapshot: %w", err)
+	}
+
+	// A snapshot of today's session is not a previous close
+	if snapshot.SessionDate >= now.In(ist).Format("2006-01-02") {
+		return 0, fmt.Errorf("OI snapshot is from the current session (%s)", snapshot.SessionDate)
+	}
+
+	loaded := 0
+	for _, chain := range s.allChains() {
+		chain.Update(func() {
+			for _, strikeData := range chain.Strikes {
+				for _, od := range []*OptionData{strikeData.Call, strikeData.Put} {
+					if od == nil {
+						continue
+					}
+					if oi, ok := snapshot.OI[od.InstrumentToken]; ok {
+						od.PrevCloseOI = oi
+						loaded++
+					}
+				}
+			}
+		})
+	}
+	return loaded, nil
+}
+
+// allChains returns every chain. They are locked on their own, after the
+// scanner's lock is released, as ticks updating a chain take the scanner's.
+func (s *Scanner) allChains() []*OptionChain {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chains []*OptionChain
+	for _, expiries := range s.chains {
+		for _, chain := range expiries {
+			chains = append(chains, chain)
+		}
+	}
+	return chains
+}
@@ -0,0 +1,111 @@
+package options
+
+// GreekExposure holds quantity-weighted Greeks of one or more positions
+type GreekExposure struct {
+	Delta float64 `json:"delta"`
+	Gamma float64 `json:"gamma"`
+	Theta float64 `json:"theta"`
+	Vega  float64 `json:"vega"`
+	Rho   float64 `json:"rho"`
+	Vanna float64 `json:"vanna"`
+	Volga float64 `json:"volga"`
+	Charm float64 `json:"charm"`
+	Speed float64 `json:"speed"`
+	Color float64 `json:"color"`
+}
+
+// PositionGreeks holds the Greek exposure of a single position
+type PositionGreeks struct {
+	InstrumentToken uint32        `json:"instrument_token"`
+	Tradingsymbol   string        `json:"tradingsymbol"`
+	Underlying      string        `json:"underlying"`
+	Quantity        int           `json:"quantity"`
+	Model           PricingModel  `json:"model,omitempty"`
+	Priced          bool          `json:"priced"` // False when the instrument is unknown to the scanner
+	Greeks          GreekExposure `json:"greeks"`
+}
+
+// PortfolioGreeks holds per-position, per-underlying and total Greek exposure
+type PortfolioGreeks struct {
+	Positions   []PositionGreeks         `json:"positions"`
+	Underlyings map[string]GreekExposure `json:"underlyings"`
+	Total       GreekExposure            `json:"total"`
+}
+
+// add accumulates another exposure
+func (g *GreekExposure) add(other GreekExposure) {
+	g.Delta += other.Delta
+	g.Gamma += other.Gamma
+	g.Theta += other.Theta
+	g.Vega += other.Vega
+	g.Rho += other.Rho
+	g.Vanna += other.Vanna
+	g.Volga += other.Volga
+	g.Charm += other.Charm
+	g.Speed += other.Speed
+	g.Color += other.Color
+}
+
+// optionExposure scales an option's Greeks by a signed quantity
+func optionExposure(od *OptionData, quantity float64) GreekExposure {
+	return GreekExposure{
+		Delta: od.Delta * quantity,
+		Gamma: od.Gamma * quantity,
+		Theta: od.Theta * quantity,
+		Vega:  od.Vega * quantity,
+		Rho:   od.Rho * quantity,
+		Vanna: od.Vanna * quantity,
+		Volga: od.Volga * quantity,
+		Charm: od.Charm * quantity,
+		Speed: od.Speed * quantity,
+		Color: od.Color * quantity,
+	}
+}
+
+// AggregateGreeks computes the Greek exposure of a set of positions using the
+// live option data in the chains. Futures and equities contribute delta only.
+func (s *Scanner) AggregateGreeks(positions []Position) PortfolioGreeks {
+	result := PortfolioGreeks{
+		Positions:   make([]PositionGreeks, 0, len(positions)),
+		Underlyings: make(map[string]GreekExposure),
+	}
+
+	for _, pos := range positions {
+		pg := PositionGreeks{
+			InstrumentToken: pos.InstrumentToken,
+			Tradingsymbol:   pos.Tradingsymbol,
+			Quantity:        pos.Quantity,
+		}
+
+		inst, ok := s.GetInstrument(pos.InstrumentToken)
+		if ok {
+			pg.Underlying = inst.Name
+			switch inst.InstrumentType {
+			case Call, Put:
+				if od, ok := s.GetOptionData(pos.InstrumentToken); ok {
+					pg.Model = od.Model
+					pg.Greeks = optionExposure(od, float64(pos.Quantity))
+					pg.Priced = true
+				}
+			case "FUT":
+				pg.Greeks.Delta = float64(pos.Quantity)
+				pg.Priced = true
+			case "EQ":
+				pg.Underlying = inst.Tradingsymbol
+				pg.Greeks.Delta = float64(pos.Quantity)
+				pg.Priced = true
+			}
+		}
+
+		if pg.Priced {
+			exposure := result.Underlyings[pg.Underlying]
+			exposure.add(pg.Greeks)
+			result.Underlyings[pg.Underlying] = exposure
+			result.Total.add(pg.Greeks)
+		}
+
+		result.Positions = append(result.Positions, pg)
+	}
+
+	return result
+}
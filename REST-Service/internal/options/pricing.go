@@ -0,0 +1,113 @@
+package options
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PricingModel selects the formula used to price options and derive Greeks
+type PricingModel string
+
+const (
+	// ModelBSM is Black-Scholes-Merton on the spot price with a continuous dividend yield
+	ModelBSM PricingModel = "bsm"
+	// ModelBlack76 is Black-76 on the price of the same-expiry future
+	ModelBlack76 PricingModel = "black76"
+	// ModelBlack76Synthetic is Black-76 on a forward implied from put-call parity on the chain
+	ModelBlack76Synthetic PricingModel = "black76_synthetic"
+
+	// DefaultRiskFreeRate is used when no rate is configured (6%)
+	DefaultRiskFreeRate = 0.06
+
+	// impliedForwardStrikes is the number of strikes closest to the forward
+	// that are averaged when deriving a synthetic forward from the chain
+	impliedForwardStrikes = 3
+)
+
+// PricingParams holds the model and rates used to price options of an underlying
+type PricingParams struct {
+	Model         PricingModel
+	RiskFreeRate  float64 // Annual, continuously compounded
+	DividendYield float64 // Annual, continuously compounded (BSM only)
+}
+
+// ParsePricingModel validates a model name from configuration
+func ParsePricingModel(name string) (PricingModel, error) {
+	switch PricingModel(name) {
+	case "":
+		return ModelBSM, nil
+	case ModelBSM, ModelBlack76, ModelBlack76Synthetic:
+		return PricingModel(name), nil
+	default:
+		return "", fmt.Errorf("unknown pricing model %q (must be %s, %s or %s)", name, ModelBSM, ModelBlack76, ModelBlack76Synthetic)
+	}
+}
+
+// UsesForward reports whether the model prices off a forward rather than the spot
+func (m PricingModel) UsesForward() bool {
+	return m == ModelBlack76 || m == ModelBlack76Synthetic
+}
+
+// MidPrice returns the mid of the best bid and ask, falling back to the last price
+// when the quote is one-sided
+func (od *OptionData) MidPrice() float64 {
+	if od.BidPrice > 0 && od.AskPrice > 0 {
+		return (od.BidPrice + od.AskPrice) / 2.0
+	}
+	return od.LastPrice
+}
+
+// ImpliedForward derives the forward price of a chain from put-call parity.
+// For every strike with both a call and a put, F = K + (C - P) * e^(rT).
+// The strikes where C - P is smallest (closest to the forward) are averaged.
+func ImpliedForward(chain *OptionChain, riskFreeRate, timeToExpiry float64) (float64, bool) {
+	if chain == nil || timeToExpiry <= 0 {
+		return 0, false
+	}
+
+	type candidate struct {
+		forward float64
+		diff    float64
+	}
+
+	growth := math.Exp(riskFreeRate * timeToExpiry)
+	candidates := make([]candidate, 0, len(chain.Strikes))
+
+	for strike, strikeData := range chain.Strikes {
+		if strikeData.Call == nil || strikeData.Put == nil {
+			continue
+		}
+
+		callPrice := strikeData.Call.MidPrice()
+		putPrice := strikeData.Put.MidPrice()
+		if callPrice <= 0 || putPrice <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			forward: strike + (callPrice-putPrice)*growth,
+			diff:    math.Abs(callPrice - putPrice),
+		})
+	}
+
+	if len(candidates) == 0 {
+		return 0, false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].diff < candidates[j].diff
+	})
+
+	n := impliedForwardStrikes
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+
+	sum := 0.0
+	for _, c := range candidates[:n] {
+		sum += c.forward
+	}
+
+	return sum / float64(n), true
+}
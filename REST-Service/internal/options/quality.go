@@ -0,0 +1,101 @@
+package options
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// QuoteQuality is a set of flags describing how trustworthy an option's quote and IV are
+type QuoteQuality uint16
+
+const (
+	// QualityStale marks a quote, or its bid and ask, not updated within QualityParams.StaleAfter
+	QualityStale QuoteQuality = 1 << iota
+	// QualityWideSpread marks a bid/ask spread wider than QualityParams.MaxSpreadPct of mid
+	QualityWideSpread
+	// QualityBelowIntrinsic marks a price below the discounted intrinsic value
+	QualityBelowIntrinsic
+	// QualityOneSided marks a quote missing either the bid or the ask
+	QualityOneSided
+	// QualityNoIV marks an option whose mid price has no implied volatility solution
+	QualityNoIV
+)
+
+var qualityNames = []struct {
+	flag QuoteQuality
+	name string
+}{
+	{QualityStale, "stale"},
+	{QualityWideSpread, "wide_spread"},
+	{QualityBelowIntrinsic, "below_intrinsic"},
+	{QualityOneSided, "one_sided"},
+	{QualityNoIV, "no_iv"},
+}
+
+// QualityParams holds the thresholds used to flag quotes
+type QualityParams struct {
+	StaleAfter   time.Duration // Quote age after which it is flagged stale
+	MaxSpreadPct float64       // Spread as a fraction of mid above which it is flagged wide
+}
+
+// DefaultQualityParams returns the thresholds used when none are configured
+func DefaultQualityParams() QualityParams {
+	return QualityParams{
+		StaleAfter:   30 * time.Second,
+		MaxSpreadPct: 0.10,
+	}
+}
+
+// Has reports whether all of the given flags are set
+func (q QuoteQuality) Has(flag QuoteQuality) bool {
+	return q&flag == flag
+}
+
+// Flags returns the names of the set flags
+func (q QuoteQuality) Flags() []string {
+	flags := make([]string, 0, len(qualityNames))
+	for _, qn := range qualityNames {
+		if q.Has(qn.flag) {
+			flags = append(flags, qn.name)
+		}
+	}
+	return flags
+}
+
+// String returns the set flags separated by "|", or "ok" when none are set
+func (q QuoteQuality) String() string {
+	if q == 0 {
+		return "ok"
+	}
+	return strings.Join(q.Flags(), "|")
+}
+
+// MarshalJSON encodes the flags as a list of names
+func (q QuoteQuality) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.Flags())
+}
+
+// assessQuote flags stale, one-sided and wide quotes
+func assessQuote(od *OptionData, params QualityParams, now time.Time) QuoteQuality {
+	var q QuoteQuality
+
+	if params.StaleAfter > 0 && now.Sub(od.LastUpdated) > params.StaleAfter {
+		q |= QualityStale
+	}
+	if params.StaleAfter > 0 && (od.BidPrice > 0 || od.AskPrice > 0) && now.Sub(od.DepthUpdated) > params.StaleAfter {
+		q |= QualityStale // Trading but the bid and ask are from an old full tick
+	}
+
+	if od.BidPrice <= 0 || od.AskPrice <= 0 {
+		q |= QualityOneSided
+		return q
+	}
+
+	mid := (od.BidPrice + od.AskPrice) / 2.0
+	if params.MaxSpreadPct > 0 && (od.AskPrice-od.BidPrice)/mid > params.MaxSpreadPct {
+		q |= QualityWideSpread
+	}
+
+	return q
+}
@@ -0,0 +1,156 @@
+package options
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+)
+
+// ChainRef identifies an option chain
+type ChainRef struct {
+	Underlying string    `json:"underlying"`
+	Expiry     time.Time `json:"expiry"`
+}
+
+// RefreshResult describes what changed when a new instrument master was applied
+type RefreshResult struct {
+	Version         string     `json:"version,omitempty"` // Saved version of the applied master
+	Instruments     int        `json:"instruments"`
+	Options         int        `json:"options"`
+	Migrated        int        `json:"migrated"` // Options and futures that kept their live state
+	Added           []uint32   `json:"added"`
+	Removed         []uint32   `json:"removed"` // Expired or delisted tokens
+	AddedExpiries   []ChainRef `json:"added_expiries"`
+	RemovedExpiries []ChainRef `json:"removed_expiries"`
+}
+
+// RefreshInstruments fetches the instrument master and applies it, dropping
+// the contracts that have expired by now
+func (s *Scanner) RefreshInstruments(now time.Time) (RefreshResult, error) {
+	allInstruments, err := s.fetchInstruments()
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	result := s.ApplyInstruments(allInstruments, now)
+	result.Version = s.saveMaster(now)
+	return result, nil
+}
+
+// ApplyInstruments replaces the instruments, option chains and futures with
+// those of an instrument master. The new chains are built before the swap, so
+// readers see either the old or the new set. Options and futures in both keep
+// their OptionData and FutureData, with the live quotes, IV and OI state, and
+// chains keep their underlying price. Derivatives that have expired by now are
+// left out; a zero now keeps every contract.
+func (s *Scanner) ApplyInstruments(allInstruments []kiteconnect.Instrument, now time.Time) RefreshResult {
+	s.mu.RLock()
+	cal := s.calendar
+	s.mu.RUnlock()
+
+	expired := func(expiry time.Time) bool {
+		return !now.IsZero() && !now.Before(cal.ExpiryTime(expiry))
+	}
+	set := buildInstruments(allInstruments, expired)
+	index := newSearchIndex(set.instruments)
+
+	s.mu.Lock()
+	result := s.migrate(set)
+	s.allInstruments = set.all
+	s.instruments = set.instruments
+	s.chains = set.chains
+	s.futures = set.futures
+	s.search = index
+	s.version = "" // Until the set is saved
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	result.Instruments = len(set.instruments)
+	result.Options = set.options
+	log.Printf("Found %d option instruments", set.options)
+	log.Printf("Built option chains for %d underlyings", len(set.chains))
+	return result
+}
+
+// migrate moves the live state of the current chains and futures into set and
+// diffs the two. The caller must hold the lock.
+func (s *Scanner) migrate(set *instrumentSet) RefreshResult {
+	var result RefreshResult
+
+	for token := range set.instruments {
+		if _, ok := s.instruments[token]; !ok {
+			result.Added = append(result.Added, token)
+		}
+	}
+	for token := range s.instruments {
+		if _, ok := set.instruments[token]; !ok {
+			result.Removed = append(result.Removed, token)
+		}
+	}
+	sortTokens(result.Added)
+	sortTokens(result.Removed)
+
+	for underlying, expiries := range set.chains {
+		for expiry, chain := range expiries {
+			old := s.chains[underlying][expiry]
+			if old == nil {
+				result.AddedExpiries = append(result.AddedExpiries, ChainRef{Underlying: underlying, Expiry: expiry})
+				continue
+			}
+			chain.UnderlyingToken = old.UnderlyingToken
+			chain.UnderlyingPrice = old.UnderlyingPrice
+			chain.Forward = old.Forward
+			chain.LastUpdated = old.LastUpdated
+
+			for strike, sd := range chain.Strikes {
+				oldSD := old.Strikes[strike]
+				if oldSD == nil {
+					continue
+				}
+				if sd.Call != nil && oldSD.Call != nil && oldSD.Call.InstrumentToken == sd.Call.InstrumentToken {
+					sd.Call = oldSD.Call
+					result.Migrated++
+				}
+				if sd.Put != nil && oldSD.Put != nil && oldSD.Put.InstrumentToken == sd.Put.InstrumentToken {
+					sd.Put = oldSD.Put
+					result.Migrated++
+				}
+				sd.LastUpdated = oldSD.LastUpdated
+			}
+		}
+	}
+	for underlying, expiries := range s.chains {
+		for expiry := range expiries {
+			if set.chains[underlying][expiry] == nil {
+				result.RemovedExpiries = append(result.RemovedExpiries, ChainRef{Underlying: underlying, Expiry: expiry})
+			}
+		}
+	}
+	sortChainRefs(result.AddedExpiries)
+	sortChainRefs(result.RemovedExpiries)
+
+	for underlying, expiries := range set.futures {
+		for expiry, future := range expiries {
+			if old := s.futures[underlying][expiry]; old != nil && old.InstrumentToken == future.InstrumentToken {
+				set.futures[underlying][expiry] = old
+				result.Migrated++
+			}
+		}
+	}
+
+	return result
+}
+
+func sortTokens(tokens []uint32) {
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+}
+
+func sortChainRefs(refs []ChainRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Underlying != refs[j].Underlying {
+			return refs[i].Underlying < refs[j].Underlying
+		}
+		return refs[i].Expiry.Before(refs[j].Expiry)
+	})
+}
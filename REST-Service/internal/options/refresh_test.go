@@ -0,0 +1,66 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyInstrumentsRollsOver(t *testing.T) {
+	now := time.Date(2026, 10, 20, 8, 45, 0, 0, ist) // Tuesday before the open
+	expired := models.Time{Time: time.Date(2026, 10, 13, 0, 0, 0, 0, ist)}
+	current := models.Time{Time: time.Date(2026, 10, 20, 0, 0, 0, 0, ist)}
+	next := models.Time{Time: time.Date(2026, 10, 27, 0, 0, 0, 0, ist)}
+
+	s := NewScanner(nil)
+	s.LoadInstruments([]kiteconnect.Instrument{
+		{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: expired},
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: current},
+		{InstrumentToken: 10, Name: "NIFTY", InstrumentType: "FUT", Expiry: current},
+	})
+
+	od, ok := s.GetOptionData(2)
+	require.True(t, ok)
+	od.LastPrice, od.IV, od.OpenOI = 120, 0.14, 5000
+	require.True(t, s.UpdateFuture(models.Tick{InstrumentToken: 10, LastPrice: 25100}))
+	s.chains["NIFTY"][normalize(current.Time)].UnderlyingPrice = 25050
+
+	// A stale master still lists the expired contract; the new weekly appears
+	result := s.ApplyInstruments([]kiteconnect.Instrument{
+		{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: expired},
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: current},
+		{InstrumentToken: 3, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: next},
+		{InstrumentToken: 10, Name: "NIFTY", InstrumentType: "FUT", Expiry: current},
+	}, now)
+
+	require.Equal(t, []uint32{3}, result.Added)
+	require.Equal(t, []uint32{1}, result.Removed)
+	require.Equal(t, 2, result.Migrated)
+	require.Equal(t, []ChainRef{{Underlying: "NIFTY", Expiry: normalize(next.Time)}}, result.AddedExpiries)
+	require.Equal(t, []ChainRef{{Underlying: "NIFTY", Expiry: normalize(expired.Time)}}, result.RemovedExpiries)
+
+	_, ok = s.GetInstrument(1)
+	require.False(t, ok)
+	migrated, ok := s.GetOptionData(2)
+	require.True(t, ok)
+	require.Same(t, od, migrated) // Ticks already holding it keep updating the chain
+	require.Equal(t, uint32(5000), migrated.OpenOI)
+	migratedFuture, ok := s.GetFutureData(10)
+	require.True(t, ok)
+	require.Equal(t, 25100.0, migratedFuture.LastPrice)
+	require.Equal(t, 25050.0, s.chains["NIFTY"][normalize(current.Time)].UnderlyingPrice)
+
+	// The expiring weekly is kept until its cutoff
+	master := []kiteconnect.Instrument{
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: current},
+		{InstrumentToken: 3, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: next},
+	}
+	result = s.ApplyInstruments(master, time.Date(2026, 10, 20, 15, 29, 0, 0, ist))
+	require.Equal(t, []uint32{10}, result.Removed)
+	result = s.ApplyInstruments(master, time.Date(2026, 10, 20, 15, 30, 0, 0, ist))
+	require.Equal(t, []uint32{2}, result.Removed)
+}
@@ -0,0 +1,140 @@
+package options
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SpotRef identifies the index or equity an underlying's derivatives settle on
+type SpotRef struct {
+	Exchange      string `json:"exchange"`      // "NSE" or "BSE"
+	Tradingsymbol string `json:"tradingsymbol"` // e.g. "NIFTY 50", "RELIANCE"
+}
+
+// IndexSpots maps the Name of index derivatives to their spot index. Index
+// tradingsymbols don't match the derivative names, so they must be listed here;
+// equities resolve by name on NSE.
+var IndexSpots = map[string]SpotRef{
+	"NIFTY":      {Exchange: "NSE", Tradingsymbol: "NIFTY 50"},
+	"BANKNIFTY":  {Exchange: "NSE", Tradingsymbol: "NIFTY BANK"},
+	"FINNIFTY":   {Exchange: "NSE", Tradingsymbol: "NIFTY FIN SERVICE"},
+	"MIDCPNIFTY": {Exchange: "NSE", Tradingsymbol: "NIFTY MID SELECT"},
+	"NIFTYNXT50": {Exchange: "NSE", Tradingsymbol: "NIFTY NEXT 50"},
+	"SENSEX":     {Exchange: "BSE", Tradingsymbol: "SENSEX"},
+	"BANKEX":     {Exchange: "BSE", Tradingsymbol: "BANKEX"},
+}
+
+// UnderlyingResolution is the spot and near future an underlying resolved to
+type UnderlyingResolution struct {
+	Underlying  string  `json:"underlying"`
+	Spot        SpotRef `json:"spot"`
+	SpotToken   uint32  `json:"spot_token"`   // 0 when unresolved
+	FutureToken uint32  `json:"future_token"` // Nearest unexpired future, 0 if none
+	Resolved    bool    `json:"resolved"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// ResolveUnderlyings resolves the spot token of every underlying with option
+// chains or futures, using overrides first, then IndexSpots, then the NSE
+// equity of the same name. Every chain gets its spot token; chains of
+// unresolved underlyings are left without one so no Greeks are computed from
+// a wrong price. The returned resolutions are sorted by underlying.
+func (s *Scanner) ResolveUnderlyings(overrides map[string]SpotRef) []UnderlyingResolution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Index the spot candidates by exchange and tradingsymbol
+	spots := make(map[SpotRef]uint32)
+	for _, inst := range s.allInstruments {
+		if inst.InstrumentType != "EQ" || (inst.Segment != "INDICES" && inst.Segment != inst.Exchange) {
+			continue
+		}
+		spots[SpotRef{Exchange: inst.Exchange, Tradingsymbol: inst.Tradingsymbol}] = uint32(inst.InstrumentToken)
+	}
+
+	names := make(map[string]bool)
+	for name := range s.chains {
+		names[name] = true
+	}
+	for name := range s.futures {
+		names[name] = true
+	}
+
+	now := time.Now()
+	s.spotTokens = make(map[string]uint32, len(names))
+	resolutions := make([]UnderlyingResolution, 0, len(names))
+	for name := range names {
+		ref, ok := overrides[name]
+		if !ok {
+			ref, ok = IndexSpots[name]
+		}
+		if !ok {
+			ref = SpotRef{Exchange: "NSE", Tradingsymbol: name}
+		}
+
+		res := UnderlyingResolution{Underlying: name, Spot: ref}
+		if token, ok := spots[ref]; ok {
+			res.SpotToken = token
+			res.Resolved = true
+			s.spotTokens[name] = token
+		} else {
+			res.Error = fmt.Sprintf("no %s instrument %q", ref.Exchange, ref.Tradingsymbol)
+		}
+		res.FutureToken = s.nearFutureToken(name, now)
+
+		for _, chain := range s.chains[name] {
+			chain.UnderlyingToken = res.SpotToken
+		}
+
+		resolutions = append(resolutions, res)
+	}
+
+	sort.Slice(resolutions, func(i, j int) bool {
+		return resolutions[i].Underlying < resolutions[j].Underlying
+	})
+
+	s.resolutions = resolutions
+	return resolutions
+}
+
+// GetResolutions returns the result of the last ResolveUnderlyings
+func (s *Scanner) GetResolutions() []UnderlyingResolution {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resolutions
+}
+
+// SetSpotToken sets the token of the index or equity an underlying's derivatives settle on
+func (s *Scanner) SetSpotToken(underlying string, token uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spotTokens[underlying] = token
+}
+
+// GetSpotToken returns the spot token of an underlying
+func (s *Scanner) GetSpotToken(underlying string) (uint32, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.spotTokens[underlying]
+	return token, ok
+}
+
+// nearFutureToken returns the nearest unexpired future of an underlying. The
+// caller must hold the lock.
+func (s *Scanner) nearFutureToken(underlying string, now time.Time) uint32 {
+	var near *FutureData
+	for _, future := range s.futures[underlying] {
+		if s.calendar.DaysToExpiry(now, future.Expiry) < 0 {
+			continue
+		}
+		if near == nil || future.Expiry.Before(near.Expiry) {
+			near = future
+		}
+	}
+
+	if near == nil {
+		return 0
+	}
+	return near.InstrumentToken
+}
@@ -0,0 +1,50 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveUnderlyings(t *testing.T) {
+	s := NewScanner(nil)
+	s.allInstruments = []kiteconnect.Instrument{
+		{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Exchange: "NSE", Segment: "INDICES", InstrumentType: "EQ"},
+		{InstrumentToken: 260105, Tradingsymbol: "NIFTY BANK", Exchange: "NSE", Segment: "INDICES", InstrumentType: "EQ"},
+		{InstrumentToken: 5633, Tradingsymbol: "ACC", Exchange: "NSE", Segment: "NSE", InstrumentType: "EQ"},
+		{InstrumentToken: 1111, Tradingsymbol: "ACC", Exchange: "NFO", Segment: "NFO-FUT", InstrumentType: "FUT"},
+	}
+
+	expiry := normalize(time.Now().AddDate(0, 0, 10))
+	for _, name := range []string{"NIFTY", "BANKNIFTY", "ACC", "FINNIFTY"} {
+		s.chains[name] = map[time.Time]*OptionChain{expiry: {Underlying: name, Expiry: expiry}}
+	}
+	s.futures["ACC"] = map[time.Time]*FutureData{expiry: {InstrumentToken: 1111, Expiry: expiry}}
+
+	byName := make(map[string]UnderlyingResolution)
+	for _, res := range s.ResolveUnderlyings(nil) {
+		byName[res.Underlying] = res
+	}
+
+	require.Equal(t, uint32(256265), byName["NIFTY"].SpotToken)
+	require.Equal(t, uint32(260105), s.chains["BANKNIFTY"][expiry].UnderlyingToken)
+	require.Equal(t, uint32(5633), byName["ACC"].SpotToken)
+	require.Equal(t, uint32(1111), byName["ACC"].FutureToken)
+
+	// An index missing from the instrument master is reported, not guessed
+	require.False(t, byName["FINNIFTY"].Resolved)
+	require.NotEmpty(t, byName["FINNIFTY"].Error)
+	require.Zero(t, s.chains["FINNIFTY"][expiry].UnderlyingToken)
+
+	// Overrides take precedence over the built-in table
+	s.allInstruments = append(s.allInstruments, kiteconnect.Instrument{
+		InstrumentToken: 257801, Tradingsymbol: "NIFTY FIN SERVICE ALT", Exchange: "NSE", Segment: "INDICES", InstrumentType: "EQ",
+	})
+	s.ResolveUnderlyings(map[string]SpotRef{"FINNIFTY": {Exchange: "NSE", Tradingsymbol: "NIFTY FIN SERVICE ALT"}})
+	token, ok := s.GetSpotToken("FINNIFTY")
+	require.True(t, ok)
+	require.Equal(t, uint32(257801), token)
+}
@@ -10,6 +10,9 @@ import (
 	"sync"
 	"time"
 
+	"rest-service/internal/calendar"
+	"rest-service/internal/instruments"
+
 	kiteconnect "gokiteconnect-master"
 
 	"github.com/gocarina/gocsv"
@@ -21,7 +24,17 @@ type Scanner struct {
 	instruments    map[uint32]*OptionInstrument          // token -> instrument
 	chains         map[string]map[time.Time]*OptionChain // underlying -> expiry -> chain
 	allInstruments []kiteconnect.Instrument              // Cache of all instruments for underlying lookup
+	futures        map[string]map[time.Time]*FutureData  // underlying -> expiry -> future
+	spotTokens     map[string]uint32                     // underlying -> spot (index/equity) token
+	resolutions    []UnderlyingResolution                // Result of the last ResolveUnderlyings
+	calendar       *calendar.Calendar                    // Trading calendar for days to expiry
+	master         *instruments.Store                    // Saved versions of the instrument master, if set
+	version        string                                // Version of the master the instruments were loaded from
+	search         *SearchIndex                          // Index over the instruments for search
+	loadedAt       time.Time                             // When the instruments were last applied
+	curves         map[string]cachedCurve                // underlying -> futures curve points for InterpolatedForward
 	mu             sync.RWMutex
+	futuresMu      sync.RWMutex // Held by UpdateFuture, and read-held while futures are copied
 }
 
 // NewScanner creates a new option scanner
@@ -30,9 +43,20 @@ func NewScanner(kiteClient *kiteconnect.Client) *Scanner {
 		kiteClient:  kiteClient,
 		instruments: make(map[uint32]*OptionInstrument),
 		chains:      make(map[string]map[time.Time]*OptionChain),
+		futures:     make(map[string]map[time.Time]*FutureData),
+		spotTokens:  make(map[string]uint32),
+		calendar:    calendar.Default(),
+		curves:      make(map[string]cachedCurve),
 	}
 }
 
+// SetCalendar sets the trading calendar used to compute days to expiry
+func (s *Scanner) SetCalendar(cal *calendar.Calendar) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calendar = cal
+}
+
 var ist, _ = time.LoadLocation("Asia/Kolkata")
 
 func normalize(t time.Time) time.Time {
@@ -90,74 +114,133 @@ func (s *Scanner) loadInstrumentsFromCSV() (kiteconnect.Instruments, error) {
 	return instruments, nil
 }
 
-// ScanInstruments fetches all instruments and filters for options
+// ScanInstruments fetches all instruments and filters for options. Contracts
+// that have already expired, as in a stale CSV, are left out.
 func (s *Scanner) ScanInstruments() error {
 	log.Println("Scanning for option instruments...")
+	_, err := s.RefreshInstruments(time.Now())
+	return err
+}
 
-	// Fetch all instruments from Kite Connect
+// fetchInstruments fetches the instrument master from Kite Connect, falling
+// back to the last good saved version and then to the CSV file
+func (s *Scanner) fetchInstruments() ([]kiteconnect.Instrument, error) {
 	allInstruments, err := s.kiteClient.GetInstruments()
 	if err != nil {
 		log.Printf("Failed to fetch instruments from Kite Connect: %v", err)
+
+		s.mu.RLock()
+		master := s.master
+		s.mu.RUnlock()
+		if master != nil {
+			v, saved, err := master.Latest()
+			if err == nil {
+				log.Printf("Loaded %d instruments from saved version %s", v.Count, v.Version)
+				return saved, nil
+			}
+			log.Printf("No saved instrument master: %v", err)
+		}
+
 		log.Println("Falling back to CSV file...")
 
 		// Fallback to CSV file
 		allInstruments, err = s.loadInstrumentsFromCSV()
 		if err != nil {
-			return fmt.Errorf("failed to fetch instruments from API and CSV fallback: %w", err)
+			return nil, fmt.Errorf("failed to fetch instruments from API and CSV fallback: %w", err)
 		}
 	}
+	return allInstruments, nil
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// LoadInstruments rebuilds the instruments, option chains and futures from an
+// instrument master, keeping every contract in it
+func (s *Scanner) LoadInstruments(allInstruments []kiteconnect.Instrument) {
+	s.ApplyInstruments(allInstruments, time.Time{})
+}
+
+// NewOptionInstrument converts an instrument master row
+func NewOptionInstrument(inst kiteconnect.Instrument) *OptionInstrument {
+	return &OptionInstrument{
+		InstrumentToken: uint32(inst.InstrumentToken),
+		ExchangeToken:   uint32(inst.ExchangeToken),
+		Tradingsymbol:   inst.Tradingsymbol,
+		Name:            inst.Name,
+		Exchange:        inst.Exchange,
+		Segment:         inst.Segment,
+		InstrumentType:  OptionType(inst.InstrumentType),
+		StrikePrice:     inst.StrikePrice,
+		Expiry:          normalize(inst.Expiry.Time), //2026-02-24 00:00:00 +0530 IST
+		TickSize:        inst.TickSize,
+		LotSize:         int(inst.LotSize),
+		LastPrice:       inst.LastPrice,
+	}
+}
 
-	// Clear existing data
-	s.instruments = make(map[uint32]*OptionInstrument)
-	s.chains = make(map[string]map[time.Time]*OptionChain)
-	s.allInstruments = allInstruments // Cache all instruments
+// instrumentSet is the instruments, option chains and futures built from one
+// instrument master
+type instrumentSet struct {
+	all         []kiteconnect.Instrument
+	instruments map[uint32]*OptionInstrument
+	chains      map[string]map[time.Time]*OptionChain
+	futures     map[string]map[time.Time]*FutureData
+	options     int
+}
 
-	optionCount := 0
-	if len(allInstruments) > 0 {
-		log.Println(allInstruments[0])
+// buildInstruments builds the option chains and futures of an instrument
+// master. Derivatives for which expired reports true are left out.
+func buildInstruments(allInstruments []kiteconnect.Instrument, expired func(expiry time.Time) bool) *instrumentSet {
+	set := &instrumentSet{
+		all:         make([]kiteconnect.Instrument, 0, len(allInstruments)),
+		instruments: make(map[uint32]*OptionInstrument),
+		chains:      make(map[string]map[time.Time]*OptionChain),
+		futures:     make(map[string]map[time.Time]*FutureData),
 	}
+
 	// Filter for options (CE or PE)
 	for _, inst := range allInstruments {
-		optInst := &OptionInstrument{
-			InstrumentToken: uint32(inst.InstrumentToken),
-			ExchangeToken:   uint32(inst.ExchangeToken),
-			Tradingsymbol:   inst.Tradingsymbol,
-			Name:            inst.Name,
-			Exchange:        inst.Exchange,
-			Segment:         inst.Segment,
-			InstrumentType:  OptionType(inst.InstrumentType),
-			StrikePrice:     inst.StrikePrice,
-			Expiry:          normalize(inst.Expiry.Time), //2026-02-24 00:00:00 +0530 IST
-			TickSize:        inst.TickSize,
-			LotSize:         int(inst.LotSize),
-			LastPrice:       inst.LastPrice,
+		optInst := NewOptionInstrument(inst)
+
+		derivative := inst.InstrumentType == "FUT" || inst.InstrumentType == "CE" || inst.InstrumentType == "PE"
+		if derivative && expired(optInst.Expiry) {
+			continue
+		}
+
+		set.all = append(set.all, inst)
+		set.instruments[optInst.InstrumentToken] = optInst
+
+		// Index futures by underlying and expiry so options can be priced off them
+		if inst.InstrumentType == "FUT" && inst.Name != "" {
+			if set.futures[inst.Name] == nil {
+				set.futures[inst.Name] = make(map[time.Time]*FutureData)
+			}
+			set.futures[inst.Name][optInst.Expiry] = &FutureData{
+				InstrumentToken: optInst.InstrumentToken,
+				Tradingsymbol:   optInst.Tradingsymbol,
+				Underlying:      inst.Name,
+				Exchange:        optInst.Exchange,
+				Expiry:          optInst.Expiry,
+				LotSize:         optInst.LotSize,
+				LastPrice:       optInst.LastPrice,
+			}
 		}
 
-		s.instruments[optInst.InstrumentToken] = optInst
 		if inst.InstrumentType == "CE" || inst.InstrumentType == "PE" {
 
-			optionCount++
+			set.options++
 
 			// Build option chain structure
-			// underlying := s.extractUnderlying(inst.Tradingsymbol)
 			underlying := inst.Name
-			// if underlying == "NIFTY" {
-			// 	underlying = "NIFTY 50"
-			// }
 			if underlying == "" {
 				continue
 			}
 
-			if s.chains[underlying] == nil {
-				s.chains[underlying] = make(map[time.Time]*OptionChain)
+			if set.chains[underlying] == nil {
+				set.chains[underlying] = make(map[time.Time]*OptionChain)
 			}
 
-			expiry := normalize(inst.Expiry.Time)
-			if s.chains[underlying][expiry] == nil {
-				s.chains[underlying][expiry] = &OptionChain{
+			expiry := optInst.Expiry
+			if set.chains[underlying][expiry] == nil {
+				set.chains[underlying][expiry] = &OptionChain{
 					Underlying:  underlying,
 					Expiry:      expiry,
 					Strikes:     make(map[float64]*StrikeData),
@@ -165,10 +248,8 @@ func (s *Scanner) ScanInstruments() error {
 				}
 			}
 
-			chain := s.chains[underlying][expiry]
+			chain := set.chains[underlying][expiry]
 			if chain.Strikes[inst.StrikePrice] == nil {
-				chain.UnderlyingToken = uint32(inst.InstrumentToken)
-				chain.Underlying = underlying
 				chain.Strikes[inst.StrikePrice] = &StrikeData{
 					Strike:      inst.StrikePrice,
 					LastUpdated: time.Now(),
@@ -193,14 +274,7 @@ func (s *Scanner) ScanInstruments() error {
 		}
 	}
 
-	// var IST, _ = time.LoadLocation("Asia/Kolkata")
-	// fmt.Println(normalize(time.Date(2025, 12, 30, 0, 0, 0, 0, IST)))
-	// fmt.Println(s.chains["NIFTY"][normalize(time.Date(2025, 12, 30, 0, 0, 0, 0, IST))].Strikes[26000].Call.Tradingsymbol) // 2025-12-30 00:00:00 +0530 IST
-
-	log.Printf("Found %d option instruments", optionCount)
-	log.Printf("Built option chains for %d underlyings", len(s.chains))
-
-	return nil
+	return set
 }
 
 // extractUnderlying extracts underlying symbol from option trading symbol
@@ -276,6 +350,64 @@ func (s *Scanner) GetOptionChain(underlying string, expiry time.Time) (*OptionCh
 	return chain, ok
 }
 
+// GetOptionData returns the live option data for an option token
+func (s *Scanner) GetOptionData(token uint32) (*OptionData, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	inst, ok := s.instruments[token]
+	if !ok || (inst.InstrumentType != Call && inst.InstrumentType != Put) {
+		return nil, false
+	}
+
+	chain, ok := s.chains[inst.Name][inst.Expiry]
+	if !ok {
+		return nil, false
+	}
+
+	strikeData, ok := chain.Strikes[inst.StrikePrice]
+	if !ok {
+		return nil, false
+	}
+
+	if inst.InstrumentType == Call && strikeData.Call != nil {
+		return strikeData.Call, true
+	}
+	if inst.InstrumentType == Put && strikeData.Put != nil {
+		return strikeData.Put, true
+	}
+	return nil, false
+}
+
+// GetFutureToken returns the token of the future expiring with the given option expiry
+func (s *Scanner) GetFutureToken(underlying string, expiry time.Time) (uint32, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.futures[underlying] == nil {
+		return 0, false
+	}
+
+	future, ok := s.futures[underlying][normalize(expiry)]
+	if !ok {
+		return 0, false
+	}
+	return future.InstrumentToken, true
+}
+
+// GetFutureTokens returns the tokens of all futures of an underlying
+func (s *Scanner) GetFutureTokens(underlying string) []uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]uint32, 0, len(s.futures[underlying]))
+	for _, future := range s.futures[underlying] {
+		tokens = append(tokens, future.InstrumentToken)
+	}
+
+	return tokens
+}
+
 // GetUnderlyings returns list of all underlyings
 func (s *Scanner) GetUnderlyings() []string {
 	s.mu.RLock()
@@ -373,6 +505,7 @@ type FilterCriteria struct {
 	OptionType      *OptionType // nil = both CE and PE
 	MinDaysToExpiry int
 	MaxDaysToExpiry int
+	Window          *StrikeWindow // nil = no ATM-relative window
 }
 
 // FilterOptions returns tokens matching the filter criteria
@@ -383,6 +516,11 @@ func (s *Scanner) FilterOptions(criteria FilterCriteria) map[uint32]*OptionInstr
 	tokens := make(map[uint32]*OptionInstrument)
 	now := time.Now()
 
+	var window *windowFilter
+	if criteria.Window != nil {
+		window = s.newWindowFilter(criteria.Window, now)
+	}
+
 	fmt.Println(criteria)
 
 	for token, inst := range s.instruments {
@@ -415,8 +553,11 @@ func (s *Scanner) FilterOptions(criteria FilterCriteria) map[uint32]*OptionInstr
 			continue
 		}
 
-		// Filter by days to expiry
-		daysToExpiry := int(inst.Expiry.Sub(now).Hours() / 24)
+		// Filter by days to expiry, skipping contracts past their expiry cutoff
+		daysToExpiry := s.calendar.DaysToExpiry(now, inst.Expiry)
+		if daysToExpiry < 0 {
+			continue
+		}
 		if criteria.MinDaysToExpiry > 0 && daysToExpiry < criteria.MinDaysToExpiry {
 			continue
 		}
@@ -424,6 +565,11 @@ func (s *Scanner) FilterOptions(criteria FilterCriteria) map[uint32]*OptionInstr
 			continue
 		}
 
+		// Filter by the window around the underlying price
+		if window != nil && !window.keep(inst) {
+			continue
+		}
+
 		tokens[token] = inst
 	}
 
@@ -455,7 +601,6 @@ func (s *Scanner) GetAllInstruments() ([]kiteconnect.Instrument, error) {
 	return s.allInstruments, nil
 }
 
-
 func (s *Scanner) GetAllInstrumentsMap() (map[uint32]*OptionInstrument, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -0,0 +1,500 @@
+package options
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Search result limits
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// SearchQuery is an instrument search. Set filters must all match.
+type SearchQuery struct {
+	Terms    []string  `json:"terms,omitempty"`    // Words matched against names and trading symbols
+	Numbers  []float64 `json:"numbers,omitempty"`  // Each must be the strike, the token or a word of the symbol
+	Exchange string    `json:"exchange,omitempty"` // e.g. "NFO"
+	Segment  string    `json:"segment,omitempty"`  // e.g. "NFO-OPT"
+	Type     string    `json:"type,omitempty"`     // "CE", "PE", "FUT", "EQ"...
+	Strike   float64   `json:"strike,omitempty"`
+	Expiry   string    `json:"expiry,omitempty"` // "YYYY-MM-DD", or "YYYY-MM" for any expiry in the month
+	Offset   int       `json:"offset"`
+	Limit    int       `json:"limit"`
+}
+
+// SearchHit is an instrument matching a search
+type SearchHit struct {
+	InstrumentToken uint32  `json:"instrument_token"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	Name            string  `json:"name"`
+	Exchange        string  `json:"exchange"`
+	Segment         string  `json:"segment"`
+	InstrumentType  string  `json:"instrument_type"`
+	StrikePrice     float64 `json:"strike,omitempty"`
+	Expiry          string  `json:"expiry,omitempty"`
+	LotSize         int     `json:"lot_size"`
+	TickSize        float64 `json:"tick_size"`
+	Score           float64 `json:"score"`
+}
+
+// SearchResult is one page of search hits, best first
+type SearchResult struct {
+	Query   SearchQuery `json:"query"`
+	Total   int         `json:"total"`
+	Results []SearchHit `json:"results"`
+}
+
+var (
+	dayMonthPattern = regexp.MustCompile(`^(\d{1,2})([a-z]{3,9})(\d{2}|\d{4})?$`)
+	monthPattern    = regexp.MustCompile(`^([a-z]{3,9})(\d{2}|\d{4})?$`)
+	strikeType      = regexp.MustCompile(`^(\d+(?:\.\d+)?)(ce|pe)$`)
+)
+
+var typeWords = map[string]string{
+	"ce": "CE", "call": "CE", "calls": "CE",
+	"pe": "PE", "put": "PE", "puts": "PE",
+	"fut": "FUT", "futs": "FUT", "future": "FUT", "futures": "FUT",
+}
+
+// ParseSearch parses free text such as "nifty 26000 ce 30dec". Option types,
+// numbers ("26000", "26000ce") and expiries like "30dec", "30 dec 25", "dec"
+// or "2025-12-30" become filters, the remaining words are matched against
+// names and symbols. An expiry without a year is the next one on or after now.
+func ParseSearch(text string, now time.Time) SearchQuery {
+	var q SearchQuery
+	today := now.In(ist)
+	words := strings.Fields(strings.ToLower(text))
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		if t, ok := typeWords[word]; ok {
+			q.Type = t
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", word); err == nil {
+			q.Expiry = word
+			continue
+		}
+
+		// "30 dec" and "30 dec 25" are read as one date
+		if n, err := strconv.Atoi(word); err == nil && n >= 1 && n <= 31 && i+1 < len(words) {
+			if month, ok := parseMonth(words[i+1]); ok {
+				year := ""
+				if i+2 < len(words) && isYear(words[i+2]) {
+					year = words[i+2]
+				}
+				q.Expiry = expiryDate(today, n, month, year)
+				i++
+				if year != "" {
+					i++
+				}
+				continue
+			}
+		}
+
+		if m := dayMonthPattern.FindStringSubmatch(word); m != nil {
+			if month, ok := parseMonth(m[2]); ok {
+				day, _ := strconv.Atoi(m[1])
+				q.Expiry = expiryDate(today, day, month, m[3])
+				continue
+			}
+		}
+		if m := monthPattern.FindStringSubmatch(word); m != nil {
+			if month, ok := parseMonth(m[1]); ok {
+				year := fullYear(m[2])
+				if year == 0 {
+					year = today.Year()
+					if month < today.Month() {
+						year++
+					}
+				}
+				q.Expiry = time.Date(year, month, 1, 0, 0, 0, 0, ist).Format("2006-01")
+				continue
+			}
+		}
+
+		if m := strikeType.FindStringSubmatch(word); m != nil {
+			n, _ := strconv.ParseFloat(m[1], 64)
+			q.Numbers = append(q.Numbers, n)
+			q.Type = typeWords[m[2]]
+			continue
+		}
+		if n, err := strconv.ParseFloat(word, 64); err == nil && n > 0 {
+			q.Numbers = append(q.Numbers, n)
+			continue
+		}
+		q.Terms = append(q.Terms, word)
+	}
+	return q
+}
+
+func parseMonth(word string) (time.Month, bool) {
+	if len(word) < 3 {
+		return 0, false
+	}
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if word == name || word == name[:3] || (len(word) > 3 && strings.HasPrefix(name, word)) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+func isYear(word string) bool {
+	return fullYear(word) != 0
+}
+
+// fullYear reads a two or four digit year, 0 if word is not one
+func fullYear(word string) int {
+	if len(word) != 2 && len(word) != 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(word)
+	if err != nil {
+		return 0
+	}
+	if len(word) == 2 {
+		year += 2000
+	}
+	return year
+}
+
+// expiryDate returns a date as "YYYY-MM-DD", in the given year or else the
+// next one on or after today
+func expiryDate(today time.Time, day int, month time.Month, year string) string {
+	y := fullYear(year)
+	if y == 0 {
+		y = today.Year()
+		midnight := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, ist)
+		if time.Date(y, month, day, 0, 0, 0, 0, ist).Before(midnight) {
+			y++
+		}
+	}
+	return time.Date(y, month, day, 0, 0, 0, 0, ist).Format("2006-01-02")
+}
+
+// SearchIndex looks up instruments by name, symbol prefix and token. It is
+// built once per instrument master and never modified.
+type SearchIndex struct {
+	insts     []*OptionInstrument
+	roots     []string         // Sorted lowercase names, plus symbols of instruments that are not derivatives
+	byRoot    map[string][]int // root -> indexes into insts
+	symbols   []symbolEntry    // Sorted by lowercase symbol
+	liquidity map[string]int   // Derivatives listed per root, as a proxy for how liquid it is
+}
+
+type symbolEntry struct {
+	key string
+	idx int
+}
+
+// newSearchIndex indexes a set of instruments
+func newSearchIndex(instruments map[uint32]*OptionInstrument) *SearchIndex {
+	ix := &SearchIndex{
+		insts:     make([]*OptionInstrument, 0, len(instruments)),
+		byRoot:    make(map[string][]int),
+		symbols:   make([]symbolEntry, 0, len(instruments)),
+		liquidity: make(map[string]int),
+	}
+	for _, inst := range instruments {
+		ix.insts = append(ix.insts, inst)
+	}
+	sort.Slice(ix.insts, func(i, j int) bool { return ix.insts[i].InstrumentToken < ix.insts[j].InstrumentToken })
+
+	for i, inst := range ix.insts {
+		symbol := strings.ToLower(inst.Tradingsymbol)
+		ix.symbols = append(ix.symbols, symbolEntry{key: symbol, idx: i})
+
+		roots := []string{strings.ToLower(inst.Name)}
+		if isDerivative(inst) {
+			ix.liquidity[roots[0]]++
+		} else {
+			roots = append(roots, symbol)
+		}
+		for j, root := range roots {
+			if root == "" || (j > 0 && root == roots[0]) {
+				continue
+			}
+			ix.byRoot[root] = append(ix.byRoot[root], i)
+		}
+	}
+
+	for root := range ix.byRoot {
+		ix.roots = append(ix.roots, root)
+	}
+	sort.Strings(ix.roots)
+	sort.Slice(ix.symbols, func(i, j int) bool { return ix.symbols[i].key < ix.symbols[j].key })
+	return ix
+}
+
+func isDerivative(inst *OptionInstrument) bool {
+	return inst.InstrumentType == Call || inst.InstrumentType == Put || inst.InstrumentType == "FUT"
+}
+
+// hasExpiry reports whether an instrument has an expiry. Instruments without
+// one carry the normalized zero date.
+func hasExpiry(inst *OptionInstrument) bool {
+	return inst.Expiry.Year() > 1
+}
+
+// Search returns the page of instruments matching q. Hits are ranked by how
+// well the words match, then the underlying's liquidity, then nearest expiry.
+func (ix *SearchIndex) Search(q SearchQuery) SearchResult {
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		q.Limit = MaxSearchLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	// Every word must match; scores add up
+	var candidates map[int]float64
+	for _, term := range q.Terms {
+		hits := ix.matchTerm(term)
+		if candidates == nil {
+			candidates = hits
+			continue
+		}
+		for i, score := range candidates {
+			if hit, ok := hits[i]; ok {
+				candidates[i] = score + hit
+			} else {
+				delete(candidates, i)
+			}
+		}
+	}
+
+	var matches []SearchHit
+	consider := func(i int, score float64) {
+		inst := ix.insts[i]
+		extra, ok := q.matches(inst)
+		if !ok {
+			return
+		}
+		matches = append(matches, newSearchHit(inst, score+extra))
+	}
+	if candidates == nil {
+		for i := range ix.insts {
+			consider(i, 0)
+		}
+	} else {
+		for i, score := range candidates {
+			consider(i, score)
+		}
+	}
+
+	ix.rank(matches)
+
+	result := SearchResult{Query: q, Total: len(matches), Results: []SearchHit{}}
+	if q.Offset < len(matches) {
+		end := q.Offset + q.Limit
+		if end > len(matches) {
+			end = len(matches)
+		}
+		result.Results = matches[q.Offset:end]
+	}
+	return result
+}
+
+// matches applies the filters to an instrument and returns the score of a
+// token match
+func (q *SearchQuery) matches(inst *OptionInstrument) (float64, bool) {
+	if q.Exchange != "" && !strings.EqualFold(inst.Exchange, q.Exchange) {
+		return 0, false
+	}
+	if q.Segment != "" && !strings.EqualFold(inst.Segment, q.Segment) {
+		return 0, false
+	}
+	if q.Type != "" && !strings.EqualFold(string(inst.InstrumentType), q.Type) {
+		return 0, false
+	}
+	if q.Strike > 0 && inst.StrikePrice != q.Strike {
+		return 0, false
+	}
+	if q.Expiry != "" && (!hasExpiry(inst) || !strings.HasPrefix(inst.Expiry.Format("2006-01-02"), q.Expiry)) {
+		return 0, false
+	}
+
+	score := 0.0
+	for _, n := range q.Numbers {
+		switch {
+		case float64(inst.InstrumentToken) == n:
+			score += 2
+		case inst.StrikePrice == n && isDerivative(inst):
+		case symbolHasWord(inst.Tradingsymbol, strconv.FormatFloat(n, 'f', -1, 64)):
+			score += 0.5 // "nifty 50"
+		default:
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+func symbolHasWord(symbol, word string) bool {
+	for _, field := range strings.Fields(symbol) {
+		if field == word {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTerm scores the instruments a word matches: exactly a name 1, the start
+// of a name 0.8, the start of a symbol 0.7 and, when nothing starts with it,
+// a name within a typo or two 0.5 less 0.1 per edit
+func (ix *SearchIndex) matchTerm(term string) map[int]float64 {
+	hits := make(map[int]float64)
+	add := func(idx []int, score float64) {
+		for _, i := range idx {
+			if score > hits[i] {
+				hits[i] = score
+			}
+		}
+	}
+
+	for j := sort.SearchStrings(ix.roots, term); j < len(ix.roots) && strings.HasPrefix(ix.roots[j], term); j++ {
+		score := 0.8
+		if ix.roots[j] == term {
+			score = 1
+		}
+		add(ix.byRoot[ix.roots[j]], score)
+	}
+
+	start := sort.Search(len(ix.symbols), func(j int) bool { return ix.symbols[j].key >= term })
+	for j := start; j < len(ix.symbols) && strings.HasPrefix(ix.symbols[j].key, term); j++ {
+		add([]int{ix.symbols[j].idx}, 0.7)
+	}
+
+	if len(hits) > 0 {
+		return hits
+	}
+	maxEdits := 0
+	switch {
+	case len(term) >= 8:
+		maxEdits = 2
+	case len(term) >= 4:
+		maxEdits = 1
+	}
+	if maxEdits == 0 {
+		return hits
+	}
+	for _, root := range ix.roots {
+		d := editDistance(term, root)
+		if first := strings.Fields(root); len(first) > 1 {
+			if fd := editDistance(term, first[0]); fd < d {
+				d = fd
+			}
+		}
+		if d <= maxEdits {
+			add(ix.byRoot[root], 0.5-0.1*float64(d))
+		}
+	}
+	return hits
+}
+
+// rank orders hits best first
+func (ix *SearchIndex) rank(hits []SearchHit) {
+	liquidity := func(h *SearchHit) int {
+		if h.Expiry != "" {
+			return ix.liquidity[strings.ToLower(h.Name)]
+		}
+		return ix.liquidity[strings.ToLower(h.Tradingsymbol)]
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := &hits[i], &hits[j]
+		if math.Abs(a.Score-b.Score) > 1e-9 {
+			return a.Score > b.Score
+		}
+		if la, lb := liquidity(a), liquidity(b); la != lb {
+			return la > lb
+		}
+		if a.Expiry != b.Expiry {
+			return a.Expiry < b.Expiry // Cash and indices first, then the nearest expiry
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.StrikePrice != b.StrikePrice {
+			return a.StrikePrice < b.StrikePrice
+		}
+		return a.Tradingsymbol < b.Tradingsymbol
+	})
+}
+
+func newSearchHit(inst *OptionInstrument, score float64) SearchHit {
+	hit := SearchHit{
+		InstrumentToken: inst.InstrumentToken,
+		Tradingsymbol:   inst.Tradingsymbol,
+		Name:            inst.Name,
+		Exchange:        inst.Exchange,
+		Segment:         inst.Segment,
+		InstrumentType:  string(inst.InstrumentType),
+		StrikePrice:     inst.StrikePrice,
+		LotSize:         inst.LotSize,
+		TickSize:        inst.TickSize,
+		Score:           math.Round(score*100) / 100,
+	}
+	if hasExpiry(inst) {
+		hit.Expiry = inst.Expiry.Format("2006-01-02")
+	}
+	return hit
+}
+
+// editDistance returns the optimal string alignment distance between a and b,
+// counting a swap of adjacent letters as one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && prev2[j-2]+1 < cur[j] {
+				cur[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// SearchInstruments searches the loaded instruments
+func (s *Scanner) SearchInstruments(q SearchQuery) SearchResult {
+	s.mu.RLock()
+	index := s.search
+	s.mu.RUnlock()
+	if index == nil {
+		index = newSearchIndex(nil)
+	}
+	return index.Search(q)
+}
@@ -0,0 +1,84 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSearch(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, ist)
+
+	q := ParseSearch("nifty 26000 ce 30dec", now)
+	require.Equal(t, []string{"nifty"}, q.Terms)
+	require.Equal(t, []float64{26000}, q.Numbers)
+	require.Equal(t, "CE", q.Type)
+	require.Equal(t, "2026-12-30", q.Expiry)
+
+	// A date that has passed this year is next year's
+	q = ParseSearch("banknifty 52000pe 30 sep", now)
+	require.Equal(t, "PE", q.Type)
+	require.Equal(t, "2027-09-30", q.Expiry)
+
+	q = ParseSearch("reliance dec 25 fut", now)
+	require.Equal(t, "FUT", q.Type)
+	require.Equal(t, "2026-12", q.Expiry)
+	require.Equal(t, []float64{25}, q.Numbers)
+
+	q = ParseSearch("nifty 2026-10-27", now)
+	require.Equal(t, "2026-10-27", q.Expiry)
+}
+
+func TestSearchInstruments(t *testing.T) {
+	near := models.Time{Time: time.Date(2026, 10, 27, 0, 0, 0, 0, ist)}
+	far := models.Time{Time: time.Date(2026, 12, 29, 0, 0, 0, 0, ist)}
+	s := NewScanner(nil)
+	s.LoadInstruments([]kiteconnect.Instrument{
+		{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Name: "NIFTY 50", Exchange: "NSE", Segment: "INDICES", InstrumentType: "EQ"},
+		{InstrumentToken: 738561, Tradingsymbol: "RELIANCE", Name: "RELIANCE INDUSTRIES", Exchange: "NSE", Segment: "NSE", InstrumentType: "EQ"},
+		{InstrumentToken: 1, Tradingsymbol: "NIFTY26DEC26000CE", Name: "NIFTY", Exchange: "NFO", Segment: "NFO-OPT", InstrumentType: "CE", StrikePrice: 26000, Expiry: far},
+		{InstrumentToken: 2, Tradingsymbol: "NIFTY26O2726000CE", Name: "NIFTY", Exchange: "NFO", Segment: "NFO-OPT", InstrumentType: "CE", StrikePrice: 26000, Expiry: near},
+		{InstrumentToken: 3, Tradingsymbol: "NIFTY26O2726000PE", Name: "NIFTY", Exchange: "NFO", Segment: "NFO-OPT", InstrumentType: "PE", StrikePrice: 26000, Expiry: near},
+		{InstrumentToken: 4, Tradingsymbol: "NIFTY26OCTFUT", Name: "NIFTY", Exchange: "NFO", Segment: "NFO-FUT", InstrumentType: "FUT", Expiry: near},
+		{InstrumentToken: 5, Tradingsymbol: "NIFTYNXT5026OCTFUT", Name: "NIFTYNXT50", Exchange: "NFO", Segment: "NFO-FUT", InstrumentType: "FUT", Expiry: near},
+		{InstrumentToken: 6, Tradingsymbol: "RELIANCE26OCTFUT", Name: "RELIANCE", Exchange: "NFO", Segment: "NFO-FUT", InstrumentType: "FUT", Expiry: near},
+	})
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, ist)
+	tokens := func(r SearchResult) []uint32 {
+		out := make([]uint32, len(r.Results))
+		for i, hit := range r.Results {
+			out[i] = hit.InstrumentToken
+		}
+		return out
+	}
+
+	// Near expiry first
+	r := s.SearchInstruments(ParseSearch("nifty 26000 ce", now))
+	require.Equal(t, []uint32{2, 1}, tokens(r))
+
+	r = s.SearchInstruments(ParseSearch("nifty 26000 ce 30dec", now))
+	require.Empty(t, r.Results)
+	r = s.SearchInstruments(ParseSearch("nifty 26000 ce 29dec", now))
+	require.Equal(t, []uint32{1}, tokens(r))
+
+	// Exact name before a longer name, the more liquid underlying first
+	r = s.SearchInstruments(ParseSearch("nifty fut", now))
+	require.Equal(t, []uint32{4, 5}, tokens(r))
+	r = s.SearchInstruments(ParseSearch("nifty 50", now))
+	require.Equal(t, []uint32{256265}, tokens(r))
+
+	// Typos, the equity by symbol and by token
+	r = s.SearchInstruments(ParseSearch("relaince", now))
+	require.Equal(t, []uint32{738561, 6}, tokens(r))
+	r = s.SearchInstruments(SearchQuery{Numbers: []float64{738561}})
+	require.Equal(t, []uint32{738561}, tokens(r))
+
+	// Pagination
+	r = s.SearchInstruments(SearchQuery{Exchange: "NFO", Limit: 2, Offset: 4})
+	require.Equal(t, 6, r.Total)
+	require.Len(t, r.Results, 2)
+}
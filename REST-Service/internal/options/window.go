@@ -0,0 +1,187 @@
+package options
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Defaults for StrikeWindow
+const (
+	DefaultWindowVolatility = 0.2    // Delta band volatility for options without an IV yet
+	DefaultRecenterPct      = 0.0025 // Underlying move that re-centres a window
+)
+
+// StrikeWindow selects strikes relative to the underlying price instead of a
+// fixed range. Each set limit applies; a strike must pass all of them. A window
+// without a centre selects nothing, since the ATM strike is not yet known.
+type StrikeWindow struct {
+	Strikes     int     // Strikes each side of ATM, 0 = no limit
+	RangePct    float64 // Max distance of the strike from the centre, e.g. 0.03 for ±3%
+	MinDelta    float64 // Absolute delta band, e.g. 0.05-0.95; 0 = no limit
+	MaxDelta    float64
+	Volatility  float64 // For the delta band when the option has no IV yet
+	RecenterPct float64 // Underlying move from the centre before the window follows it
+	Center      float64 // Underlying price the window is centred on
+}
+
+// NeedsRecenter reports whether price has moved far enough from the centre
+// for the window to follow it. The threshold keeps the window from flapping
+// while the price oscillates around a strike boundary.
+func (w *StrikeWindow) NeedsRecenter(price float64) bool {
+	if price <= 0 {
+		return false
+	}
+	if w.Center <= 0 {
+		return true
+	}
+	threshold := w.RecenterPct
+	if threshold <= 0 {
+		threshold = DefaultRecenterPct
+	}
+	return math.Abs(price/w.Center-1) > threshold
+}
+
+// windowFilter applies a StrikeWindow to the instruments of one scan. The
+// caller must hold the scanner's read lock.
+type windowFilter struct {
+	s      *Scanner
+	w      *StrikeWindow
+	now    time.Time
+	gc     *GreeksCalculator
+	atm    map[*OptionChain]int // Index of the ATM strike in the chain's sorted strikes
+	sorted map[*OptionChain][]float64
+}
+
+func (s *Scanner) newWindowFilter(w *StrikeWindow, now time.Time) *windowFilter {
+	return &windowFilter{
+		s:      s,
+		w:      w,
+		now:    now,
+		gc:     NewGreeksCalculator(DefaultRiskFreeRate),
+		atm:    make(map[*OptionChain]int),
+		sorted: make(map[*OptionChain][]float64),
+	}
+}
+
+// keep reports whether an option falls inside the window
+func (f *windowFilter) keep(inst *OptionInstrument) bool {
+	center := f.w.Center
+	if center <= 0 {
+		return false
+	}
+	if f.w.RangePct > 0 && math.Abs(inst.StrikePrice/center-1) > f.w.RangePct {
+		return false
+	}
+
+	chain := f.s.chains[inst.Name][inst.Expiry]
+	if chain == nil {
+		return false
+	}
+
+	if f.w.Strikes > 0 {
+		strikes, atm := f.strikes(chain)
+		i := sort.SearchFloat64s(strikes, inst.StrikePrice)
+		if i >= len(strikes) || strikes[i] != inst.StrikePrice {
+			return false
+		}
+		if d := i - atm; d > f.w.Strikes || -d > f.w.Strikes {
+			return false
+		}
+	}
+
+	if f.w.MinDelta > 0 || f.w.MaxDelta > 0 {
+		delta := math.Abs(f.delta(chain, inst))
+		if f.w.MinDelta > 0 && delta < f.w.MinDelta {
+			return false
+		}
+		if f.w.MaxDelta > 0 && delta > f.w.MaxDelta {
+			return false
+		}
+	}
+
+	return true
+}
+
+// strikes returns a chain's sorted strikes and the index of the one nearest the centre
+func (f *windowFilter) strikes(chain *OptionChain) ([]float64, int) {
+	if strikes, ok := f.sorted[chain]; ok {
+		return strikes, f.atm[chain]
+	}
+
+	strikes := make([]float64, 0, len(chain.Strikes))
+	for strike := range chain.Strikes {
+		strikes = append(strikes, strike)
+	}
+	sort.Float64s(strikes)
+
+	atm := 0
+	for i, strike := range strikes {
+		if math.Abs(strike-f.w.Center) < math.Abs(strikes[atm]-f.w.Center) {
+			atm = i
+		}
+	}
+
+	f.sorted[chain] = strikes
+	f.atm[chain] = atm
+	return strikes, atm
+}
+
+// delta estimates an option's delta at the centre, using its live IV when it
+// has one
+func (f *windowFilter) delta(chain *OptionChain, inst *OptionInstrument) float64 {
+	iv := f.w.Volatility
+	if iv <= 0 {
+		iv = DefaultWindowVolatility
+	}
+	if sd := chain.Strikes[inst.StrikePrice]; sd != nil {
+		od := sd.Call
+		if inst.InstrumentType == Put {
+			od = sd.Put
+		}
+		if od != nil && od.IV > 0 {
+			iv = od.IV
+		}
+	}
+
+	T := f.s.calendar.TimeToExpiry(f.now, inst.Expiry)
+	if T <= 0 {
+		// At expiry the delta is 1 in the money and 0 out of it
+		itm := (inst.InstrumentType == Call && f.w.Center > inst.StrikePrice) ||
+			(inst.InstrumentType == Put && f.w.Center < inst.StrikePrice)
+		if itm {
+			return 1
+		}
+		return 0
+	}
+
+	delta, _, _, _ := f.gc.CalculateGreeks(inst.InstrumentType, f.w.Center, inst.StrikePrice, T, iv)
+	return delta
+}
+
+// StrikeDistance returns how many strikes an option is from the strike
+// nearest price in its chain
+func (s *Scanner) StrikeDistance(token uint32, price float64) (int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	inst, ok := s.instruments[token]
+	if !ok || (inst.InstrumentType != Call && inst.InstrumentType != Put) || price <= 0 {
+		return 0, false
+	}
+	chain := s.chains[inst.Name][inst.Expiry]
+	if chain == nil {
+		return 0, false
+	}
+
+	f := &windowFilter{w: &StrikeWindow{Center: price}, atm: make(map[*OptionChain]int), sorted: make(map[*OptionChain][]float64)}
+	strikes, atm := f.strikes(chain)
+	i := sort.SearchFloat64s(strikes, inst.StrikePrice)
+	if i >= len(strikes) || strikes[i] != inst.StrikePrice {
+		return 0, false
+	}
+	if i < atm {
+		return atm - i, true
+	}
+	return i - atm, true
+}
@@ -0,0 +1,61 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterOptionsWindow(t *testing.T) {
+	expiry := models.Time{Time: time.Now().AddDate(0, 0, 20)}
+	instruments := make([]kiteconnect.Instrument, 0)
+	for i, strike := 0, 24000.0; strike <= 26000; i, strike = i+1, strike+100 {
+		instruments = append(instruments,
+			kiteconnect.Instrument{InstrumentToken: 1000 + i, Name: "NIFTY", InstrumentType: "CE", StrikePrice: strike, Expiry: expiry},
+			kiteconnect.Instrument{InstrumentToken: 2000 + i, Name: "NIFTY", InstrumentType: "PE", StrikePrice: strike, Expiry: expiry},
+		)
+	}
+	s := NewScanner(nil)
+	s.LoadInstruments(instruments)
+
+	strikesOf := func(w *StrikeWindow) map[float64]bool {
+		strikes := make(map[float64]bool)
+		for _, inst := range s.FilterOptions(FilterCriteria{Underlying: "NIFTY", Window: w}) {
+			strikes[inst.StrikePrice] = true
+		}
+		return strikes
+	}
+
+	// Two strikes each side of 25040, whose nearest strike is 25000
+	strikes := strikesOf(&StrikeWindow{Strikes: 2, Center: 25040})
+	require.Len(t, strikes, 5)
+	require.True(t, strikes[24800])
+	require.True(t, strikes[25200])
+
+	// ±1% of 25000
+	strikes = strikesOf(&StrikeWindow{RangePct: 0.01, Center: 25000})
+	require.Len(t, strikes, 5)
+	require.False(t, strikes[25300])
+
+	// A delta band drops the far wings but keeps ATM
+	strikes = strikesOf(&StrikeWindow{MinDelta: 0.05, MaxDelta: 0.95, Volatility: 0.05, Center: 25000})
+	require.True(t, strikes[25000])
+	require.False(t, strikes[24000])
+	require.False(t, strikes[26000])
+
+	// No centre yet, nothing selected
+	require.Empty(t, strikesOf(&StrikeWindow{Strikes: 2}))
+}
+
+func TestStrikeWindowNeedsRecenter(t *testing.T) {
+	w := &StrikeWindow{RecenterPct: 0.01, Center: 25000}
+	require.False(t, w.NeedsRecenter(25200))
+	require.True(t, w.NeedsRecenter(25300))
+	require.True(t, w.NeedsRecenter(24700))
+	require.False(t, w.NeedsRecenter(0))
+	require.True(t, (&StrikeWindow{}).NeedsRecenter(25000))
+}
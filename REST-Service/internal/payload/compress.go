@@ -0,0 +1,117 @@
+package payload
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// encoder is a pooled compressor, reset onto each response
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// encodings are the content codings offered, most preferred first when the
+// client weighs them equally: brotli compresses JSON smaller than gzip
+var encodings = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"br", &sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}}},
+	{"gzip", &sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}}},
+}
+
+// Compress compresses the response body with brotli or gzip, whichever the
+// client's Accept-Encoding weighs highest. Responses without a body, such as
+// 304, are left alone.
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		i := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if i < 0 {
+			c.Next()
+			return
+		}
+
+		w := &compressResponseWriter{ResponseWriter: c.Writer, name: encodings[i].name, pool: encodings[i].pool}
+		c.Writer = w
+		defer w.close()
+		c.Next()
+	}
+}
+
+// negotiateEncoding returns the index in encodings of the coding an
+// Accept-Encoding header weighs highest, or -1 when it accepts none
+func negotiateEncoding(header string) int {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				q, _ = strconv.ParseFloat(kv[1], 64)
+			}
+		}
+		weights[coding] = q
+	}
+
+	best, bestQ := -1, 0.0
+	for i, e := range encodings {
+		q, ok := weights[e.name]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+// compressResponseWriter starts compressing on the first write, when the
+// status and headers are known
+type compressResponseWriter struct {
+	gin.ResponseWriter
+	name string
+	pool *sync.Pool
+	enc  encoder
+}
+
+func (w *compressResponseWriter) Write(data []byte) (int, error) {
+	if w.enc == nil {
+		h := w.Header()
+		h.Set("Content-Encoding", w.name)
+		h.Del("Content-Length")
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	return w.enc.Write(data)
+}
+
+func (w *compressResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressResponseWriter) close() {
+	if w.enc == nil {
+		return
+	}
+	w.enc.Close()
+	w.pool.Put(w.enc)
+	w.enc = nil
+}
@@ -0,0 +1,155 @@
+package payload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	kiteconnect "gokiteconnect-master"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// Envelope statuses
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+// RequestIDHeader carries the ID of a request, sent by the client or
+// generated, and is echoed on the response
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+// Error types of the service's own authentication, kept apart from Kite's
+// TokenException so clients don't take a bad API key for an expired session
+const (
+	AuthError       = "AuthException"       // Missing or invalid API key
+	PermissionError = "PermissionException" // Key or origin not allowed on the route
+)
+
+// Envelope is the body of every JSON and MessagePack response, shaped like
+// Kite's own: data on success, and the Kite error type and a message on
+// failure. Protobuf responses are the bare message.
+type Envelope struct {
+	Status    string      `json:"status"`
+	ErrorType string      `json:"error_type,omitempty"`
+	Message   string      `json:"message,omitempty"`
+	Data      interface{} `json:"data"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// RequestID tags each request with the X-Request-ID the client sent, or a
+// new random one, for the envelopes and the response header
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID RequestID tagged a request with
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID accepts short IDs of letters, digits and -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Error writes an error envelope of the service's own, typed by status:
+// 401 is an AuthException, 403 a PermissionException, other 4xx an
+// InputException and 5xx a GeneralException. NetworkException is left to
+// Kite being unreachable.
+func Error(c *gin.Context, status int, message string) {
+	errorType := kiteconnect.GeneralError
+	switch {
+	case status == http.StatusUnauthorized:
+		errorType = AuthError
+	case status == http.StatusForbidden:
+		errorType = PermissionError
+	case status < http.StatusInternalServerError:
+		errorType = kiteconnect.InputError
+	}
+	Fail(c, status, errorType, message, nil)
+}
+
+// Fail writes an error envelope in JSON or MessagePack, as negotiated, and
+// aborts the remaining handlers
+func Fail(c *gin.Context, status int, errorType, message string, data interface{}) {
+	format, _ := Negotiate(c.GetHeader("Accept"), JSON, MsgPack)
+	c.Abort()
+	write(c, status, format, Envelope{
+		Status:    StatusError,
+		ErrorType: errorType,
+		Message:   message,
+		Data:      data,
+		RequestID: GetRequestID(c),
+	})
+}
+
+// KiteError writes the error of a Kite API call with the status and error
+// type Kite answered, so a rejected order (400 OrderException) is told apart
+// from an expired session (403 TokenException) or Kite being unreachable (503
+// NetworkException). A call past the request's deadline is a 504; other
+// errors are a 500.
+func KiteError(c *gin.Context, err error) {
+	var kerr kiteconnect.Error
+	switch {
+	case errors.As(err, &kerr):
+		status := kerr.Code
+		if status < http.StatusBadRequest {
+			status = http.StatusInternalServerError
+		}
+		errorType := kerr.ErrorType
+		if errorType == "" {
+			errorType = kiteconnect.GetErrorName(status)
+		}
+		Fail(c, status, errorType, kerr.Message, kerr.Data)
+	case errors.Is(err, context.DeadlineExceeded):
+		Fail(c, http.StatusGatewayTimeout, kiteconnect.NetworkError, "Kite request timed out", nil)
+	default:
+		Fail(c, http.StatusInternalServerError, kiteconnect.GeneralError, err.Error(), nil)
+	}
+}
+
+// success wraps the data of a response
+func success(c *gin.Context, data interface{}) Envelope {
+	return Envelope{Status: StatusSuccess, Data: data, RequestID: GetRequestID(c)}
+}
+
+// write renders an envelope in JSON or MessagePack
+func write(c *gin.Context, status int, format Format, env Envelope) {
+	if format == MsgPack {
+		c.Render(status, render.MsgPack{Data: env})
+		return
+	}
+	c.JSON(status, env)
+}
@@ -0,0 +1,132 @@
+// Package payload encodes REST responses in the format a client negotiates:
+// JSON, MessagePack or Protobuf via Accept, compressed with brotli or gzip via
+// Accept-Encoding. JSON and MessagePack bodies are wrapped in an Envelope;
+// the Protobuf messages are described in rest.proto.
+package payload
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Format is a response encoding
+type Format int
+
+const (
+	JSON Format = iota
+	MsgPack
+	Protobuf
+)
+
+// SchemaVersion is the version of the response schemas, sent in the
+// X-Schema-Version header. A client can pin it with a version parameter on
+// the Accept media type; other versions are not acceptable.
+const SchemaVersion = 1
+
+var mediaTypes = map[string]Format{
+	"application/json":                JSON,
+	"application/msgpack":             MsgPack,
+	"application/x-msgpack":           MsgPack,
+	"application/vnd.msgpack":         MsgPack,
+	"application/protobuf":            Protobuf,
+	"application/x-protobuf":          Protobuf,
+	"application/vnd.google.protobuf": Protobuf,
+}
+
+// Negotiate picks the offered format the Accept header prefers. An empty
+// header or a wildcard gets the first offered format; ok is false when
+// nothing offered is acceptable.
+func Negotiate(accept string, offered ...Format) (Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0], true
+	}
+
+	type candidate struct {
+		format Format
+		q      float64
+		pos    int
+	}
+	var candidates []candidate
+	for pos, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		media := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		versionOK := true
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.ToLower(kv[0]) {
+			case "q":
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = v
+				}
+			case "version":
+				versionOK = strings.Trim(kv[1], `"`) == strconv.Itoa(SchemaVersion)
+			}
+		}
+		if q <= 0 || !versionOK {
+			continue
+		}
+
+		if media == "*/*" || media == "application/*" {
+			candidates = append(candidates, candidate{format: offered[0], q: q, pos: pos})
+			continue
+		}
+		if format, ok := mediaTypes[media]; ok && isOffered(format, offered) {
+			candidates = append(candidates, candidate{format: format, q: q, pos: pos})
+		}
+	}
+	if len(candidates) == 0 {
+		return offered[0], false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].format, true
+}
+
+func isOffered(format Format, offered []Format) bool {
+	for _, f := range offered {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is a response with a Protobuf encoding
+type Message interface {
+	ProtoName() string           // Fully qualified message name in rest.proto
+	AppendProto(b []byte) []byte // Appends the wire encoding to b
+}
+
+// Respond writes v in the format the request's Accept header negotiates, as
+// the data of a success envelope in JSON and MessagePack. msg is the Protobuf
+// encoding of v, nil if it has none.
+func Respond(c *gin.Context, status int, v interface{}, msg Message) {
+	offered := []Format{JSON, MsgPack}
+	available := "application/json, application/msgpack"
+	if msg != nil {
+		offered = append(offered, Protobuf)
+		available += ", application/x-protobuf"
+	}
+
+	c.Writer.Header().Add("Vary", "Accept")
+	format, ok := Negotiate(c.GetHeader("Accept"), offered...)
+	if !ok {
+		Error(c, http.StatusNotAcceptable, "Not acceptable. Available: "+available+" (version "+strconv.Itoa(SchemaVersion)+")")
+		return
+	}
+
+	c.Header("X-Schema-Version", strconv.Itoa(SchemaVersion))
+	if format == Protobuf {
+		c.Data(status, `application/x-protobuf; messageType="`+msg.ProtoName()+`"`, msg.AppendProto(nil))
+		return
+	}
+	write(c, status, format, success(c, v))
+}
@@ -0,0 +1,232 @@
+package payload
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rest-service/internal/options"
+
+	kiteconnect "gokiteconnect-master"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestNegotiate(t *testing.T) {
+	all := []Format{JSON, MsgPack, Protobuf}
+
+	cases := []struct {
+		accept string
+		format Format
+		ok     bool
+	}{
+		{"", JSON, true},
+		{"*/*", JSON, true},
+		{"application/msgpack", MsgPack, true},
+		{"application/x-protobuf, application/json;q=0.5", Protobuf, true},
+		{"application/json;q=0.5, application/x-protobuf", Protobuf, true},
+		{"application/x-protobuf;version=1", Protobuf, true},
+		{"application/x-protobuf;version=2, application/json;q=0.1", JSON, true},
+		{"application/x-protobuf;version=2", JSON, false},
+		{"text/html", JSON, false},
+		{"application/msgpack;q=0", JSON, false},
+	}
+	for _, tc := range cases {
+		format, ok := Negotiate(tc.accept, all...)
+		require.Equal(t, tc.ok, ok, tc.accept)
+		if ok {
+			require.Equal(t, tc.format, format, tc.accept)
+		}
+	}
+
+	// Protobuf is only acceptable for responses that have an encoding
+	_, ok := Negotiate("application/x-protobuf", JSON, MsgPack)
+	require.False(t, ok)
+}
+
+func serve(handlers ...gin.HandlerFunc) func(accept, encoding string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", handlers...)
+	return func(accept, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+}
+
+func TestRespond(t *testing.T) {
+	insts := []*options.OptionInstrument{
+		{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Name: "NIFTY 50", Exchange: "NSE"},
+		{InstrumentToken: 12345, Tradingsymbol: "NIFTY26OCT25000CE", Name: "NIFTY", Exchange: "NFO", InstrumentType: options.Call,
+			StrikePrice: 25000, LotSize: 75, Expiry: time.Date(2026, 10, 27, 0, 0, 0, 0, time.UTC)},
+	}
+	body := gin.H{"version": "2026-10-18", "count": 2}
+	get := serve(func(c *gin.Context) {
+		Respond(c, http.StatusOK, body, InstrumentList{Version: "2026-10-18", Instruments: insts})
+	})
+
+	w := get("", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "1", w.Header().Get("X-Schema-Version"))
+	require.JSONEq(t, `{"status":"success","data":{"version":"2026-10-18","count":2}}`, w.Body.String())
+
+	w = get("application/msgpack", "")
+	require.Equal(t, "application/msgpack; charset=utf-8", w.Header().Get("Content-Type"))
+	var decoded struct {
+		Status string                 `json:"status"`
+		Data   map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), new(codec.MsgpackHandle)).Decode(&decoded))
+	require.Equal(t, StatusSuccess, decoded.Status)
+	require.EqualValues(t, 2, decoded.Data["count"])
+
+	w = get("application/x-protobuf", "")
+	require.Equal(t, `application/x-protobuf; messageType="rest.v1.InstrumentList"`, w.Header().Get("Content-Type"))
+	fields := decode(t, w.Body.Bytes())
+	require.Equal(t, "2026-10-18", string(fields[1][0]))
+	require.Len(t, fields[2], 2)
+	option := decode(t, fields[2][1])
+	require.Equal(t, "NIFTY26OCT25000CE", string(option[2][0]))
+	require.Equal(t, "2026-10-27", string(option[10][0]))
+	index := decode(t, fields[2][0])
+	require.NotContains(t, index, protowire.Number(10)) // No expiry
+
+	w = get("application/x-protobuf;version=2", "")
+	require.Equal(t, http.StatusNotAcceptable, w.Code)
+	require.Contains(t, w.Body.String(), `"error_type":"InputException"`)
+}
+
+func TestEnvelope(t *testing.T) {
+	var fail gin.HandlerFunc
+	get := serve(RequestID(), func(c *gin.Context) { fail(c) })
+	decodeEnvelope := func(w *httptest.ResponseRecorder) Envelope {
+		var env Envelope
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env), w.Body.String())
+		require.Equal(t, StatusError, env.Status)
+		require.Equal(t, w.Header().Get(RequestIDHeader), env.RequestID)
+		return env
+	}
+
+	cases := []struct {
+		err       error
+		status    int
+		errorType string
+	}{
+		{kiteconnect.NewError(kiteconnect.OrderError, "Insufficient funds.", nil), http.StatusBadRequest, kiteconnect.OrderError},
+		{kiteconnect.NewError(kiteconnect.TokenError, "Session expired.", nil), http.StatusForbidden, kiteconnect.TokenError},
+		{kiteconnect.NewError(kiteconnect.NetworkError, "Request failed.", nil), http.StatusServiceUnavailable, kiteconnect.NetworkError},
+		{kiteconnect.Error{Code: http.StatusTooManyRequests, Message: "Too many requests"}, http.StatusTooManyRequests, kiteconnect.GeneralError},
+		{fmt.Errorf("fetching quote: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, kiteconnect.NetworkError},
+		{errors.New("boom"), http.StatusInternalServerError, kiteconnect.GeneralError},
+	}
+	for _, tc := range cases {
+		err := tc.err
+		fail = func(c *gin.Context) { KiteError(c, err) }
+		w := get("", "")
+		require.Equal(t, tc.status, w.Code, err.Error())
+		env := decodeEnvelope(w)
+		require.Equal(t, tc.errorType, env.ErrorType, err.Error())
+		require.Len(t, env.RequestID, 16)
+	}
+
+	// The service's own errors are typed by status, and a client's request
+	// ID is kept
+	fail = func(c *gin.Context) { Error(c, http.StatusNotFound, "No futures for XYZ") }
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "client-42")
+	w := httptest.NewRecorder()
+	r := gin.New()
+	r.GET("/", RequestID(), fail)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	env := decodeEnvelope(w)
+	require.Equal(t, "client-42", env.RequestID)
+	require.Equal(t, kiteconnect.InputError, env.ErrorType)
+	require.Equal(t, "No futures for XYZ", env.Message)
+
+	fail = func(c *gin.Context) { Error(c, http.StatusServiceUnavailable, "Scanner not enabled") }
+	require.Equal(t, kiteconnect.GeneralError, decodeEnvelope(get("", "")).ErrorType)
+
+	// Local auth failures are not mistaken for an expired Kite session
+	fail = func(c *gin.Context) { Error(c, http.StatusUnauthorized, "Missing or invalid API key") }
+	require.Equal(t, AuthError, decodeEnvelope(get("", "")).ErrorType)
+	fail = func(c *gin.Context) { Error(c, http.StatusForbidden, "Origin not allowed") }
+	require.Equal(t, PermissionError, decodeEnvelope(get("", "")).ErrorType)
+}
+
+func TestCompress(t *testing.T) {
+	get := serve(Compress(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": "instruments"})
+	})
+
+	w := get("", "gzip")
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	plain, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":"instruments"}`, string(plain))
+
+	// Brotli is preferred unless the client weighs gzip higher
+	w = get("", "gzip, deflate, br")
+	require.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	plain, err = io.ReadAll(brotli.NewReader(w.Body))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":"instruments"}`, string(plain))
+
+	require.Equal(t, "gzip", get("", "br;q=0.5, gzip").Header().Get("Content-Encoding"))
+	require.Equal(t, "br", get("", "*").Header().Get("Content-Encoding"))
+	require.Equal(t, "gzip", get("", "*, br;q=0").Header().Get("Content-Encoding"))
+
+	w = get("", "gzip;q=0")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	w = get("", "deflate")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.JSONEq(t, `{"data":"instruments"}`, w.Body.String())
+
+	// A body-less 304 is not wrapped in an empty gzip stream
+	notModified := serve(Compress(), func(c *gin.Context) { c.Status(http.StatusNotModified) })
+	w = notModified("", "gzip")
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Zero(t, w.Body.Len())
+}
+
+// decode splits a message into its length-delimited fields by number
+func decode(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	fields := make(map[protowire.Number][][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		if typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(b)
+			require.GreaterOrEqual(t, m, 0)
+			fields[num] = append(fields[num], v)
+			b = b[m:]
+			continue
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		require.GreaterOrEqual(t, m, 0)
+		fields[num] = append(fields[num], nil)
+		b = b[m:]
+	}
+	return fields
+}
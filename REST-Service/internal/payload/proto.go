@@ -0,0 +1,263 @@
+package payload
+
+import (
+	"math"
+	"time"
+
+	"rest-service/internal/options"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages below follow rest.proto. Fields at their zero value are left
+// out, as proto3 does, and times are Unix milliseconds.
+
+// InstrumentList is the rest.v1.InstrumentList encoding of /instruments
+type InstrumentList struct {
+	Version     string
+	Instruments []*options.OptionInstrument
+}
+
+func (InstrumentList) ProtoName() string { return "rest.v1.InstrumentList" }
+
+func (m InstrumentList) AppendProto(b []byte) []byte {
+	b = appendString(b, 1, m.Version)
+	for _, inst := range m.Instruments {
+		b = appendMessage(b, 2, func(b []byte) []byte { return appendInstrument(b, inst) })
+	}
+	return b
+}
+
+// InstrumentDelta is the rest.v1.InstrumentDelta encoding of /instruments?since=
+type InstrumentDelta struct {
+	options.InstrumentDelta
+}
+
+func (InstrumentDelta) ProtoName() string { return "rest.v1.InstrumentDelta" }
+
+func (m InstrumentDelta) AppendProto(b []byte) []byte {
+	b = appendString(b, 1, m.Version)
+	b = appendString(b, 2, m.Since)
+	for _, inst := range m.Added {
+		b = appendMessage(b, 3, func(b []byte) []byte { return appendInstrument(b, inst) })
+	}
+	for _, inst := range m.Changed {
+		b = appendMessage(b, 4, func(b []byte) []byte { return appendInstrument(b, inst) })
+	}
+	if len(m.Removed) > 0 {
+		b = appendMessage(b, 5, func(b []byte) []byte {
+			for _, token := range m.Removed {
+				b = protowire.AppendVarint(b, uint64(token))
+			}
+			return b
+		})
+	}
+	return b
+}
+
+func appendInstrument(b []byte, inst *options.OptionInstrument) []byte {
+	b = appendVarint(b, 1, uint64(inst.InstrumentToken))
+	b = appendString(b, 2, inst.Tradingsymbol)
+	b = appendString(b, 3, inst.Name)
+	b = appendString(b, 4, inst.Exchange)
+	b = appendString(b, 5, inst.Segment)
+	b = appendString(b, 6, string(inst.InstrumentType))
+	b = appendDouble(b, 7, inst.TickSize)
+	b = appendVarint(b, 8, uint64(inst.LotSize))
+	b = appendDouble(b, 9, inst.StrikePrice)
+	if inst.Expiry.Year() > 1 {
+		b = appendString(b, 10, inst.Expiry.Format("2006-01-02"))
+	}
+	return b
+}
+
+// CandleList is the rest.v1.CandleList encoding of /historical
+type CandleList struct {
+	Candles []kiteconnect.HistoricalData
+}
+
+func (CandleList) ProtoName() string { return "rest.v1.CandleList" }
+
+func (m CandleList) AppendProto(b []byte) []byte {
+	for _, candle := range m.Candles {
+		b = appendMessage(b, 1, func(b []byte) []byte {
+			b = appendTime(b, 1, candle.Date.Time)
+			b = appendDouble(b, 2, candle.Open)
+			b = appendDouble(b, 3, candle.High)
+			b = appendDouble(b, 4, candle.Low)
+			b = appendDouble(b, 5, candle.Close)
+			b = appendVarint(b, 6, uint64(candle.Volume))
+			return appendVarint(b, 7, uint64(candle.OI))
+		})
+	}
+	return b
+}
+
+// Tick is the rest.v1.Tick encoding of /quote
+type Tick struct {
+	models.Tick
+}
+
+func (Tick) ProtoName() string { return "rest.v1.Tick" }
+
+func (m Tick) AppendProto(b []byte) []byte {
+	b = appendVarint(b, 1, uint64(m.InstrumentToken))
+	b = appendString(b, 2, m.Mode)
+	b = appendBool(b, 3, m.IsTradable)
+	b = appendBool(b, 4, m.IsIndex)
+	b = appendTime(b, 5, m.Timestamp.Time)
+	b = appendTime(b, 6, m.LastTradeTime.Time)
+	b = appendDouble(b, 7, m.LastPrice)
+	b = appendVarint(b, 8, uint64(m.LastTradedQuantity))
+	b = appendVarint(b, 9, uint64(m.TotalBuyQuantity))
+	b = appendVarint(b, 10, uint64(m.TotalSellQuantity))
+	b = appendVarint(b, 11, uint64(m.VolumeTraded))
+	b = appendDouble(b, 12, m.AverageTradePrice)
+	b = appendVarint(b, 13, uint64(m.OI))
+	b = appendVarint(b, 14, uint64(m.OIDayHigh))
+	b = appendVarint(b, 15, uint64(m.OIDayLow))
+	b = appendDouble(b, 16, m.NetChange)
+	b = appendDouble(b, 17, m.OHLC.Open)
+	b = appendDouble(b, 18, m.OHLC.High)
+	b = appendDouble(b, 19, m.OHLC.Low)
+	b = appendDouble(b, 20, m.OHLC.Close)
+	b = appendDepth(b, 21, m.Depth.Buy[:])
+	return appendDepth(b, 22, m.Depth.Sell[:])
+}
+
+func appendDepth(b []byte, num protowire.Number, items []models.DepthItem) []byte {
+	for _, item := range items {
+		if item == (models.DepthItem{}) {
+			continue
+		}
+		b = appendMessage(b, num, func(b []byte) []byte {
+			b = appendDouble(b, 1, item.Price)
+			b = appendVarint(b, 2, uint64(item.Quantity))
+			return appendVarint(b, 3, uint64(item.Orders))
+		})
+	}
+	return b
+}
+
+// OptionChain is the rest.v1.OptionChain encoding of /options/:underlying/:expiry
+type OptionChain struct {
+	Chain   *options.OptionChain
+	Strikes []*options.StrikeData // Ascending
+}
+
+func (OptionChain) ProtoName() string { return "rest.v1.OptionChain" }
+
+func (m OptionChain) AppendProto(b []byte) []byte {
+	b = appendString(b, 1, m.Chain.Underlying)
+	b = appendVarint(b, 2, uint64(m.Chain.UnderlyingToken))
+	b = appendDouble(b, 3, m.Chain.UnderlyingPrice)
+	b = appendDouble(b, 4, m.Chain.Forward)
+	b = appendString(b, 5, m.Chain.Expiry.Format("2006-01-02"))
+	b = appendTime(b, 6, m.Chain.LastUpdated)
+	for _, sd := range m.Strikes {
+		b = appendMessage(b, 7, func(b []byte) []byte {
+			b = appendDouble(b, 1, sd.Strike)
+			if sd.Call != nil {
+				b = appendMessage(b, 2, func(b []byte) []byte { return appendOption(b, sd.Call) })
+			}
+			if sd.Put != nil {
+				b = appendMessage(b, 3, func(b []byte) []byte { return appendOption(b, sd.Put) })
+			}
+			return b
+		})
+	}
+	return b
+}
+
+func appendOption(b []byte, od *options.OptionData) []byte {
+	b = appendVarint(b, 1, uint64(od.InstrumentToken))
+	b = appendString(b, 2, od.Tradingsymbol)
+	b = appendString(b, 3, string(od.Type))
+	b = appendDouble(b, 4, od.Strike)
+	b = appendDouble(b, 5, od.LastPrice)
+	b = appendDouble(b, 6, od.BidPrice)
+	b = appendDouble(b, 7, od.AskPrice)
+	b = appendVarint(b, 8, uint64(od.BidQty))
+	b = appendVarint(b, 9, uint64(od.AskQty))
+	b = appendVarint(b, 10, uint64(od.Volume))
+	b = appendVarint(b, 11, uint64(od.OI))
+	b = appendDouble(b, 12, od.PrevClose)
+	b = appendVarint(b, 13, uint64(od.PrevCloseOI))
+	b = appendVarint(b, 14, uint64(od.OpenOI))
+	b = appendString(b, 15, string(od.Buildup))
+	b = appendString(b, 16, string(od.Model))
+	b = appendDouble(b, 17, od.Forward)
+	b = appendDouble(b, 18, od.IV)
+	b = appendDouble(b, 19, od.BidIV)
+	b = appendDouble(b, 20, od.AskIV)
+	b = appendVarint(b, 21, uint64(od.Quality))
+	b = appendDouble(b, 22, od.Delta)
+	b = appendDouble(b, 23, od.Gamma)
+	b = appendDouble(b, 24, od.Theta)
+	b = appendDouble(b, 25, od.Vega)
+	b = appendDouble(b, 26, od.Rho)
+	b = appendDouble(b, 27, od.Vanna)
+	b = appendDouble(b, 28, od.Volga)
+	b = appendDouble(b, 29, od.Charm)
+	b = appendDouble(b, 30, od.Speed)
+	b = appendDouble(b, 31, od.Color)
+	b = appendDouble(b, 32, od.IntrinsicValue)
+	b = appendDouble(b, 33, od.TimeValue)
+	return appendTime(b, 34, od.LastUpdated)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarint(b, num, 1)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendTime appends t as int64 Unix milliseconds
+func appendTime(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	return appendVarint(b, num, uint64(t.UnixNano()/int64(time.Millisecond)))
+}
+
+// appendMessage appends the embedded message that body appends, encoding it
+// in place and shifting it up to fit the length prefix
+func appendMessage(b []byte, num protowire.Number, body func([]byte) []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	start := len(b)
+	b = body(b)
+	n := len(b) - start
+	size := protowire.SizeVarint(uint64(n))
+	b = append(b, make([]byte, size)...)
+	copy(b[start+size:], b[start:start+n])
+	protowire.AppendVarint(b[:start], uint64(n))
+	return b
+}
@@ -12,6 +12,7 @@ type Client struct {
 	conn     *websocket.Conn
 	manager  *ClientManager
 	tokenMap map[uint32]bool // instrument token map
+	channels map[string]bool // JSON channels the client subscribed to
 	mu       sync.Mutex
 }
 
@@ -23,6 +24,12 @@ func (c *Client) unsubscribe(instrumentToken uint32) {
 	delete(c.tokenMap, instrumentToken)
 }
 
+func (c *Client) subscribedTo(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels[channel]
+}
+
 func (c *Client) filterBinaryMsg(msg []byte) []byte {
 	var filteredMsg []byte
 	packets := SplitPackets(msg)
@@ -7,7 +7,7 @@ import (
 
 	"net/http"
 
-	kiteticker "rest-service/internal/ticker"
+	"rest-service/internal/metrics"
 
 	"github.com/gorilla/websocket"
 )
@@ -18,22 +18,40 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// Ticker is the part of the Kite ticker the manager subscribes through when
+// no TokenSubscriber is set
+type Ticker interface {
+	Subscribe(tokens []uint32) error
+	Unsubscribe(tokens []uint32) error
+	SetFullMode(tokens []uint32) error
+}
+
+// TokenSubscriber subscribes tokens on behalf of websocket clients, picking
+// their streaming mode
+type TokenSubscriber interface {
+	View(tokens []uint32) error
+	Unview(tokens []uint32) error
+}
+
 type ClientManager struct {
 	clientList       map[*Client]bool
 	broadcast        chan []byte
+	publish          chan channelPayload
 	register         chan *Client
 	unregister       chan *Client
 	subscribeToken   chan []uint32
 	unsubscribeToken chan []uint32
 	tokenMap         map[uint32]bool
-	ticker           *kiteticker.ExtendedTicker // Dependency
+	ticker           Ticker                     // Dependency
+	subscriber       TokenSubscriber            // Used instead of the ticker when set
 }
 
 // NewClientManager creates a new instance and injects the ticker dependency
-func NewClientManager(t *kiteticker.ExtendedTicker) *ClientManager {
+func NewClientManager(t Ticker) *ClientManager {
 	return &ClientManager{
 		clientList:       make(map[*Client]bool),
 		broadcast:        make(chan []byte),
+		publish:          make(chan channelPayload, 64),
 		register:         make(chan *Client),
 		unregister:       make(chan *Client),
 		subscribeToken:   make(chan []uint32),
@@ -50,11 +68,13 @@ func (m *ClientManager) Start() {
 
 		case client := <-m.register:
 			m.clientList[client] = true
+			metrics.WSClients.Set(float64(len(m.clientList)))
 			log.Printf("New client connected from %s. Total clients: %d", client.conn.RemoteAddr(), len(m.clientList))
 
 		case client := <-m.unregister:
 			if _, ok := m.clientList[client]; ok {
 				delete(m.clientList, client)
+				metrics.WSClients.Set(float64(len(m.clientList)))
 				log.Printf("Client disconnected (%s). Total clients: %d", client.conn.RemoteAddr(), len(m.clientList))
 			}
 
@@ -63,6 +83,7 @@ func (m *ClientManager) Start() {
 				// if len(msg) == 1 {
 				if err := c.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
 					log.Printf("Write error: %v", err)
+					metrics.WSDropped.Inc("write_error")
 					c.conn.Close()
 					m.unregister <- c
 				}
@@ -76,7 +97,27 @@ func (m *ClientManager) Start() {
 
 			}
 
+		case p := <-m.publish:
+			for c := range m.clientList {
+				if !c.subscribedTo(p.channel) {
+					continue
+				}
+				if err := c.conn.WriteMessage(websocket.TextMessage, p.data); err != nil {
+					log.Printf("Write error: %v", err)
+					metrics.WSDropped.Inc("write_error")
+					c.conn.Close()
+					delete(m.clientList, c)
+					metrics.WSClients.Set(float64(len(m.clientList)))
+				}
+			}
+
 		case tokenList := <-m.subscribeToken:
+			if m.subscriber != nil {
+				if err := m.subscriber.View(tokenList); err != nil {
+					log.Println("Err : ", err)
+				}
+				continue
+			}
 
 			if len(tokenList) > 0 {
 
@@ -101,6 +142,12 @@ func (m *ClientManager) Start() {
 				}
 			}
 		case tokenList := <-m.unsubscribeToken:
+			if m.subscriber != nil {
+				if err := m.subscriber.Unview(tokenList); err != nil {
+					log.Println("Err : ", err)
+				}
+				continue
+			}
 			if len(tokenList) > 0 {
 				_tokenList := []uint32{}
 				for _, token := range tokenList {
@@ -121,11 +168,42 @@ func (m *ClientManager) Start() {
 	}
 }
 
+// SetSubscriber routes client subscriptions through s, which keeps a token
+// subscribed while any client or other consumer needs it. Must be called
+// before Start.
+func (m *ClientManager) SetSubscriber(s TokenSubscriber) {
+	m.subscriber = s
+}
+
 // Broadcast sends a message to the broadcast channel
 func (m *ClientManager) Broadcast(msg []byte) {
 	m.broadcast <- msg
 }
 
+// channelPayload is an encoded JSON message for the subscribers of a channel
+type channelPayload struct {
+	channel string
+	data    []byte
+}
+
+// Publish sends v as a JSON text message to clients subscribed to channel.
+// Messages are dropped rather than blocking the publisher when the manager
+// falls behind.
+func (m *ClientManager) Publish(channel string, v interface{}) {
+	data, err := json.Marshal(ChannelMessage{Channel: channel, Data: v})
+	if err != nil {
+		log.Printf("Error encoding %s message: %v", channel, err)
+		return
+	}
+
+	select {
+	case m.publish <- channelPayload{channel: channel, data: data}:
+	default:
+		log.Printf("Dropping %s message, publish queue full", channel)
+		metrics.WSDropped.Inc("queue_full")
+	}
+}
+
 func (m *ClientManager) HandleNewConnection(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -133,11 +211,24 @@ func (m *ClientManager) HandleNewConnection(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	client := Client{conn: conn, manager: m, tokenMap: map[uint32]bool{}}
+	client := Client{conn: conn, manager: m, tokenMap: map[uint32]bool{}, channels: map[string]bool{}}
 
 	defer func() {
 		m.unregister <- &client
 		conn.Close()
+
+		// Release what the client was viewing
+		if m.subscriber != nil {
+			client.mu.Lock()
+			tokens := make([]uint32, 0, len(client.tokenMap))
+			for token := range client.tokenMap {
+				tokens = append(tokens, token)
+			}
+			client.mu.Unlock()
+			if len(tokens) > 0 {
+				m.unsubscribeToken <- tokens
+			}
+		}
 	}()
 
 	m.register <- &client
@@ -163,20 +254,43 @@ func (m *ClientManager) HandleNewConnection(w http.ResponseWriter, r *http.Reque
 		log.Printf("Message received (type: %d): %s", messageType, message)
 		switch data.Type {
 		case "subscribe":
+			// Only tokens new to this client, so views are counted once per client
+			added := make([]uint32, 0, len(data.Val))
 			client.mu.Lock()
 			for _, token := range data.Val {
+				if !client.tokenMap[token] {
+					added = append(added, token)
+				}
 				client.tokenMap[token] = true
 			}
 			client.mu.Unlock()
-			m.subscribeToken <- data.Val
+			m.subscribeToken <- added
 
 		case "unsubscribe":
+			removed := make([]uint32, 0, len(data.Val))
 			client.mu.Lock()
 			for _, token := range data.Val {
+				if client.tokenMap[token] {
+					removed = append(removed, token)
+				}
 				delete(client.tokenMap, token)
 			}
 			client.mu.Unlock()
-			m.unsubscribeToken <- data.Val
+			m.unsubscribeToken <- removed
+
+		case "subscribe_channel":
+			client.mu.Lock()
+			for _, channel := range data.Channels {
+				client.channels[channel] = true
+			}
+			client.mu.Unlock()
+
+		case "unsubscribe_channel":
+			client.mu.Lock()
+			for _, channel := range data.Channels {
+				delete(client.channels, channel)
+			}
+			client.mu.Unlock()
 		default:
 			log.Println("Invalid request type")
 		}
@@ -5,9 +5,17 @@ import (
 )
 
 // payload = { a: "subscribe", v: [[408065]] };
+// channels = { a: "subscribe_channel", c: ["arbitrage"] };
 type Payload struct {
-	Type string   `json:"a"`
-	Val  []uint32 `json:"v"`
+	Type     string   `json:"a"`
+	Val      []uint32 `json:"v"`
+	Channels []string `json:"c,omitempty"`
+}
+
+// ChannelMessage is a JSON text message published on a named channel
+type ChannelMessage struct {
+	Channel string      `json:"channel"`
+	Data    interface{} `json:"data"`
 }
 
 func Int16ToBytes(n int16) []byte {
@@ -3,6 +3,7 @@ package store
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"gokiteconnect-master/models"
 )
@@ -13,6 +14,12 @@ type TickStore struct {
 	ticks map[uint32]*atomic.Value
 }
 
+// entry is a tick with the time it was received
+type entry struct {
+	tick     models.Tick
+	received time.Time
+}
+
 // Global instance
 var GlobalStore = New()
 
@@ -35,9 +42,10 @@ func (s *TickStore) UpdateFromTick(tick models.Tick) {
 	val, ok := s.ticks[tick.InstrumentToken]
 	s.mu.RUnlock()
 
+	e := entry{tick: tick, received: time.Now()}
 	if ok {
 		// Wait-free atomic store
-		val.Store(tick)
+		val.Store(e)
 		return
 	}
 
@@ -52,7 +60,7 @@ func (s *TickStore) UpdateFromTick(tick models.Tick) {
 	s.mu.Unlock()
 
 	// Store value
-	val.Store(tick)
+	val.Store(e)
 }
 
 // Get returns the tick for a given token
@@ -71,7 +79,23 @@ func (s *TickStore) Get(token uint32) (models.Tick, bool) {
 	if x == nil {
 		return models.Tick{}, false
 	}
-	return x.(models.Tick), true
+	return x.(entry).tick, true
+}
+
+// LastUpdate returns when the last tick for a token was received
+func (s *TickStore) LastUpdate(token uint32) (time.Time, bool) {
+	s.mu.RLock()
+	val, ok := s.ticks[token]
+	s.mu.RUnlock()
+
+	if !ok {
+		return time.Time{}, false
+	}
+	x := val.Load()
+	if x == nil {
+		return time.Time{}, false
+	}
+	return x.(entry).received, true
 }
 
 
@@ -91,7 +115,7 @@ func (s *TickStore) GetLTP(token uint32) (float64, bool) {
 	if x == nil {
 		return 0, false
 	}
-	return x.(models.Tick).LastPrice, true
+	return x.(entry).tick.LastPrice, true
 }
 
 // GetAll returns all stored ticks
@@ -103,7 +127,7 @@ func (s *TickStore) GetAll() map[uint32]models.Tick {
 	for token, val := range s.ticks {
 		x := val.Load()
 		if x != nil {
-			result[token] = x.(models.Tick)
+			result[token] = x.(entry).tick
 		}
 	}
 	return result
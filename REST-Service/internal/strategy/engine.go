@@ -0,0 +1,406 @@
+// Package strategy prices multi-leg option, futures and stock positions:
+// payoff at expiry, theoretical P&L before expiry, breakevens, probability of
+// profit and scenario grids.
+package strategy
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"rest-service/internal/calendar"
+	"rest-service/internal/options"
+)
+
+// Leg instrument types besides options.Call and options.Put
+const (
+	Future = "FUT"
+	Stock  = "EQ"
+)
+
+// Leg is one position of a strategy
+type Leg struct {
+	Tradingsymbol string    `json:"tradingsymbol,omitempty"`
+	Type          string    `json:"type"` // "CE", "PE", "FUT" or "EQ"
+	Strike        float64   `json:"strike,omitempty"`
+	Expiry        time.Time `json:"expiry,omitempty"`
+	Quantity      int       `json:"quantity"` // Units, negative for short
+	EntryPrice    float64   `json:"entry_price"`
+	IV            float64   `json:"iv,omitempty"` // Options only, annual
+}
+
+// Params controls the analysis
+type Params struct {
+	Spot          float64
+	RiskFreeRate  float64
+	DividendYield float64               // Continuous, annual
+	Model         options.PricingModel  // Option pricing model, default BSM
+	Carry         map[time.Time]float64 // Expiry -> annual carry of the forward over spot, default r - q
+	Now           time.Time
+	Calendar      *calendar.Calendar
+	PriceMin      float64 // Spot range of the curves
+	PriceMax      float64
+	Steps         int
+	Horizons      []int     // Days from now of the T+n curves
+	SpotShocks    []float64 // Grid: relative spot moves, e.g. -0.05
+	IVShocks      []float64 // Grid: absolute IV changes, e.g. 0.02 for +2 vol points
+	DayShocks     []int     // Grid: days from now
+}
+
+// Point is the P&L of the strategy at one spot price
+type Point struct {
+	Spot float64 `json:"spot"`
+	PnL  float64 `json:"pnl"`
+}
+
+// Curve is the P&L across spot prices at one horizon
+type Curve struct {
+	Days   int     `json:"days"`
+	Date   string  `json:"date"`
+	Points []Point `json:"points"`
+}
+
+// GridCell is the P&L under one spot, IV and time shock
+type GridCell struct {
+	SpotShock float64 `json:"spot_shock"`
+	IVShock   float64 `json:"iv_shock"`
+	Days      int     `json:"days"`
+	Spot      float64 `json:"spot"`
+	PnL       float64 `json:"pnl"`
+}
+
+// Result is the analysis of a strategy
+type Result struct {
+	Legs                []Leg      `json:"legs"`
+	Spot                float64    `json:"spot"`
+	Expiry              time.Time  `json:"expiry"`      // Earliest option/future expiry, where the payoff is evaluated
+	NetPremium          float64    `json:"net_premium"` // Paid (positive) or received (negative) for all legs
+	Payoff              []Point    `json:"payoff"`      // At expiry
+	Curves              []Curve    `json:"curves"`      // T+n theoretical P&L
+	Breakevens          []float64  `json:"breakevens"`
+	MaxProfit           float64    `json:"max_profit"`
+	MaxLoss             float64    `json:"max_loss"` // Negative for a loss
+	MaxProfitUnbounded  bool       `json:"max_profit_unbounded"`
+	MaxLossUnbounded    bool       `json:"max_loss_unbounded"`
+	ProbabilityOfProfit float64    `json:"probability_of_profit"`
+	Volatility          float64    `json:"volatility"` // Used for the probability of profit
+	Grid                []GridCell `json:"grid,omitempty"`
+}
+
+// ErrNoLegs is returned when a strategy has no legs
+var ErrNoLegs = errors.New("strategy has no legs")
+
+// Analyze computes the payoff, P&L curves, breakevens, max profit/loss,
+// probability of profit and the scenario grid of a set of legs
+func Analyze(legs []Leg, p Params) (*Result, error) {
+	if len(legs) == 0 {
+		return nil, ErrNoLegs
+	}
+	if p.Spot <= 0 {
+		return nil, errors.New("spot price must be positive")
+	}
+	if p.Calendar == nil {
+		p.Calendar = calendar.Default()
+	}
+	if p.Steps < 2 {
+		p.Steps = 201
+	}
+	if p.PriceMin <= 0 || p.PriceMax <= p.PriceMin {
+		p.PriceMin, p.PriceMax = p.Spot*0.8, p.Spot*1.2
+	}
+
+	e := &engine{legs: legs, p: p, gc: options.NewGreeksCalculatorWithParams(options.PricingParams{
+		Model:         p.Model,
+		RiskFreeRate:  p.RiskFreeRate,
+		DividendYield: p.DividendYield,
+	})}
+	e.expiry = e.evaluationExpiry()
+
+	result := &Result{
+		Legs:   legs,
+		Spot:   p.Spot,
+		Expiry: e.expiry,
+	}
+	for _, leg := range legs {
+		result.NetPremium += leg.EntryPrice * float64(leg.Quantity)
+	}
+
+	// Payoff at the earliest expiry; later legs keep their remaining time value
+	expiryTime := p.Now
+	if !e.expiry.IsZero() {
+		expiryTime = p.Calendar.ExpiryTime(e.expiry)
+	}
+	result.Payoff = e.curve(expiryTime, 0)
+
+	for _, days := range p.Horizons {
+		at := p.Now.AddDate(0, 0, days)
+		if at.After(expiryTime) {
+			at = expiryTime
+		}
+		result.Curves = append(result.Curves, Curve{
+			Days:   days,
+			Date:   at.In(calendar.IST).Format("2006-01-02"),
+			Points: e.curve(at, 0),
+		})
+	}
+
+	result.Breakevens = e.breakevens(expiryTime)
+	e.extremes(result, expiryTime)
+
+	result.Volatility = e.volatility()
+	T := p.Calendar.TimeToExpiry(p.Now, e.expiry)
+	result.ProbabilityOfProfit = e.probabilityOfProfit(result.Breakevens, result.Volatility, T)
+
+	for _, days := range p.DayShocks {
+		at := p.Now.AddDate(0, 0, days)
+		for _, ds := range p.SpotShocks {
+			for _, dv := range p.IVShocks {
+				spot := p.Spot * (1 + ds)
+				result.Grid = append(result.Grid, GridCell{
+					SpotShock: ds,
+					IVShock:   dv,
+					Days:      days,
+					Spot:      spot,
+					PnL:       e.pnl(spot, at, dv),
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+type engine struct {
+	legs   []Leg
+	p      Params
+	gc     *options.GreeksCalculator
+	expiry time.Time
+}
+
+// evaluationExpiry returns the earliest expiry of the option and future legs
+func (e *engine) evaluationExpiry() time.Time {
+	var earliest time.Time
+	for _, leg := range e.legs {
+		if leg.Type == Stock || leg.Expiry.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || leg.Expiry.Before(earliest) {
+			earliest = leg.Expiry
+		}
+	}
+	return earliest
+}
+
+// value returns the theoretical value of one unit of a leg
+func (e *engine) value(leg Leg, spot float64, at time.Time, ivShock float64) float64 {
+	switch leg.Type {
+	case Stock:
+		return spot
+	case Future:
+		T := e.p.Calendar.TimeToExpiry(at, leg.Expiry)
+		return spot * math.Exp(e.carry(leg.Expiry)*T)
+	default:
+		T := e.p.Calendar.TimeToExpiry(at, leg.Expiry)
+		iv := math.Max(0, leg.IV+ivShock)
+		if e.gc.Model().UsesForward() {
+			// Black-76 prices off the forward, carried from the spot as the live forward was
+			spot *= math.Exp(e.carry(leg.Expiry) * T)
+		}
+		return e.gc.Price(options.OptionType(leg.Type), spot, leg.Strike, T, iv)
+	}
+}
+
+// carry returns the annual carry of an expiry's forward over the spot
+func (e *engine) carry(expiry time.Time) float64 {
+	if carry, ok := e.p.Carry[expiry]; ok {
+		return carry
+	}
+	return e.p.RiskFreeRate - e.p.DividendYield
+}
+
+// pnl returns the strategy P&L at a spot price and time
+func (e *engine) pnl(spot float64, at time.Time, ivShock float64) float64 {
+	total := 0.0
+	for _, leg := range e.legs {
+		total += (e.value(leg, spot, at, ivShock) - leg.EntryPrice) * float64(leg.Quantity)
+	}
+	return total
+}
+
+// curve evaluates the P&L across the price range
+func (e *engine) curve(at time.Time, ivShock float64) []Point {
+	points := make([]Point, e.p.Steps)
+	step := (e.p.PriceMax - e.p.PriceMin) / float64(e.p.Steps-1)
+	for i := range points {
+		spot := e.p.PriceMin + step*float64(i)
+		points[i] = Point{Spot: spot, PnL: e.pnl(spot, at, ivShock)}
+	}
+	return points
+}
+
+// extremes sets max profit and loss over [0, ∞) at expiry. The payoff is
+// piecewise linear in spot, so the extremes lie at 0, a strike or the tails.
+func (e *engine) extremes(result *Result, expiryTime time.Time) {
+	candidates := []float64{0, e.p.PriceMin, e.p.PriceMax}
+	for _, leg := range e.legs {
+		if leg.Strike > 0 {
+			candidates = append(candidates, leg.Strike)
+		}
+	}
+
+	result.MaxProfit, result.MaxLoss = math.Inf(-1), math.Inf(1)
+	for _, spot := range candidates {
+		pnl := e.pnl(spot, expiryTime, 0)
+		result.MaxProfit = math.Max(result.MaxProfit, pnl)
+		result.MaxLoss = math.Min(result.MaxLoss, pnl)
+	}
+
+	// Slope beyond the highest strike decides whether the upside is bounded
+	high := e.p.PriceMax
+	for _, spot := range candidates {
+		high = math.Max(high, spot)
+	}
+	slope := e.pnl(high*2, expiryTime, 0) - e.pnl(high*2-1, expiryTime, 0)
+	result.MaxProfitUnbounded = slope > 1e-6
+	result.MaxLossUnbounded = slope < -1e-6
+}
+
+// volatility returns the quantity-weighted average IV of the option legs
+func (e *engine) volatility() float64 {
+	sum, weight := 0.0, 0.0
+	for _, leg := range e.legs {
+		if leg.IV > 0 {
+			w := math.Abs(float64(leg.Quantity))
+			sum += leg.IV * w
+			weight += w
+		}
+	}
+	if weight == 0 {
+		return 0
+	}
+	return sum / weight
+}
+
+// probabilityOfProfit integrates a lognormal terminal distribution with the
+// given volatility over the spot ranges where the expiry payoff is positive
+func (e *engine) probabilityOfProfit(breakevens []float64, vol, T float64) float64 {
+	if vol <= 0 || T <= 0 {
+		return 0
+	}
+
+	cdf := func(x float64) float64 {
+		if x <= 0 {
+			return 0
+		}
+		if math.IsInf(x, 1) {
+			return 1
+		}
+		z := (math.Log(x/e.p.Spot) - (e.p.RiskFreeRate-0.5*vol*vol)*T) / (vol * math.Sqrt(T))
+		return 0.5 * math.Erfc(-z/math.Sqrt2)
+	}
+
+	bounds := append([]float64{0}, breakevens...)
+	bounds = append(bounds, math.Inf(1))
+	expiryTime := e.p.Calendar.ExpiryTime(e.expiry)
+
+	pop := 0.0
+	for i := 0; i+1 < len(bounds); i++ {
+		lo, hi := bounds[i], bounds[i+1]
+		mid := (lo + hi) / 2
+		if math.IsInf(hi, 1) {
+			mid = lo*1.5 + 1
+		}
+		if e.pnl(mid, expiryTime, 0) > 0 {
+			pop += cdf(hi) - cdf(lo)
+		}
+	}
+	return pop
+}
+
+// breakevens returns the spots over (0, ∞) where the P&L at expiry crosses
+// zero, whatever the price range of the curves. Legs expiring then make the
+// P&L linear between their strikes and beyond the highest, so each section
+// is solved exactly; options expiring later keep time value and bend their
+// sections, which are then searched for sign changes and bisected.
+func (e *engine) breakevens(expiryTime time.Time) []float64 {
+	pnl := func(spot float64) float64 { return e.pnl(spot, expiryTime, 0) }
+
+	knots := []float64{0}
+	bent := false
+	for _, leg := range e.legs {
+		if leg.Type == Stock || leg.Type == Future {
+			continue
+		}
+		knots = append(knots, leg.Strike)
+		bent = bent || leg.Expiry.After(e.expiry)
+	}
+	sort.Float64s(knots)
+	top := math.Max(knots[len(knots)-1], e.p.Spot)
+
+	pieces := 1
+	if bent {
+		pieces = breakevenPieces
+		// The tail has no last kink; follow it until the time value fades
+		for end := top * 2; end <= top*breakevenTailFactor; end *= 2 {
+			knots = append(knots, end)
+		}
+	}
+
+	result := make([]float64, 0)
+	add := func(spot float64) {
+		if spot > 0 && (len(result) == 0 || spot-result[len(result)-1] > 1e-9) {
+			result = append(result, spot)
+		}
+	}
+	for i := 1; i < len(knots); i++ {
+		step := (knots[i] - knots[i-1]) / float64(pieces)
+		for j := 0; j < pieces; j++ {
+			lo, hi := knots[i-1]+step*float64(j), knots[i-1]+step*float64(j+1)
+			if root, ok := crossing(pnl, lo, hi, bent); ok {
+				add(root)
+			}
+		}
+	}
+
+	// Beyond the last knot the P&L is taken as linear
+	last := knots[len(knots)-1]
+	at := pnl(last)
+	slope := pnl(last+1) - at
+	if at == 0 {
+		add(last)
+	} else if slope != 0 && -at/slope > 0 {
+		add(last - at/slope)
+	}
+	return result
+}
+
+// Sections of bent P&L are split into breakevenPieces and its tail followed
+// to breakevenTailFactor times the highest strike or spot
+const (
+	breakevenPieces     = 64
+	breakevenTailFactor = 16
+)
+
+// crossing returns where f crosses zero in [lo, hi), solving linear f
+// exactly and bisecting otherwise
+func crossing(f func(float64) float64, lo, hi float64, bent bool) (float64, bool) {
+	a, b := f(lo), f(hi)
+	switch {
+	case a == 0:
+		return lo, true
+	case (a < 0) == (b < 0) || b == 0:
+		return 0, false
+	case !bent:
+		return lo + (hi-lo)*(-a)/(b-a), true
+	}
+	for i := 0; i < 100 && hi-lo > 1e-9; i++ {
+		mid := (lo + hi) / 2
+		if m := f(mid); (m < 0) == (a < 0) {
+			lo, a = mid, m
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
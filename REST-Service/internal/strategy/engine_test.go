@@ -0,0 +1,145 @@
+package strategy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rest-service/internal/calendar"
+	"rest-service/internal/options"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeLongStraddle(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, calendar.IST)
+	expiry := time.Date(2025, 12, 30, 0, 0, 0, 0, calendar.IST)
+	legs := []Leg{
+		{Type: "CE", Strike: 100, Expiry: expiry, Quantity: 1, EntryPrice: 5, IV: 0.2},
+		{Type: "PE", Strike: 100, Expiry: expiry, Quantity: 1, EntryPrice: 5, IV: 0.2},
+	}
+
+	r, err := Analyze(legs, Params{Spot: 100, RiskFreeRate: 0.06, Now: now, Steps: 401})
+	require.NoError(t, err)
+	require.Equal(t, 10.0, r.NetPremium)
+	require.Len(t, r.Breakevens, 2)
+	require.InDelta(t, 90, r.Breakevens[0], 1e-9)
+	require.InDelta(t, 110, r.Breakevens[1], 1e-9)
+	require.True(t, r.MaxProfitUnbounded)
+	require.False(t, r.MaxLossUnbounded)
+	require.InDelta(t, -10, r.MaxLoss, 1e-9)
+	require.Greater(t, r.ProbabilityOfProfit, 0.0)
+	require.Less(t, r.ProbabilityOfProfit, 0.5)
+}
+
+func TestAnalyzeBullCallSpread(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, calendar.IST)
+	expiry := time.Date(2025, 12, 30, 0, 0, 0, 0, calendar.IST)
+	legs := []Leg{
+		{Type: "CE", Strike: 100, Expiry: expiry, Quantity: 50, EntryPrice: 4, IV: 0.2},
+		{Type: "CE", Strike: 110, Expiry: expiry, Quantity: -50, EntryPrice: 1, IV: 0.2},
+	}
+
+	r, err := Analyze(legs, Params{
+		Spot:       100,
+		Now:        now,
+		SpotShocks: []float64{-0.1, 0, 0.1},
+		IVShocks:   []float64{0},
+		DayShocks:  []int{0, 7},
+	})
+	require.NoError(t, err)
+	require.InDelta(t, 350, r.MaxProfit, 1e-9)
+	require.InDelta(t, -150, r.MaxLoss, 1e-9)
+	require.False(t, r.MaxProfitUnbounded)
+	require.False(t, r.MaxLossUnbounded)
+	require.Len(t, r.Breakevens, 1)
+	require.InDelta(t, 103, r.Breakevens[0], 1e-9)
+	require.Len(t, r.Grid, 6)
+
+	_, err = Analyze(nil, Params{Spot: 100})
+	require.Equal(t, ErrNoLegs, err)
+}
+
+func TestAnalyzeBreakevensOutsideRange(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, calendar.IST)
+	expiry := time.Date(2025, 12, 30, 0, 0, 0, 0, calendar.IST)
+	legs := []Leg{
+		{Type: "PE", Strike: 85, Expiry: expiry, Quantity: -1, EntryPrice: 12, IV: 0.2},
+		{Type: "CE", Strike: 115, Expiry: expiry, Quantity: -1, EntryPrice: 12, IV: 0.2},
+	}
+
+	// The curves only cover 80 to 120, the breakevens are at 61 and 139
+	r, err := Analyze(legs, Params{Spot: 100, RiskFreeRate: 0.06, Now: now})
+	require.NoError(t, err)
+	require.Len(t, r.Breakevens, 2)
+	require.InDelta(t, 61, r.Breakevens[0], 1e-9)
+	require.InDelta(t, 139, r.Breakevens[1], 1e-9)
+	require.True(t, r.MaxLossUnbounded)
+	require.Greater(t, r.ProbabilityOfProfit, 0.99)
+}
+
+func TestAnalyzeCalendarBreakevens(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, calendar.IST)
+	near := time.Date(2025, 12, 30, 0, 0, 0, 0, calendar.IST)
+	far := time.Date(2026, 1, 27, 0, 0, 0, 0, calendar.IST)
+	legs := []Leg{
+		{Type: "CE", Strike: 100, Expiry: near, Quantity: -1, EntryPrice: 2.5, IV: 0.2},
+		{Type: "CE", Strike: 100, Expiry: far, Quantity: 1, EntryPrice: 4, IV: 0.2},
+	}
+
+	// The far call keeps time value at the near expiry, so the P&L is
+	// curved and the breakevens are bisected
+	r, err := Analyze(legs, Params{Spot: 100, Now: now})
+	require.NoError(t, err)
+	require.Len(t, r.Breakevens, 2)
+	require.Less(t, r.Breakevens[0], 100.0)
+	require.Greater(t, r.Breakevens[1], 100.0)
+	e := &engine{legs: legs, p: Params{Calendar: calendar.Default()}, gc: options.NewGreeksCalculator(0), expiry: near}
+	for _, spot := range r.Breakevens {
+		require.InDelta(t, 0, e.pnl(spot, calendar.Default().ExpiryTime(near), 0), 1e-6)
+	}
+}
+
+func TestAnalyzeForwardModel(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, calendar.IST)
+	expiry := time.Date(2025, 12, 30, 0, 0, 0, 0, calendar.IST)
+	legs := []Leg{{Type: "CE", Strike: 100, Expiry: expiry, Quantity: 1, EntryPrice: 3, IV: 0.2}}
+	p := Params{Spot: 100, RiskFreeRate: 0.06, Now: now, Horizons: []int{0}}
+
+	bsm, err := Analyze(legs, p)
+	require.NoError(t, err)
+
+	// Black-76 on a forward carried at r - q matches BSM with that dividend yield
+	p.Model = options.ModelBlack76
+	p.DividendYield = 0.02
+	black76, err := Analyze(legs, p)
+	require.NoError(t, err)
+	p.Model = options.ModelBSM
+	dividend, err := Analyze(legs, p)
+	require.NoError(t, err)
+	require.InDelta(t, dividend.Curves[0].Points[100].PnL, black76.Curves[0].Points[100].PnL, 1e-9)
+	require.Less(t, black76.Curves[0].Points[100].PnL, bsm.Curves[0].Points[100].PnL)
+
+	// A live forward's carry overrides r - q
+	p.Model = options.ModelBlack76
+	p.Carry = map[time.Time]float64{expiry: 0.1}
+	carried, err := Analyze(legs, p)
+	require.NoError(t, err)
+	require.Greater(t, carried.Curves[0].Points[100].PnL, bsm.Curves[0].Points[100].PnL)
+}
+
+func TestRequestLimits(t *testing.T) {
+	r := &Resolver{}
+	legs := []LegRequest{{Type: "CE", Strike: 100, Expiry: "2025-12-30", Quantity: 1}}
+
+	for name, req := range map[string]Request{
+		"steps":  {Legs: legs, Steps: MaxSteps + 1},
+		"days":   {Legs: legs, Days: make([]int, MaxHorizons+1)},
+		"shocks": {Legs: legs, SpotShocks: make([]float64, MaxShocks+1)},
+		"grid":   {Legs: legs, SpotShocks: make([]float64, MaxShocks), IVShocks: make([]float64, MaxShocks), DayShocks: make([]int, 2)},
+		"legs":   {Legs: make([]LegRequest, MaxLegs+1)},
+	} {
+		_, err := r.Analyze(req, time.Now())
+		require.True(t, errors.Is(err, ErrLimit), name)
+	}
+}
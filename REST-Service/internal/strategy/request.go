@@ -0,0 +1,322 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"rest-service/internal/calendar"
+	"rest-service/internal/options"
+	"rest-service/internal/store"
+)
+
+// LegRequest describes a leg either by instrument token or by contract terms.
+// Entry price and IV default to the live mid price and scanner IV.
+type LegRequest struct {
+	InstrumentToken uint32  `json:"instrument_token,omitempty"`
+	Type            string  `json:"type,omitempty"`   // "CE", "PE", "FUT" or "EQ"
+	Strike          float64 `json:"strike,omitempty"` // Options
+	Expiry          string  `json:"expiry,omitempty"` // "YYYY-MM-DD", options and futures
+	Quantity        int     `json:"quantity" binding:"required"`
+	EntryPrice      float64 `json:"entry_price,omitempty"`
+	IV              float64 `json:"iv,omitempty"`
+}
+
+// Request is the body of POST /strategy/analyze
+type Request struct {
+	Underlying   string       `json:"underlying" binding:"required"`
+	Legs         []LegRequest `json:"legs" binding:"required"`
+	Spot         float64      `json:"spot,omitempty"`           // Defaults to the live spot
+	RiskFreeRate *float64     `json:"risk_free_rate,omitempty"` // Defaults to the underlying's configured rate
+	RangePct     float64      `json:"range_pct,omitempty"`      // Curve range around spot, default 0.2
+	Steps        int          `json:"steps,omitempty"`          // Curve points, default 201
+	Days         []int        `json:"days,omitempty"`           // T+n curves, default today
+	SpotShocks   []float64    `json:"spot_shocks,omitempty"`    // Grid, default -10% to +10%
+	IVShocks     []float64    `json:"iv_shocks,omitempty"`      // Grid, default -5 to +5 vol points
+	DayShocks    []int        `json:"day_shocks,omitempty"`     // Grid, default today and 1 week
+}
+
+// Limits on the size of a request, which bound the work of one analysis
+const (
+	MaxLegs     = 20
+	MaxSteps    = 2001
+	MaxHorizons = 10
+	MaxShocks   = 50 // Per grid axis
+	MaxGrid     = 2500
+)
+
+// ErrLimit is returned when a request exceeds one of the size limits
+var ErrLimit = errors.New("request exceeds limit")
+
+// Resolver fills in strategy legs from the scanner's instruments and live data
+type Resolver struct {
+	Scanner    *options.Scanner
+	Calculator *options.Calculator // Pricing of each underlying, BSM at the default rate when nil
+	Calendar   *calendar.Calendar
+}
+
+// Analyze resolves the request's legs and spot and runs the analysis
+func (r *Resolver) Analyze(req Request, now time.Time) (*Result, error) {
+	if len(req.Legs) == 0 {
+		return nil, ErrNoLegs
+	}
+	if err := req.checkLimits(); err != nil {
+		return nil, err
+	}
+
+	legs := make([]Leg, 0, len(req.Legs))
+	for i, lr := range req.Legs {
+		leg, err := r.resolveLeg(req.Underlying, lr)
+		if err != nil {
+			return nil, fmt.Errorf("leg %d: %w", i, err)
+		}
+		legs = append(legs, leg)
+	}
+
+	spot := req.Spot
+	if spot <= 0 {
+		var ok bool
+		if spot, ok = r.spot(req.Underlying); !ok {
+			return nil, fmt.Errorf("no live spot price for %s, pass spot", req.Underlying)
+		}
+	}
+
+	pricing := options.PricingParams{Model: options.ModelBSM, RiskFreeRate: options.DefaultRiskFreeRate}
+	if r.Calculator != nil {
+		pricing = r.Calculator.PricingFor(req.Underlying)
+	}
+	if req.RiskFreeRate != nil {
+		pricing.RiskFreeRate = *req.RiskFreeRate
+	}
+	rangePct := req.RangePct
+	if rangePct <= 0 {
+		rangePct = 0.2
+	}
+
+	params := Params{
+		Spot:          spot,
+		RiskFreeRate:  pricing.RiskFreeRate,
+		DividendYield: pricing.DividendYield,
+		Model:         pricing.Model,
+		Now:           now,
+		Calendar:      r.Calendar,
+		PriceMin:      spot * (1 - rangePct),
+		PriceMax:      spot * (1 + rangePct),
+		Steps:         req.Steps,
+		Horizons:      req.Days,
+		SpotShocks:    req.SpotShocks,
+		IVShocks:      req.IVShocks,
+		DayShocks:     req.DayShocks,
+	}
+	if pricing.Model.UsesForward() {
+		params.Carry = r.carry(req.Underlying, legs, now)
+	}
+	if len(params.Horizons) == 0 {
+		params.Horizons = []int{0}
+	}
+	if len(params.SpotShocks) == 0 {
+		params.SpotShocks = []float64{-0.1, -0.05, -0.02, 0, 0.02, 0.05, 0.1}
+	}
+	if len(params.IVShocks) == 0 {
+		params.IVShocks = []float64{-0.05, 0, 0.05}
+	}
+	if len(params.DayShocks) == 0 {
+		params.DayShocks = []int{0, 7}
+	}
+
+	return Analyze(legs, params)
+}
+
+// checkLimits rejects requests whose curves or grid are too large to compute
+func (req Request) checkLimits() error {
+	grid := 1
+	for _, axis := range []struct {
+		name string
+		n    int
+	}{
+		{"spot_shocks", len(req.SpotShocks)},
+		{"iv_shocks", len(req.IVShocks)},
+		{"day_shocks", len(req.DayShocks)},
+	} {
+		if axis.n > MaxShocks {
+			return fmt.Errorf("%w: %d %s (max %d)", ErrLimit, axis.n, axis.name, MaxShocks)
+		}
+		if axis.n > 0 {
+			grid *= axis.n
+		}
+	}
+
+	switch {
+	case len(req.Legs) > MaxLegs:
+		return fmt.Errorf("%w: %d legs (max %d)", ErrLimit, len(req.Legs), MaxLegs)
+	case req.Steps > MaxSteps:
+		return fmt.Errorf("%w: %d steps (max %d)", ErrLimit, req.Steps, MaxSteps)
+	case len(req.Days) > MaxHorizons:
+		return fmt.Errorf("%w: %d days (max %d)", ErrLimit, len(req.Days), MaxHorizons)
+	case grid > MaxGrid:
+		return fmt.Errorf("%w: %d grid cells (max %d)", ErrLimit, grid, MaxGrid)
+	}
+	return nil
+}
+
+// carry returns the annual carry over the live spot of the forward each option
+// expiry was priced off, for models that price off a forward
+func (r *Resolver) carry(underlying string, legs []Leg, now time.Time) map[time.Time]float64 {
+	spot, ok := r.spot(underlying)
+	if !ok {
+		return nil
+	}
+	cal := r.Calendar
+	if cal == nil {
+		cal = calendar.Default()
+	}
+
+	carry := make(map[time.Time]float64)
+	for _, leg := range legs {
+		if leg.Type != string(options.Call) && leg.Type != string(options.Put) {
+			continue
+		}
+		if _, ok := carry[leg.Expiry]; ok {
+			continue
+		}
+		live, ok := r.Scanner.GetOptionChain(underlying, leg.Expiry)
+		if !ok {
+			continue
+		}
+		strike, ok := live.Snapshot().Strikes[leg.Strike]
+		if !ok {
+			continue
+		}
+		od := strike.Call
+		if leg.Type == string(options.Put) {
+			od = strike.Put
+		}
+		T := cal.TimeToExpiry(now, leg.Expiry)
+		// Options that fell back to the spot keep the default carry
+		if od == nil || !od.Model.UsesForward() || od.Forward <= 0 || T <= 0 {
+			continue
+		}
+		carry[leg.Expiry] = math.Log(od.Forward/spot) / T
+	}
+	return carry
+}
+
+// resolveLeg finds the leg's instrument and fills in entry price and IV
+func (r *Resolver) resolveLeg(underlying string, lr LegRequest) (Leg, error) {
+	if lr.Quantity == 0 {
+		return Leg{}, fmt.Errorf("quantity cannot be zero")
+	}
+
+	leg := Leg{
+		Type:       lr.Type,
+		Strike:     lr.Strike,
+		Quantity:   lr.Quantity,
+		EntryPrice: lr.EntryPrice,
+		IV:         lr.IV,
+	}
+	if lr.Expiry != "" {
+		expiry, err := time.ParseInLocation("2006-01-02", lr.Expiry, calendar.IST)
+		if err != nil {
+			return Leg{}, fmt.Errorf("invalid expiry %q (must be YYYY-MM-DD)", lr.Expiry)
+		}
+		leg.Expiry = expiry
+	}
+
+	token := lr.InstrumentToken
+	if token != 0 {
+		inst, ok := r.Scanner.GetInstrument(token)
+		if !ok {
+			return Leg{}, fmt.Errorf("unknown instrument token %d", token)
+		}
+		leg.Tradingsymbol = inst.Tradingsymbol
+		leg.Type = string(inst.InstrumentType)
+		leg.Strike = inst.StrikePrice
+		leg.Expiry = inst.Expiry
+	}
+
+	switch leg.Type {
+	case string(options.Call), string(options.Put):
+		return r.resolveOption(underlying, leg)
+	case Future:
+		if leg.Expiry.IsZero() {
+			return Leg{}, fmt.Errorf("future leg needs an expiry")
+		}
+		if token == 0 {
+			var ok bool
+			if token, ok = r.Scanner.GetFutureToken(underlying, leg.Expiry); !ok {
+				return Leg{}, fmt.Errorf("no %s future expiring %s", underlying, lr.Expiry)
+			}
+		}
+		if leg.EntryPrice <= 0 {
+			price, ok := store.GlobalStore.GetLTP(token)
+			if !ok || price <= 0 {
+				return Leg{}, fmt.Errorf("no live price for future %d, pass entry_price", token)
+			}
+			leg.EntryPrice = price
+		}
+		return leg, nil
+	case Stock:
+		if leg.EntryPrice <= 0 {
+			price, ok := r.spot(underlying)
+			if !ok {
+				return Leg{}, fmt.Errorf("no live price for %s, pass entry_price", underlying)
+			}
+			leg.EntryPrice = price
+		}
+		leg.Expiry = time.Time{}
+		return leg, nil
+	default:
+		return Leg{}, fmt.Errorf("unknown leg type %q (must be CE, PE, FUT or EQ)", leg.Type)
+	}
+}
+
+// resolveOption fills in an option leg from its chain
+func (r *Resolver) resolveOption(underlying string, leg Leg) (Leg, error) {
+	if leg.Expiry.IsZero() || leg.Strike <= 0 {
+		return Leg{}, fmt.Errorf("option leg needs a strike and expiry")
+	}
+
+	live, ok := r.Scanner.GetOptionChain(underlying, leg.Expiry)
+	if !ok {
+		return Leg{}, fmt.Errorf("no %s option chain expiring %s", underlying, leg.Expiry.Format("2006-01-02"))
+	}
+	chain := live.Snapshot()
+	strike, ok := chain.Strikes[leg.Strike]
+	if !ok {
+		return Leg{}, fmt.Errorf("no %s strike %.2f", underlying, leg.Strike)
+	}
+
+	od := strike.Call
+	if leg.Type == string(options.Put) {
+		od = strike.Put
+	}
+	if od == nil {
+		return Leg{}, fmt.Errorf("no %s %.2f %s", underlying, leg.Strike, leg.Type)
+	}
+
+	leg.Tradingsymbol = od.Tradingsymbol
+	if leg.EntryPrice <= 0 {
+		leg.EntryPrice = od.MidPrice()
+	}
+	if leg.IV <= 0 {
+		leg.IV = od.IV
+	}
+	if leg.EntryPrice <= 0 {
+		return Leg{}, fmt.Errorf("no live price for %s, pass entry_price", od.Tradingsymbol)
+	}
+	if leg.IV <= 0 {
+		return Leg{}, fmt.Errorf("no IV for %s, pass iv", od.Tradingsymbol)
+	}
+	return leg, nil
+}
+
+// spot returns the live spot of an underlying
+func (r *Resolver) spot(underlying string) (float64, bool) {
+	token, ok := r.Scanner.GetSpotToken(underlying)
+	if !ok {
+		return 0, false
+	}
+	price, ok := store.GlobalStore.GetLTP(token)
+	return price, ok && price > 0
+}
@@ -0,0 +1,458 @@
+// Package subscription keeps the ticker subscribed to the tokens the
+// configured underlyings need, applying only the difference when the
+// configuration changes, and picks each token's streaming mode.
+package subscription
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"rest-service/internal/options"
+	"rest-service/internal/store"
+	kiteticker "rest-service/internal/ticker"
+)
+
+// Ticker is the part of the Kite ticker the manager drives
+type Ticker interface {
+	Subscribe(tokens []uint32) error
+	Unsubscribe(tokens []uint32) error
+	SetMode(mode kiteticker.Mode, tokens []uint32) error
+}
+
+// Delta is the result of applying a token set
+type Delta struct {
+	Subscribed   []uint32 `json:"subscribed"`
+	Unsubscribed []uint32 `json:"unsubscribed"`
+	Total        int      `json:"total"` // Tokens subscribed after the change
+}
+
+// PriceFunc returns the live price of an underlying
+type PriceFunc func(underlying string) (float64, bool)
+
+// Manager tracks the subscribed tokens and applies changes in batches. Criteria
+// with a strike window are re-centred on the underlying as it moves. A token
+// stays subscribed while the underlyings, a pin or a websocket view need it.
+type Manager struct {
+	ticker     Ticker
+	scanner    *options.Scanner
+	price      PriceFunc
+	batchSize  int
+	batchDelay time.Duration
+
+	criteria []options.FilterCriteria // Last applied, windows carrying their centre
+	tokens   map[uint32]bool          // Subscribed for the underlyings
+	pinned   map[uint32]bool          // Subscribed for other consumers, never dropped
+	viewers  map[uint32]int           // Websocket clients viewing each token
+
+	rules       ModeRules
+	positions   map[uint32]bool
+	modes       map[uint32]TokenMode // Mode each subscribed token streams in
+	modeCenters map[string]float64   // Underlying price the option modes were picked at
+
+	pending []tickerCall // Ticker calls waiting to be sent, in order
+	sending bool         // Whether a goroutine is sending the pending calls
+
+	mu      sync.Mutex
+	applyMu sync.Mutex // Serializes Apply and Recenter
+}
+
+// tickerCall is a ticker call worked out under the lock and sent after it is
+// released
+type tickerCall struct {
+	tokens []uint32
+	send   func([]uint32) error
+	action string
+}
+
+// NewManager creates a subscription manager
+func NewManager(ticker Ticker, scanner *options.Scanner, batchSize int, batchDelay time.Duration) *Manager {
+	m := &Manager{
+		ticker:      ticker,
+		scanner:     scanner,
+		tokens:      make(map[uint32]bool),
+		pinned:      make(map[uint32]bool),
+		viewers:     make(map[uint32]int),
+		positions:   make(map[uint32]bool),
+		modes:       make(map[uint32]TokenMode),
+		modeCenters: make(map[string]float64),
+	}
+	m.price = m.spotPrice
+	m.SetBatching(batchSize, batchDelay)
+	return m
+}
+
+// SetBatching changes the batch size and delay between batches
+func (m *Manager) SetBatching(batchSize int, batchDelay time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	m.batchSize = batchSize
+	m.batchDelay = batchDelay
+}
+
+// SetPriceFunc replaces the underlying price source, the spot LTP by default
+func (m *Manager) SetPriceFunc(f PriceFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.price = f
+}
+
+// spotPrice returns the last traded price of an underlying's spot
+func (m *Manager) spotPrice(underlying string) (float64, bool) {
+	token, ok := m.scanner.GetSpotToken(underlying)
+	if !ok {
+		return 0, false
+	}
+	price, ok := store.GlobalStore.GetLTP(token)
+	return price, ok && price > 0
+}
+
+// Tokens returns the options, spot and futures tokens the criteria need
+func (m *Manager) Tokens(criteria []options.FilterCriteria) map[uint32]bool {
+	tokens := make(map[uint32]bool)
+	for _, c := range criteria {
+		for token := range m.scanner.FilterOptions(c) {
+			tokens[token] = true
+		}
+		// Spot and futures price the options and the futures curve
+		if token, ok := m.scanner.GetSpotToken(c.Underlying); ok {
+			tokens[token] = true
+		}
+		for _, token := range m.scanner.GetFutureTokens(c.Underlying) {
+			tokens[token] = true
+		}
+	}
+	return tokens
+}
+
+// Apply subscribes the tokens the criteria need that are not yet subscribed
+// and unsubscribes the ones no longer needed. Strike windows keep the centre
+// they had for the same underlying, or start at the live price.
+func (m *Manager) Apply(criteria []options.FilterCriteria) Delta {
+	m.applyMu.Lock()
+	defer m.applyMu.Unlock()
+
+	m.mu.Lock()
+	centers := make(map[string]float64)
+	for _, c := range m.criteria {
+		if c.Window != nil {
+			centers[c.Underlying] = c.Window.Center
+		}
+	}
+	price := m.price
+	m.mu.Unlock()
+
+	applied := make([]options.FilterCriteria, len(criteria))
+	for i, c := range criteria {
+		if c.Window != nil {
+			w := *c.Window
+			if w.Center = centers[c.Underlying]; w.Center <= 0 {
+				w.Center, _ = price(c.Underlying)
+			}
+			c.Window = &w
+		}
+		applied[i] = c
+	}
+
+	return m.apply(applied)
+}
+
+// apply subscribes the criteria's tokens and records them. The caller must
+// hold applyMu.
+func (m *Manager) apply(criteria []options.FilterCriteria) Delta {
+	delta := m.ApplyTokens(m.Tokens(criteria))
+
+	m.mu.Lock()
+	m.criteria = criteria
+	m.mu.Unlock()
+	return delta
+}
+
+// ApplyTokens makes the given set the token set subscribed for the underlyings
+func (m *Manager) ApplyTokens(desired map[uint32]bool) Delta {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var delta Delta
+	for token := range desired {
+		if !m.subscribed(token) {
+			delta.Subscribed = append(delta.Subscribed, token)
+		}
+	}
+	for token := range m.tokens {
+		if !desired[token] && !m.pinned[token] && m.viewers[token] == 0 {
+			delta.Unsubscribed = append(delta.Unsubscribed, token)
+		}
+	}
+	sortTokens(delta.Subscribed)
+	sortTokens(delta.Unsubscribed)
+
+	m.tokens = make(map[uint32]bool, len(desired))
+	for token := range desired {
+		m.tokens[token] = true
+	}
+
+	m.unsubscribe(delta.Unsubscribed)
+	m.subscribe(delta.Subscribed)
+
+	delta.Total = len(m.tokens)
+	return delta
+}
+
+// Pin subscribes tokens that other consumers need regardless of the
+// underlyings, such as the cash arbitrage legs
+func (m *Manager) Pin(tokens []uint32) {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	added := make([]uint32, 0, len(tokens))
+	changed := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if m.pinned[token] {
+			continue
+		}
+		if m.subscribed(token) {
+			changed = append(changed, token)
+		} else {
+			added = append(added, token)
+		}
+		m.pinned[token] = true
+	}
+	m.subscribe(added)
+	m.updateModes(changed)
+}
+
+// View subscribes tokens a websocket client opened and streams them in full
+func (m *Manager) View(tokens []uint32) error {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	added := make([]uint32, 0, len(tokens))
+	changed := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if m.subscribed(token) {
+			changed = append(changed, token)
+		} else {
+			added = append(added, token)
+		}
+		m.viewers[token]++
+	}
+	m.subscribe(added)
+	m.updateModes(changed)
+	return nil
+}
+
+// Unview releases tokens a websocket client closed. Tokens nothing else
+// needs are unsubscribed, the rest fall back to their policy mode.
+func (m *Manager) Unview(tokens []uint32) error {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := make([]uint32, 0, len(tokens))
+	changed := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if m.viewers[token] == 0 {
+			continue
+		}
+		if m.viewers[token]--; m.viewers[token] > 0 {
+			continue
+		}
+		delete(m.viewers, token)
+		if m.subscribed(token) {
+			changed = append(changed, token)
+		} else {
+			removed = append(removed, token)
+		}
+	}
+	m.unsubscribe(removed)
+	m.updateModes(changed)
+	return nil
+}
+
+// Drop unsubscribes tokens that no longer exist, such as expired contracts,
+// whatever needs them
+func (m *Manager) Drop(tokens []uint32) {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := make([]uint32, 0, len(tokens))
+	for _, token := range tokens {
+		if m.subscribed(token) {
+			removed = append(removed, token)
+		}
+		delete(m.tokens, token)
+		delete(m.pinned, token)
+		delete(m.viewers, token)
+		delete(m.positions, token)
+	}
+	m.unsubscribe(removed)
+}
+
+// Subscribed returns whether a token is subscribed
+func (m *Manager) Subscribed(token uint32) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.subscribed(token)
+}
+
+// subscribed reports whether anything needs a token. The caller must hold
+// the lock.
+func (m *Manager) subscribed(token uint32) bool {
+	return m.tokens[token] || m.pinned[token] || m.viewers[token] > 0
+}
+
+// Count returns the number of subscribed tokens
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.modes)
+}
+
+// Recenter moves the strike windows whose underlying has moved past their
+// re-centre threshold and applies the resulting delta. It returns the
+// underlyings that were re-centred.
+func (m *Manager) Recenter() ([]string, Delta) {
+	m.applyMu.Lock()
+	defer m.applyMu.Unlock()
+
+	m.mu.Lock()
+	criteria := m.criteria
+	price := m.price
+	m.mu.Unlock()
+
+	var moved []string
+	next := make([]options.FilterCriteria, len(criteria))
+	for i, c := range criteria {
+		if c.Window != nil {
+			if p, ok := price(c.Underlying); ok && c.Window.NeedsRecenter(p) {
+				w := *c.Window
+				w.Center = p
+				c.Window = &w
+				moved = append(moved, c.Underlying)
+			}
+		}
+		next[i] = c
+	}
+	if len(moved) == 0 {
+		return nil, Delta{}
+	}
+
+	return moved, m.apply(next)
+}
+
+// RunRecenter re-centres the strike windows and re-evaluates the token modes
+// every interval until stop is closed
+func (m *Manager) RunRecenter(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if moved, delta := m.Recenter(); len(moved) > 0 {
+				log.Printf("Re-centred strike windows of %v: subscribed %d, unsubscribed %d",
+					moved, len(delta.Subscribed), len(delta.Unsubscribed))
+			}
+			if changed := m.UpdateModes(); changed > 0 {
+				log.Printf("Changed the mode of %d tokens", changed)
+			}
+		}
+	}
+}
+
+// Centers returns the current centre of each underlying's strike window
+func (m *Manager) Centers() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	centers := make(map[string]float64)
+	for _, c := range m.criteria {
+		if c.Window != nil {
+			centers[c.Underlying] = c.Window.Center
+		}
+	}
+	return centers
+}
+
+// subscribe queues new tokens to be subscribed and their policy modes set.
+// The caller must hold the lock.
+func (m *Manager) subscribe(tokens []uint32) {
+	m.batches(tokens, m.ticker.Subscribe, "subscribing")
+	m.updateModes(tokens)
+}
+
+// unsubscribe queues tokens to be unsubscribed. The caller must hold the lock.
+func (m *Manager) unsubscribe(tokens []uint32) {
+	m.batches(tokens, m.ticker.Unsubscribe, "unsubscribing")
+	for _, token := range tokens {
+		delete(m.modes, token)
+	}
+}
+
+// batches queues tokens to be sent in batches. The caller must hold the lock
+// and flush once it has released it.
+func (m *Manager) batches(tokens []uint32, send func([]uint32) error, action string) {
+	if len(tokens) > 0 {
+		m.pending = append(m.pending, tickerCall{tokens: tokens, send: send, action: action})
+	}
+}
+
+// flush sends the pending ticker calls in order. The lock is released while
+// they are sent, so ticks and websocket views aren't held up by the pauses
+// between batches; calls queued while another goroutine is sending are left
+// to it. The caller must not hold the lock.
+func (m *Manager) flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sending {
+		return
+	}
+	m.sending = true
+	for len(m.pending) > 0 {
+		calls, size, delay := m.pending, m.batchSize, m.batchDelay
+		m.pending = nil
+		m.mu.Unlock()
+		for _, call := range calls {
+			sendBatches(call, size, delay)
+		}
+		m.mu.Lock()
+	}
+	m.sending = false
+}
+
+// sendBatches sends a call's tokens in batches, pausing between them to stay
+// within the ticker's rate limits
+func sendBatches(call tickerCall, size int, delay time.Duration) {
+	tokens := call.tokens
+	for i := 0; i < len(tokens); i += size {
+		end := i + size
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+
+		if err := call.send(tokens[i:end]); err != nil {
+			log.Printf("Error %s batch %d-%d: %v", call.action, i, end, err)
+		} else {
+			log.Printf("Finished %s batch %d-%d (%d tokens)", call.action, i, end, end-i)
+		}
+
+		if end < len(tokens) {
+			time.Sleep(delay)
+		}
+	}
+}
+
+func sortTokens(tokens []uint32) {
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+}
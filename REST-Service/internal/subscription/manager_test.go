@@ -0,0 +1,267 @@
+package subscription
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"rest-service/internal/options"
+	kiteticker "rest-service/internal/ticker"
+
+	kiteconnect "gokiteconnect-master"
+	"gokiteconnect-master/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTicker struct {
+	subscribed map[uint32]bool
+	modes      map[uint32]kiteticker.Mode
+	calls      int
+}
+
+func newFakeTicker() *fakeTicker {
+	return &fakeTicker{subscribed: make(map[uint32]bool), modes: make(map[uint32]kiteticker.Mode)}
+}
+
+func (f *fakeTicker) Subscribe(tokens []uint32) error {
+	f.calls++
+	for _, token := range tokens {
+		f.subscribed[token] = true
+	}
+	return nil
+}
+
+func (f *fakeTicker) Unsubscribe(tokens []uint32) error {
+	f.calls++
+	for _, token := range tokens {
+		delete(f.subscribed, token)
+	}
+	return nil
+}
+
+func (f *fakeTicker) SetMode(mode kiteticker.Mode, tokens []uint32) error {
+	for _, token := range tokens {
+		f.modes[token] = mode
+	}
+	return nil
+}
+
+func TestManagerAppliesDelta(t *testing.T) {
+	expiry := models.Time{Time: time.Now().AddDate(0, 0, 10)}
+	instruments := []kiteconnect.Instrument{
+		{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Exchange: "NSE", Segment: "INDICES", InstrumentType: "EQ"},
+		{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: expiry},
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25100, Expiry: expiry},
+		{InstrumentToken: 3, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25200, Expiry: expiry},
+		{InstrumentToken: 10, Name: "NIFTY", InstrumentType: "FUT", Expiry: expiry},
+	}
+	scanner := options.NewScanner(nil)
+	scanner.LoadInstruments(instruments)
+	scanner.ResolveUnderlyings(nil)
+
+	ticker := newFakeTicker()
+	m := NewManager(ticker, scanner, 2, 0)
+
+	low, high := 25000.0, 25100.0
+	delta := m.Apply([]options.FilterCriteria{{Underlying: "NIFTY", MinStrike: &low, MaxStrike: &high}})
+	require.Equal(t, []uint32{1, 2, 10, 256265}, delta.Subscribed)
+	require.Empty(t, delta.Unsubscribed)
+	require.Equal(t, 2, ticker.calls) // Two batches of two
+
+	// Moving the window only touches the strikes that changed
+	low, high = 25100, 25200
+	ticker.calls = 0
+	delta = m.Apply([]options.FilterCriteria{{Underlying: "NIFTY", MinStrike: &low, MaxStrike: &high}})
+	require.Equal(t, []uint32{3}, delta.Subscribed)
+	require.Equal(t, []uint32{1}, delta.Unsubscribed)
+	require.Equal(t, 4, delta.Total)
+	require.Equal(t, 2, ticker.calls)
+
+	// Pinned tokens survive the underlying being removed
+	m.Pin([]uint32{256265})
+	delta = m.Apply(nil)
+	require.NotContains(t, delta.Unsubscribed, uint32(256265))
+	require.True(t, ticker.subscribed[256265])
+	require.False(t, ticker.subscribed[3])
+	require.Equal(t, 1, m.Count())
+}
+
+func TestManagerRecentersWindow(t *testing.T) {
+	expiry := models.Time{Time: time.Now().AddDate(0, 0, 10)}
+	instruments := make([]kiteconnect.Instrument, 0)
+	for i, strike := 0, 24500.0; strike <= 25500; i, strike = i+1, strike+100 {
+		instruments = append(instruments, kiteconnect.Instrument{
+			InstrumentToken: 100 + i, Name: "NIFTY", InstrumentType: "CE", StrikePrice: strike, Expiry: expiry,
+		})
+	}
+	scanner := options.NewScanner(nil)
+	scanner.LoadInstruments(instruments)
+
+	ticker := newFakeTicker()
+	m := NewManager(ticker, scanner, 100, 0)
+	price := 25000.0
+	m.SetPriceFunc(func(string) (float64, bool) { return price, true })
+
+	criteria := []options.FilterCriteria{{
+		Underlying: "NIFTY",
+		Window:     &options.StrikeWindow{Strikes: 1, RecenterPct: 0.005},
+	}}
+	delta := m.Apply(criteria)
+	require.Equal(t, []uint32{104, 105, 106}, delta.Subscribed)
+	require.Zero(t, criteria[0].Window.Center) // The caller's criteria are not modified
+
+	// A move inside the threshold leaves the window alone
+	price = 25090
+	moved, _ := m.Recenter()
+	require.Empty(t, moved)
+
+	// Past the threshold, the window follows and only the edges change
+	price = 25140
+	moved, delta = m.Recenter()
+	require.Equal(t, []string{"NIFTY"}, moved)
+	require.Equal(t, []uint32{107}, delta.Subscribed)
+	require.Equal(t, []uint32{104}, delta.Unsubscribed)
+	require.Equal(t, 25140.0, m.Centers()["NIFTY"])
+
+	// Reapplying the config keeps the centre
+	delta = m.Apply(criteria)
+	require.Empty(t, delta.Subscribed)
+	require.Empty(t, delta.Unsubscribed)
+}
+
+func TestManagerModePolicy(t *testing.T) {
+	expiry := models.Time{Time: time.Now().AddDate(0, 0, 10)}
+	instruments := []kiteconnect.Instrument{
+		{InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Exchange: "NSE", Segment: "INDICES", InstrumentType: "EQ"},
+	}
+	for i, strike := 0, 24000.0; strike <= 26000; i, strike = i+1, strike+100 {
+		instruments = append(instruments, kiteconnect.Instrument{
+			InstrumentToken: 100 + i, Name: "NIFTY", InstrumentType: "CE", StrikePrice: strike, Expiry: expiry,
+		})
+	}
+	scanner := options.NewScanner(nil)
+	scanner.LoadInstruments(instruments)
+	scanner.ResolveUnderlyings(nil)
+
+	ticker := newFakeTicker()
+	m := NewManager(ticker, scanner, 100, 0)
+	price := 25000.0
+	m.SetPriceFunc(func(string) (float64, bool) { return price, true })
+	m.SetModeRules(ModeRules{Enabled: true, FullStrikes: 1, QuoteStrikes: 3})
+	m.Apply([]options.FilterCriteria{{Underlying: "NIFTY"}})
+
+	// 25000 is token 110
+	require.Equal(t, kiteticker.ModeFull, ticker.modes[256265])
+	require.Equal(t, kiteticker.ModeFull, ticker.modes[111])
+	require.Equal(t, kiteticker.ModeQuote, ticker.modes[113])
+	require.Equal(t, kiteticker.ModeLTP, ticker.modes[114])
+
+	// Viewing promotes, closing the view demotes but keeps the subscription
+	require.NoError(t, m.View([]uint32{120}))
+	require.Equal(t, kiteticker.ModeFull, ticker.modes[120])
+	require.NoError(t, m.Unview([]uint32{120}))
+	require.Equal(t, kiteticker.ModeLTP, ticker.modes[120])
+	require.True(t, ticker.subscribed[120])
+
+	// Viewing an unrelated token subscribes it until the view closes
+	require.NoError(t, m.View([]uint32{999}))
+	require.True(t, ticker.subscribed[999])
+	require.NoError(t, m.Unview([]uint32{999}))
+	require.False(t, ticker.subscribed[999])
+
+	// Positions stream in full
+	m.SetPositions([]uint32{100})
+	require.Equal(t, kiteticker.ModeFull, ticker.modes[100])
+
+	// The modes follow the underlying once it moves past the threshold
+	price = 25300
+	require.Positive(t, m.UpdateModes())
+	require.Equal(t, kiteticker.ModeFull, ticker.modes[113])
+	require.Equal(t, kiteticker.ModeLTP, ticker.modes[109])
+
+	policy := m.Modes()
+	require.Equal(t, 25300.0, policy.Centers["NIFTY"])
+	require.Equal(t, len(instruments), len(policy.Tokens))
+}
+
+func TestManagerRollsOverExpiry(t *testing.T) {
+	current := models.Time{Time: time.Now().AddDate(0, 0, -1)}
+	next := models.Time{Time: time.Now().AddDate(0, 0, 6)}
+	scanner := options.NewScanner(nil)
+	scanner.LoadInstruments([]kiteconnect.Instrument{
+		{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: current},
+	})
+
+	ticker := newFakeTicker()
+	m := NewManager(ticker, scanner, 100, 0)
+	criteria := []options.FilterCriteria{{Underlying: "NIFTY", MaxDaysToExpiry: 30}}
+	m.Apply(criteria)
+	require.NoError(t, m.View([]uint32{1}))
+	require.True(t, ticker.subscribed[1])
+
+	// The refreshed master drops the expired weekly, even while it is viewed,
+	// and the next one is picked up by the criteria
+	result := scanner.ApplyInstruments([]kiteconnect.Instrument{
+		{InstrumentToken: 1, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: current},
+		{InstrumentToken: 2, Name: "NIFTY", InstrumentType: "CE", StrikePrice: 25000, Expiry: next},
+	}, time.Now())
+	m.Drop(result.Removed)
+	delta := m.Apply(criteria)
+
+	require.False(t, ticker.subscribed[1])
+	require.Equal(t, []uint32{2}, delta.Subscribed)
+	require.Equal(t, 1, m.Count())
+}
+
+// gatedTicker blocks subscribing until the gate is opened.
+type gatedTicker struct {
+	gate    chan struct{}
+	started chan struct{}
+	mu      sync.Mutex
+	modes   map[uint32]kiteticker.Mode
+}
+
+func (g *gatedTicker) Subscribe(tokens []uint32) error {
+	g.started <- struct{}{}
+	<-g.gate
+	return nil
+}
+
+func (g *gatedTicker) Unsubscribe(tokens []uint32) error { return nil }
+
+func (g *gatedTicker) SetMode(mode kiteticker.Mode, tokens []uint32) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, token := range tokens {
+		g.modes[token] = mode
+	}
+	return nil
+}
+
+func TestManagerViewsDuringBatches(t *testing.T) {
+	ticker := &gatedTicker{gate: make(chan struct{}), started: make(chan struct{}, 2), modes: make(map[uint32]kiteticker.Mode)}
+	m := NewManager(ticker, options.NewScanner(nil), 1, 0)
+
+	done := make(chan struct{})
+	go func() {
+		m.ApplyTokens(map[uint32]bool{1: true, 2: true})
+		close(done)
+	}()
+	<-ticker.started
+
+	// The first batch is still being sent, but views don't wait for it
+	viewed := make(chan error, 1)
+	go func() { viewed <- m.View([]uint32{1}) }()
+	select {
+	case err := <-viewed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("View blocked behind the subscription batches")
+	}
+	require.True(t, m.Subscribed(2))
+
+	close(ticker.gate)
+	<-done
+	require.Equal(t, kiteticker.ModeFull, ticker.modes[1])
+}
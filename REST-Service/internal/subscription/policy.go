@@ -0,0 +1,223 @@
+package subscription
+
+import (
+	"rest-service/internal/options"
+	kiteticker "rest-service/internal/ticker"
+)
+
+// Reasons a token streams in its mode
+const (
+	ReasonPolicyOff  = "policy_disabled"
+	ReasonPinned     = "pinned"
+	ReasonViewed     = "viewed"
+	ReasonPosition   = "position"
+	ReasonUnderlying = "underlying" // Spot, futures and other non-option tokens
+	ReasonNoPrice    = "no_underlying_price"
+	ReasonNearATM    = "near_atm"
+	ReasonMidWing    = "mid_wing"
+	ReasonFarWing    = "far_wing"
+)
+
+// ModeRules picks the streaming mode of option tokens by their distance from
+// ATM. Viewed, position, pinned and non-option tokens always stream in full.
+type ModeRules struct {
+	Enabled      bool    `json:"enabled"`       // Disabled streams everything in full
+	FullStrikes  int     `json:"full_strikes"`  // Strikes each side of ATM in full mode
+	QuoteStrikes int     `json:"quote_strikes"` // Strikes each side of ATM in quote mode, LTP beyond
+	RecenterPct  float64 `json:"recenter_pct"`  // Underlying move before modes are re-evaluated
+}
+
+// TokenMode is the mode a token streams in and why
+type TokenMode struct {
+	Mode   kiteticker.Mode `json:"mode"`
+	Reason string          `json:"reason"`
+}
+
+// TokenModeInfo is a subscribed token's mode for the admin view
+type TokenModeInfo struct {
+	InstrumentToken uint32 `json:"instrument_token"`
+	Tradingsymbol   string `json:"tradingsymbol"`
+	TokenMode
+}
+
+// ModePolicy is the state of the mode policy
+type ModePolicy struct {
+	Rules   ModeRules               `json:"rules"`
+	Counts  map[kiteticker.Mode]int `json:"counts"`
+	Centers map[string]float64      `json:"centers"` // Underlying price the modes were picked at
+	Tokens  []TokenModeInfo         `json:"tokens"`
+}
+
+// SetModeRules replaces the mode rules and re-evaluates every token
+func (m *Manager) SetModeRules(rules ModeRules) {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = rules
+	m.updateModes(m.subscribedTokens())
+}
+
+// SetPositions sets the tokens with open positions, which stream in full
+func (m *Manager) SetPositions(tokens []uint32) {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := make([]uint32, 0, len(tokens))
+	positions := make(map[uint32]bool, len(tokens))
+	for _, token := range tokens {
+		positions[token] = true
+		if !m.positions[token] {
+			changed = append(changed, token)
+		}
+	}
+	for token := range m.positions {
+		if !positions[token] {
+			changed = append(changed, token)
+		}
+	}
+	m.positions = positions
+
+	subscribed := make([]uint32, 0, len(changed))
+	for _, token := range changed {
+		if m.subscribed(token) {
+			subscribed = append(subscribed, token)
+		}
+	}
+	m.updateModes(subscribed)
+}
+
+// UpdateModes re-evaluates the option modes of underlyings that moved past
+// the re-centre threshold and returns how many tokens changed mode
+func (m *Manager) UpdateModes() int {
+	defer m.flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.rules.Enabled {
+		return 0
+	}
+
+	moved := false
+	for underlying, center := range m.modeCenters {
+		w := options.StrikeWindow{Center: center, RecenterPct: m.rules.RecenterPct}
+		if p, ok := m.price(underlying); ok && w.NeedsRecenter(p) {
+			m.modeCenters[underlying] = p
+			moved = true
+		}
+	}
+	if !moved {
+		return 0
+	}
+	return m.updateModes(m.subscribedTokens())
+}
+
+// Modes returns the rules and the mode of every subscribed token
+func (m *Manager) Modes() ModePolicy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	policy := ModePolicy{
+		Rules:   m.rules,
+		Counts:  make(map[kiteticker.Mode]int),
+		Centers: make(map[string]float64, len(m.modeCenters)),
+		Tokens:  make([]TokenModeInfo, 0, len(m.modes)),
+	}
+	for underlying, center := range m.modeCenters {
+		policy.Centers[underlying] = center
+	}
+
+	tokens := make([]uint32, 0, len(m.modes))
+	for token := range m.modes {
+		tokens = append(tokens, token)
+	}
+	sortTokens(tokens)
+	for _, token := range tokens {
+		mode := m.modes[token]
+		info := TokenModeInfo{InstrumentToken: token, TokenMode: mode}
+		if inst, ok := m.scanner.GetInstrument(token); ok {
+			info.Tradingsymbol = inst.Tradingsymbol
+		}
+		policy.Tokens = append(policy.Tokens, info)
+		policy.Counts[mode.Mode]++
+	}
+	return policy
+}
+
+// subscribedTokens returns every subscribed token. The caller must hold the lock.
+func (m *Manager) subscribedTokens() []uint32 {
+	tokens := make([]uint32, 0, len(m.modes))
+	for token := range m.modes {
+		tokens = append(tokens, token)
+	}
+	sortTokens(tokens)
+	return tokens
+}
+
+// updateModes sets the policy mode of subscribed tokens whose mode changed and
+// returns how many did. The caller must hold the lock.
+func (m *Manager) updateModes(tokens []uint32) int {
+	changes := make(map[kiteticker.Mode][]uint32)
+	changed := 0
+	for _, token := range tokens {
+		mode := m.modeFor(token)
+		if current, ok := m.modes[token]; ok && current.Mode == mode.Mode {
+			m.modes[token] = mode // Reason may change without a new mode
+			continue
+		}
+		m.modes[token] = mode
+		changes[mode.Mode] = append(changes[mode.Mode], token)
+		changed++
+	}
+
+	for _, mode := range []kiteticker.Mode{kiteticker.ModeFull, kiteticker.ModeQuote, kiteticker.ModeLTP} {
+		mode := mode // Sent after the loop
+		m.batches(changes[mode], func(batch []uint32) error {
+			return m.ticker.SetMode(mode, batch)
+		}, "setting "+string(mode)+" mode for")
+	}
+	return changed
+}
+
+// modeFor picks a token's mode. The caller must hold the lock.
+func (m *Manager) modeFor(token uint32) TokenMode {
+	full := func(reason string) TokenMode {
+		return TokenMode{Mode: kiteticker.ModeFull, Reason: reason}
+	}
+	switch {
+	case !m.rules.Enabled:
+		return full(ReasonPolicyOff)
+	case m.pinned[token]:
+		return full(ReasonPinned)
+	case m.viewers[token] > 0:
+		return full(ReasonViewed)
+	case m.positions[token]:
+		return full(ReasonPosition)
+	}
+
+	inst, ok := m.scanner.GetInstrument(token)
+	if !ok || (inst.InstrumentType != options.Call && inst.InstrumentType != options.Put) {
+		return full(ReasonUnderlying)
+	}
+
+	// Tracked from the first option seen, so UpdateModes picks up the price
+	// once it arrives
+	center := m.modeCenters[inst.Name]
+	if center <= 0 {
+		center, _ = m.price(inst.Name)
+		m.modeCenters[inst.Name] = center
+	}
+	distance, ok := m.scanner.StrikeDistance(token, center)
+	if !ok {
+		return TokenMode{Mode: kiteticker.ModeQuote, Reason: ReasonNoPrice}
+	}
+
+	switch {
+	case distance <= m.rules.FullStrikes:
+		return full(ReasonNearATM)
+	case distance <= m.rules.QuoteStrikes:
+		return TokenMode{Mode: kiteticker.ModeQuote, Reason: ReasonMidWing}
+	default:
+		return TokenMode{Mode: kiteticker.ModeLTP, Reason: ReasonFarWing}
+	}
+}
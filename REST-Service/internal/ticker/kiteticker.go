@@ -26,10 +26,14 @@ type ExtendedTicker struct {
 	extendedCallbacks
 }
 
+// Kite web ticker url, which authenticates with the enctoken.
+var webTickerURL = url.URL{Scheme: "wss", Host: "ws.zerodha.com", Path: "/"}
+
 // New creates a new ExtendedTicker instance.
 func ExtendedNew(apiKey string, accessToken string) *ExtendedTicker {
 	ticker := &ExtendedTicker{
 		Ticker: New(apiKey, accessToken)}
+	ticker.url = webTickerURL
 
 	return ticker
 }
@@ -95,7 +99,7 @@ func (t *ExtendedTicker) ServeWithContext(ctx context.Context) {
 			d.HandshakeTimeout = t.connectTimeout
 
 			// var ws_url = "wss://ws.zerodha.com/?api_key=kitefront&user_id=VM2107&enctoken="+ os.Getenv("ENC_TOKEN")+ "%3D%3D&uid=1745504664336&user-agent=kite3-web&version=3.0.0"
-			url, _ := url.Parse("wss://ws.zerodha.com/")
+			url := t.url
 			query := url.Query()
 			query.Set("api_key", "kitefront")
 			query.Set("user_id", "VM2107")
@@ -0,0 +1,495 @@
+package kiteticker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gokiteconnect-master/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrPoolFull is returned for tokens that did not fit on any connection
+var ErrPoolFull = errors.New("ticker pool is full")
+
+const (
+	// DefaultConnections is the number of WebSocket connections Kite allows per API key
+	DefaultConnections = 3
+	// DefaultTokensPerConnection is the number of tokens Kite allows per WebSocket connection
+	DefaultTokensPerConnection = 3000
+)
+
+// Pool shards subscriptions across several ticker connections, each holding
+// up to perConn tokens, and merges their ticks into one stream. New tokens go
+// to the connection with the fewest, and connections that drift apart by more
+// than a quarter of perConn after unsubscribes are evened out. Each connection
+// reconnects on its own and resubscribes its share of the tokens with their
+// modes.
+type Pool struct {
+	shards  []*shard
+	perConn int
+	owner   map[uint32]*shard
+	mu      sync.Mutex
+
+	onTick       func(models.Tick)
+	onBinaryTick func([]byte)
+	tickMu       sync.Mutex // Ticks reach the callbacks one at a time, as from a single connection
+}
+
+// shard is one connection of the pool
+type shard struct {
+	id         int
+	ticker     *ExtendedTicker
+	tokens     map[uint32]Mode // modeEmpty until a mode is set
+	connected  bool
+	reconnects int
+}
+
+// ShardStatus describes one connection of the pool
+type ShardStatus struct {
+	ID         int  `json:"id"`
+	Tokens     int  `json:"tokens"`
+	Connected  bool `json:"connected"`
+	Reconnects int  `json:"reconnects"` // Reconnect attempts since start
+}
+
+// NewPool creates a pool of connections tickers from newTicker, each holding
+// at most perConn tokens. Non-positive values take the Kite defaults.
+func NewPool(connections, perConn int, newTicker func() *ExtendedTicker) *Pool {
+	if connections <= 0 {
+		connections = DefaultConnections
+	}
+	if perConn <= 0 {
+		perConn = DefaultTokensPerConnection
+	}
+
+	p := &Pool{perConn: perConn, owner: make(map[uint32]*shard)}
+	for i := 0; i < connections; i++ {
+		s := &shard{id: i, ticker: newTicker(), tokens: make(map[uint32]Mode)}
+		s.ticker.OnConnect(func() { p.connect(s) })
+		s.ticker.OnReconnect(func(attempt int, delay time.Duration) {
+			p.disconnect(s, true)
+			log.Printf("Ticker %d: reconnect attempt %d in %.0fs", s.id, attempt, delay.Seconds())
+		})
+		s.ticker.OnClose(func(code int, reason string) {
+			p.disconnect(s, false)
+			log.Printf("Ticker %d: closed (%d) %s", s.id, code, reason)
+		})
+		s.ticker.OnError(func(err error) { log.Printf("Ticker %d: %v", s.id, err) })
+		s.ticker.OnNoReconnect(func(attempt int) {
+			log.Printf("Ticker %d: giving up after %d reconnect attempts", s.id, attempt)
+		})
+		s.ticker.OnTick(p.triggerTick)
+		s.ticker.OnBinaryTick(p.triggerBinaryTick)
+		p.shards = append(p.shards, s)
+	}
+	return p
+}
+
+// OnTick sets the callback for ticks from every connection
+func (p *Pool) OnTick(f func(tick models.Tick)) {
+	p.onTick = f
+}
+
+// OnBinaryTick sets the callback for raw tick packets from every connection
+func (p *Pool) OnBinaryTick(f func(tick []byte)) {
+	p.onBinaryTick = f
+}
+
+func (p *Pool) triggerTick(tick models.Tick) {
+	if p.onTick != nil {
+		p.tickMu.Lock()
+		p.onTick(tick)
+		p.tickMu.Unlock()
+	}
+}
+
+func (p *Pool) triggerBinaryTick(tick []byte) {
+	if p.onBinaryTick != nil {
+		p.tickMu.Lock()
+		p.onBinaryTick(tick)
+		p.tickMu.Unlock()
+	}
+}
+
+// Serve connects every ticker and blocks until they all stop
+func (p *Pool) Serve() {
+	p.ServeWithContext(context.Background())
+}
+
+// ServeWithContext connects every ticker and blocks until they all stop or
+// ctx is done
+func (p *Pool) ServeWithContext(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range p.shards {
+		wg.Add(1)
+		go func(s *shard) {
+			defer wg.Done()
+			s.ticker.ServeWithContext(ctx)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Stop stops every ticker
+func (p *Pool) Stop() {
+	for _, s := range p.shards {
+		s.ticker.Stop()
+	}
+}
+
+// Subscribe subscribes tokens, placing each new one on the connection with
+// the fewest tokens. Tokens that do not fit are left out with ErrPoolFull.
+func (p *Pool) Subscribe(tokens []uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	placed := make(map[*shard][]uint32)
+	full := 0
+	for _, token := range tokens {
+		if _, ok := p.owner[token]; ok {
+			continue
+		}
+		s := p.emptiest()
+		if len(s.tokens) >= p.perConn {
+			full++
+			continue
+		}
+		s.tokens[token] = modeEmpty
+		p.owner[token] = s
+		placed[s] = append(placed[s], token)
+	}
+
+	var errs []error
+	for s, batch := range placed {
+		if err := s.send("subscribe", batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if full > 0 {
+		errs = append(errs, fmt.Errorf("%w: %d tokens not subscribed", ErrPoolFull, full))
+	}
+	return joinErrors(errs)
+}
+
+// Unsubscribe unsubscribes tokens and evens out the connections if they have
+// drifted apart
+func (p *Pool) Unsubscribe(tokens []uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	removed := make(map[*shard][]uint32)
+	for _, token := range tokens {
+		s, ok := p.owner[token]
+		if !ok {
+			continue
+		}
+		delete(s.tokens, token)
+		delete(p.owner, token)
+		removed[s] = append(removed[s], token)
+	}
+
+	var errs []error
+	for s, batch := range removed {
+		if err := s.send("unsubscribe", batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := p.rebalance(); err != nil {
+		errs = append(errs, err)
+	}
+	return joinErrors(errs)
+}
+
+// SetMode sets the streaming mode of subscribed tokens
+func (p *Pool) SetMode(mode Mode, tokens []uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := make(map[*shard][]uint32)
+	for _, token := range tokens {
+		s, ok := p.owner[token]
+		if !ok {
+			continue
+		}
+		s.tokens[token] = mode
+		changed[s] = append(changed[s], token)
+	}
+
+	var errs []error
+	for s, batch := range changed {
+		if err := s.send("mode", []interface{}{mode, batch}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// SetFullMode streams tokens in full mode
+func (p *Pool) SetFullMode(tokens []uint32) error {
+	return p.SetMode(ModeFull, tokens)
+}
+
+// Tokens returns the subscribed tokens
+func (p *Pool) Tokens() []uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tokens := make([]uint32, 0, len(p.owner))
+	for token := range p.owner {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// Connections returns the subscribed tokens of each connection by ID
+func (p *Pool) Connections() map[int][]uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := make(map[int][]uint32, len(p.shards))
+	for _, s := range p.shards {
+		tokens := make([]uint32, 0, len(s.tokens))
+		for token := range s.tokens {
+			tokens = append(tokens, token)
+		}
+		conns[s.id] = tokens
+	}
+	return conns
+}
+
+// Resubscribe unsubscribes tokens and subscribes them again on their
+// connections, keeping their modes
+func (p *Pool) Resubscribe(tokens []uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	owned := make(map[*shard][]uint32)
+	for _, token := range tokens {
+		if s, ok := p.owner[token]; ok {
+			owned[s] = append(owned[s], token)
+		}
+	}
+
+	var errs []error
+	for s, batch := range owned {
+		modes := make(map[Mode][]uint32)
+		for _, token := range batch {
+			if mode := s.tokens[token]; mode != modeEmpty {
+				modes[mode] = append(modes[mode], token)
+			}
+		}
+		if err := s.send("unsubscribe", batch); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := s.send("subscribe", batch); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for mode, byMode := range modes {
+			if err := s.send("mode", []interface{}{mode, byMode}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return joinErrors(errs)
+}
+
+// Reconnect drops the connections holding tokens; each reconnects on its own
+// and resubscribes its tokens. It returns the IDs of the dropped connections.
+func (p *Pool) Reconnect(tokens []uint32) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dropped := make(map[*shard]bool)
+	for _, token := range tokens {
+		if s, ok := p.owner[token]; ok && s.connected {
+			dropped[s] = true
+		}
+	}
+
+	var ids []int
+	for s := range dropped {
+		s.connected = false
+		s.ticker.Conn.Close()
+		ids = append(ids, s.id)
+		log.Printf("Ticker %d: dropping the connection to reconnect", s.id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Shards returns the status of each connection
+func (p *Pool) Shards() []ShardStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make([]ShardStatus, len(p.shards))
+	for i, s := range p.shards {
+		status[i] = ShardStatus{ID: s.id, Tokens: len(s.tokens), Connected: s.connected, Reconnects: s.reconnects}
+	}
+	return status
+}
+
+// ModeCounts returns the number of subscribed tokens in each mode; tokens
+// without a mode set are counted under the ticker's default, quote
+func (p *Pool) ModeCounts() map[Mode]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counts := make(map[Mode]int)
+	for _, s := range p.shards {
+		for _, mode := range s.tokens {
+			if mode == modeEmpty {
+				mode = ModeQuote
+			}
+			counts[mode]++
+		}
+	}
+	return counts
+}
+
+// emptiest returns the connection with the fewest tokens. The caller must
+// hold the lock.
+func (p *Pool) emptiest() *shard {
+	best := p.shards[0]
+	for _, s := range p.shards[1:] {
+		if len(s.tokens) < len(best.tokens) {
+			best = s
+		}
+	}
+	return best
+}
+
+// rebalance moves tokens from the fullest to the emptiest connection while
+// they are more than a quarter of perConn apart. A moved token is subscribed
+// on its new connection before it is dropped from the old one, so it keeps
+// ticking. The caller must hold the lock.
+func (p *Pool) rebalance() error {
+	slack := p.perConn / 4
+	if slack < 1 {
+		slack = 1
+	}
+
+	var errs []error
+	for {
+		from, to := p.shards[0], p.emptiest()
+		for _, s := range p.shards[1:] {
+			if len(s.tokens) > len(from.tokens) {
+				from = s
+			}
+		}
+		gap := len(from.tokens) - len(to.tokens)
+		if gap <= slack {
+			return joinErrors(errs)
+		}
+
+		moving := make([]uint32, 0, len(from.tokens))
+		for token := range from.tokens {
+			moving = append(moving, token)
+		}
+		sort.Slice(moving, func(i, j int) bool { return moving[i] < moving[j] })
+		moving = moving[:gap/2]
+
+		modes := make(map[Mode][]uint32)
+		for _, token := range moving {
+			mode := from.tokens[token]
+			to.tokens[token] = mode
+			delete(from.tokens, token)
+			p.owner[token] = to
+			if mode != modeEmpty {
+				modes[mode] = append(modes[mode], token)
+			}
+		}
+		log.Printf("Ticker pool: moving %d tokens from connection %d to %d", len(moving), from.id, to.id)
+
+		if err := to.send("subscribe", moving); err != nil {
+			errs = append(errs, err)
+		}
+		for mode, batch := range modes {
+			if err := to.send("mode", []interface{}{mode, batch}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := from.send("unsubscribe", moving); err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+// connect marks a connection up and resubscribes its tokens with their modes
+func (p *Pool) connect(s *shard) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s.connected = true
+	log.Printf("Ticker %d: connected, resubscribing %d tokens", s.id, len(s.tokens))
+	if len(s.tokens) == 0 {
+		return
+	}
+
+	tokens := make([]uint32, 0, len(s.tokens))
+	modes := make(map[Mode][]uint32)
+	for token, mode := range s.tokens {
+		tokens = append(tokens, token)
+		if mode != modeEmpty {
+			modes[mode] = append(modes[mode], token)
+		}
+	}
+	if err := s.send("subscribe", tokens); err != nil {
+		log.Printf("Ticker %d: resubscribe failed: %v", s.id, err)
+		return
+	}
+	for mode, batch := range modes {
+		if err := s.send("mode", []interface{}{mode, batch}); err != nil {
+			log.Printf("Ticker %d: setting %s mode failed: %v", s.id, mode, err)
+		}
+	}
+}
+
+// disconnect marks a connection down, counting a reconnect attempt if it is
+// one; its tokens are resubscribed when it reconnects
+func (p *Pool) disconnect(s *shard, reconnect bool) {
+	p.mu.Lock()
+	s.connected = false
+	if reconnect {
+		s.reconnects++
+	}
+	p.mu.Unlock()
+}
+
+// send writes a request to the connection if it is up; the pool's lock
+// serialises writes. A connection that is down picks the change up on
+// reconnect.
+func (s *shard) send(typ string, val interface{}) error {
+	if !s.connected {
+		return nil
+	}
+	out, err := json.Marshal(tickerInput{Type: typ, Val: val})
+	if err != nil {
+		return err
+	}
+	if err := s.ticker.Conn.WriteMessage(websocket.TextMessage, out); err != nil {
+		return fmt.Errorf("ticker %d: %s: %w", s.id, typ, err)
+	}
+	return nil
+}
+
+// joinErrors returns the first error, noting how many more there were
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%w (and %d more errors)", errs[0], len(errs)-1)
+	}
+}
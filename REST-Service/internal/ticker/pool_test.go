@@ -0,0 +1,113 @@
+package kiteticker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPool(connections, perConn int) *Pool {
+	return NewPool(connections, perConn, func() *ExtendedTicker { return ExtendedNew("", "") })
+}
+
+func counts(p *Pool) []int {
+	var n []int
+	for _, s := range p.Shards() {
+		n = append(n, s.Tokens)
+	}
+	return n
+}
+
+func TestPoolShardsTokens(t *testing.T) {
+	p := newTestPool(2, 4)
+
+	require.NoError(t, p.Subscribe([]uint32{1, 2, 3, 4, 5, 6}))
+	require.Equal(t, []int{3, 3}, counts(p))
+
+	// Subscribed tokens are not placed twice; what does not fit is reported
+	err := p.Subscribe([]uint32{1, 7, 8, 9})
+	require.True(t, errors.Is(err, ErrPoolFull))
+	require.Equal(t, []int{4, 4}, counts(p))
+
+	// Unsubscribing one connection's tokens moves some over from the other
+	var first []uint32
+	for token := range p.shards[0].tokens {
+		first = append(first, token)
+	}
+	require.NoError(t, p.SetMode(ModeQuote, []uint32{1, 2, 3, 4, 5, 6, 7, 8}))
+	require.NoError(t, p.Unsubscribe(first[:3]))
+	n := counts(p)
+	require.Equal(t, 5, n[0]+n[1])
+	require.LessOrEqual(t, n[1]-n[0], 1)
+	for token, s := range p.owner {
+		require.Equal(t, ModeQuote, s.tokens[token], "moved tokens keep their mode")
+	}
+}
+
+func TestPoolResubscribesOnConnect(t *testing.T) {
+	received := make(chan tickerInput, 16)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var in tickerInput
+			if json.Unmarshal(msg, &in) == nil {
+				received <- in
+			}
+		}
+	}))
+	defer server.Close()
+
+	p := newTestPool(1, 10)
+	require.NoError(t, p.Subscribe([]uint32{1, 2}))
+	require.NoError(t, p.SetMode(ModeFull, []uint32{2}))
+	select {
+	case in := <-received:
+		t.Fatalf("sent %v while disconnected", in)
+	default:
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	s := p.shards[0]
+	s.ticker.Conn = conn
+	p.connect(s)
+
+	next := func() tickerInput {
+		select {
+		case in := <-received:
+			return in
+		case <-time.After(time.Second):
+			t.Fatal("no message")
+			return tickerInput{}
+		}
+	}
+	in := next()
+	require.Equal(t, "subscribe", in.Type)
+	require.ElementsMatch(t, []interface{}{1.0, 2.0}, in.Val)
+	in = next()
+	require.Equal(t, "mode", in.Type)
+	require.Equal(t, []interface{}{"full", []interface{}{2.0}}, in.Val)
+
+	// Once connected, changes are sent straight away
+	require.NoError(t, p.Subscribe([]uint32{3}))
+	in = next()
+	require.Equal(t, "subscribe", in.Type)
+	require.Equal(t, []interface{}{3.0}, in.Val)
+}
@@ -3,6 +3,7 @@ package kiteticker
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"time"
 )
 
@@ -69,3 +70,15 @@ func StartTicker() *ExtendedTicker {
 	return ticker
 
 }
+
+// StartPool creates a pool of connections tickers, each holding up to
+// perConn tokens. A nil root connects to Kite.
+func StartPool(connections, perConn int, root *url.URL) *Pool {
+	return NewPool(connections, perConn, func() *ExtendedTicker {
+		t := ExtendedNew("a", "b")
+		if root != nil {
+			t.SetRootURL(*root)
+		}
+		return t
+	})
+}
@@ -4,17 +4,30 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"reflect"
 	"strconv"
 	"syscall"
 	"time"
 
 	"rest-service/handlers"
+	"rest-service/internal/arbitrage"
+	"rest-service/internal/auth"
+	"rest-service/internal/calendar"
 	"rest-service/internal/config"
+	"rest-service/internal/health"
+	"rest-service/internal/instruments"
+	"rest-service/internal/killswitch"
+	"rest-service/internal/kitefake"
+	"rest-service/internal/metrics"
 	"rest-service/internal/socket"
 	"rest-service/internal/store"
+	"rest-service/internal/strategy"
+	"rest-service/internal/subscription"
 	kiteticker "rest-service/internal/ticker"
 
 	kiteconnect "gokiteconnect-master"
@@ -22,14 +35,21 @@ import (
 
 	"rest-service/internal/options"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 var (
 	manager    *socket.ClientManager
-	ticker     *kiteticker.ExtendedTicker
+	ticker     *kiteticker.Pool
 	calculator *options.Calculator
+
+	cashArbitrage *arbitrage.CashScanner
+	subscriptions *subscription.Manager
+)
+
+const (
+	configPath          = "config.json"
+	configWatchInterval = 5 * time.Second
 )
 
 func main() {
@@ -38,24 +58,74 @@ func main() {
 		log.Printf("Warning: Error loading .env file: %v", err)
 	}
 
-	encToken := os.Getenv("ENCTOKEN")
-	if encToken == "" {
-		log.Fatal("ENCTOKEN not found in environment")
-	}
-
 	// --- Ticker & Store Setup ---
 
-	// Initialize ticker
-	// Initialize Kite Connect client
-	kc := kiteconnect.NewWithEncToken(encToken)
-	ticker = kiteticker.StartTicker()
+	// Initialize Kite Connect client, against an in-process fake of Kite when
+	// KITE_MOCK is set
+	var (
+		kc         *kiteconnect.Client
+		tickerRoot *url.URL
+	)
+	if os.Getenv("KITE_MOCK") != "" {
+		kc, tickerRoot = StartKiteFake()
+	} else {
+		encToken := os.Getenv("ENCTOKEN")
+		if encToken == "" {
+			log.Fatal("ENCTOKEN not found in environment")
+		}
+		kc = kiteconnect.NewWithEncToken(encToken)
+	}
+	kc.SetRequestHook(metrics.ObserveKiteRequest)
 	scanner := options.NewScanner(kc)
 
-	// Initialize Greeks Calculator (6% risk-free rate)
-	calculator = options.NewCalculator(scanner, 0.06)
+	// Load configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Requests are throttled per class of endpoints and failed reads retried
+	for class, limit := range cfg.Kite.ToRateLimits() {
+		kc.SetRateLimit(class, limit)
+	}
+	kc.SetRetryPolicy(cfg.Kite.ToRetryPolicy())
+
+	// Subscriptions are sharded over several ticker connections to get past
+	// the per-connection token limit
+	ticker = kiteticker.StartPool(cfg.Ticker.Connections, cfg.Ticker.TokensPerConnection, tickerRoot)
+	log.Printf("Ticker pool: %d connections of up to %d tokens", cfg.Ticker.Connections, cfg.Ticker.TokensPerConnection)
+	registerTickerMetrics(ticker)
+
+	// Trading calendar drives time to expiry for Greeks and option filtering
+	tradingCalendar, err := cfg.Calendar.NewCalendar()
+	if err != nil {
+		log.Fatalf("Invalid calendar config: %v", err)
+	}
+	log.Printf("Trading calendar: %d holidays, %s time convention", tradingCalendar.Holidays(), tradingCalendar.Convention())
+	scanner.SetCalendar(tradingCalendar)
+
+	// Initialize Greeks Calculator with the configured pricing models
+	defaultPricing, underlyingPricing, err := cfg.GetPricingParams()
+	if err != nil {
+		log.Fatalf("Invalid pricing config: %v", err)
+	}
+	calculator = options.NewCalculator(scanner, defaultPricing.RiskFreeRate)
+	calculator.SetDefaultPricing(defaultPricing)
+	calculator.SetQualityParams(cfg.QuoteQuality.ToQualityParams())
+	calculator.SetCalendar(tradingCalendar)
+	for underlying, params := range underlyingPricing {
+		calculator.SetPricing(underlying, params)
+		log.Printf("Pricing %s with %s (r=%.4f, q=%.4f)", underlying, params.Model, params.RiskFreeRate, params.DividendYield)
+	}
+
+	// Subscriptions are diffed against the configured underlyings and streamed
+	// in the mode the policy picks; websocket clients subscribe through it too
+	subscriptions = subscription.NewManager(ticker, scanner, cfg.Subscription.BatchSize, time.Duration(cfg.Subscription.BatchDelayMs)*time.Millisecond)
+	subscriptions.SetModeRules(cfg.Subscription.Modes.ToModeRules())
 
 	// Initialize WebSocket Client Manager
 	manager = socket.NewClientManager(ticker)
+	manager.SetSubscriber(subscriptions)
 	go manager.Start()
 
 	ticker.OnBinaryTick(func(tick []byte) {
@@ -67,110 +137,109 @@ func main() {
 	})
 
 	ticker.OnTick(func(tick models.Tick) {
+		metrics.ObserveTick(tick, time.Now())
+
 		// Update in-memory store
 		// fmt.Println(tick)
 		store.GlobalStore.UpdateFromTick(tick)
 		UpdateOptionData(tick, scanner)
+		if cashArbitrage != nil {
+			cashArbitrage.OnTick(tick)
+		}
 	})
 
 	// Start Ticker
 	go func() {
-		log.Println("Starting Kite Ticker...")
+		log.Println("Starting Kite Ticker pool...")
 		ticker.Serve()
 	}()
 
-	// Load configuration
-	cfg, err := config.LoadConfig("config.json")
+	// Each applied instrument master is saved, so a restart works without the API
+	master, err := instruments.NewStore(cfg.InstrumentMaster.Dir, cfg.InstrumentMaster.Keep)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		log.Printf("Warning: instrument master versions disabled: %v", err)
+	} else {
+		scanner.SetMaster(master)
 	}
 
 	OptionScanner(scanner)
-	FilterCriteriaAndSubscribeTokens(scanner, ticker, cfg)
-	SubscribeToUnderlyings(scanner, ticker, cfg)
-
-	// Initialize Handler Controller
-	ctrl := handlers.NewController(kc, scanner)
-
-	r := gin.Default()
-
-	// CORS Middleware
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
-
-	// WebSocket Endpoint
-	r.GET("/ws", func(c *gin.Context) {
-		manager.HandleNewConnection(c.Writer, c.Request)
-	})
+	ResolveUnderlyings(scanner, cfg)
+	if loaded, err := scanner.LoadOISnapshot(cfg.OISnapshotFile, time.Now()); err != nil {
+		log.Printf("Previous close OI not loaded, OI change falls back to session open: %v", err)
+	} else {
+		log.Printf("Loaded previous close OI for %d options", loaded)
+	}
+	go SaveOISnapshots(scanner, tradingCalendar, cfg.OISnapshotFile)
+	FilterCriteriaAndSubscribeTokens(subscriptions, cfg)
+	go subscriptions.RunRecenter(time.Duration(cfg.Subscription.RecenterIntervalMs)*time.Millisecond, nil)
+	if cfg.Subscription.Modes.Enabled {
+		go TrackPositions(kc, subscriptions, time.Duration(cfg.Subscription.Modes.PositionsRefreshSeconds)*time.Second)
+	}
 
-	// Quote Endpoint (Read from Memory)
-	r.GET("/quote/:token", func(c *gin.Context) {
-		tokenStr := c.Param("token")
-		token, err := strconv.ParseUint(tokenStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token"})
-			return
-		}
+	// Without api_keys LoadConfig requires auth.disabled and an origin
+	// allowlist, and the API is then only served locally to those origins
+	host := ""
+	keys, _ := cfg.Auth.ToKeys() // Validated by LoadConfig
+	authn := auth.NewAuthenticator(keys)
+	if cfg.Auth.Disabled {
+		authn = auth.NewDisabledAuthenticator()
+		host = "127.0.0.1"
+		log.Println("Warning: auth disabled, serving on 127.0.0.1 only")
+	} else {
+		log.Printf("Auth: %d API keys", len(keys))
+	}
 
-		tick, found := store.GlobalStore.Get(uint32(token))
-		if !found {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Tick not found. Ensure Ticker is subscribed."})
-			return
+	// Underlyings can be changed over REST or by editing the config file, as
+	// can the API keys
+	liveConfig := config.NewLive(configPath, cfg)
+	liveConfig.OnChange(func(prev, next *config.Config) {
+		ApplyConfig(scanner, subscriptions, prev, next)
+		if !reflect.DeepEqual(prev.Auth.APIKeys, next.Auth.APIKeys) {
+			keys, _ := next.Auth.ToKeys()
+			authn.SetKeys(keys)
+			log.Printf("Auth: reloaded %d API keys", len(keys))
 		}
-		c.JSON(http.StatusOK, tick)
 	})
+	go liveConfig.Watch(configWatchInterval, nil)
+	if cfg.InstrumentRefresh.Enabled {
+		go RefreshInstruments(scanner, subscriptions, tradingCalendar, liveConfig)
+	}
+	if cfg.Arbitrage.Cash.Enabled {
+		StartCashArbitrage(scanner, subscriptions, cfg)
+	}
+	var optionsArbitrage *arbitrage.OptionsDetector
+	if cfg.Arbitrage.Options.Enabled {
+		optionsArbitrage = StartOptionsArbitrage(scanner, tradingCalendar, defaultPricing.RiskFreeRate, cfg)
+	}
 
-	r.GET("/ltp/:token", func(c *gin.Context) {
-		tokenStr := c.Param("token")
-		token, err := strconv.ParseUint(tokenStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token"})
-			return
-		}
+	readiness, staleness := StartHealth(kc, scanner, tradingCalendar, cfg)
 
-		tick, found := store.GlobalStore.Get(uint32(token))
-		if !found {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Tick not found. Ensure Ticker is subscribed."})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"ltp": tick.LastPrice})
-	})
+	// Initialize Handler Controller
+	ctrl := handlers.NewController(kc, scanner)
+	ctrl.CashArbitrage = cashArbitrage
+	ctrl.OptionsArbitrage = optionsArbitrage
+	ctrl.Strategy = &strategy.Resolver{Scanner: scanner, Calculator: calculator, Calendar: tradingCalendar}
+	ctrl.Config = liveConfig
+	ctrl.Subscriptions = subscriptions
+	ctrl.Readiness = readiness
+	ctrl.Staleness = staleness
+	ctrl.KillSwitch = &killswitch.Switch{}
 
-	r.GET("/instruments", ctrl.GetInstruments)
-	r.GET("/user/profile/full", ctrl.GetProfile)
-	r.GET("/user/margins", ctrl.GetMargins)
-	r.GET("/portfolio/holdings", ctrl.GetHoldings)
-	r.GET("/portfolio/positions", ctrl.GetPositions)
-	r.GET("/orders", ctrl.GetOrders)
-	r.GET("/trades", ctrl.GetTrades)
-	r.GET("/orders/:order_id", ctrl.GetOrderHistory)
-	r.GET("/orders/:order_id/trades", ctrl.GetOrderTrades)
-	r.GET("/historical/:instrument_token/:interval", ctrl.GetHistoricalData)
-
-	r.POST("/orders/:variety", ctrl.PlaceOrder)
-	r.PUT("/orders/:variety/:order_id", ctrl.ModifyOrder)
-	r.DELETE("/orders/:variety/:order_id", ctrl.CancelOrder)
+	r := NewRouter(ctrl, authn, auth.Origins(cfg.Auth.AllowedOrigins))
 
 	port := "8080"
+	// Requests carry a context cancelled when shutdown runs out of time, which
+	// stops their Kite calls
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: r,
+		Addr:        host + ":" + port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return requestCtx },
 	}
 
 	// Start Server in a goroutine
 	go func() {
-		log.Printf("Starting Unified Service on port %s...\n", port)
+		log.Printf("Starting Unified Service on %s...\n", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %s\n", err)
 		}
@@ -192,7 +261,16 @@ func main() {
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown: ", err)
+		log.Printf("Server forced to shutdown, cancelling in-flight requests: %v", err)
+	}
+	cancelRequests()
+
+	// Keep today's closing OI if the session has already ended
+	now := time.Now()
+	if tradingCalendar.IsTradingDay(now) && !now.Before(tradingCalendar.SessionClose(now)) {
+		if err := scanner.SaveOISnapshot(cfg.OISnapshotFile, now); err != nil {
+			log.Printf("Error saving OI snapshot: %v", err)
+		}
 	}
 
 	// Stop Ticker
@@ -215,106 +293,253 @@ func OptionScanner(scanner *options.Scanner) {
 
 }
 
-func FilterCriteriaAndSubscribeTokens(scanner *options.Scanner, ticker *kiteticker.ExtendedTicker, cfg *config.Config) {
+// FilterCriteriaAndSubscribeTokens subscribes the options, spot and futures of
+// every configured underlying
+func FilterCriteriaAndSubscribeTokens(subs *subscription.Manager, cfg *config.Config) {
 	// Get filter criteria for all underlyings
 	allCriteria, err := cfg.GetAllFilterCriteria()
 	if err != nil {
 		log.Fatalf("Invalid filter criteria: %v", err)
 	}
 
-	// Collect all tokens from all underlyings
-	allTokensMap := make(map[uint32]*options.OptionInstrument)
-
-	for _, criteria := range allCriteria {
-		tokensMap := scanner.FilterOptions(criteria)
-		log.Printf("Found %d matching options for %s", len(tokensMap), criteria.Underlying)
+	delta := subs.Apply(allCriteria)
+	log.Printf("Subscribed %d tokens across %d underlyings", delta.Total, len(allCriteria))
+}
 
-		// Merge tokens into the main map
-		for token, inst := range tokensMap {
-			allTokensMap[token] = inst
+// ApplyConfig applies a reloaded or edited configuration: pricing, quote
+// quality, spot resolution and the subscription delta. Calendar, ticker,
+// health, kite, arbitrage and snapshot settings only take effect on restart.
+func ApplyConfig(scanner *options.Scanner, subs *subscription.Manager, prev, next *config.Config) {
+	defaultPricing, underlyingPricing, err := next.GetPricingParams()
+	if err != nil {
+		log.Printf("Error applying pricing config: %v", err)
+	} else {
+		calculator.SetDefaultPricing(defaultPricing)
+		for _, uc := range prev.Underlyings {
+			if _, ok := underlyingPricing[uc.Underlying]; !ok {
+				calculator.ClearPricing(uc.Underlying)
+			}
+		}
+		for underlying, params := range underlyingPricing {
+			calculator.SetPricing(underlying, params)
 		}
 	}
+	calculator.SetQualityParams(next.QuoteQuality.ToQualityParams())
 
-	log.Printf("Total unique options found across all underlyings: %d", len(allTokensMap))
+	if !reflect.DeepEqual(prev.GetSpotOverrides(), next.GetSpotOverrides()) {
+		ReresolveUnderlyings(scanner, next)
+	}
 
-	// Subscribe in batches using config settings
-	batchSize := cfg.Subscription.BatchSize
-	batchDelay := time.Duration(cfg.Subscription.BatchDelayMs) * time.Millisecond
+	allCriteria, err := next.GetAllFilterCriteria()
+	if err != nil {
+		log.Printf("Error applying filter criteria: %v", err)
+		return
+	}
+	subs.SetModeRules(next.Subscription.Modes.ToModeRules())
+	subs.SetBatching(next.Subscription.BatchSize, time.Duration(next.Subscription.BatchDelayMs)*time.Millisecond)
+	delta := subs.Apply(allCriteria)
+	log.Printf("Config applied: subscribed %d, unsubscribed %d, %d tokens for %d underlyings",
+		len(delta.Subscribed), len(delta.Unsubscribed), delta.Total, len(allCriteria))
+
+	if !reflect.DeepEqual(prev.Calendar, next.Calendar) || !reflect.DeepEqual(prev.Arbitrage, next.Arbitrage) ||
+		prev.OISnapshotFile != next.OISnapshotFile || prev.InstrumentRefresh.Enabled != next.InstrumentRefresh.Enabled ||
+		prev.InstrumentMaster != next.InstrumentMaster || prev.Ticker != next.Ticker || prev.Health != next.Health ||
+		!reflect.DeepEqual(prev.Kite, next.Kite) {
+		log.Println("Warning: calendar, ticker, health, kite, arbitrage, oi_snapshot_file, instrument_refresh.enabled and instrument_master changes take effect on restart")
+	}
+}
 
-	keys := make([]uint32, 0, len(allTokensMap))
-	for key := range allTokensMap {
-		keys = append(keys, key)
+// ReresolveUnderlyings resolves the underlyings again on a running service,
+// warning instead of failing when a configured one has no spot
+func ReresolveUnderlyings(scanner *options.Scanner, cfg *config.Config) {
+	configured := make(map[string]bool)
+	for _, uc := range cfg.Underlyings {
+		configured[uc.Underlying] = true
+	}
+	for _, res := range scanner.ResolveUnderlyings(cfg.GetSpotOverrides()) {
+		if !res.Resolved && configured[res.Underlying] {
+			log.Printf("Warning: cannot resolve spot of %s: %s", res.Underlying, res.Error)
+		}
 	}
+}
 
-	for i := 0; i < len(keys); i += batchSize {
-		end := i + batchSize
-		if end > len(keys) {
-			end = len(keys)
+// RefreshInstruments reloads the instrument master before each session opens.
+// Expired contracts are unsubscribed and dropped from the chains, the live
+// state of the rest is kept, and the filter criteria are applied again so new
+// expiries are subscribed.
+func RefreshInstruments(scanner *options.Scanner, subs *subscription.Manager, cal *calendar.Calendar, live *config.Live) {
+	for {
+		now := time.Now()
+		lead := time.Duration(live.Get().InstrumentRefresh.BeforeOpenMinutes) * time.Minute
+		open := cal.NextSessionOpen(now)
+		if !open.Add(-lead).After(now) {
+			// Already inside this session's lead time; the startup scan is fresh
+			open = cal.NextSessionOpen(open)
 		}
+		time.Sleep(time.Until(open.Add(-lead)))
 
-		batch := keys[i:end]
+		cfg := live.Get()
+		result, err := scanner.RefreshInstruments(time.Now())
+		if err != nil {
+			log.Printf("Error refreshing instruments, keeping the current chains: %v", err)
+			continue
+		}
+		for _, ref := range result.RemovedExpiries {
+			log.Printf("Expired %s %s", ref.Underlying, ref.Expiry.Format("2006-01-02"))
+		}
+		for _, ref := range result.AddedExpiries {
+			log.Printf("New expiry %s %s", ref.Underlying, ref.Expiry.Format("2006-01-02"))
+		}
+		subs.Drop(result.Removed)
 
-		if err := ticker.Subscribe(batch); err == nil {
-			err = ticker.SetFullMode(batch)
-			if err != nil {
-				log.Printf("Error setting mode for batch %d-%d: %v", i, end, err)
-			} else {
-				log.Printf("Successfully subscribed batch %d-%d (%d tokens)", i, end, len(batch))
-				for _, key := range batch {
-					fmt.Println(allTokensMap[key])
-				}
-			}
+		ReresolveUnderlyings(scanner, cfg)
+		if loaded, err := scanner.LoadOISnapshot(cfg.OISnapshotFile, time.Now()); err != nil {
+			log.Printf("Previous close OI not loaded: %v", err)
 		} else {
-			log.Printf("Error subscribing batch %d-%d: %v", i, end, err)
+			log.Printf("Loaded previous close OI for %d options", loaded)
 		}
 
-		// Add delay between batches to avoid rate limiting
-		if i+batchSize < len(keys) {
-			time.Sleep(batchDelay)
+		allCriteria, err := cfg.GetAllFilterCriteria()
+		if err != nil {
+			log.Printf("Error applying filter criteria: %v", err)
+			continue
 		}
+		delta := subs.Apply(allCriteria)
+		log.Printf("Refreshed %d instruments: %d added, %d removed, %d kept their state; subscribed %d, unsubscribed %d",
+			result.Instruments, len(result.Added), len(result.Removed), result.Migrated,
+			len(delta.Subscribed), len(delta.Unsubscribed))
 	}
 }
 
-// SubscribeToUnderlyings subscribes to underlying tokens for price tracking
-func SubscribeToUnderlyings(scanner *options.Scanner, ticker *kiteticker.ExtendedTicker, cfg *config.Config) {
-	// Get all unique underlyings from config
-	underlyingSet := make(map[string]bool)
+// ResolveUnderlyings resolves every underlying to its spot instrument and
+// fails when a configured underlying has no spot, rather than pricing it off
+// the wrong instrument
+func ResolveUnderlyings(scanner *options.Scanner, cfg *config.Config) {
+	configured := make(map[string]bool)
 	for _, uc := range cfg.Underlyings {
-		underlyingSet[uc.Underlying] = true
+		configured[uc.Underlying] = true
+	}
+
+	unresolved := 0
+	for _, res := range scanner.ResolveUnderlyings(cfg.GetSpotOverrides()) {
+		switch {
+		case res.Resolved && configured[res.Underlying]:
+			log.Printf("Resolved %s to %s:%s (token %d, near future %d)", res.Underlying, res.Spot.Exchange, res.Spot.Tradingsymbol, res.SpotToken, res.FutureToken)
+		case !res.Resolved && configured[res.Underlying]:
+			log.Fatalf("Cannot resolve spot of %s: %s. Set \"spot\" for it in config.json", res.Underlying, res.Error)
+		case !res.Resolved:
+			unresolved++
+			log.Printf("Warning: cannot resolve spot of %s: %s", res.Underlying, res.Error)
+		}
+		delete(configured, res.Underlying)
+	}
+
+	for underlying := range configured {
+		log.Printf("Warning: no options or futures found for configured underlying %s", underlying)
+	}
+	if unresolved > 0 {
+		log.Printf("Warning: %d underlyings have no spot and are priced without it", unresolved)
+	}
+}
+
+// TrackPositions periodically fetches the open positions so their tokens
+// stream in full mode
+func TrackPositions(kc *kiteconnect.Client, subs *subscription.Manager, interval time.Duration) {
+	for {
+		positions, err := kc.GetPositions()
+		if err != nil {
+			log.Printf("Error fetching positions for the mode policy: %v", err)
+		} else {
+			tokens := make([]uint32, 0, len(positions.Net))
+			for _, p := range positions.Net {
+				if p.Quantity != 0 {
+					tokens = append(tokens, p.InstrumentToken)
+				}
+			}
+			subs.SetPositions(tokens)
+		}
+		time.Sleep(interval)
 	}
+}
 
-	// Find underlying tokens from instruments
-	underlyingTokens := make([]uint32, 0)
+// StartCashArbitrage pairs the configured NSE and BSE listings, subscribes both
+// legs and pushes the ranked opportunities on the "arbitrage" /ws channel
+func StartCashArbitrage(scanner *options.Scanner, subs *subscription.Manager, cfg *config.Config) {
 	allInstruments, err := scanner.GetAllInstruments()
 	if err != nil {
-		log.Printf("Warning: Could not get all instruments to find underlying tokens: %v", err)
+		log.Printf("Warning: Could not get instruments for cash arbitrage: %v", err)
 		return
 	}
 
-	for _, inst := range allInstruments {
-		// Check if this is an underlying (not an option) and matches our config
-		if inst.InstrumentType == "EQ" && inst.Exchange == "NSE" { // For Now we are only interested in Equity Instruments not in Futures or Options
-			if (inst.Tradingsymbol == "NIFTY 50" && underlyingSet["NIFTY"]) || underlyingSet[inst.Tradingsymbol] {
-				underlyingTokens = append(underlyingTokens, uint32(inst.InstrumentToken))
-				log.Printf("Found underlying token for %s: %d", inst.Tradingsymbol, inst.InstrumentToken)
-			}
-		}
+	arb := arbitrage.NewCashScanner(cfg.Arbitrage.Cash.ToCashParams())
+	for _, symbol := range arb.BuildPairs(allInstruments, cfg.Arbitrage.Cash.Symbols) {
+		log.Printf("Warning: %s is not listed on both NSE and BSE under the same symbol", symbol)
 	}
 
-	if len(underlyingTokens) > 0 {
-		log.Printf("Subscribing to %d underlying tokens", len(underlyingTokens))
-		if err := ticker.Subscribe(underlyingTokens); err != nil {
-			log.Printf("Error subscribing to underlying tokens: %v", err)
+	tokens := arb.Tokens()
+	log.Printf("Cash arbitrage: subscribing to %d NSE/BSE tokens", len(tokens))
+	subs.Pin(tokens)
+	cashArbitrage = arb
+
+	go func() {
+		interval := time.Duration(cfg.Arbitrage.Cash.PublishIntervalMs) * time.Millisecond
+		for now := range time.Tick(interval) {
+			arb.ExpireStale(now)
+			manager.Publish("arbitrage", arb.Opportunities(50, false))
+		}
+	}()
+}
+
+// StartOptionsArbitrage periodically scans the option chains for no-arbitrage
+// violations and pushes them on the "option_arbitrage" /ws channel
+func StartOptionsArbitrage(scanner *options.Scanner, cal *calendar.Calendar, riskFreeRate float64, cfg *config.Config) *arbitrage.OptionsDetector {
+	detector := arbitrage.NewOptionsDetector(scanner, cfg.Arbitrage.Options.ToOptionsParams(riskFreeRate))
+	detector.SetCalendar(cal)
+
+	go func() {
+		interval := time.Duration(cfg.Arbitrage.Options.ScanIntervalMs) * time.Millisecond
+		for now := range time.Tick(interval) {
+			manager.Publish("option_arbitrage", detector.Scan(now))
+		}
+	}()
+
+	return detector
+}
+
+// SaveOISnapshots saves the closing OI of every option shortly after each
+// session close, to be loaded as the previous close OI on the next start
+func SaveOISnapshots(scanner *options.Scanner, cal *calendar.Calendar, path string) {
+	for {
+		now := time.Now()
+		saveAt := cal.SessionClose(now).Add(time.Minute)
+		if !cal.IsTradingDay(now) || !now.Before(saveAt) {
+			next := cal.NextSessionOpen(now)
+			saveAt = cal.SessionClose(next).Add(time.Minute)
+		}
+
+		time.Sleep(time.Until(saveAt))
+
+		if err := scanner.SaveOISnapshot(path, saveAt); err != nil {
+			log.Printf("Error saving OI snapshot: %v", err)
 		} else {
-			if err := ticker.SetFullMode(underlyingTokens); err != nil {
-				log.Printf("Error setting mode for underlying tokens: %v", err)
-			}
+			log.Printf("Saved closing OI snapshot to %s", path)
 		}
 	}
 }
 
 // UpdateOptionData updates option data and calculates Greeks
 func UpdateOptionData(tick models.Tick, scanner *options.Scanner) {
+	// A future or spot tick moves the forwards options are priced off
+	if calculator != nil {
+		calculator.InvalidateForwards(tick.InstrumentToken)
+	} else {
+		scanner.InvalidateForwards(tick.InstrumentToken)
+	}
+
+	if scanner.UpdateFuture(tick) {
+		return
+	}
+
 	inst, ok := scanner.GetInstrument(tick.InstrumentToken)
 	if inst.InstrumentType != "CE" && inst.InstrumentType != "PE" {
 		return
@@ -337,18 +562,98 @@ func UpdateOptionData(tick models.Tick, scanner *options.Scanner) {
 		return
 	}
 
-	// Update option data
-	var optionData *options.OptionData
-	if inst.InstrumentType == options.Call && strikeData.Call != nil {
-		strikeData.Call.UpdateFromTick(tick)
-		optionData = strikeData.Call
-	} else if inst.InstrumentType == options.Put && strikeData.Put != nil {
-		strikeData.Put.UpdateFromTick(tick)
-		optionData = strikeData.Put
-	}
+	// Update option data and calculate Greeks, locked against snapshots
+	chain.Update(func() {
+		var optionData *options.OptionData
+		if inst.InstrumentType == options.Call && strikeData.Call != nil {
+			strikeData.Call.UpdateFromTick(tick)
+			optionData = strikeData.Call
+		} else if inst.InstrumentType == options.Put && strikeData.Put != nil {
+			strikeData.Put.UpdateFromTick(tick)
+			optionData = strikeData.Put
+		}
 
-	// Calculate Greeks if we have option data
-	if optionData != nil && calculator != nil {
-		calculator.CalculateAllGreeks(optionData, chain)
+		if optionData != nil && calculator != nil {
+			calculator.CalculateAllGreeks(optionData, chain)
+		}
+	})
+}
+
+// StartKiteFake serves a fake of the Kite API and ticker on a local port,
+// moving its market every second, and returns a client and ticker root url
+// for it
+func StartKiteFake() (*kiteconnect.Client, *url.URL) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Failed to start the Kite fake: %v", err)
 	}
+	fake := kitefake.New(kitefake.WithSeed(time.Now().UnixNano()))
+	go http.Serve(listener, fake)
+	go fake.Run(time.Second, nil)
+
+	addr := listener.Addr().String()
+	log.Printf("KITE_MOCK set: using the Kite fake on %s", addr)
+
+	kc := kiteconnect.New("kitefake")
+	kc.SetAccessToken("kitefake")
+	kc.SetBaseURI("http://" + addr)
+	return kc, &url.URL{Scheme: "ws", Host: addr, Path: "/ws"}
+}
+
+// StartHealth starts the session and tick staleness monitors, and returns the
+// readiness checks of the ticker, the session and the instruments
+func StartHealth(kc *kiteconnect.Client, scanner *options.Scanner, cal *calendar.Calendar, cfg *config.Config) (*health.Readiness, *health.StalenessMonitor) {
+	session := health.NewSessionMonitor(func() error {
+		_, err := kc.GetUserProfile()
+		return err
+	})
+	go session.Run(time.Duration(cfg.Health.SessionCheckMinutes)*time.Minute, nil)
+
+	readiness := health.NewReadiness()
+	readiness.Add("ticker", health.TickerCheck(ticker.Shards))
+	readiness.Add("session", session.Ready)
+	readiness.Add("instruments", health.InstrumentsCheck(scanner.InstrumentsLoadedAt, cal))
+
+	staleness := health.NewStalenessMonitor(ticker, store.GlobalStore, cal,
+		time.Duration(cfg.Health.StaleAfterSeconds)*time.Second, health.StaleAction(cfg.Health.StaleAction), cfg.Health.ReconnectStaleFraction)
+	go staleness.Run(time.Duration(cfg.Health.CheckIntervalSeconds)*time.Second, nil)
+	metrics.NewGaugeFunc("kite_stale_tokens", "Subscribed tokens without a tick within the staleness threshold.", nil,
+		func(emit func(float64, ...string)) {
+			emit(float64(len(staleness.Report().Stale)))
+		})
+
+	return readiness, staleness
+}
+
+// registerTickerMetrics exposes the subscriptions and connection state of the
+// ticker pool
+func registerTickerMetrics(pool *kiteticker.Pool) {
+	metrics.NewGaugeFunc("kite_subscribed_tokens", "Tokens subscribed on the Kite ticker by mode.", []string{"mode"},
+		func(emit func(float64, ...string)) {
+			for mode, n := range pool.ModeCounts() {
+				emit(float64(n), string(mode))
+			}
+		})
+	metrics.NewGaugeFunc("kite_ticker_connection_tokens", "Tokens subscribed on each ticker connection.", []string{"connection"},
+		func(emit func(float64, ...string)) {
+			for _, shard := range pool.Shards() {
+				emit(float64(shard.Tokens), strconv.Itoa(shard.ID))
+			}
+		})
+	metrics.NewGaugeFunc("kite_ticker_connection_up", "Whether each ticker connection is connected.", []string{"connection"},
+		func(emit func(float64, ...string)) {
+			for _, shard := range pool.Shards() {
+				up := 0.0
+				if shard.Connected {
+					up = 1
+				}
+				emit(up, strconv.Itoa(shard.ID))
+			}
+		})
+	metrics.NewCounterFunc("kite_ticker_reconnects_total", "Reconnect attempts of each ticker connection.", []string{"connection"},
+		func(emit func(float64, ...string)) {
+			for _, shard := range pool.Shards() {
+				emit(float64(shard.Reconnects), strconv.Itoa(shard.ID))
+			}
+		})
 }
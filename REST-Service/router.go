@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"rest-service/handlers"
+	"rest-service/internal/auth"
+	"rest-service/internal/metrics"
+	"rest-service/internal/payload"
+	"rest-service/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouter registers the routes of the service on a new engine. Probes and
+// metrics are open; market data needs a viewer key, account data and orders a
+// trader key, and config and the kill switch an admin key.
+func NewRouter(ctrl *handlers.Controller, authn *auth.Authenticator, origins auth.Origins) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/ws"}}), payload.RequestID(), gin.CustomRecovery(func(c *gin.Context, _ interface{}) {
+		payload.Error(c, http.StatusInternalServerError, "Internal server error")
+	}))
+	r.NoRoute(func(c *gin.Context) {
+		payload.Error(c, http.StatusNotFound, "Route not found")
+	})
+	r.Use(auth.CORS(origins))
+	if !authn.Enabled() {
+		r.Use(auth.CheckOrigin(origins)) // Nothing else stops other sites
+	}
+
+	r.GET("/metrics", gin.WrapH(metrics.DefaultRegistry.Handler()))
+	r.GET("/healthz", ctrl.Healthz)
+	r.GET("/readyz", ctrl.Readyz)
+
+	viewer := r.Group("/", authn.Require(auth.Viewer))
+	trader := r.Group("/", authn.Require(auth.Trader))
+	admin := r.Group("/", authn.Require(auth.Admin))
+
+	// WebSocket Endpoint, not logged as the key may be in the query
+	viewer.GET("/ws", auth.CheckOrigin(origins), func(c *gin.Context) {
+		manager.HandleNewConnection(c.Writer, c.Request)
+	})
+	viewer.GET("/feed/stale", ctrl.GetStaleTokens)
+
+	// Quote Endpoint (Read from Memory)
+	viewer.GET("/quote/:token", func(c *gin.Context) {
+		tokenStr := c.Param("token")
+		token, err := strconv.ParseUint(tokenStr, 10, 32)
+		if err != nil {
+			payload.Error(c, http.StatusBadRequest, "Invalid token")
+			return
+		}
+
+		tick, found := store.GlobalStore.Get(uint32(token))
+		if !found {
+			payload.Error(c, http.StatusNotFound, "Tick not found. Ensure Ticker is subscribed.")
+			return
+		}
+		payload.Respond(c, http.StatusOK, tick, payload.Tick{Tick: tick})
+	})
+
+	viewer.GET("/ltp/:token", func(c *gin.Context) {
+		tokenStr := c.Param("token")
+		token, err := strconv.ParseUint(tokenStr, 10, 32)
+		if err != nil {
+			payload.Error(c, http.StatusBadRequest, "Invalid token")
+			return
+		}
+
+		tick, found := store.GlobalStore.Get(uint32(token))
+		if !found {
+			payload.Error(c, http.StatusNotFound, "Tick not found. Ensure Ticker is subscribed.")
+			return
+		}
+		payload.Respond(c, http.StatusOK, gin.H{"ltp": tick.LastPrice}, nil)
+	})
+
+	viewer.GET("/instruments", payload.Compress(), ctrl.GetInstruments)
+	viewer.GET("/instruments/search", ctrl.SearchInstruments)
+	viewer.GET("/historical/:instrument_token/:interval", payload.Compress(), ctrl.GetHistoricalData)
+	viewer.GET("/underlyings", ctrl.GetUnderlyingResolutions)
+	viewer.GET("/subscriptions", ctrl.GetSubscriptions)
+	viewer.GET("/arbitrage/cash", ctrl.GetCashArbitrage)
+	viewer.GET("/arbitrage/cash/history", ctrl.GetCashArbitrageHistory)
+	viewer.GET("/arbitrage/options", ctrl.GetOptionsArbitrage)
+	viewer.GET("/arbitrage/options/history", ctrl.GetOptionsArbitrageHistory)
+	viewer.GET("/futures", ctrl.GetFutureUnderlyings)
+	viewer.GET("/futures/:underlying", ctrl.GetFuturesCurve)
+	viewer.GET("/futures/:underlying/rollover", ctrl.GetFuturesRollover)
+	viewer.GET("/options", ctrl.GetOptionUnderlyings)
+	viewer.GET("/options/:underlying", ctrl.GetOptionExpiries)
+	viewer.GET("/options/:underlying/:expiry", payload.Compress(), ctrl.GetOptionChain)
+	viewer.GET("/options/:underlying/:expiry/analytics", ctrl.GetOptionChainAnalytics)
+	viewer.POST("/strategy/analyze", ctrl.AnalyzeStrategy)
+
+	trader.GET("/user/profile/full", ctrl.GetProfile)
+	trader.GET("/user/margins", ctrl.GetMargins)
+	trader.GET("/portfolio/holdings", ctrl.GetHoldings)
+	trader.GET("/portfolio/positions", ctrl.GetPositions)
+	trader.GET("/portfolio/greeks", ctrl.GetPortfolioGreeks)
+	trader.GET("/orders", ctrl.GetOrders)
+	trader.GET("/trades", ctrl.GetTrades)
+	trader.GET("/orders/:order_id", ctrl.GetOrderHistory)
+	trader.GET("/orders/:order_id/trades", ctrl.GetOrderTrades)
+	trader.POST("/orders/:variety", ctrl.PlaceOrder)
+	trader.PUT("/orders/:variety/:order_id", ctrl.ModifyOrder)
+	trader.DELETE("/orders/:variety/:order_id", ctrl.CancelOrder)
+
+	admin.GET("/config/underlyings", ctrl.GetConfiguredUnderlyings)
+	admin.GET("/config/underlyings/:underlying", ctrl.GetConfiguredUnderlying)
+	admin.POST("/config/underlyings", ctrl.AddUnderlying)
+	admin.PUT("/config/underlyings/:underlying", ctrl.UpdateUnderlying)
+	admin.DELETE("/config/underlyings/:underlying", ctrl.RemoveUnderlying)
+	admin.GET("/admin/subscriptions/modes", ctrl.GetSubscriptionModes)
+	admin.GET("/admin/kill-switch", ctrl.GetKillSwitch)
+	admin.PUT("/admin/kill-switch", ctrl.SetKillSwitch)
+
+	return r
+}
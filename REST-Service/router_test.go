@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rest-service/handlers"
+	"rest-service/internal/auth"
+	"rest-service/internal/killswitch"
+	"rest-service/internal/kitefake"
+	"rest-service/internal/options"
+	"rest-service/internal/payload"
+
+	kiteconnect "gokiteconnect-master"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// testOrigin is the browser origin the test routers allow.
+const testOrigin = "http://localhost:3000"
+
+// newTestRouter returns the service's router backed by a Kite fake,
+// authenticating with keys if any and with authentication disabled if not.
+func newTestRouter(t *testing.T, keys ...auth.Key) (*gin.Engine, *kitefake.Server) {
+	gin.SetMode(gin.TestMode)
+
+	fake := kitefake.New()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	kc := kiteconnect.New("api_key")
+	kc.SetAccessToken("access_token")
+	kc.SetBaseURI(srv.URL)
+	kc.SetRetryPolicy(kiteconnect.RetryPolicy{})
+	for class := range kiteconnect.DefaultRateLimits() {
+		kc.SetRateLimit(class, kiteconnect.RateLimit{})
+	}
+
+	scanner := options.NewScanner(kc)
+	require.NoError(t, scanner.ScanInstruments())
+	ctrl := handlers.NewController(kc, scanner)
+	ctrl.KillSwitch = &killswitch.Switch{}
+	authn := auth.NewDisabledAuthenticator()
+	if len(keys) > 0 {
+		authn = auth.NewAuthenticator(keys)
+	}
+	return NewRouter(ctrl, authn, auth.Origins{testOrigin}), fake
+}
+
+// envelope is a response envelope with its data left encoded.
+type envelope struct {
+	Status    string          `json:"status"`
+	ErrorType string          `json:"error_type"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+	RequestID string          `json:"request_id"`
+}
+
+// serveEnvelope sends a request with an optional JSON body, returning the
+// status and the response envelope.
+func serveEnvelope(t *testing.T, r *gin.Engine, method, path, body string) (int, envelope) {
+	t.Helper()
+	return send(t, r, newRequest(method, path, body))
+}
+
+// newRequest returns a request with an optional JSON body.
+func newRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// send serves a request, returning the status and the response envelope.
+func send(t *testing.T, r *gin.Engine, req *http.Request) (int, envelope) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var env envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env), w.Body.String())
+	require.NotEmpty(t, env.RequestID)
+	require.Equal(t, env.RequestID, w.Header().Get(payload.RequestIDHeader))
+	return w.Code, env
+}
+
+// serve sends a request like serveEnvelope, decoding the data of a success
+// envelope into v.
+func serve(t *testing.T, r *gin.Engine, method, path, body string, v interface{}) int {
+	t.Helper()
+	code, env := serveEnvelope(t, r, method, path, body)
+	if code < http.StatusBadRequest {
+		require.Equal(t, payload.StatusSuccess, env.Status)
+		if v != nil {
+			require.NoError(t, json.Unmarshal(env.Data, v), string(env.Data))
+		}
+	}
+	return code
+}
+
+func TestAccountRoutes(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	var profile map[string]interface{}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/user/profile/full", "", &profile))
+	require.Equal(t, kitefake.UserID, profile["user_id"])
+
+	var margins kiteconnect.AllMargins
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/user/margins", "", &margins))
+	require.Equal(t, kitefake.DefaultCash, margins.Equity.Net)
+
+	var holdings []map[string]interface{}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/portfolio/holdings", "", &holdings))
+	require.Len(t, holdings, 3)
+
+	var candles struct {
+		Candles [][]interface{} `json:"candles"`
+	}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/historical/408065/day?from=2025-01-06&to=2025-01-10", "", &candles))
+	require.Len(t, candles.Candles, 5)
+
+	var underlyings []interface{}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/options", "", &underlyings))
+	require.NotEmpty(t, underlyings)
+}
+
+func TestOrderRoutes(t *testing.T) {
+	r, fake := newTestRouter(t)
+	require.NoError(t, fake.SetPrice("NSE", "INFY", 1500))
+
+	var placed kiteconnect.OrderResponse
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodPost, "/orders/regular",
+		`{"exchange":"NSE","tradingsymbol":"INFY","transaction_type":"BUY","order_type":"MARKET","product":"CNC","quantity":5}`, &placed))
+	require.NotEmpty(t, placed.OrderID)
+
+	var orders []map[string]interface{}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/orders", "", &orders))
+	require.Len(t, orders, 1)
+	require.Equal(t, kiteconnect.OrderStatusComplete, orders[0]["status"])
+
+	var history, trades []map[string]interface{}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/orders/"+placed.OrderID, "", &history))
+	require.Equal(t, kiteconnect.OrderStatusComplete, history[len(history)-1]["status"])
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/orders/"+placed.OrderID+"/trades", "", &trades))
+	require.Len(t, trades, 1)
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/trades", "", &trades))
+	require.Len(t, trades, 1)
+
+	var positions kiteconnect.Positions
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/portfolio/positions", "", &positions))
+	require.Len(t, positions.Net, 1)
+	require.Equal(t, 5, positions.Net[0].Quantity)
+
+	// A resting limit order is modified and cancelled, after which neither is allowed
+	var resting kiteconnect.OrderResponse
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodPost, "/orders/regular",
+		`{"exchange":"NSE","tradingsymbol":"INFY","transaction_type":"BUY","order_type":"LIMIT","product":"CNC","quantity":5,"price":1400}`, &resting))
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodPut, "/orders/regular/"+resting.OrderID, `{"price":1405}`, nil))
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodDelete, "/orders/regular/"+resting.OrderID, "", nil))
+
+	code, failed := serveEnvelope(t, r, http.MethodDelete, "/orders/regular/"+resting.OrderID, "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, kiteconnect.OrderError, failed.ErrorType)
+	require.Contains(t, failed.Message, "cancelled")
+
+	// A malformed order never reaches Kite
+	code, failed = serveEnvelope(t, r, http.MethodPost, "/orders/regular", `{"quantity":"five"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, kiteconnect.InputError, failed.ErrorType)
+}
+
+func TestKiteErrors(t *testing.T) {
+	r, fake := newTestRouter(t)
+
+	// Kite's status and error type are passed on, so an expired session is
+	// told apart from Kite being down
+	fake.Fail(http.MethodGet, "/portfolio/positions", http.StatusServiceUnavailable, kiteconnect.NetworkError, "Kite is down")
+	code, failed := serveEnvelope(t, r, http.MethodGet, "/portfolio/positions", "")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, payload.StatusError, failed.Status)
+	require.Equal(t, kiteconnect.NetworkError, failed.ErrorType)
+	require.Equal(t, "Kite is down", failed.Message)
+
+	fake.Fail(http.MethodGet, "/portfolio/positions", http.StatusForbidden, kiteconnect.TokenError, "Incorrect `api_key` or `access_token`.")
+	code, failed = serveEnvelope(t, r, http.MethodGet, "/portfolio/positions", "")
+	require.Equal(t, http.StatusForbidden, code)
+	require.Equal(t, kiteconnect.TokenError, failed.ErrorType)
+
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/portfolio/positions", "", nil))
+
+	code, failed = serveEnvelope(t, r, http.MethodGet, "/no/such/route", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, kiteconnect.InputError, failed.ErrorType)
+}
+
+func TestAuth(t *testing.T) {
+	keys := map[string]auth.Role{"view-key": auth.Viewer, "trade-key": auth.Trader, "admin-key": auth.Admin}
+	var configured []auth.Key
+	for key, role := range keys {
+		configured = append(configured, auth.Key{Name: role.String(), Role: role, Hash: auth.HashKey(key)})
+	}
+	r, _ := newTestRouter(t, configured...)
+
+	as := func(key, method, path string) int {
+		req := newRequest(method, path, "")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		code, _ := send(t, r, req)
+		return code
+	}
+
+	// Each role reaches its own routes and those of the roles below it
+	require.Equal(t, http.StatusUnauthorized, as("", http.MethodGet, "/options"))
+	require.Equal(t, http.StatusUnauthorized, as("wrong-key", http.MethodGet, "/options"))
+	require.Equal(t, http.StatusOK, as("view-key", http.MethodGet, "/options"))
+	require.Equal(t, http.StatusForbidden, as("view-key", http.MethodGet, "/orders"))
+	require.Equal(t, http.StatusOK, as("trade-key", http.MethodGet, "/orders"))
+	require.Equal(t, http.StatusForbidden, as("trade-key", http.MethodGet, "/admin/kill-switch"))
+	require.Equal(t, http.StatusOK, as("admin-key", http.MethodGet, "/admin/kill-switch"))
+	require.Equal(t, http.StatusOK, as("admin-key", http.MethodGet, "/orders"))
+	require.Equal(t, http.StatusOK, as("", http.MethodGet, "/healthz"))
+
+	req := newRequest(http.MethodGet, "/orders", "")
+	req.Header.Set("X-API-Key", "view-key")
+	code, failed := send(t, r, req)
+	require.Equal(t, http.StatusForbidden, code)
+	require.Equal(t, payload.PermissionError, failed.ErrorType)
+
+	// A bad key is the service's own auth error, not Kite's session one
+	code, failed = send(t, r, newRequest(http.MethodGet, "/options", ""))
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Equal(t, payload.AuthError, failed.ErrorType)
+
+	// The key is only taken from the query on /ws, where browsers can't set
+	// headers, and /ws refuses other origins
+	require.Equal(t, http.StatusUnauthorized, as("", http.MethodGet, "/options?api_key=view-key"))
+	require.Equal(t, http.StatusUnauthorized, as("", http.MethodGet, "/ws"))
+	req = newRequest(http.MethodGet, "/ws?api_key=view-key", "")
+	req.Header.Set("Origin", "https://evil.example.com")
+	code, failed = send(t, r, req)
+	require.Equal(t, http.StatusForbidden, code)
+	require.Equal(t, "Origin not allowed", failed.Message)
+	require.Equal(t, payload.PermissionError, failed.ErrorType)
+
+	// CORS headers are only sent to allowed origins
+	for origin, allowed := range map[string]string{testOrigin: testOrigin, "https://evil.example.com": ""} {
+		req := newRequest(http.MethodOptions, "/orders", "")
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, allowed, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestAuthDisabledOrigins(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	// Without keys, other sites' form posts and sockets are refused
+	for _, path := range []string{"/orders", "/ws"} {
+		method := http.MethodPost
+		if path == "/ws" {
+			method = http.MethodGet
+		}
+		req := newRequest(method, path, "")
+		req.Header.Set("Origin", "https://evil.example.com")
+		code, failed := send(t, r, req)
+		require.Equal(t, http.StatusForbidden, code, path)
+		require.Equal(t, "Origin not allowed", failed.Message)
+	}
+	req := newRequest(http.MethodGet, "/orders", "")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	code, _ := send(t, r, req)
+	require.Equal(t, http.StatusForbidden, code)
+
+	// The allowed origin and clients other than browsers get through
+	req = newRequest(http.MethodGet, "/orders", "")
+	req.Header.Set("Origin", testOrigin)
+	code, _ = send(t, r, req)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = send(t, r, newRequest(http.MethodGet, "/orders", ""))
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestKillSwitch(t *testing.T) {
+	r, fake := newTestRouter(t)
+	require.NoError(t, fake.SetPrice("NSE", "INFY", 1500))
+
+	var resting kiteconnect.OrderResponse
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodPost, "/orders/regular",
+		`{"exchange":"NSE","tradingsymbol":"INFY","transaction_type":"BUY","order_type":"LIMIT","product":"CNC","quantity":5,"price":1400}`, &resting))
+
+	code, _ := serveEnvelope(t, r, http.MethodPut, "/admin/kill-switch", `{"engaged":true}`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	var engaged struct {
+		KillSwitch killswitch.State `json:"kill_switch"`
+		Cancelled  []string         `json:"cancelled"`
+	}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodPut, "/admin/kill-switch",
+		`{"engaged":true,"reason":"runaway strategy","cancel_open_orders":true}`, &engaged))
+	require.True(t, engaged.KillSwitch.Engaged)
+	require.Equal(t, auth.Anonymous.Name, engaged.KillSwitch.By)
+	require.Equal(t, []string{resting.OrderID}, engaged.Cancelled)
+
+	// Orders can't be placed or modified, only cancelled
+	code, failed := serveEnvelope(t, r, http.MethodPost, "/orders/regular",
+		`{"exchange":"NSE","tradingsymbol":"INFY","transaction_type":"BUY","order_type":"MARKET","product":"CNC","quantity":5}`)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, kiteconnect.OrderError, failed.ErrorType)
+	require.Contains(t, failed.Message, "runaway strategy")
+	code, _ = serveEnvelope(t, r, http.MethodPut, "/orders/regular/"+resting.OrderID, `{"price":1405}`)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	var orders []map[string]interface{}
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodGet, "/orders", "", &orders))
+	require.Len(t, orders, 1)
+	require.Equal(t, kiteconnect.OrderStatusCancelled, orders[0]["status"])
+
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodPut, "/admin/kill-switch", `{"engaged":false}`, nil))
+	require.Equal(t, http.StatusOK, serve(t, r, http.MethodPost, "/orders/regular",
+		`{"exchange":"NSE","tradingsymbol":"INFY","transaction_type":"BUY","order_type":"MARKET","product":"CNC","quantity":5}`, nil))
+}
@@ -1,5 +1,51 @@
 const API_BASE = 'http://localhost:8080';
 
+// Every JSON response is wrapped in an envelope. On failure error_type is the
+// Kite exception (OrderException, TokenException, NetworkException, ...) so a
+// rejected order can be told apart from an expired session or Kite being down.
+// The service's own API key checks fail with AuthException (401) and
+// PermissionException (403).
+export interface ApiEnvelope<T> {
+  status: 'success' | 'error';
+  data: T;
+  error_type?: string;
+  message?: string;
+  request_id?: string;
+}
+
+export class ApiError extends Error {
+  status: number;
+  errorType: string;
+  requestId?: string;
+
+  constructor(status: number, errorType: string, message: string, requestId?: string) {
+    super(message);
+    this.name = 'ApiError';
+    this.status = status;
+    this.errorType = errorType;
+    this.requestId = requestId;
+  }
+}
+
+// API key sent to the REST service when it has authentication enabled
+const API_KEY = import.meta.env.VITE_API_KEY;
+
+// request fetches a URL and returns the data of its envelope, throwing an
+// ApiError with the server's message on failure
+async function request<T>(url: string, what: string): Promise<T> {
+  const response = await fetch(url, API_KEY ? { headers: { Authorization: `Bearer ${API_KEY}` } } : undefined);
+  const body: ApiEnvelope<T> | null = await response.json().catch(() => null);
+  if (!response.ok || !body || body.status !== 'success') {
+    throw new ApiError(
+      response.status,
+      body?.error_type ?? 'GeneralException',
+      body?.message || `Failed to fetch ${what}: ${response.statusText}`,
+      body?.request_id ?? response.headers.get('X-Request-ID') ?? undefined
+    );
+  }
+  return body.data;
+}
+
 export interface QuoteResponse {
   instrument_token: number;
   last_price: number;
@@ -7,19 +53,11 @@ export interface QuoteResponse {
 }
 
 export async function getQuote(token: number): Promise<QuoteResponse> {
-  const response = await fetch(`${API_BASE}/quote/${token}`);
-  if (!response.ok) {
-    throw new Error(`Failed to fetch quote: ${response.statusText}`);
-  }
-  return response.json();
+  return request(`${API_BASE}/quote/${token}`, 'quote');
 }
 
 export async function getLTP(token: number): Promise<{ ltp: number }> {
-  const response = await fetch(`${API_BASE}/ltp/${token}`);
-  if (!response.ok) {
-    throw new Error(`Failed to fetch LTP: ${response.statusText}`);
-  }
-  return response.json();
+  return request(`${API_BASE}/ltp/${token}`, 'LTP');
 }
 
 export interface InstrumentResponse {
@@ -93,19 +131,11 @@ export async function getOptionChain(
   if (expiry) {
     params.set('expiry', expiry);
   }
-  const response = await fetch(`${API_BASE}/options/chain?${params.toString()}`);
-  if (!response.ok) {
-    throw new Error(`Failed to fetch option chain: ${response.statusText}`);
-  }
-  return response.json();
+  return request(`${API_BASE}/options/chain?${params.toString()}`, 'option chain');
 }
 
 export async function getInstruments(): Promise<ParsedInstrument[]> {
-  const response = await fetch(`${API_BASE}/instruments`);
-  if (!response.ok) {
-    throw new Error(`Failed to fetch instruments: ${response.statusText}`);
-  }
-  const data: InstrumentResponse = await response.json();
+  const data = await request<InstrumentResponse>(`${API_BASE}/instruments`, 'instruments');
   
   // Create reverse enum maps for decoding
   const exchangeMap = new Map<number, string>();
@@ -166,10 +196,7 @@ export interface HistoricalCandle {
 }
 
 export interface HistoricalDataResponse {
-  status: string;
-  data: {
-    candles: Array<[string, number, number, number, number, number, number?]>;
-  };
+  candles: Array<[string, number, number, number, number, number, number?]>;
 }
 
 /**
@@ -202,21 +229,10 @@ export async function getHistoricalData(
     url.searchParams.set('oi', '1');
   }
 
-  const response = await fetch(url.toString());
-
-  if (!response.ok) {
-    const errorData = await response.json().catch(() => ({}));
-    throw new Error(errorData.error || `Failed to fetch historical data: ${response.statusText}`);
-  }
-
-  const data: HistoricalDataResponse = await response.json();
-
-  if (data.status !== 'success') {
-    throw new Error('Historical data API returned error status');
-  }
+  const data = await request<HistoricalDataResponse>(url.toString(), 'historical data');
 
   // Parse candles: [timestamp, open, high, low, close, volume, oi?]
-  return data.data.candles.map((candle) => ({
+  return data.candles.map((candle) => ({
     timestamp: candle[0] as string,
     open: candle[1] as number,
     high: candle[2] as number,
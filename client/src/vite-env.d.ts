@@ -3,6 +3,7 @@
 interface ImportMetaEnv {
   readonly VITE_ENCTOKEN: string;
   readonly VITE_USER_ID?: string;
+  readonly VITE_API_KEY?: string;
 }
 
 interface ImportMeta {
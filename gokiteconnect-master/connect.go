@@ -1,6 +1,7 @@
 package kiteconnect
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -16,14 +17,22 @@ type PlainResponse struct {
 
 // Client represents interface for Kite Connect client.
 type Client struct {
-	apiKey      string
-	accessToken string
-	encToken    string
-	debug       bool
-	baseURI     string
-	httpClient  HTTPClient
+	apiKey         string
+	accessToken    string
+	encToken       string
+	debug          bool
+	baseURI        string
+	instrumentsURI string // The instruments dump is only served by the API
+	httpClient     HTTPClient
+	requestHook    RequestHook
+	limiter        *rateLimiter
+	retry          RetryPolicy
 }
 
+// RequestHook is called after every API request with its method, URI without
+// the base URI, duration and error, nil on success.
+type RequestHook func(method, uri string, elapsed time.Duration, err error)
+
 const (
 	name           string        = "gokiteconnect"
 	version        string        = "4.0.2"
@@ -154,8 +163,11 @@ const (
 // New creates a new Kite Connect client.
 func New(apiKey string) *Client {
 	client := &Client{
-		apiKey:  apiKey,
-		baseURI: baseURI,
+		apiKey:         apiKey,
+		baseURI:        baseURI,
+		instrumentsURI: baseURI,
+		limiter:        newRateLimiter(DefaultRateLimits()),
+		retry:          DefaultRetryPolicy,
 	}
 
 	// Create a default http handler with default timeout.
@@ -169,8 +181,11 @@ func New(apiKey string) *Client {
 // NewWithEncToken creates a new Kite Connect client with enc token.
 func NewWithEncToken(encToken string) *Client {
 	client := &Client{
-		encToken: encToken,
-		baseURI:  kiteBaseURIOMS,
+		encToken:       encToken,
+		baseURI:        kiteBaseURIOMS,
+		instrumentsURI: baseURI,
+		limiter:        newRateLimiter(DefaultRateLimits()),
+		retry:          DefaultRetryPolicy,
 	}
 
 	// Create a default http handler with default timeout.
@@ -193,9 +208,11 @@ func (c *Client) SetDebug(debug bool) {
 	c.httpClient.GetClient().debug = debug
 }
 
-// SetBaseURI overrides the base Kiteconnect API endpoint with custom url.
+// SetBaseURI overrides the base Kiteconnect API endpoint with custom url,
+// including for the instruments dump.
 func (c *Client) SetBaseURI(baseURI string) {
 	c.baseURI = baseURI
+	c.instrumentsURI = baseURI
 }
 
 // SetTimeout sets request timeout for default http client.
@@ -204,6 +221,12 @@ func (c *Client) SetTimeout(timeout time.Duration) {
 	hClient.Timeout = timeout
 }
 
+// SetRequestHook sets a hook called after every API request, such as for
+// metrics.
+func (c *Client) SetRequestHook(h RequestHook) {
+	c.requestHook = h
+}
+
 // SetAccessToken sets the access token to the Kite Connect instance.
 func (c *Client) SetAccessToken(accessToken string) {
 	c.accessToken = accessToken
@@ -220,7 +243,7 @@ func (c *Client) GetLoginURLWithparams(p url.Values) string {
 		kiteBaseURI, c.apiKey, kiteHeaderVersion, url.QueryEscape(p.Encode()))
 }
 
-func (c *Client) doEnvelope(method, uri string, params url.Values, headers http.Header, v interface{}) error {
+func (c *Client) doEnvelope(ctx context.Context, method, uri string, params url.Values, headers http.Header, v interface{}) error {
 	if params == nil {
 		params = url.Values{}
 	}
@@ -243,10 +266,13 @@ func (c *Client) doEnvelope(method, uri string, params url.Values, headers http.
 	}
 
 	fmt.Printf("%s%s\n", c.baseURI, uri)
-	return c.httpClient.DoEnvelope(method, c.baseURI+uri, params, headers, v)
+	_, err := c.send(ctx, method, uri, func() (*HTTPResponse, error) {
+		return nil, c.httpClient.DoEnvelopeContext(ctx, method, c.baseURI+uri, params, headers, v)
+	})
+	return err
 }
 
-func (c *Client) do(method, uri string, params url.Values, headers http.Header) (HTTPResponse, error) {
+func (c *Client) do(ctx context.Context, method, uri string, params url.Values, headers http.Header) (HTTPResponse, error) {
 	if params == nil {
 		params = url.Values{}
 	}
@@ -266,16 +292,17 @@ func (c *Client) do(method, uri string, params url.Values, headers http.Header)
 		headers.Add("Authorization", authHeader)
 	}
 
-	if uri == URIGetInstruments {
-		fmt.Println("https://api.kite.trade/instruments")
-		return c.httpClient.Do(method, "https://api.kite.trade/instruments", nil, headers)
-	}
-	fmt.Printf("%s%s\n", c.baseURI, uri)
-
-	return c.httpClient.Do(method, c.baseURI+uri, params, headers)
+	return c.sendResponse(ctx, method, uri, func() (HTTPResponse, error) {
+		if uri == URIGetInstruments {
+			fmt.Printf("%s%s\n", c.instrumentsURI, uri)
+			return c.httpClient.DoContext(ctx, method, c.instrumentsURI+uri, nil, headers)
+		}
+		fmt.Printf("%s%s\n", c.baseURI, uri)
+		return c.httpClient.DoContext(ctx, method, c.baseURI+uri, params, headers)
+	})
 }
 
-func (c *Client) doRaw(method, uri string, reqBody []byte, headers http.Header) (HTTPResponse, error) {
+func (c *Client) doRaw(ctx context.Context, method, uri string, reqBody []byte, headers http.Header) (HTTPResponse, error) {
 	if headers == nil {
 		headers = map[string][]string{}
 	}
@@ -288,5 +315,52 @@ func (c *Client) doRaw(method, uri string, reqBody []byte, headers http.Header)
 		headers.Add("Authorization", authHeader)
 	}
 
-	return c.httpClient.DoRaw(method, c.baseURI+uri, reqBody, headers)
+	return c.sendResponse(ctx, method, uri, func() (HTTPResponse, error) {
+		return c.httpClient.DoRawContext(ctx, method, c.baseURI+uri, reqBody, headers)
+	})
+}
+
+// send rate limits a request and retries it per the retry policy, calling
+// the request hook after each attempt. req returns a nil response when it
+// parses the envelope itself. Waiting stops when ctx is done.
+func (c *Client) send(ctx context.Context, method, uri string, req func() (*HTTPResponse, error)) (*HTTPResponse, error) {
+	class := rateClass(method, uri)
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx, class); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := req()
+		c.afterRequest(method, uri, start, resp, err)
+		if !c.shouldRetry(method, attempt, resp, err) {
+			return resp, err
+		}
+		if err := sleep(ctx, c.retry.delay(attempt)); err != nil {
+			return resp, err
+		}
+	}
+}
+
+// sendResponse is send for requests whose response the caller parses.
+func (c *Client) sendResponse(ctx context.Context, method, uri string, req func() (HTTPResponse, error)) (HTTPResponse, error) {
+	resp, err := c.send(ctx, method, uri, func() (*HTTPResponse, error) {
+		resp, err := req()
+		return &resp, err
+	})
+	if resp == nil {
+		return HTTPResponse{}, err
+	}
+	return *resp, err
+}
+
+// afterRequest calls the request hook, if set. Error responses of requests
+// whose body the caller parses are reported as the Error in their envelope.
+func (c *Client) afterRequest(method, uri string, start time.Time, resp *HTTPResponse, err error) {
+	if c.requestHook == nil {
+		return
+	}
+	if err == nil && resp != nil && resp.Response != nil && resp.Response.StatusCode >= http.StatusBadRequest {
+		err = readEnvelope(*resp, nil)
+	}
+	c.requestHook(method, uri, time.Since(start), err)
 }
@@ -1,6 +1,7 @@
 package kiteconnect
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -150,6 +151,11 @@ type GTTResponse struct {
 
 // PlaceGTT constructs and places a GTT order using GTTParams.
 func (c *Client) PlaceGTT(o GTTParams) (GTTResponse, error) {
+	return c.PlaceGTTContext(context.Background(), o)
+}
+
+// PlaceGTTContext is PlaceGTT with a context.
+func (c *Client) PlaceGTTContext(ctx context.Context, o GTTParams) (GTTResponse, error) {
 	var (
 		params    = url.Values{}
 		gtt       = newGTT(o)
@@ -170,12 +176,17 @@ func (c *Client) PlaceGTT(o GTTParams) (GTTResponse, error) {
 	params.Add("condition", string(condition))
 	params.Add("orders", string(orders))
 
-	err = c.doEnvelope(http.MethodPost, URIPlaceGTT, params, nil, &orderResp)
+	err = c.doEnvelope(ctx, http.MethodPost, URIPlaceGTT, params, nil, &orderResp)
 	return orderResp, err
 }
 
 // ModifyGTT modifies the condition or orders inside an already created GTT order.
 func (c *Client) ModifyGTT(triggerID int, o GTTParams) (GTTResponse, error) {
+	return c.ModifyGTTContext(context.Background(), triggerID, o)
+}
+
+// ModifyGTTContext is ModifyGTT with a context.
+func (c *Client) ModifyGTTContext(ctx context.Context, triggerID int, o GTTParams) (GTTResponse, error) {
 	var (
 		params    = url.Values{}
 		gtt       = newGTT(o)
@@ -196,27 +207,42 @@ func (c *Client) ModifyGTT(triggerID int, o GTTParams) (GTTResponse, error) {
 	params.Add("condition", string(condition))
 	params.Add("orders", string(orders))
 
-	err = c.doEnvelope(http.MethodPut, fmt.Sprintf(URIModifyGTT, triggerID), params, nil, &orderResp)
+	err = c.doEnvelope(ctx, http.MethodPut, fmt.Sprintf(URIModifyGTT, triggerID), params, nil, &orderResp)
 	return orderResp, err
 }
 
 // GetGTTs returns the current GTTs for the user.
 func (c *Client) GetGTTs() (GTTs, error) {
+	return c.GetGTTsContext(context.Background())
+}
+
+// GetGTTsContext is GetGTTs with a context.
+func (c *Client) GetGTTsContext(ctx context.Context) (GTTs, error) {
 	var orders GTTs
-	err := c.doEnvelope(http.MethodGet, URIGetGTTs, nil, nil, &orders)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetGTTs, nil, nil, &orders)
 	return orders, err
 }
 
 // GetGTT returns a specific GTT for the user.
 func (c *Client) GetGTT(triggerID int) (GTT, error) {
+	return c.GetGTTContext(context.Background(), triggerID)
+}
+
+// GetGTTContext is GetGTT with a context.
+func (c *Client) GetGTTContext(ctx context.Context, triggerID int) (GTT, error) {
 	var order GTT
-	err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIGetGTT, triggerID), nil, nil, &order)
+	err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIGetGTT, triggerID), nil, nil, &order)
 	return order, err
 }
 
 // DeleteGTT deletes a GTT order.
 func (c *Client) DeleteGTT(triggerID int) (GTTResponse, error) {
+	return c.DeleteGTTContext(context.Background(), triggerID)
+}
+
+// DeleteGTTContext is DeleteGTT with a context.
+func (c *Client) DeleteGTTContext(ctx context.Context, triggerID int) (GTTResponse, error) {
 	var order GTTResponse
-	err := c.doEnvelope(http.MethodDelete, fmt.Sprintf(URIGetGTT, triggerID), nil, nil, &order)
+	err := c.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(URIGetGTT, triggerID), nil, nil, &order)
 	return order, err
 }
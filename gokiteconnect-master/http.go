@@ -6,6 +6,7 @@ package kiteconnect
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -22,6 +23,10 @@ type HTTPClient interface {
 	DoRaw(method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error)
 	DoEnvelope(method, url string, params url.Values, headers http.Header, obj interface{}) error
 	DoJSON(method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error)
+	DoContext(ctx context.Context, method, rURL string, params url.Values, headers http.Header) (HTTPResponse, error)
+	DoRawContext(ctx context.Context, method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error)
+	DoEnvelopeContext(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) error
+	DoJSONContext(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error)
 	GetClient() *httpClient
 }
 
@@ -74,15 +79,26 @@ func NewHTTPClient(h *http.Client, hLog *log.Logger, debug bool) HTTPClient {
 }
 
 func (h *httpClient) Do(method, rURL string, params url.Values, headers http.Header) (HTTPResponse, error) {
+	return h.DoContext(context.Background(), method, rURL, params, headers)
+}
+
+// DoContext is Do with a context.
+func (h *httpClient) DoContext(ctx context.Context, method, rURL string, params url.Values, headers http.Header) (HTTPResponse, error) {
 	if params == nil {
 		params = url.Values{}
 	}
 
-	return h.DoRaw(method, rURL, []byte(params.Encode()), headers)
+	return h.DoRawContext(ctx, method, rURL, []byte(params.Encode()), headers)
 }
 
 // Do executes an HTTP request and returns the response.
 func (h *httpClient) DoRaw(method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error) {
+	return h.DoRawContext(context.Background(), method, rURL, reqBody, headers)
+}
+
+// DoRawContext is DoRaw with a context. A request stopped by the context
+// returns the context's error.
+func (h *httpClient) DoRawContext(ctx context.Context, method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error) {
 	var (
 		resp     = HTTPResponse{}
 		err      error
@@ -94,7 +110,7 @@ func (h *httpClient) DoRaw(method, rURL string, reqBody []byte, headers http.Hea
 		postBody = bytes.NewReader(reqBody)
 	}
 
-	req, err := http.NewRequest(method, rURL, postBody)
+	req, err := http.NewRequestWithContext(ctx, method, rURL, postBody)
 	if err != nil {
 		h.hLog.Printf("Request preparation failed: %v", err)
 		return resp, NewError(NetworkError, "Request preparation failed.", nil)
@@ -118,6 +134,9 @@ func (h *httpClient) DoRaw(method, rURL string, reqBody []byte, headers http.Hea
 
 	r, err := h.client.Do(req)
 	if err != nil {
+		if ctx.Err() != nil {
+			return resp, ctx.Err()
+		}
 		h.hLog.Printf("Request failed: %v", err)
 		return resp, NewError(NetworkError, "Request failed.", nil)
 	}
@@ -141,7 +160,12 @@ func (h *httpClient) DoRaw(method, rURL string, reqBody []byte, headers http.Hea
 
 // DoEnvelope makes an HTTP request and parses the JSON response (fastglue envelop structure)
 func (h *httpClient) DoEnvelope(method, url string, params url.Values, headers http.Header, obj interface{}) error {
-	resp, err := h.Do(method, url, params, headers)
+	return h.DoEnvelopeContext(context.Background(), method, url, params, headers, obj)
+}
+
+// DoEnvelopeContext is DoEnvelope with a context.
+func (h *httpClient) DoEnvelopeContext(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) error {
+	resp, err := h.DoContext(ctx, method, url, params, headers)
 	if err != nil {
 		return err
 	}
@@ -180,7 +204,12 @@ func readEnvelope(resp HTTPResponse, obj interface{}) error {
 
 // DoJSON makes an HTTP request and parses the JSON response.
 func (h *httpClient) DoJSON(method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error) {
-	resp, err := h.Do(method, url, params, headers)
+	return h.DoJSONContext(context.Background(), method, url, params, headers, obj)
+}
+
+// DoJSONContext is DoJSON with a context.
+func (h *httpClient) DoJSONContext(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error) {
+	resp, err := h.DoContext(ctx, method, url, params, headers)
 	if err != nil {
 		return resp, err
 	}
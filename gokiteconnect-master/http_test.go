@@ -1 +1,58 @@
 package kiteconnect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContextCancellation(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Write([]byte(`{"status":"success","data":{}}`))
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := New("api_key")
+	client.SetBaseURI(srv.URL)
+	client.SetRateLimit(RateClassDefault, RateLimit{Rate: 1, Burst: 1})
+	if _, err := client.GetUserProfile(); err != nil {
+		t.Fatalf("GetUserProfile() error = %v", err)
+	}
+
+	// The deadline passes waiting for the rate limit, before sending
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetUserProfileContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetUserProfileContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+
+	// A cancelled request in flight returns and is not retried
+	client.SetRateLimit(RateClassDefault, RateLimit{})
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := client.GetOrdersContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetOrdersContext() error = %v, want %v", err, context.Canceled)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+}
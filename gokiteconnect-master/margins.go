@@ -1,6 +1,7 @@
 package kiteconnect
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
@@ -113,6 +114,11 @@ type GetChargesParams struct {
 }
 
 func (c *Client) GetOrderMargins(marparam GetMarginParams) ([]OrderMargins, error) {
+	return c.GetOrderMarginsContext(context.Background(), marparam)
+}
+
+// GetOrderMarginsContext is GetOrderMargins with a context.
+func (c *Client) GetOrderMarginsContext(ctx context.Context, marparam GetMarginParams) ([]OrderMargins, error) {
 	body, err := json.Marshal(marparam.OrderParams)
 	if err != nil {
 		return []OrderMargins{}, err
@@ -126,7 +132,7 @@ func (c *Client) GetOrderMargins(marparam GetMarginParams) ([]OrderMargins, erro
 		uri += "?mode=compact"
 	}
 
-	resp, err := c.doRaw(http.MethodPost, uri, body, headers)
+	resp, err := c.doRaw(ctx, http.MethodPost, uri, body, headers)
 	if err != nil {
 		return []OrderMargins{}, err
 	}
@@ -140,6 +146,11 @@ func (c *Client) GetOrderMargins(marparam GetMarginParams) ([]OrderMargins, erro
 }
 
 func (c *Client) GetBasketMargins(baskparam GetBasketParams) (BasketMargins, error) {
+	return c.GetBasketMarginsContext(context.Background(), baskparam)
+}
+
+// GetBasketMarginsContext is GetBasketMargins with a context.
+func (c *Client) GetBasketMarginsContext(ctx context.Context, baskparam GetBasketParams) (BasketMargins, error) {
 	body, err := json.Marshal(baskparam.OrderParams)
 	if err != nil {
 		return BasketMargins{}, err
@@ -161,7 +172,7 @@ func (c *Client) GetBasketMargins(baskparam GetBasketParams) (BasketMargins, err
 		uri += "?" + qp
 	}
 
-	resp, err := c.doRaw(http.MethodPost, uri, body, headers)
+	resp, err := c.doRaw(ctx, http.MethodPost, uri, body, headers)
 	if err != nil {
 		return BasketMargins{}, err
 	}
@@ -175,6 +186,11 @@ func (c *Client) GetBasketMargins(baskparam GetBasketParams) (BasketMargins, err
 }
 
 func (c *Client) GetOrderCharges(chargeParam GetChargesParams) ([]OrderCharges, error) {
+	return c.GetOrderChargesContext(context.Background(), chargeParam)
+}
+
+// GetOrderChargesContext is GetOrderCharges with a context.
+func (c *Client) GetOrderChargesContext(ctx context.Context, chargeParam GetChargesParams) ([]OrderCharges, error) {
 	body, err := json.Marshal(chargeParam.OrderParams)
 	if err != nil {
 		return []OrderCharges{}, err
@@ -184,7 +200,7 @@ func (c *Client) GetOrderCharges(chargeParam GetChargesParams) ([]OrderCharges,
 	headers.Add("Content-Type", "application/json")
 
 	uri := URIOrderCharges
-	resp, err := c.doRaw(http.MethodPost, uri, body, headers)
+	resp, err := c.doRaw(ctx, http.MethodPost, uri, body, headers)
 	if err != nil {
 		return []OrderCharges{}, err
 	}
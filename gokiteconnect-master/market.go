@@ -1,6 +1,7 @@
 package kiteconnect
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -119,6 +120,11 @@ type MFInstruments []MFInstrument
 
 // GetQuote gets map of quotes for given instruments in the format of `exchange:tradingsymbol`.
 func (c *Client) GetQuote(instruments ...string) (Quote, error) {
+	return c.GetQuoteContext(context.Background(), instruments...)
+}
+
+// GetQuoteContext is GetQuote with a context.
+func (c *Client) GetQuoteContext(ctx context.Context, instruments ...string) (Quote, error) {
 	var (
 		err     error
 		quotes  Quote
@@ -134,12 +140,17 @@ func (c *Client) GetQuote(instruments ...string) (Quote, error) {
 		return quotes, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	err = c.doEnvelope(http.MethodGet, URIGetQuote, params, nil, &quotes)
+	err = c.doEnvelope(ctx, http.MethodGet, URIGetQuote, params, nil, &quotes)
 	return quotes, err
 }
 
 // GetLTP gets map of LTP quotes for given instruments in the format of `exchange:tradingsymbol`.
 func (c *Client) GetLTP(instruments ...string) (QuoteLTP, error) {
+	return c.GetLTPContext(context.Background(), instruments...)
+}
+
+// GetLTPContext is GetLTP with a context.
+func (c *Client) GetLTPContext(ctx context.Context, instruments ...string) (QuoteLTP, error) {
 	var (
 		err     error
 		quotes  QuoteLTP
@@ -155,12 +166,17 @@ func (c *Client) GetLTP(instruments ...string) (QuoteLTP, error) {
 		return quotes, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	err = c.doEnvelope(http.MethodGet, URIGetQuote, params, nil, &quotes)
+	err = c.doEnvelope(ctx, http.MethodGet, URIGetQuote, params, nil, &quotes)
 	return quotes, err
 }
 
 // GetOHLC gets map of OHLC quotes for given instruments in the format of `exchange:tradingsymbol`.
 func (c *Client) GetOHLC(instruments ...string) (QuoteOHLC, error) {
+	return c.GetOHLCContext(context.Background(), instruments...)
+}
+
+// GetOHLCContext is GetOHLC with a context.
+func (c *Client) GetOHLCContext(ctx context.Context, instruments ...string) (QuoteOHLC, error) {
 	var (
 		err     error
 		quotes  QuoteOHLC
@@ -176,7 +192,7 @@ func (c *Client) GetOHLC(instruments ...string) (QuoteOHLC, error) {
 		return quotes, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	err = c.doEnvelope(http.MethodGet, URIGetQuote, params, nil, &quotes)
+	err = c.doEnvelope(ctx, http.MethodGet, URIGetQuote, params, nil, &quotes)
 	return quotes, err
 }
 
@@ -254,6 +270,11 @@ func (c *Client) formatHistoricalData(inp historicalDataReceived) ([]HistoricalD
 
 // GetHistoricalData gets list of historical data.
 func (c *Client) GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]HistoricalData, error) {
+	return c.GetHistoricalDataContext(context.Background(), instrumentToken, interval, fromDate, toDate, continuous, OI)
+}
+
+// GetHistoricalDataContext is GetHistoricalData with a context.
+func (c *Client) GetHistoricalDataContext(ctx context.Context, instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]HistoricalData, error) {
 	var (
 		err       error
 		data      []HistoricalData
@@ -281,21 +302,21 @@ func (c *Client) GetHistoricalData(instrumentToken int, interval string, fromDat
 	}
 
 	var resp historicalDataReceived
-	if err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIGetHistorical, instrumentToken, interval), params, nil, &resp); err != nil {
+	if err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIGetHistorical, instrumentToken, interval), params, nil, &resp); err != nil {
 		return data, err
 	}
 
 	return c.formatHistoricalData(resp)
 }
 
-func (c *Client) parseInstruments(data interface{}, url string, params url.Values) error {
+func (c *Client) parseInstruments(ctx context.Context, data interface{}, url string, params url.Values) error {
 	var (
 		err  error
 		resp HTTPResponse
 	)
 
 	// Get CSV response
-	if resp, err = c.do(http.MethodGet, url, params, nil); err != nil {
+	if resp, err = c.do(ctx, http.MethodGet, url, params, nil); err != nil {
 		return err
 	}
 	
@@ -309,21 +330,36 @@ func (c *Client) parseInstruments(data interface{}, url string, params url.Value
 
 // GetInstruments retrives list of instruments.
 func (c *Client) GetInstruments() (Instruments, error) {
+	return c.GetInstrumentsContext(context.Background())
+}
+
+// GetInstrumentsContext is GetInstruments with a context.
+func (c *Client) GetInstrumentsContext(ctx context.Context) (Instruments, error) {
 	var instruments Instruments
-	err := c.parseInstruments(&instruments, URIGetInstruments, nil)
+	err := c.parseInstruments(ctx, &instruments, URIGetInstruments, nil)
 	return instruments, err
 }
 
 // GetInstrumentsByExchange retrives list of instruments for a given exchange.
 func (c *Client) GetInstrumentsByExchange(exchange string) (Instruments, error) {
+	return c.GetInstrumentsByExchangeContext(context.Background(), exchange)
+}
+
+// GetInstrumentsByExchangeContext is GetInstrumentsByExchange with a context.
+func (c *Client) GetInstrumentsByExchangeContext(ctx context.Context, exchange string) (Instruments, error) {
 	var instruments Instruments
-	err := c.parseInstruments(&instruments, fmt.Sprintf(URIGetInstrumentsExchange, exchange), nil)
+	err := c.parseInstruments(ctx, &instruments, fmt.Sprintf(URIGetInstrumentsExchange, exchange), nil)
 	return instruments, err
 }
 
 // GetMFInstruments retrives list of mutualfund instruments.
 func (c *Client) GetMFInstruments() (MFInstruments, error) {
+	return c.GetMFInstrumentsContext(context.Background())
+}
+
+// GetMFInstrumentsContext is GetMFInstruments with a context.
+func (c *Client) GetMFInstrumentsContext(ctx context.Context) (MFInstruments, error) {
 	var instruments MFInstruments
-	err := c.parseInstruments(&instruments, URIGetMFInstruments, nil)
+	err := c.parseInstruments(ctx, &instruments, URIGetMFInstruments, nil)
 	return instruments, err
 }
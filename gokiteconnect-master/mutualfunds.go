@@ -1,6 +1,7 @@
 package kiteconnect
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -146,20 +147,35 @@ type MFSIPModifyParams struct {
 
 // GetMFOrders gets list of mutualfund orders.
 func (c *Client) GetMFOrders() (MFOrders, error) {
+	return c.GetMFOrdersContext(context.Background())
+}
+
+// GetMFOrdersContext is GetMFOrders with a context.
+func (c *Client) GetMFOrdersContext(ctx context.Context) (MFOrders, error) {
 	var orders MFOrders
-	err := c.doEnvelope(http.MethodGet, URIGetMFOrders, nil, nil, &orders)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetMFOrders, nil, nil, &orders)
 	return orders, err
 }
 
 // GetMFOrderInfo get individual mutualfund order info.
 func (c *Client) GetMFOrderInfo(OrderID string) (MFOrder, error) {
+	return c.GetMFOrderInfoContext(context.Background(), OrderID)
+}
+
+// GetMFOrderInfoContext is GetMFOrderInfo with a context.
+func (c *Client) GetMFOrderInfoContext(ctx context.Context, OrderID string) (MFOrder, error) {
 	var orderInfo MFOrder
-	err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIGetMFOrderInfo, OrderID), nil, nil, &orderInfo)
+	err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIGetMFOrderInfo, OrderID), nil, nil, &orderInfo)
 	return orderInfo, err
 }
 
 // GetMFOrdersByDate gets list of mutualfund orders for a custom date range.
 func (c *Client) GetMFOrdersByDate(fromDate, toDate string) (MFOrders, error) {
+	return c.GetMFOrdersByDateContext(context.Background(), fromDate, toDate)
+}
+
+// GetMFOrdersByDateContext is GetMFOrdersByDate with a context.
+func (c *Client) GetMFOrdersByDateContext(ctx context.Context, fromDate, toDate string) (MFOrders, error) {
 	var (
 		orders MFOrders
 	)
@@ -168,12 +184,17 @@ func (c *Client) GetMFOrdersByDate(fromDate, toDate string) (MFOrders, error) {
 	params.Add("from", fromDate)
 	params.Add("to", toDate)
 
-	err := c.doEnvelope(http.MethodGet, URIGetMFOrders, params, nil, &orders)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetMFOrders, params, nil, &orders)
 	return orders, err
 }
 
 // PlaceMFOrder places an mutualfund order.
 func (c *Client) PlaceMFOrder(orderParams MFOrderParams) (MFOrderResponse, error) {
+	return c.PlaceMFOrderContext(context.Background(), orderParams)
+}
+
+// PlaceMFOrderContext is PlaceMFOrder with a context.
+func (c *Client) PlaceMFOrderContext(ctx context.Context, orderParams MFOrderParams) (MFOrderResponse, error) {
 	var (
 		orderResponse MFOrderResponse
 		params        url.Values
@@ -184,26 +205,41 @@ func (c *Client) PlaceMFOrder(orderParams MFOrderParams) (MFOrderResponse, error
 		return orderResponse, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	err = c.doEnvelope(http.MethodPost, URIPlaceMFOrder, params, nil, &orderResponse)
+	err = c.doEnvelope(ctx, http.MethodPost, URIPlaceMFOrder, params, nil, &orderResponse)
 	return orderResponse, err
 }
 
 // GetMFSIPs gets list of mutualfund SIPs.
 func (c *Client) GetMFSIPs() (MFSIPs, error) {
+	return c.GetMFSIPsContext(context.Background())
+}
+
+// GetMFSIPsContext is GetMFSIPs with a context.
+func (c *Client) GetMFSIPsContext(ctx context.Context) (MFSIPs, error) {
 	var sips MFSIPs
-	err := c.doEnvelope(http.MethodGet, URIGetMFSIPs, nil, nil, &sips)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetMFSIPs, nil, nil, &sips)
 	return sips, err
 }
 
 // GetMFSIPInfo get individual SIP info.
 func (c *Client) GetMFSIPInfo(sipID string) (MFSIP, error) {
+	return c.GetMFSIPInfoContext(context.Background(), sipID)
+}
+
+// GetMFSIPInfoContext is GetMFSIPInfo with a context.
+func (c *Client) GetMFSIPInfoContext(ctx context.Context, sipID string) (MFSIP, error) {
 	var sip MFSIP
-	err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIGetMFSIPInfo, sipID), nil, nil, &sip)
+	err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIGetMFSIPInfo, sipID), nil, nil, &sip)
 	return sip, err
 }
 
 // PlaceMFSIP places an mutualfund SIP order.
 func (c *Client) PlaceMFSIP(sipParams MFSIPParams) (MFSIPResponse, error) {
+	return c.PlaceMFSIPContext(context.Background(), sipParams)
+}
+
+// PlaceMFSIPContext is PlaceMFSIP with a context.
+func (c *Client) PlaceMFSIPContext(ctx context.Context, sipParams MFSIPParams) (MFSIPResponse, error) {
 	var (
 		sipResponse MFSIPResponse
 		params      url.Values
@@ -214,12 +250,17 @@ func (c *Client) PlaceMFSIP(sipParams MFSIPParams) (MFSIPResponse, error) {
 		return sipResponse, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	err = c.doEnvelope(http.MethodPost, URIPlaceMFSIP, params, nil, &sipResponse)
+	err = c.doEnvelope(ctx, http.MethodPost, URIPlaceMFSIP, params, nil, &sipResponse)
 	return sipResponse, err
 }
 
 // ModifyMFSIP modifies an mutualfund SIP.
 func (c *Client) ModifyMFSIP(sipID string, sipParams MFSIPModifyParams) (MFSIPResponse, error) {
+	return c.ModifyMFSIPContext(context.Background(), sipID, sipParams)
+}
+
+// ModifyMFSIPContext is ModifyMFSIP with a context.
+func (c *Client) ModifyMFSIPContext(ctx context.Context, sipID string, sipParams MFSIPModifyParams) (MFSIPResponse, error) {
 	var (
 		sipResponse MFSIPResponse
 		params      url.Values
@@ -230,44 +271,69 @@ func (c *Client) ModifyMFSIP(sipID string, sipParams MFSIPModifyParams) (MFSIPRe
 		return sipResponse, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	err = c.doEnvelope(http.MethodPut, fmt.Sprintf(URIModifyMFSIP, sipID), params, nil, &sipResponse)
+	err = c.doEnvelope(ctx, http.MethodPut, fmt.Sprintf(URIModifyMFSIP, sipID), params, nil, &sipResponse)
 	return sipResponse, err
 }
 
 // CancelMFSIP cancels an mutualfund SIP.
 func (c *Client) CancelMFSIP(sipID string) (MFSIPResponse, error) {
+	return c.CancelMFSIPContext(context.Background(), sipID)
+}
+
+// CancelMFSIPContext is CancelMFSIP with a context.
+func (c *Client) CancelMFSIPContext(ctx context.Context, sipID string) (MFSIPResponse, error) {
 	var (
 		sipResponse MFSIPResponse
 	)
 
-	err := c.doEnvelope(http.MethodDelete, fmt.Sprintf(URICancelMFSIP, sipID), nil, nil, &sipResponse)
+	err := c.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(URICancelMFSIP, sipID), nil, nil, &sipResponse)
 	return sipResponse, err
 }
 
 // CancelMFOrder cancels an mutualfund order.
 func (c *Client) CancelMFOrder(orderID string) (MFOrderResponse, error) {
+	return c.CancelMFOrderContext(context.Background(), orderID)
+}
+
+// CancelMFOrderContext is CancelMFOrder with a context.
+func (c *Client) CancelMFOrderContext(ctx context.Context, orderID string) (MFOrderResponse, error) {
 	var orderResponse MFOrderResponse
-	err := c.doEnvelope(http.MethodDelete, fmt.Sprintf(URICancelMFOrder, orderID), nil, nil, &orderResponse)
+	err := c.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(URICancelMFOrder, orderID), nil, nil, &orderResponse)
 	return orderResponse, err
 }
 
 // GetMFHoldings gets list of user mutualfund holdings.
 func (c *Client) GetMFHoldings() (MFHoldings, error) {
+	return c.GetMFHoldingsContext(context.Background())
+}
+
+// GetMFHoldingsContext is GetMFHoldings with a context.
+func (c *Client) GetMFHoldingsContext(ctx context.Context) (MFHoldings, error) {
 	var holdings MFHoldings
-	err := c.doEnvelope(http.MethodGet, URIGetMFHoldings, nil, nil, &holdings)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetMFHoldings, nil, nil, &holdings)
 	return holdings, err
 }
 
 // GetMFHoldingInfo get individual Holding info.
 func (c *Client) GetMFHoldingInfo(isin string) (MFHoldingBreakdown, error) {
+	return c.GetMFHoldingInfoContext(context.Background(), isin)
+}
+
+// GetMFHoldingInfoContext is GetMFHoldingInfo with a context.
+func (c *Client) GetMFHoldingInfoContext(ctx context.Context, isin string) (MFHoldingBreakdown, error) {
 	var holdingBreakdown MFHoldingBreakdown
-	err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIGetMFHoldingInfo, isin), nil, nil, &holdingBreakdown)
+	err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIGetMFHoldingInfo, isin), nil, nil, &holdingBreakdown)
 	return holdingBreakdown, err
 }
 
 // GetMFAllottedISINs gets list of user mutualfund holdings.
 func (c *Client) GetMFAllottedISINs() (MFAllottedISINs, error) {
+	return c.GetMFAllottedISINsContext(context.Background())
+}
+
+// GetMFAllottedISINsContext is GetMFAllottedISINs with a context.
+func (c *Client) GetMFAllottedISINsContext(ctx context.Context) (MFAllottedISINs, error) {
 	var isins MFAllottedISINs
-	err := c.doEnvelope(http.MethodGet, URIGetAllotedISINs, nil, nil, &isins)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetAllotedISINs, nil, nil, &isins)
 	return isins, err
 }
@@ -1,9 +1,11 @@
 package kiteconnect
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"gokiteconnect-master/models"
 
@@ -110,34 +112,66 @@ type Trades []Trade
 
 // GetOrders gets list of orders.
 func (c *Client) GetOrders() (Orders, error) {
+	return c.GetOrdersContext(context.Background())
+}
+
+// GetOrdersContext is GetOrders with a context.
+func (c *Client) GetOrdersContext(ctx context.Context) (Orders, error) {
 	var orders Orders
-	err := c.doEnvelope(http.MethodGet, URIGetOrders, nil, nil, &orders)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetOrders, nil, nil, &orders)
 	return orders, err
 }
 
 // GetTrades gets list of trades.
 func (c *Client) GetTrades() (Trades, error) {
+	return c.GetTradesContext(context.Background())
+}
+
+// GetTradesContext is GetTrades with a context.
+func (c *Client) GetTradesContext(ctx context.Context) (Trades, error) {
 	var trades Trades
-	err := c.doEnvelope(http.MethodGet, URIGetTrades, nil, nil, &trades)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetTrades, nil, nil, &trades)
 	return trades, err
 }
 
 // GetOrderHistory gets history of an individual order.
 func (c *Client) GetOrderHistory(OrderID string) ([]Order, error) {
+	return c.GetOrderHistoryContext(context.Background(), OrderID)
+}
+
+// GetOrderHistoryContext is GetOrderHistory with a context.
+func (c *Client) GetOrderHistoryContext(ctx context.Context, OrderID string) ([]Order, error) {
 	var orderHistory []Order
-	err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIGetOrderHistory, OrderID), nil, nil, &orderHistory)
+	err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIGetOrderHistory, OrderID), nil, nil, &orderHistory)
 	return orderHistory, err
 }
 
 // GetOrderTrades gets list of trades executed for a particular order.
 func (c *Client) GetOrderTrades(OrderID string) ([]Trade, error) {
+	return c.GetOrderTradesContext(context.Background(), OrderID)
+}
+
+// GetOrderTradesContext is GetOrderTrades with a context.
+func (c *Client) GetOrderTradesContext(ctx context.Context, OrderID string) ([]Trade, error) {
 	var orderTrades []Trade
-	err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIGetOrderTrades, OrderID), nil, nil, &orderTrades)
+	err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIGetOrderTrades, OrderID), nil, nil, &orderTrades)
 	return orderTrades, err
 }
 
-// PlaceOrder places an order.
+// PlaceOrder places an order. Placing is never retried blindly: after a
+// network error or a 5xx the order may have been placed anyway, so an order
+// with a Tag is looked up by its tag in the order book and only placed again
+// if it is not there. Orders without a Tag return the error.
+//
+// Tags must be unique per placement: an order in the book with the same tag,
+// symbol, side, type, quantity and price placed since the first attempt is
+// taken to be this one.
 func (c *Client) PlaceOrder(variety string, orderParams OrderParams) (OrderResponse, error) {
+	return c.PlaceOrderContext(context.Background(), variety, orderParams)
+}
+
+// PlaceOrderContext is PlaceOrder with a context.
+func (c *Client) PlaceOrderContext(ctx context.Context, variety string, orderParams OrderParams) (OrderResponse, error) {
 	var (
 		orderResponse OrderResponse
 		params        url.Values
@@ -147,13 +181,68 @@ func (c *Client) PlaceOrder(variety string, orderParams OrderParams) (OrderRespo
 	if params, err = query.Values(orderParams); err != nil {
 		return orderResponse, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
-	
-	err = c.doEnvelope(http.MethodPost, fmt.Sprintf(URIPlaceOrder, variety), params, nil, &orderResponse)
-	return orderResponse, err
+
+	firstAttempt := time.Now()
+	for attempt := 0; ; attempt++ {
+		err = c.doEnvelope(ctx, http.MethodPost, fmt.Sprintf(URIPlaceOrder, variety), params, nil, &orderResponse)
+		if err == nil || orderParams.Tag == "" || attempt >= c.retry.MaxRetries ||
+			!isRetryable(nil, err) || isRateLimited(nil, err) {
+			return orderResponse, err
+		}
+
+		if sleepErr := sleep(ctx, c.retry.delay(attempt)); sleepErr != nil {
+			return orderResponse, err
+		}
+		order, found, lookupErr := c.findOrderByTag(ctx, orderParams, firstAttempt)
+		if lookupErr != nil {
+			return orderResponse, err
+		}
+		if found {
+			return OrderResponse{OrderID: order.OrderID}, nil
+		}
+	}
+}
+
+// orderClockSkew is how far behind the local clock the order book's
+// timestamps may be
+const orderClockSkew = 2 * time.Second
+
+// findOrderByTag looks up an order placed since a time with the tag, symbol,
+// side, type, quantity and price of the params in the order book.
+func (c *Client) findOrderByTag(ctx context.Context, p OrderParams, since time.Time) (Order, bool, error) {
+	orders, err := c.GetOrdersContext(ctx)
+	if err != nil {
+		return Order{}, false, err
+	}
+	// Order timestamps are to the second
+	since = since.Add(-orderClockSkew).Truncate(time.Second)
+	for _, o := range orders {
+		if o.Exchange != p.Exchange || o.TradingSymbol != p.Tradingsymbol || o.TransactionType != p.TransactionType ||
+			o.OrderType != p.OrderType || o.Quantity != float64(p.Quantity) || o.Price != p.Price {
+			continue
+		}
+		if o.OrderTimestamp.Before(since) {
+			continue
+		}
+		if o.Tag == p.Tag {
+			return o, true, nil
+		}
+		for _, tag := range o.Tags {
+			if tag == p.Tag {
+				return o, true, nil
+			}
+		}
+	}
+	return Order{}, false, nil
 }
 
 // ModifyOrder modifies an order.
 func (c *Client) ModifyOrder(variety string, orderID string, orderParams OrderParams) (OrderResponse, error) {
+	return c.ModifyOrderContext(context.Background(), variety, orderID, orderParams)
+}
+
+// ModifyOrderContext is ModifyOrder with a context.
+func (c *Client) ModifyOrderContext(ctx context.Context, variety string, orderID string, orderParams OrderParams) (OrderResponse, error) {
 	var (
 		orderResponse OrderResponse
 		params        url.Values
@@ -164,12 +253,17 @@ func (c *Client) ModifyOrder(variety string, orderID string, orderParams OrderPa
 		return orderResponse, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	err = c.doEnvelope(http.MethodPut, fmt.Sprintf(URIModifyOrder, variety, orderID), params, nil, &orderResponse)
+	err = c.doEnvelope(ctx, http.MethodPut, fmt.Sprintf(URIModifyOrder, variety, orderID), params, nil, &orderResponse)
 	return orderResponse, err
 }
 
 // CancelOrder cancels/exits an order.
 func (c *Client) CancelOrder(variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
+	return c.CancelOrderContext(context.Background(), variety, orderID, parentOrderID)
+}
+
+// CancelOrderContext is CancelOrder with a context.
+func (c *Client) CancelOrderContext(ctx context.Context, variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
 	var (
 		orderResponse OrderResponse
 		params        url.Values
@@ -181,11 +275,16 @@ func (c *Client) CancelOrder(variety string, orderID string, parentOrderID *stri
 		params.Add("parent_order_id", *parentOrderID)
 	}
 
-	err := c.doEnvelope(http.MethodDelete, fmt.Sprintf(URICancelOrder, variety, orderID), params, nil, &orderResponse)
+	err := c.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(URICancelOrder, variety, orderID), params, nil, &orderResponse)
 	return orderResponse, err
 }
 
 // ExitOrder is an alias for CancelOrder which is used to cancel/exit an order.
 func (c *Client) ExitOrder(variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
-	return c.CancelOrder(variety, orderID, parentOrderID)
+	return c.ExitOrderContext(context.Background(), variety, orderID, parentOrderID)
+}
+
+// ExitOrderContext is ExitOrder with a context.
+func (c *Client) ExitOrderContext(ctx context.Context, variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
+	return c.CancelOrderContext(ctx, variety, orderID, parentOrderID)
 }
@@ -1,6 +1,7 @@
 package kiteconnect
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -146,27 +147,47 @@ type AuctionInstrument struct {
 
 // GetHoldings gets a list of holdings.
 func (c *Client) GetHoldings() (Holdings, error) {
+	return c.GetHoldingsContext(context.Background())
+}
+
+// GetHoldingsContext is GetHoldings with a context.
+func (c *Client) GetHoldingsContext(ctx context.Context) (Holdings, error) {
 	var holdings Holdings
-	err := c.doEnvelope(http.MethodGet, URIGetHoldings, nil, nil, &holdings)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetHoldings, nil, nil, &holdings)
 	return holdings, err
 }
 
 // GetAuctionInstruments retrieves list of available instruments for a auction session
 func (c *Client) GetAuctionInstruments() ([]AuctionInstrument, error) {
+	return c.GetAuctionInstrumentsContext(context.Background())
+}
+
+// GetAuctionInstrumentsContext is GetAuctionInstruments with a context.
+func (c *Client) GetAuctionInstrumentsContext(ctx context.Context) ([]AuctionInstrument, error) {
 	var auctionInstruments []AuctionInstrument
-	err := c.doEnvelope(http.MethodGet, URIAuctionInstruments, nil, nil, &auctionInstruments)
+	err := c.doEnvelope(ctx, http.MethodGet, URIAuctionInstruments, nil, nil, &auctionInstruments)
 	return auctionInstruments, err
 }
 
 // GetPositions gets user positions.
 func (c *Client) GetPositions() (Positions, error) {
+	return c.GetPositionsContext(context.Background())
+}
+
+// GetPositionsContext is GetPositions with a context.
+func (c *Client) GetPositionsContext(ctx context.Context) (Positions, error) {
 	var positions Positions
-	err := c.doEnvelope(http.MethodGet, URIGetPositions, nil, nil, &positions)
+	err := c.doEnvelope(ctx, http.MethodGet, URIGetPositions, nil, nil, &positions)
 	return positions, err
 }
 
 // ConvertPosition converts postion's product type.
 func (c *Client) ConvertPosition(positionParams ConvertPositionParams) (bool, error) {
+	return c.ConvertPositionContext(context.Background(), positionParams)
+}
+
+// ConvertPositionContext is ConvertPosition with a context.
+func (c *Client) ConvertPositionContext(ctx context.Context, positionParams ConvertPositionParams) (bool, error) {
 	var (
 		b      bool
 		err    error
@@ -177,7 +198,7 @@ func (c *Client) ConvertPosition(positionParams ConvertPositionParams) (bool, er
 		return false, NewError(InputError, fmt.Sprintf("Error decoding order params: %v", err), nil)
 	}
 
-	if err = c.doEnvelope(http.MethodPut, URIConvertPosition, params, nil, nil); err == nil {
+	if err = c.doEnvelope(ctx, http.MethodPut, URIConvertPosition, params, nil, nil); err == nil {
 		b = true
 	}
 
@@ -216,6 +237,11 @@ type HoldingsAuthResp struct {
 // redirect the user in a web view. The client forms and returns the
 // formed RedirectURL as well.
 func (c *Client) InitiateHoldingsAuth(haps HoldingAuthParams) (HoldingsAuthResp, error) {
+	return c.InitiateHoldingsAuthContext(context.Background(), haps)
+}
+
+// InitiateHoldingsAuthContext is InitiateHoldingsAuth with a context.
+func (c *Client) InitiateHoldingsAuthContext(ctx context.Context, haps HoldingAuthParams) (HoldingsAuthResp, error) {
 	var (
 		params = make(url.Values)
 	)
@@ -238,7 +264,7 @@ func (c *Client) InitiateHoldingsAuth(haps HoldingAuthParams) (HoldingsAuthResp,
 	}
 
 	var resp HoldingsAuthResp
-	if err := c.doEnvelope(http.MethodPost, URIInitHoldingsAuth, params, nil, &resp); err != nil {
+	if err := c.doEnvelope(ctx, http.MethodPost, URIInitHoldingsAuth, params, nil, &resp); err != nil {
 		return resp, err
 	}
 
@@ -0,0 +1,232 @@
+package kiteconnect
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit classes. Kite limits each class of endpoints separately, see
+// https://kite.trade/docs/connect/v3/exceptions/#api-rate-limit.
+const (
+	RateClassQuote      = "quote"      // /quote, /quote/ltp and /quote/ohlc
+	RateClassHistorical = "historical" // Historical candles
+	RateClassOrders     = "orders"     // Placing, modifying and cancelling orders
+	RateClassDefault    = "default"    // Every other endpoint
+)
+
+// RateLimit is a token bucket: Rate requests per second on average, with
+// bursts of up to Burst requests.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// DefaultRateLimits returns the Kite limits per rate limit class.
+func DefaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		RateClassQuote:      {Rate: 1, Burst: 1},
+		RateClassHistorical: {Rate: 3, Burst: 3},
+		RateClassOrders:     {Rate: 10, Burst: 10},
+		RateClassDefault:    {Rate: 10, Burst: 10},
+	}
+}
+
+// RetryPolicy is how failed requests are retried. Read (GET) requests are
+// retried on 429, 5xx and network errors; other requests only on 429, which
+// Kite returns before processing them. Delays grow exponentially from
+// BaseDelay up to MaxDelay, with full jitter.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy retries up to 3 times, waiting up to 250ms, 500ms and 1s.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  250 * time.Millisecond,
+	MaxDelay:   4 * time.Second,
+}
+
+// delay returns the jittered delay before the given retry, from 0.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay << uint(retry)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// tokenBucket is a token bucket limiter. Requests reserve a token and wait
+// until it is available, so waiting requests are served in order.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst}
+}
+
+// reserve takes a token at now and returns how long to wait for it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate <= 0 {
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter holds a token bucket per rate limit class. Classes without a
+// limit fall back to the default class, and are unlimited without one.
+type rateLimiter struct {
+	buckets map[string]*tokenBucket
+	mu      sync.RWMutex
+}
+
+func newRateLimiter(limits map[string]RateLimit) *rateLimiter {
+	l := &rateLimiter{buckets: make(map[string]*tokenBucket, len(limits))}
+	for class, limit := range limits {
+		l.buckets[class] = newTokenBucket(limit)
+	}
+	return l
+}
+
+// wait blocks until a request of the class may be sent or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, class string) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.RLock()
+	b, ok := l.buckets[class]
+	if !ok {
+		b = l.buckets[RateClassDefault]
+	}
+	l.mu.RUnlock()
+
+	if b == nil {
+		return ctx.Err()
+	}
+	return sleep(ctx, b.reserve(time.Now()))
+}
+
+// sleep waits for d or until ctx is done, returning the context's error.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// set replaces the limit of a class; a zero Rate removes it.
+func (l *rateLimiter) set(class string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit.Rate <= 0 {
+		delete(l.buckets, class)
+		return
+	}
+	l.buckets[class] = newTokenBucket(limit)
+}
+
+// rateClass returns the rate limit class of a request.
+func rateClass(method, uri string) string {
+	switch {
+	case uri == URIGetQuote || uri == URIGetLTP || uri == URIGetOHLC:
+		return RateClassQuote
+	case strings.HasPrefix(uri, "/instruments/historical/"):
+		return RateClassHistorical
+	case method != http.MethodGet && strings.HasPrefix(uri, "/orders"):
+		return RateClassOrders
+	}
+	return RateClassDefault
+}
+
+// isRetryable reports whether a request failed transiently: rate limited,
+// a 5xx response or a network error. resp is nil for requests whose
+// envelope was parsed into err.
+func isRetryable(resp *HTTPResponse, err error) bool {
+	if err != nil {
+		var kerr Error
+		if !errors.As(err, &kerr) {
+			return false
+		}
+		return kerr.ErrorType == NetworkError || kerr.Code == http.StatusTooManyRequests || kerr.Code >= http.StatusInternalServerError
+	}
+	if resp == nil || resp.Response == nil {
+		return false
+	}
+	code := resp.Response.StatusCode
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// isRateLimited reports whether a request was rejected with 429, before
+// Kite processed it.
+func isRateLimited(resp *HTTPResponse, err error) bool {
+	var kerr Error
+	if err != nil {
+		return errors.As(err, &kerr) && kerr.Code == http.StatusTooManyRequests
+	}
+	return resp != nil && resp.Response != nil && resp.Response.StatusCode == http.StatusTooManyRequests
+}
+
+// shouldRetry reports whether the attempt, from 0, of a request is retried.
+func (c *Client) shouldRetry(method string, attempt int, resp *HTTPResponse, err error) bool {
+	if attempt >= c.retry.MaxRetries {
+		return false
+	}
+	if method == http.MethodGet {
+		return isRetryable(resp, err)
+	}
+	return isRateLimited(resp, err)
+}
+
+// SetRateLimit sets the token bucket of a rate limit class, one of the
+// RateClass constants; a zero Rate removes the limit.
+func (c *Client) SetRateLimit(class string, limit RateLimit) {
+	if c.limiter == nil {
+		c.limiter = newRateLimiter(nil)
+	}
+	c.limiter.set(class, limit)
+}
+
+// SetRetryPolicy sets how failed requests are retried; a zero policy
+// disables retries.
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry = p
+}
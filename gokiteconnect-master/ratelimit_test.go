@@ -0,0 +1,178 @@
+package kiteconnect
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+// newRetryTestClient returns a client for a server answering with handler
+// and counting requests per method and path.
+func newRetryTestClient(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, n int32)) (*Client, map[string]*int32) {
+	counts := map[string]*int32{
+		"GET /user/profile":    new(int32),
+		"GET /orders":          new(int32),
+		"POST /orders/regular": new(int32),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, ok := counts[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r, atomic.AddInt32(count, 1))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := New("api_key")
+	client.SetBaseURI(srv.URL)
+	client.SetRetryPolicy(testRetryPolicy)
+	return client, counts
+}
+
+func writeError(w http.ResponseWriter, code int, etype string) {
+	w.WriteHeader(code)
+	w.Write([]byte(`{"status":"error","error_type":"` + etype + `","message":"failed"}`))
+}
+
+func TestRetryReads(t *testing.T) {
+	t.Parallel()
+
+	client, counts := newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if n < 3 {
+			writeError(w, http.StatusServiceUnavailable, NetworkError)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"user_id":"AB1234"}}`))
+	})
+
+	profile, err := client.GetUserProfile()
+	if err != nil {
+		t.Fatalf("GetUserProfile() error = %v", err)
+	}
+	if profile.UserID != "AB1234" || *counts["GET /user/profile"] != 3 {
+		t.Errorf("got user %q after %d requests, want AB1234 after 3", profile.UserID, *counts["GET /user/profile"])
+	}
+
+	// Input errors are not retried
+	client, counts = newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		writeError(w, http.StatusBadRequest, InputError)
+	})
+	if _, err := client.GetUserProfile(); err == nil || *counts["GET /user/profile"] != 1 {
+		t.Errorf("got error %v after %d requests, want an error after 1", err, *counts["GET /user/profile"])
+	}
+}
+
+func TestPlaceOrderIdempotency(t *testing.T) {
+	t.Parallel()
+
+	params := OrderParams{Exchange: ExchangeNSE, Tradingsymbol: "INFY", TransactionType: TransactionTypeBuy,
+		OrderType: OrderTypeLimit, Quantity: 1, Price: 1500}
+
+	// Without a tag a failed placement is never retried
+	client, counts := newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		writeError(w, http.StatusBadGateway, NetworkError)
+	})
+	if _, err := client.PlaceOrder(VarietyRegular, params); err == nil || *counts["POST /orders/regular"] != 1 {
+		t.Errorf("got error %v after %d placements, want an error after 1", err, *counts["POST /orders/regular"])
+	}
+
+	// With a tag, the order placed despite the error is found in the order
+	// book. Orders with other params, or placed before, are not it.
+	params.Tag = "strat1"
+	ist, _ := time.LoadLocation("Asia/Kolkata")
+	now := time.Now().In(ist).Format("2006-01-02 15:04:05")
+	earlier := time.Now().Add(-time.Hour).In(ist).Format("2006-01-02 15:04:05")
+	order := func(id, timestamp string, quantity int, tag string) string {
+		return fmt.Sprintf(`{"order_id":"%s","order_timestamp":"%s","exchange":"NSE","tradingsymbol":"INFY",`+
+			`"transaction_type":"BUY","order_type":"LIMIT","quantity":%d,"price":1500,%s}`, id, timestamp, quantity, tag)
+	}
+	client, counts = newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if r.Method == http.MethodPost {
+			writeError(w, http.StatusBadGateway, NetworkError)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":[` +
+			order("1", now, 1, `"tag":"other"`) + "," +
+			order("2", earlier, 1, `"tag":"strat1"`) + "," +
+			order("3", now, 2, `"tag":"strat1"`) + "," +
+			order("4", now, 1, `"tags":["strat1"]`) + `]}`))
+	})
+	resp, err := client.PlaceOrder(VarietyRegular, params)
+	if err != nil || resp.OrderID != "4" || *counts["POST /orders/regular"] != 1 {
+		t.Errorf("got order %q, error %v after %d placements, want order 4 after 1", resp.OrderID, err, *counts["POST /orders/regular"])
+	}
+
+	// Not found, it is placed again
+	client, counts = newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"status":"success","data":[]}`))
+		case n == 1:
+			writeError(w, http.StatusInternalServerError, GeneralError)
+		default:
+			w.Write([]byte(`{"status":"success","data":{"order_id":"3"}}`))
+		}
+	})
+	resp, err = client.PlaceOrder(VarietyRegular, params)
+	if err != nil || resp.OrderID != "3" || *counts["POST /orders/regular"] != 2 || *counts["GET /orders"] != 1 {
+		t.Errorf("got order %q, error %v after %d placements and %d lookups, want order 3 after 2 and 1",
+			resp.OrderID, err, *counts["POST /orders/regular"], *counts["GET /orders"])
+	}
+
+	// Rate limited placements were not processed and are retried as they are
+	client, counts = newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if n == 1 {
+			writeError(w, http.StatusTooManyRequests, NetworkError)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"order_id":"4"}}`))
+	})
+	resp, err = client.PlaceOrder(VarietyRegular, params)
+	if err != nil || resp.OrderID != "4" || *counts["POST /orders/regular"] != 2 || *counts["GET /orders"] != 0 {
+		t.Errorf("got order %q, error %v after %d placements and %d lookups, want order 4 after 2 and 0",
+			resp.OrderID, err, *counts["POST /orders/regular"], *counts["GET /orders"])
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b := newTokenBucket(RateLimit{Rate: 2, Burst: 2})
+	for i, want := range []time.Duration{0, 0, 500 * time.Millisecond, time.Second} {
+		if got := b.reserve(now); got != want {
+			t.Errorf("reserve %d waits %v, want %v", i, got, want)
+		}
+	}
+	// Two tokens refill after a second, both already reserved
+	if got := b.reserve(now.Add(time.Second)); got != 500*time.Millisecond {
+		t.Errorf("reserve after refill waits %v, want 500ms", got)
+	}
+}
+
+func TestRateClass(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method, uri, want string
+	}{
+		{http.MethodGet, URIGetLTP, RateClassQuote},
+		{http.MethodGet, "/instruments/historical/123/minute", RateClassHistorical},
+		{http.MethodPost, "/orders/regular", RateClassOrders},
+		{http.MethodDelete, "/orders/regular/1", RateClassOrders},
+		{http.MethodGet, URIGetOrders, RateClassDefault},
+		{http.MethodGet, URIGetPositions, RateClassDefault},
+	}
+	for _, tt := range tests {
+		if got := rateClass(tt.method, tt.uri); got != tt.want {
+			t.Errorf("rateClass(%s %s) = %s, want %s", tt.method, tt.uri, got, tt.want)
+		}
+	}
+}
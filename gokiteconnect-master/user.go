@@ -1,6 +1,7 @@
 package kiteconnect
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"net/http"
@@ -130,6 +131,11 @@ type AllMargins struct {
 // and retrieve the `accessToken` required for all subsequent requests. The
 // response contains not just the `accessToken`, but metadata for the user who has authenticated.
 func (c *Client) GenerateSession(requestToken string, apiSecret string) (UserSession, error) {
+	return c.GenerateSessionContext(context.Background(), requestToken, apiSecret)
+}
+
+// GenerateSessionContext is GenerateSession with a context.
+func (c *Client) GenerateSessionContext(ctx context.Context, requestToken string, apiSecret string) (UserSession, error) {
 	// Get SHA256 checksum
 	h := sha256.New()
 	h.Write([]byte(c.apiKey + requestToken + apiSecret))
@@ -141,7 +147,7 @@ func (c *Client) GenerateSession(requestToken string, apiSecret string) (UserSes
 	params.Set("checksum", fmt.Sprintf("%x", h.Sum(nil)))
 
 	var session UserSession
-	err := c.doEnvelope(http.MethodPost, URIUserSession, params, nil, &session)
+	err := c.doEnvelope(ctx, http.MethodPost, URIUserSession, params, nil, &session)
 
 	// Set accessToken on successful session retrieve
 	if err != nil && session.AccessToken != "" {
@@ -151,7 +157,7 @@ func (c *Client) GenerateSession(requestToken string, apiSecret string) (UserSes
 	return session, err
 }
 
-func (c *Client) invalidateToken(tokenType string, token string) (bool, error) {
+func (c *Client) invalidateToken(ctx context.Context, tokenType string, token string) (bool, error) {
 	var b bool
 
 	// construct url values
@@ -159,7 +165,7 @@ func (c *Client) invalidateToken(tokenType string, token string) (bool, error) {
 	params.Add("api_key", c.apiKey)
 	params.Add(tokenType, token)
 
-	err := c.doEnvelope(http.MethodDelete, URIUserSessionInvalidate, params, nil, nil)
+	err := c.doEnvelope(ctx, http.MethodDelete, URIUserSessionInvalidate, params, nil, nil)
 	if err == nil {
 		b = true
 	}
@@ -169,11 +175,21 @@ func (c *Client) invalidateToken(tokenType string, token string) (bool, error) {
 
 // InvalidateAccessToken invalidates the current access token.
 func (c *Client) InvalidateAccessToken() (bool, error) {
-	return c.invalidateToken("access_token", c.accessToken)
+	return c.InvalidateAccessTokenContext(context.Background())
+}
+
+// InvalidateAccessTokenContext is InvalidateAccessToken with a context.
+func (c *Client) InvalidateAccessTokenContext(ctx context.Context) (bool, error) {
+	return c.invalidateToken(ctx, "access_token", c.accessToken)
 }
 
 // RenewAccessToken renews expired access token using valid refresh token.
 func (c *Client) RenewAccessToken(refreshToken string, apiSecret string) (UserSessionTokens, error) {
+	return c.RenewAccessTokenContext(context.Background(), refreshToken, apiSecret)
+}
+
+// RenewAccessTokenContext is RenewAccessToken with a context.
+func (c *Client) RenewAccessTokenContext(ctx context.Context, refreshToken string, apiSecret string) (UserSessionTokens, error) {
 	// Get SHA256 checksum
 	h := sha256.New()
 	h.Write([]byte(c.apiKey + refreshToken + apiSecret))
@@ -185,7 +201,7 @@ func (c *Client) RenewAccessToken(refreshToken string, apiSecret string) (UserSe
 	params.Set("checksum", fmt.Sprintf("%x", h.Sum(nil)))
 
 	var session UserSessionTokens
-	err := c.doEnvelope(http.MethodPost, URIUserSessionRenew, params, nil, &session)
+	err := c.doEnvelope(ctx, http.MethodPost, URIUserSessionRenew, params, nil, &session)
 
 	// Set accessToken on successful session retrieve
 	if err != nil && session.AccessToken != "" {
@@ -197,33 +213,58 @@ func (c *Client) RenewAccessToken(refreshToken string, apiSecret string) (UserSe
 
 // InvalidateRefreshToken invalidates the given refresh token.
 func (c *Client) InvalidateRefreshToken(refreshToken string) (bool, error) {
-	return c.invalidateToken("refresh_token", refreshToken)
+	return c.InvalidateRefreshTokenContext(context.Background(), refreshToken)
+}
+
+// InvalidateRefreshTokenContext is InvalidateRefreshToken with a context.
+func (c *Client) InvalidateRefreshTokenContext(ctx context.Context, refreshToken string) (bool, error) {
+	return c.invalidateToken(ctx, "refresh_token", refreshToken)
 }
 
 // GetUserProfile gets user profile.
 func (c *Client) GetUserProfile() (UserProfile, error) {
+	return c.GetUserProfileContext(context.Background())
+}
+
+// GetUserProfileContext is GetUserProfile with a context.
+func (c *Client) GetUserProfileContext(ctx context.Context) (UserProfile, error) {
 	var userProfile UserProfile
-	err := c.doEnvelope(http.MethodGet, URIUserProfile, nil, nil, &userProfile)
+	err := c.doEnvelope(ctx, http.MethodGet, URIUserProfile, nil, nil, &userProfile)
 	return userProfile, err
 }
 
 // GetFullUserProfile gets full user profile.
 func (c *Client) GetFullUserProfile() (FullUserProfile, error) {
+	return c.GetFullUserProfileContext(context.Background())
+}
+
+// GetFullUserProfileContext is GetFullUserProfile with a context.
+func (c *Client) GetFullUserProfileContext(ctx context.Context) (FullUserProfile, error) {
 	var fUserProfile FullUserProfile
-	err := c.doEnvelope(http.MethodGet, URIFullUserProfile, nil, nil, &fUserProfile)
+	err := c.doEnvelope(ctx, http.MethodGet, URIFullUserProfile, nil, nil, &fUserProfile)
 	return fUserProfile, err
 }
 
 // GetUserMargins gets all user margins.
 func (c *Client) GetUserMargins() (AllMargins, error) {
+	return c.GetUserMarginsContext(context.Background())
+}
+
+// GetUserMarginsContext is GetUserMargins with a context.
+func (c *Client) GetUserMarginsContext(ctx context.Context) (AllMargins, error) {
 	var allUserMargins AllMargins
-	err := c.doEnvelope(http.MethodGet, URIUserMargins, nil, nil, &allUserMargins)
+	err := c.doEnvelope(ctx, http.MethodGet, URIUserMargins, nil, nil, &allUserMargins)
 	return allUserMargins, err
 }
 
 // GetUserSegmentMargins gets segmentwise user margins.
 func (c *Client) GetUserSegmentMargins(segment string) (Margins, error) {
+	return c.GetUserSegmentMarginsContext(context.Background(), segment)
+}
+
+// GetUserSegmentMarginsContext is GetUserSegmentMargins with a context.
+func (c *Client) GetUserSegmentMarginsContext(ctx context.Context, segment string) (Margins, error) {
 	var margins Margins
-	err := c.doEnvelope(http.MethodGet, fmt.Sprintf(URIUserMarginsSegment, segment), nil, nil, &margins)
+	err := c.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(URIUserMarginsSegment, segment), nil, nil, &margins)
 	return margins, err
 }